@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"video-processor/api/internal/config"
 	"video-processor/api/internal/handlers"
+	"video-processor/internal/queue"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,15 +19,32 @@ func main() {
 	cfg.CreateDirectories()
 
 	apiHandlers := handlers.NewAPIHandlers(cfg)
+	if err := apiHandlers.RecoverJobs(); err != nil {
+		log.Printf("Warning: Failed to recover interrupted jobs: %v", err)
+	}
+
+	if cfg.Consumer != nil {
+		worker := queue.NewWorker(cfg.Consumer, apiHandlers.ProcessQueuedJob, queue.Options{
+			Visibility:  cfg.Embedded.VisibilityTimeout,
+			MaxAttempts: cfg.Embedded.MaxAttempts,
+			Exhausted:   apiHandlers.FailExhaustedJob,
+		})
+		for i := 0; i < cfg.Embedded.Workers; i++ {
+			go worker.Run(context.Background())
+		}
+		fmt.Println("📬 Consumindo jobs da fila local", cfg.Embedded.Path)
+	}
 
 	r := gin.Default()
 
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "POST, GET, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Methods", "POST, GET, HEAD, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, If-None-Match, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, X-Tenant-ID, X-Checksum-SHA256")
+		c.Header("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Upload-Offset, Upload-Length, Upload-Metadata, Digest, X-Checksum-SHA256")
 
-		if c.Request.Method == "OPTIONS" {
+		// tus discovery requests are answered by the tus routes.
+		if c.Request.Method == "OPTIONS" && !strings.HasPrefix(c.Request.URL.Path, "/api/v1/tus") {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -38,7 +58,22 @@ func main() {
 	apiV1.POST("/videos", apiHandlers.CreateVideo)
 	apiV1.GET("/videos", apiHandlers.GetVideos)
 	apiV1.GET("/videos/:filename/download", apiHandlers.GetVideoDownload)
+	apiV1.GET("/videos/:filename/frame", apiHandlers.GetVideoFrame)
+	apiV1.GET("/videos/:filename/hls/:asset", apiHandlers.GetVideoHLS)
 	apiV1.DELETE("/videos/:filename", apiHandlers.DeleteVideo)
+	apiV1.POST("/videos/:filename/restore", apiHandlers.RestoreVideo)
+	apiV1.GET("/trash", apiHandlers.GetTrash)
+	apiV1.GET("/jobs", apiHandlers.GetJobs)
+	apiV1.GET("/jobs/:id", apiHandlers.GetJob)
+	apiV1.POST("/uploads", apiHandlers.CreateUpload)
+	apiV1.POST("/uploads/:key/complete", apiHandlers.CompleteUpload)
+	apiV1.OPTIONS("/tus", apiHandlers.TusOptions)
+	apiV1.POST("/tus", apiHandlers.CreateTusUpload)
+	apiV1.OPTIONS("/tus/:id", apiHandlers.TusOptions)
+	apiV1.HEAD("/tus/:id", apiHandlers.HeadTusUpload)
+	apiV1.PATCH("/tus/:id", apiHandlers.PatchTusUpload)
+	apiV1.GET("/tus/:id", apiHandlers.GetTusUpload)
+	apiV1.DELETE("/tus/:id", apiHandlers.DeleteTusUpload)
 
 	fmt.Printf("🎬 API Service iniciado na porta %s\n", cfg.Port)
 	fmt.Printf("🔧 Processor URL configurado: %s\n", cfg.ProcessorURL)
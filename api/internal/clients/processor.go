@@ -8,20 +8,45 @@ import (
 	"log"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"time"
 
 	"video-processor/api/internal/models"
+	"video-processor/internal/checksum"
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/jobs"
 )
 
 type ProcessorClientInterface interface {
-	ProcessVideo(filename string, videoFile io.Reader) (*models.ProcessingResult, error)
-	ProcessVideoFromS3(s3Key string) (*models.ProcessingResult, error)
+	ProcessVideo(filename string, videoFile io.Reader, options string) (*models.ProcessingResult, error)
+	// ProcessVideoFromS3 has the processor fetch s3Key from uploads storage and verify it
+	// against sha256 (hex) when that is not empty.
+	ProcessVideoFromS3(s3Key, options, sha256 string) (*models.ProcessingResult, error)
+	GetFrame(videoID string, query url.Values) (*models.FrameImage, error)
+	// DeleteFrames drops the frames the processor cached for videoID.
+	DeleteFrames(videoID string) error
 	HealthCheck() error
+	// ForTenant returns a client whose requests name tenant to the processor.
+	ForTenant(tenant string) ProcessorClientInterface
+	// ForJob returns a client whose requests name the job the processor should update.
+	ForJob(jobID string) ProcessorClientInterface
+}
+
+// ProcessorError carries a non-success status returned by the processor service.
+type ProcessorError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProcessorError) Error() string {
+	return fmt.Sprintf("processor service returned status %d: %s", e.StatusCode, e.Message)
 }
 
 type ProcessorClient struct {
 	baseURL string
 	client  *http.Client
+	tenant  string
+	jobID   string
 }
 
 func NewProcessorClient(baseURL string) *ProcessorClient {
@@ -33,30 +58,54 @@ func NewProcessorClient(baseURL string) *ProcessorClient {
 	}
 }
 
-func (pc *ProcessorClient) ProcessVideo(filename string, videoFile io.Reader) (*models.ProcessingResult, error) {
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
+func (pc *ProcessorClient) ForTenant(tenant string) ProcessorClientInterface {
+	scoped := *pc
+	scoped.tenant = tenant
+	return &scoped
+}
 
-	part, err := writer.CreateFormFile("video", filename)
+func (pc *ProcessorClient) ForJob(jobID string) ProcessorClientInterface {
+	scoped := *pc
+	scoped.jobID = jobID
+	return &scoped
+}
+
+// newRequest sets the tenant and job headers on requests made for a tenant or a job.
+func (pc *ProcessorClient) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, pc.baseURL+path, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
+		return nil, err
 	}
-
-	if _, err := io.Copy(part, videoFile); err != nil {
-		return nil, fmt.Errorf("failed to copy file content: %w", err)
+	if pc.tenant != "" {
+		req.Header.Set(baseConfig.TenantHeader, pc.tenant)
 	}
-
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
+	if pc.jobID != "" {
+		req.Header.Set(jobs.Header, pc.jobID)
 	}
+	return req, nil
+}
+
+// ProcessVideo streams the video to the processor as a multipart form without buffering
+// it: the form is written into a pipe while the request reads from the other end. A
+// failure reading videoFile aborts the request and is returned from ProcessVideo.
+func (pc *ProcessorClient) ProcessVideo(filename string, videoFile io.Reader, options string) (*models.ProcessingResult, error) {
+	bodyReader, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+
+	go func() {
+		bodyWriter.CloseWithError(writeVideoForm(writer, filename, videoFile, options))
+	}()
 
-	req, err := http.NewRequest("POST", pc.baseURL+"/process", &requestBody)
+	req, err := pc.newRequest("POST", "/process", bodyReader)
 	if err != nil {
+		bodyReader.CloseWithError(err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
+	// The transport closes bodyReader once the request ends, which also stops the writer
+	// if the processor answers before reading the whole video.
 	resp, err := pc.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -67,7 +116,51 @@ func (pc *ProcessorClient) ProcessVideo(filename string, videoFile io.Reader) (*
 		}
 	}()
 
+	return decodeProcessingResult(resp)
+}
+
+// writeVideoForm writes the options field and the video part, then a sha256 field with the
+// checksum of the bytes sent, which the processor verifies, and closes the form.
+func writeVideoForm(writer *multipart.Writer, filename string, videoFile io.Reader, options string) error {
+	if options != "" {
+		if err := writer.WriteField("options", options); err != nil {
+			return fmt.Errorf("failed to write options field: %w", err)
+		}
+	}
+
+	part, err := writer.CreateFormFile("video", filename)
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	hashed := checksum.NewReader(videoFile, "")
+	if _, err := io.Copy(part, hashed); err != nil {
+		return fmt.Errorf("failed to copy file content: %w", err)
+	}
+
+	if err := writer.WriteField("sha256", hashed.Sum()); err != nil {
+		return fmt.Errorf("failed to write sha256 field: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
+
+// decodeProcessingResult reads the processor's answer. Processing failures come back as
+// a result with Success false; a rejected upload becomes a ProcessorError.
+func decodeProcessingResult(resp *http.Response) (*models.ProcessingResult, error) {
 	var result models.ProcessingResult
+
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		message := http.StatusText(resp.StatusCode)
+		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Message != "" {
+			message = result.Message
+		}
+		return nil, &ProcessorError{StatusCode: resp.StatusCode, Message: message}
+	}
+
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
@@ -75,7 +168,7 @@ func (pc *ProcessorClient) ProcessVideo(filename string, videoFile io.Reader) (*
 	return &result, nil
 }
 
-func (pc *ProcessorClient) ProcessVideoFromS3(s3Key string) (*models.ProcessingResult, error) {
+func (pc *ProcessorClient) ProcessVideoFromS3(s3Key, options, sha256 string) (*models.ProcessingResult, error) {
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
@@ -83,11 +176,23 @@ func (pc *ProcessorClient) ProcessVideoFromS3(s3Key string) (*models.ProcessingR
 		return nil, fmt.Errorf("failed to write s3_key field: %w", err)
 	}
 
+	if options != "" {
+		if err := writer.WriteField("options", options); err != nil {
+			return nil, fmt.Errorf("failed to write options field: %w", err)
+		}
+	}
+
+	if sha256 != "" {
+		if err := writer.WriteField("sha256", sha256); err != nil {
+			return nil, fmt.Errorf("failed to write sha256 field: %w", err)
+		}
+	}
+
 	if err := writer.Close(); err != nil {
 		return nil, fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", pc.baseURL+"/process-s3", &requestBody)
+	req, err := pc.newRequest("POST", "/process-s3", &requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -104,12 +209,76 @@ func (pc *ProcessorClient) ProcessVideoFromS3(s3Key string) (*models.ProcessingR
 		}
 	}()
 
-	var result models.ProcessingResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	return decodeProcessingResult(resp)
+}
+
+// GetFrame asks the processor for a single still of a retained source video.
+// Only the t, format and width parameters are forwarded.
+func (pc *ProcessorClient) GetFrame(videoID string, query url.Values) (*models.FrameImage, error) {
+	params := url.Values{}
+	params.Set("video_id", videoID)
+	for _, key := range []string{"t", "format", "width"} {
+		if value := query.Get(key); value != "" {
+			params.Set(key, value)
+		}
 	}
 
-	return &result, nil
+	req, err := pc.newRequest("GET", "/frame?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := pc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Warning: Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			body.Error = http.StatusText(resp.StatusCode)
+		}
+		return nil, &ProcessorError{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame: %w", err)
+	}
+
+	return &models.FrameImage{
+		ContentType: resp.Header.Get("Content-Type"),
+		Data:        data,
+	}, nil
+}
+
+func (pc *ProcessorClient) DeleteFrames(videoID string) error {
+	req, err := pc.newRequest("DELETE", "/frame?"+url.Values{"video_id": {videoID}}.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := pc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Warning: Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return &ProcessorError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return nil
 }
 
 func (pc *ProcessorClient) HealthCheck() error {
@@ -0,0 +1,185 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"video-processor/api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessVideo_ShouldStreamMultipartForm(t *testing.T) {
+	video := bytes.Repeat([]byte("0123456789abcdef"), 1<<18) // 4 MiB
+	expected := sha256.Sum256(video)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/process", r.URL.Path)
+		assert.Equal(t, int64(-1), r.ContentLength, "body should be streamed, not buffered")
+
+		reader, err := r.MultipartReader()
+		require.NoError(t, err)
+
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "options", part.FormName())
+		options, _ := io.ReadAll(part)
+		assert.Equal(t, `{"fps":2}`, string(options))
+
+		part, err = reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "video", part.FormName())
+		assert.Equal(t, "clip.mp4", part.FileName())
+		hash := sha256.New()
+		_, err = io.Copy(hash, part)
+		require.NoError(t, err)
+		assert.Equal(t, expected[:], hash.Sum(nil))
+
+		part, err = reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "sha256", part.FormName(), "the checksum follows the video")
+		sum, _ := io.ReadAll(part)
+		assert.Equal(t, hex.EncodeToString(expected[:]), string(sum))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.ProcessingResult{Success: true, FrameCount: 3})
+	}))
+	defer server.Close()
+
+	result, err := NewProcessorClient(server.URL).ProcessVideo("clip.mp4", bytes.NewReader(video), `{"fps":2}`)
+
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 3, result.FrameCount)
+}
+
+type failingReader struct {
+	remaining int
+	err       error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, r.err
+	}
+	n := len(p)
+	if n > r.remaining {
+		n = r.remaining
+	}
+	r.remaining -= n
+	return n, nil
+}
+
+func TestProcessVideo_ShouldPropagateSourceReadErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	readErr := errors.New("disk read failed")
+	_, err := NewProcessorClient(server.URL).ProcessVideo("clip.mp4", &failingReader{remaining: 1 << 20, err: readErr}, "")
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, readErr)
+}
+
+func TestProcessVideo_ShouldReturnProcessorErrorWhenUploadIsTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(models.ProcessingResult{Success: false, Message: "Arquivo muito grande"})
+	}))
+	defer server.Close()
+
+	video := bytes.NewReader(make([]byte, 8<<20))
+	_, err := NewProcessorClient(server.URL).ProcessVideo("clip.mp4", video, "")
+
+	var processorErr *ProcessorError
+	require.ErrorAs(t, err, &processorErr)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, processorErr.StatusCode)
+	assert.Equal(t, "Arquivo muito grande", processorErr.Message)
+}
+
+func TestProcessVideoFromS3_ShouldReturnProcessorErrorWhenUploadIsTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/process-s3", r.URL.Path)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer server.Close()
+
+	_, err := NewProcessorClient(server.URL).ProcessVideoFromS3("20240101_120000_clip.mp4", "", "")
+
+	var processorErr *ProcessorError
+	require.ErrorAs(t, err, &processorErr)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, processorErr.StatusCode)
+}
+
+func TestForTenant_ShouldForwardTenantHeader(t *testing.T) {
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Tenant-ID"))
+		if r.URL.Path == "/frame" {
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("jpeg"))
+			return
+		}
+		json.NewEncoder(w).Encode(models.ProcessingResult{Success: true})
+	}))
+	defer server.Close()
+
+	client := NewProcessorClient(server.URL)
+	_, err := client.ForTenant("acme").ProcessVideoFromS3("20240101_120000_clip.mp4", "", "")
+	require.NoError(t, err)
+	_, err = client.ForTenant("acme").GetFrame("20240101_120000", nil)
+	require.NoError(t, err)
+	_, err = client.ProcessVideoFromS3("20240101_120000_clip.mp4", "", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"acme", "acme", ""}, tenants)
+}
+
+func TestForJob_ShouldForwardJobHeader(t *testing.T) {
+	var headers [][2]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, [2]string{r.Header.Get("X-Tenant-ID"), r.Header.Get("X-Job-ID")})
+		json.NewEncoder(w).Encode(models.ProcessingResult{Success: true})
+	}))
+	defer server.Close()
+
+	client := NewProcessorClient(server.URL)
+	_, err := client.ForTenant("acme").ForJob("0123456789abcdef0123456789abcdef").ProcessVideo("clip.mp4", bytes.NewReader([]byte("video")), "")
+	require.NoError(t, err)
+	_, err = client.ProcessVideoFromS3("20240101_120000_clip.mp4", "", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{{"acme", "0123456789abcdef0123456789abcdef"}, {"", ""}}, headers)
+}
+
+func TestDeleteFrames_ShouldNameTheVideo(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Query().Get("video_id") == "missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewProcessorClient(server.URL)
+	require.NoError(t, client.DeleteFrames("20240101_120000"))
+	var procErr *ProcessorError
+	require.ErrorAs(t, client.DeleteFrames("missing"), &procErr)
+
+	assert.Equal(t, http.StatusBadRequest, procErr.StatusCode)
+	assert.Equal(t, []string{"DELETE /frame?video_id=20240101_120000", "DELETE /frame?video_id=missing"}, requests)
+}
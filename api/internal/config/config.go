@@ -1,13 +1,42 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
+
 	baseConfig "video-processor/internal/config"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+	"video-processor/internal/storage"
 )
 
 type APIConfig struct {
 	Port         string
 	ProcessorURL string
+
+	// StageUploads stores uploads in the shared uploads storage and asks the processor
+	// to fetch them by key, instead of streaming the file to the processor.
+	StageUploads bool
+	// MaxUploadSize caps the size of a single video in bytes; zero disables the limit.
+	MaxUploadSize int64
+	// TrashRetention is how long deleted outputs can be restored; zero deletes them at once.
+	TrashRetention time.Duration
+	Uploads        storage.Storage
+	Outputs        storage.Storage
+	// Router resolves each tenant's view of Uploads and Outputs.
+	Router *storage.Router
+	// Jobs records every processing job; JOB_STORE picks memory, dynamodb or bolt.
+	Jobs jobs.JobRepository
+	// Queue, when set, carries accepted jobs to the processor instead of HTTP calls;
+	// JOB_QUEUE picks http, sqs or bolt.
+	Queue queue.Producer
+	// Consumer is the embedded queue, set when JOB_QUEUE is bolt. The API works it off
+	// itself, dispatching each job to the processor over HTTP.
+	Consumer queue.Consumer
+	Embedded *baseConfig.EmbeddedConfig
+
 	*baseConfig.DirectoryConfig
 	*baseConfig.AWSConfig
 	S3Service *baseConfig.S3Service
@@ -28,15 +57,75 @@ func New() *APIConfig {
 		s3Service = nil
 	}
 
+	dirs := baseConfig.NewDirectoryConfig()
+	uploads, outputs, err := storage.NewBackends(dirs, awsConfig, s3Service)
+	if err != nil {
+		// Never fall back to plaintext outputs when encryption was requested.
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+	router, err := storage.NewRouter(baseConfig.NewRoutingConfig(), awsConfig, s3Service, uploads, outputs)
+	if err != nil {
+		log.Fatalf("Failed to configure storage routing: %v", err)
+	}
+	embedded := baseConfig.NewEmbeddedConfig()
+	jobRepository, err := jobs.Open(GetEnv("JOB_STORE", ""), awsConfig, s3Service != nil, embedded)
+	if err != nil {
+		log.Fatalf("Failed to configure job store: %v", err)
+	}
+	queueBackend := GetEnv("JOB_QUEUE", "")
+	jobQueue, err := queue.Open(queueBackend, awsConfig, embedded)
+	if err != nil {
+		log.Fatalf("Failed to configure job queue: %v", err)
+	}
+	var producer queue.Producer
+	var consumer queue.Consumer
+	switch {
+	case jobQueue == nil:
+	case queueBackend == queue.BackendBolt:
+		// The API hears how the jobs it dispatches end, so any job store will do.
+		producer, consumer = jobQueue, jobQueue
+	default:
+		// Only the processor hears how queued jobs end, so it must write to the same store.
+		// Memory stores are per process and the embedded store is locked by the API.
+		switch jobRepository.(type) {
+		case *jobs.MemoryRepository, *jobs.BoltRepository:
+			log.Fatalf("Failed to configure job queue: JOB_QUEUE=%s needs a job store shared with the processor, use JOB_STORE=%s", queueBackend, jobs.BackendDynamoDB)
+		}
+		producer = jobQueue
+	}
+
 	return &APIConfig{
 		Port:            GetEnv("PORT", "8081"),
 		ProcessorURL:    GetEnv("PROCESSOR_URL", "http://localhost:8082"),
-		DirectoryConfig: baseConfig.NewDirectoryConfig(),
+		StageUploads:    GetEnv("STAGE_UPLOADS", strconv.FormatBool(s3Service != nil)) == "true",
+		MaxUploadSize:   baseConfig.ParseSize(GetEnv("MAX_UPLOAD_SIZE", "10GB"), baseConfig.DefaultMaxUploadSize),
+		TrashRetention:  parseTrashRetention(GetEnv("TRASH_RETENTION", "7d")),
+		Uploads:         uploads,
+		Outputs:         outputs,
+		Router:          router,
+		Jobs:            jobRepository,
+		Queue:           producer,
+		Consumer:        consumer,
+		Embedded:        embedded,
+		DirectoryConfig: dirs,
 		AWSConfig:       awsConfig,
 		S3Service:       s3Service,
 	}
 }
 
+// defaultTrashRetention applies when TRASH_RETENTION cannot be read; losing deleted
+// outputs by mistake is what the trash is there to prevent.
+const defaultTrashRetention = 7 * 24 * time.Hour
+
+func parseTrashRetention(value string) time.Duration {
+	ttl, err := baseConfig.ParseTTL(value)
+	if err != nil {
+		log.Printf("Warning: Invalid trash retention %s, using %s", value, defaultTrashRetention)
+		return defaultTrashRetention
+	}
+	return ttl
+}
+
 func (c *APIConfig) CreateDirectories() {
 	c.DirectoryConfig.CreateDirectories()
 }
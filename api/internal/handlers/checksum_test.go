@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otherDigest is a well-formed checksum that matches none of the test videos.
+const otherDigest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// fakeVideoDigest is the SHA-256 of the video newMultipartVideoRequest uploads.
+func fakeVideoDigest() string {
+	sum := sha256.Sum256([]byte("fake video content"))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCreateVideo_ShouldForwardChecksumOfStagedUpload(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.StageUploads = true
+	handlers.config.Uploads = storage.NewMemory()
+	mock := &MockProcessorClient{}
+	handlers.processorClient = mock
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+	c.Request.Header.Set(checksum.Header, strings.ToUpper(fakeVideoDigest()))
+
+	handlers.CreateVideo(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, fakeVideoDigest(), mock.sha256)
+}
+
+func TestCreateVideo_ShouldRejectStagedUploadWithWrongChecksum(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := storage.NewMemory()
+	handlers.config.StageUploads = true
+	handlers.config.Uploads = uploads
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFromS3Func: func(string, string) (*models.ProcessingResult, error) {
+			t.Fatal("a corrupted upload must not be processed")
+			return nil, nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+	c.Request.Header.Set(checksum.Header, otherDigest)
+
+	handlers.CreateVideo(c)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, fakeVideoDigest(), response.SourceChecksum)
+	objects, err := uploads.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects, "the upload is not kept")
+}
+
+func TestCreateVideo_ShouldAbortStreamWithWrongChecksum(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(_ string, video io.Reader, _ string) (*models.ProcessingResult, error) {
+			if _, err := io.ReadAll(video); err != nil {
+				return nil, err
+			}
+			return &models.ProcessingResult{Success: true}, nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+	c.Request.Header.Set(checksum.Header, otherDigest)
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestCreateVideo_ShouldRejectMalformedChecksum(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+	c.Request.Header.Set(checksum.Header, "md5:abc")
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetVideoDownload_ShouldAnnounceChecksum(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	outputs := storage.NewMemory()
+	handlers.config.Outputs = outputs
+	require.NoError(t, storage.PutWithMetadata(outputs, "frames_20240101_120000.zip", strings.NewReader("fake video content"), "application/zip",
+		map[string]string{storage.MetaChecksum: fakeVideoDigest()}))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_20240101_120000.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_20240101_120000.zip/download", http.NoBody)
+
+	handlers.GetVideoDownload(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, fakeVideoDigest(), w.Header().Get(checksum.Header))
+	encoded, err := checksum.Base64(fakeVideoDigest())
+	require.NoError(t, err)
+	assert.Equal(t, "sha-256="+encoded, w.Header().Get("Digest"))
+	assert.Equal(t, "fake video content", w.Body.String())
+}
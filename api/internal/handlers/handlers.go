@@ -1,18 +1,31 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"video-processor/api/internal/clients"
 	"video-processor/api/internal/config"
 	"video-processor/api/internal/models"
+	"video-processor/api/internal/tus"
+	"video-processor/internal/checksum"
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/dedup"
+	"video-processor/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,20 +33,52 @@ import (
 const (
 	StatusHealthy   = "healthy"
 	StatusUnhealthy = "unhealthy"
+
+	hlsPrefix = "hls_"
+
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+
+	// multipartOverhead is the room left for boundaries and form fields around the
+	// video when the request body of an upload is limited.
+	multipartOverhead = 1 << 20
 )
 
 type APIHandlers struct {
 	processorClient clients.ProcessorClientInterface
 	config          *config.APIConfig
+	resumable       tus.Store
+	resumableLocks  *sync.Map
+	// jobSlots bounds how many jobs run in the background at once when there is no job
+	// queue; it is shared by every copy of the handlers.
+	jobSlots chan struct{}
+	dedup    *dedup.Index
+	// tenant is set on the copies forTenant makes when storage routing is enabled.
+	tenant string
+	// jobID is set on the copies startJob makes for a tracked job.
+	jobID string
 }
 
 func NewAPIHandlers(cfg *config.APIConfig) *APIHandlers {
 	return &APIHandlers{
 		processorClient: clients.NewProcessorClient(cfg.ProcessorURL),
 		config:          cfg,
+		resumable:       newResumableStore(cfg),
+		resumableLocks:  &sync.Map{},
+		jobSlots:        make(chan struct{}, backgroundJobs(cfg)),
+		dedup:           dedup.NewIndex(cfg.Outputs),
 	}
 }
 
+// backgroundJobs is how many jobs run at once without a job queue: JOB_WORKERS, as
+// for the embedded queue.
+func backgroundJobs(cfg *config.APIConfig) int {
+	if cfg.Embedded == nil {
+		return baseConfig.DefaultEmbeddedWorkers
+	}
+	return cfg.Embedded.Workers
+}
+
 func (ah *APIHandlers) GetAPIHealth(c *gin.Context) {
 	health := gin.H{
 		"status":    StatusHealthy,
@@ -153,6 +198,7 @@ func (ah *APIHandlers) checkS3Connectivity() gin.H {
 			"latency_ms": latency.Milliseconds(),
 			"last_check": time.Now().Unix(),
 			"endpoint":   ah.config.AWSConfig.GetS3Endpoint(),
+			"provider":   ah.config.AWSConfig.ProviderType(),
 		}
 	}
 
@@ -161,12 +207,33 @@ func (ah *APIHandlers) checkS3Connectivity() gin.H {
 		"latency_ms": latency.Milliseconds(),
 		"last_check": time.Now().Unix(),
 		"endpoint":   ah.config.AWSConfig.GetS3Endpoint(),
+		"provider":   ah.config.AWSConfig.ProviderType(),
 	}
 }
 
+// CreateVideo accepts a video for processing. With a job store it answers 202 with the
+// job tracking it, whose status is polled at the Location the response names.
 func (ah *APIHandlers) CreateVideo(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	if ah.config.MaxUploadSize > 0 {
+		if ah.exceedsUploadLimit(c.Request.ContentLength - multipartOverhead) {
+			ah.respondUploadTooLarge(c)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ah.config.MaxUploadSize+multipartOverhead)
+	}
+
 	file, header, err := c.Request.FormFile("video")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ah.respondUploadTooLarge(c)
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
 			Message: "Erro ao receber arquivo: " + err.Error(),
@@ -179,6 +246,11 @@ func (ah *APIHandlers) CreateVideo(c *gin.Context) {
 		}
 	}()
 
+	if ah.exceedsUploadLimit(header.Size) {
+		ah.respondUploadTooLarge(c)
+		return
+	}
+
 	if !IsValidVideoFile(header.Filename) {
 		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
@@ -187,263 +259,765 @@ func (ah *APIHandlers) CreateVideo(c *gin.Context) {
 		return
 	}
 
-	if err := ah.processorClient.HealthCheck(); err != nil {
-		c.JSON(http.StatusServiceUnavailable, models.ProcessingResult{
+	options := c.PostForm("options")
+	if options != "" && !json.Valid([]byte(options)) {
+		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
-			Message: "Serviço de processamento indisponível: " + err.Error(),
+			Message: "Opções de processamento inválidas: JSON malformado",
 		})
 		return
 	}
 
-	if ah.config.IsS3Enabled() {
-		ah.processVideoWithS3(c, file, header.Filename)
+	declared, ok := declaredChecksum(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ProcessingResult{
+			Success: false,
+			Message: "Checksum SHA-256 inválido: use 64 caracteres hexadecimais",
+		})
+		return
+	}
+
+	// Queued jobs wait for the processor, so only direct calls need it up.
+	if ah.config.Queue == nil {
+		if err := ah.processorClient.HealthCheck(); err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.ProcessingResult{
+				Success: false,
+				Message: "Serviço de processamento indisponível: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if ah.config.Jobs != nil {
+		ah.acceptVideo(c, file, header.Filename, options, declared)
+		return
+	}
+
+	// Without a job store there is nothing to poll, so the video is processed in the request.
+	if ah.config.StageUploads {
+		ah.processStagedVideo(c, file, header.Filename, options, declared)
 	} else {
-		ah.processVideoDirectly(c, file, header.Filename)
+		ah.processVideoDirectly(c, file, header.Filename, options, declared)
+	}
+}
+
+// declaredChecksum returns the SHA-256 the client declared for the video, in the
+// X-Checksum-SHA256 header or the "sha256" form field.
+func declaredChecksum(c *gin.Context) (string, bool) {
+	declared := c.GetHeader(checksum.Header)
+	if declared == "" {
+		declared = c.PostForm("sha256")
 	}
+	return checksum.Normalize(declared)
 }
 
-func (ah *APIHandlers) processVideoWithS3(c *gin.Context, file io.Reader, filename string) {
-	timestamp := time.Now().Format("20060102_150405")
-	s3Key := fmt.Sprintf("%s_%s", timestamp, filepath.Base(filename))
+// processStagedVideo stores the video in uploads storage, hashing it on the way, and has
+// the processor verify the copy it fetches against that hash. A video that does not
+// match the checksum the client declared is rejected before it is stored.
+func (ah *APIHandlers) processStagedVideo(c *gin.Context, file io.Reader, filename, options, declared string) {
+	key := newUploadKey(filename)
 
-	if err := ah.config.S3Service.UploadFile(ah.config.S3Buckets.UploadsBucket, s3Key, file); err != nil {
+	var metadata map[string]string
+	if declared != "" {
+		metadata = map[string]string{storage.MetaChecksum: declared}
+	}
+	hashed := checksum.NewReader(file, "")
+	if err := storage.PutWithMetadata(ah.config.Uploads, key, hashed, "", metadata); err != nil {
+		ah.finishJob(nil, err)
+		if errors.Is(err, checksum.ErrMismatch) {
+			respondChecksumMismatch(c, hashed.Sum())
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
 			Success: false,
-			Message: "Erro ao fazer upload do vídeo para S3: " + err.Error(),
+			Message: "Erro ao armazenar o vídeo: " + err.Error(),
+			JobID:   ah.jobID,
 		})
 		return
 	}
 
-	log.Printf("Video uploaded to S3: s3://%s/%s", ah.config.S3Buckets.UploadsBucket, s3Key)
+	log.Printf("Video staged in uploads storage: %s", key)
 
-	result, err := ah.processorClient.ProcessVideoFromS3(s3Key)
+	ah.processStoredVideo(c, key, options, hashed.Sum())
+}
+
+// respondChecksumMismatch rejects a video whose bytes differ from its declared checksum.
+func respondChecksumMismatch(c *gin.Context, computed string) {
+	c.JSON(http.StatusUnprocessableEntity, models.ProcessingResult{
+		Success:        false,
+		Message:        "Checksum do vídeo não confere: o arquivo foi corrompido no envio",
+		SourceChecksum: computed,
+	})
+}
+
+// processStoredVideo asks the processor to fetch key from uploads storage and answers
+// with the processing result.
+func (ah *APIHandlers) processStoredVideo(c *gin.Context, key, options, sha256 string) {
+	result, err := ah.processStagedKey(key, options, sha256)
 	if err != nil {
-		if cleanupErr := ah.config.S3Service.DeleteFile(ah.config.S3Buckets.UploadsBucket, s3Key); cleanupErr != nil {
-			log.Printf("Warning: Failed to cleanup uploaded video from S3: %v", cleanupErr)
-		}
-		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
-			Success: false,
-			Message: "Erro ao processar vídeo: " + err.Error(),
-		})
+		ah.respondProcessingError(c, err)
 		return
 	}
 
-	if result.Success {
-		// Generate presigned URL for download
-		if result.ZipPath != "" {
-			downloadURL, err := ah.config.S3Service.GeneratePresignedURL(ah.config.S3Buckets.OutputsBucket, result.ZipPath, time.Hour)
-			if err != nil {
-				log.Printf("Warning: Failed to generate presigned URL for %s: %v", result.ZipPath, err)
-				// Fallback to API endpoint
-				downloadURL = "/api/v1/videos/" + result.ZipPath + "/download"
-			}
-			result.DownloadURL = downloadURL
+	ah.respondWithResult(c, result)
+}
+
+// processStagedKey asks the processor to fetch key from uploads storage, removing the
+// upload when processing cannot start. The processor checks the video against sha256,
+// when known, or the checksum stored with the upload.
+func (ah *APIHandlers) processStagedKey(key, options, sha256 string) (*models.ProcessingResult, error) {
+	result, err := ah.processorClient.ProcessVideoFromS3(key, options, sha256)
+	if err != nil {
+		if cleanupErr := ah.config.Uploads.Delete(key); cleanupErr != nil {
+			log.Printf("Warning: Failed to cleanup staged video %s: %v", key, cleanupErr)
 		}
-		c.JSON(http.StatusCreated, result)
-	} else {
-		c.JSON(http.StatusUnprocessableEntity, result)
+		return nil, err
 	}
+	return result, nil
+}
+
+// processUploadedVideo runs an upload already in uploads storage through the same path as
+// CreateVideo: by key when uploads are staged, streamed to the processor otherwise. A
+// non-empty sha256 is the checksum the client declared for the video.
+func (ah *APIHandlers) processUploadedVideo(key, filename, options, sha256 string) (*models.ProcessingResult, error) {
+	result, err := ah.sendUpload(key, filename, options, sha256)
+	ah.releaseUpload(key, err)
+	return result, err
 }
 
-func (ah *APIHandlers) processVideoDirectly(c *gin.Context, file io.Reader, filename string) {
-	result, err := ah.processorClient.ProcessVideo(filename, file)
+// sendUpload has the processor process an upload in uploads storage, leaving the
+// upload for releaseUpload.
+func (ah *APIHandlers) sendUpload(key, filename, options, sha256 string) (*models.ProcessingResult, error) {
+	if ah.config.StageUploads {
+		return ah.processorClient.ProcessVideoFromS3(key, options, sha256)
+	}
+
+	reader, err := ah.config.Uploads.Get(key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close upload %s: %v", key, err)
+		}
+	}()
+
+	hashed := checksum.NewReader(reader, sha256)
+	result, err := ah.processorClient.ProcessVideo(filename, hashed, options)
+	if err != nil && hashed.Mismatch() {
+		return nil, fmt.Errorf("checksum do vídeo não confere: %w", checksum.ErrMismatch)
+	}
+	return result, err
+}
+
+// releaseUpload removes an upload sendUpload is done with. The processor removes staged
+// uploads it fetched, so those are only removed when processing could not start.
+func (ah *APIHandlers) releaseUpload(key string, err error) {
+	if ah.config.StageUploads && err == nil {
+		return
+	}
+	if cleanupErr := ah.config.Uploads.Delete(key); cleanupErr != nil {
+		log.Printf("Warning: Failed to cleanup upload %s: %v", key, cleanupErr)
+	}
+}
+
+// processVideoDirectly streams the video to the processor, which verifies it against the
+// checksum the client computes on the way. A video that does not match the checksum the
+// client declared aborts the stream before processing starts.
+func (ah *APIHandlers) processVideoDirectly(c *gin.Context, file io.Reader, filename, options, declared string) {
+	hashed := checksum.NewReader(file, declared)
+	result, err := ah.processorClient.ProcessVideo(filename, hashed, options)
+	if err != nil && hashed.Mismatch() {
+		ah.finishJob(nil, checksum.ErrMismatch)
+		respondChecksumMismatch(c, hashed.Sum())
+		return
+	}
+	if err != nil {
+		ah.respondProcessingError(c, err)
+		return
+	}
+
+	ah.respondWithResult(c, result)
+}
+
+// respondProcessingError answers a failed processor call, passing on a 413 from the
+// processor so clients see the same status whichever service enforced the limit.
+func (ah *APIHandlers) respondProcessingError(c *gin.Context, err error) {
+	ah.finishJob(nil, err)
+
+	var procErr *clients.ProcessorError
+	if errors.As(err, &procErr) && procErr.StatusCode == http.StatusRequestEntityTooLarge {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ProcessingResult{
 			Success: false,
-			Message: "Erro ao processar vídeo: " + err.Error(),
+			Message: "Arquivo excede o tamanho máximo aceito pelo processador: " + procErr.Message,
+			JobID:   ah.jobID,
 		})
 		return
 	}
 
+	c.JSON(http.StatusInternalServerError, models.ProcessingResult{
+		Success: false,
+		Message: "Erro ao processar vídeo: " + err.Error(),
+		JobID:   ah.jobID,
+	})
+}
+
+// exceedsUploadLimit reports whether a video of size bytes is above MaxUploadSize.
+func (ah *APIHandlers) exceedsUploadLimit(size int64) bool {
+	return ah.config.MaxUploadSize > 0 && size > ah.config.MaxUploadSize
+}
+
+func (ah *APIHandlers) uploadTooLargeMessage() string {
+	return fmt.Sprintf("Arquivo excede o tamanho máximo permitido de %d bytes", ah.config.MaxUploadSize)
+}
+
+func (ah *APIHandlers) respondUploadTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, models.ProcessingResult{
+		Success: false,
+		Message: ah.uploadTooLargeMessage(),
+	})
+}
+
+func (ah *APIHandlers) respondWithResult(c *gin.Context, result *models.ProcessingResult) {
+	ah.finishJob(result, nil)
 	if result.Success {
+		ah.fillOutputURLs(result)
 		c.JSON(http.StatusCreated, result)
 	} else {
 		c.JSON(http.StatusUnprocessableEntity, result)
 	}
 }
 
-func (ah *APIHandlers) GetVideos(c *gin.Context) {
-	if ah.config.IsS3Enabled() {
-		ah.getVideosFromS3(c)
-	} else {
-		ah.getVideosFromFilesystem(c)
+// fillOutputURLs points the archive, clips, the proxy and the HLS playlist at URLs the browser can use directly.
+func (ah *APIHandlers) fillOutputURLs(result *models.ProcessingResult) {
+	if result.ZipPath != "" && result.DownloadURL == "" {
+		downloadURL, err := ah.config.Outputs.DownloadURL(result.ZipPath, time.Hour)
+		switch {
+		case err == nil:
+			result.DownloadURL = downloadURL
+		case !errors.Is(err, storage.ErrURLNotSupported):
+			log.Printf("Warning: Failed to generate download URL for %s: %v", result.ZipPath, err)
+			result.DownloadURL = "/api/v1/videos/" + result.ZipPath + "/download"
+		}
+	}
+
+	for i := range result.Clips {
+		result.Clips[i].DownloadURL = ah.outputURL(result.Clips[i].Filename)
+	}
+
+	if result.ProxyPath != "" {
+		result.ProxyURL = ah.outputURL(result.ProxyPath)
+	}
+
+	if result.PlaylistPath != "" {
+		videoID := strings.TrimPrefix(path.Dir(result.PlaylistPath), hlsPrefix)
+		result.PlaylistURL = "/api/v1/videos/" + videoID + "/hls/" + path.Base(result.PlaylistPath)
+	}
+}
+
+// outputURL returns a direct link to a stored output, falling back to the API download route.
+func (ah *APIHandlers) outputURL(key string) string {
+	downloadURL, err := ah.config.Outputs.DownloadURL(key, time.Hour)
+	if err == nil {
+		return downloadURL
+	}
+	if !errors.Is(err, storage.ErrURLNotSupported) {
+		log.Printf("Warning: Failed to generate download URL for %s: %v", key, err)
 	}
+	return "/api/v1/videos/" + key + "/download"
 }
 
-func (ah *APIHandlers) getVideosFromS3(c *gin.Context) {
-	files, err := ah.config.S3Service.ListFiles(ah.config.S3Buckets.OutputsBucket, "")
+// GetVideos lists processed archives in key order, one page at a time. The opaque
+// next_cursor of a response is passed back as cursor to fetch the following page.
+func (ah *APIHandlers) GetVideos(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	limit, err := ParsePageLimit(c.Query("limit"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar arquivos do S3: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	results := make([]map[string]interface{}, 0, len(files))
-	for _, file := range files {
-		if !strings.HasSuffix(file, ".zip") {
-			continue
-		}
+	startAfter, err := DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, nextKey, err := ah.listArchives(startAfter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar arquivos: " + err.Error()})
+		return
+	}
+	ah.addArchiveMetadata(results)
+
+	response := gin.H{
+		"videos": results,
+		"total":  len(results),
+		"limit":  limit,
+	}
+	if nextKey != "" {
+		response["next_cursor"] = EncodeCursor(nextKey)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
 
-		info, err := ah.config.S3Service.GetFileInfo(ah.config.S3Buckets.OutputsBucket, file)
+// listArchives collects up to limit top-level ZIPs after startAfter, reading further
+// storage pages while other outputs (clips, HLS segments) fill them. It returns the key
+// to resume from, or "" when the listing is exhausted.
+func (ah *APIHandlers) listArchives(startAfter string, limit int) ([]map[string]interface{}, string, error) {
+	results := make([]map[string]interface{}, 0, limit)
+
+	for {
+		objects, more, err := ah.config.Outputs.ListPage("", startAfter, limit)
 		if err != nil {
-			log.Printf("Warning: Failed to get file info for %s: %v", file, err)
-			continue
+			return nil, "", err
 		}
 
-		downloadURL, err := ah.config.S3Service.GeneratePresignedURL(ah.config.S3Buckets.OutputsBucket, file, time.Hour)
-		if err != nil {
-			log.Printf("Warning: Failed to generate presigned URL for %s: %v", file, err)
-			downloadURL = "/api/v1/videos/" + filepath.Base(file) + "/download"
+		for i, obj := range objects {
+			startAfter = obj.Key
+			if path.Ext(obj.Key) != ".zip" || strings.Contains(obj.Key, "/") {
+				continue
+			}
+
+			results = append(results, map[string]interface{}{
+				"filename":     obj.Key,
+				"size":         obj.Size,
+				"created_at":   obj.LastModified.Format("2006-01-02 15:04:05"),
+				"download_url": ah.outputURL(obj.Key),
+			})
+
+			if len(results) == limit {
+				if more || i < len(objects)-1 {
+					return results, obj.Key, nil
+				}
+				return results, "", nil
+			}
 		}
 
-		results = append(results, map[string]interface{}{
-			"filename":     filepath.Base(file),
-			"size":         *info.ContentLength,
-			"created_at":   info.LastModified.Format("2006-01-02 15:04:05"),
-			"download_url": downloadURL,
-		})
+		if !more || len(objects) == 0 {
+			return results, "", nil
+		}
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"videos": results,
-		"total":  len(results),
-	})
 }
 
-func (ah *APIHandlers) getVideosFromFilesystem(c *gin.Context) {
-	files, err := filepath.Glob(filepath.Join(ah.config.OutputsDir, "*.zip"))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar arquivos"})
-		return
+// metadataLookups bounds the concurrent Stat calls made to describe a page of archives.
+const metadataLookups = 8
+
+// addArchiveMetadata fills in what the processor stored with each archive. Listings carry
+// no metadata, so every archive costs a Stat (a HEAD request on S3); they run a few at a
+// time. Archives whose metadata cannot be read are listed without it.
+func (ah *APIHandlers) addArchiveMetadata(results []map[string]interface{}) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, metadataLookups)
+
+	for _, result := range results {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(result map[string]interface{}) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			key := result["filename"].(string)
+			info, err := ah.config.Outputs.Stat(key)
+			if err != nil {
+				log.Printf("Warning: Failed to read metadata of %s: %v", key, err)
+				return
+			}
+			for field, value := range archiveMetadataFields(info.Metadata) {
+				result[field] = value
+			}
+		}(result)
 	}
+	wg.Wait()
+}
 
-	results := make([]map[string]interface{}, 0, len(files))
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			continue
+// archiveMetadataFields maps stored metadata onto the fields of a listed archive.
+func archiveMetadataFields(metadata map[string]string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for field, key := range map[string]string{
+		"original_name": storage.MetaOriginalName,
+		"source_hash":   storage.MetaSourceHash,
+		"owner":         storage.MetaOwner,
+		"sha256":        storage.MetaChecksum,
+	} {
+		if value := metadata[key]; value != "" {
+			fields[field] = value
 		}
+	}
+	if count, err := strconv.Atoi(metadata[storage.MetaFrameCount]); err == nil {
+		fields["frame_count"] = count
+	}
+	if options := metadata[storage.MetaOptions]; json.Valid([]byte(options)) {
+		fields["options"] = json.RawMessage(options)
+	}
+	return fields
+}
 
-		results = append(results, map[string]interface{}{
-			"filename":     filepath.Base(file),
-			"size":         info.Size(),
-			"created_at":   info.ModTime().Format("2006-01-02 15:04:05"),
-			"download_url": "/api/v1/videos/" + filepath.Base(file) + "/download",
-		})
+// ParsePageLimit reads the page size, defaulting to DefaultPageSize and capping at MaxPageSize.
+func ParsePageLimit(raw string) (int, error) {
+	if raw == "" {
+		return DefaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("parâmetro limit inválido: %s", raw)
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
 	}
+	return limit, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"videos": results,
-		"total":  len(results),
-	})
+// EncodeCursor turns the last key of a page into an opaque, URL-safe cursor.
+func EncodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// DecodeCursor recovers the key a page should start after; an empty cursor starts from the beginning.
+func DecodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) == 0 {
+		return "", fmt.Errorf("parâmetro cursor inválido")
+	}
+	return string(key), nil
 }
 
 // GetVideoDownload handles video download requests with flexible response modes.
-// Supports both direct redirect to presigned URL and JSON response with URL.
-// Query parameter 'redirect=true' enables direct redirect mode for better UX.
+// When the outputs storage hands out direct links, 'redirect=true' redirects to it and
+// the default is a JSON response with the URL; otherwise the file is streamed.
 func (ah *APIHandlers) GetVideoDownload(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
 	filename := c.Param("filename")
 	if filename == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome do arquivo é obrigatório"})
 		return
 	}
 
-	if ah.config.IsS3Enabled() {
-		ah.downloadVideoFromS3(c, filename)
-	} else {
-		ah.downloadVideoFromFilesystem(c, filename)
+	info, ok := ah.statOutput(c, filename)
+	if !ok {
+		return
+	}
+	sum := setChecksumHeaders(c, info)
+
+	downloadURL, err := ah.config.Outputs.DownloadURL(filename, time.Hour)
+	if err == nil {
+		if c.Query("redirect") == "true" {
+			c.Redirect(http.StatusFound, downloadURL)
+			return
+		}
+
+		response := gin.H{
+			"download_url": downloadURL,
+			"filename":     filename,
+			"expires_in":   3600,
+		}
+		if sum != "" {
+			response["sha256"] = sum
+		}
+		c.JSON(http.StatusOK, response)
+		return
 	}
+	if !errors.Is(err, storage.ErrURLNotSupported) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar URL de download: " + err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	ah.streamOutput(c, info, OutputContentType(filename))
 }
 
-func (ah *APIHandlers) downloadVideoFromS3(c *gin.Context, filename string) {
-	exists, err := ah.config.S3Service.FileExists(ah.config.S3Buckets.OutputsBucket, filename)
+// setChecksumHeaders announces the stored SHA-256 of an output, as X-Checksum-SHA256 (hex)
+// and Digest (RFC 3230, base64), so clients can verify what they download. It returns the
+// hex digest, empty for outputs stored without one.
+func setChecksumHeaders(c *gin.Context, info *storage.ObjectInfo) string {
+	sum, ok := checksum.Normalize(info.Metadata[storage.MetaChecksum])
+	if !ok || sum == "" {
+		return ""
+	}
+	encoded, err := checksum.Base64(sum)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar arquivo no S3: " + err.Error()})
-		return
+		return ""
 	}
+	c.Header(checksum.Header, sum)
+	c.Header("Digest", "sha-256="+encoded)
+	return sum
+}
 
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+// DeleteVideo moves an output to the trash, together with the proxy, clips and HLS
+// renditions when it is a frames archive. Without a trash retention it is deleted at once.
+func (ah *APIHandlers) DeleteVideo(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
 		return
 	}
 
-	presignedURL, err := ah.config.S3Service.GeneratePresignedURL(ah.config.S3Buckets.OutputsBucket, filename, time.Hour)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar URL de download: " + err.Error()})
+	filename := c.Param("filename")
+
+	if _, ok := ah.statOutput(c, filename); !ok {
 		return
 	}
 
-	// Support both redirect and JSON response modes
-	if c.Query("redirect") == "true" {
-		// Direct redirect to S3 - better UX, faster download
-		c.Redirect(http.StatusFound, presignedURL)
+	// Archives shared by deduplicated jobs stay until the last of them is deleted, whose
+	// reference is only given back once the outputs are gone.
+	videoID := VideoIDFromParam(filename)
+	archive := filename == "frames_"+videoID+".zip"
+	removed := true
+	var removeErr error
+	if archive {
+		var err error
+		removed, err = ah.dedup.Release(videoID, func() error {
+			removeErr = ah.removeOutput(filename)
+			return removeErr
+		})
+		if err != nil && removeErr == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao liberar referência do arquivo: " + err.Error()})
+			return
+		}
+	} else {
+		removeErr = ah.removeOutput(filename)
+	}
+	if removeErr != nil {
+		message := "Erro ao mover arquivo para a lixeira: "
+		if ah.config.TrashRetention == 0 {
+			message = "Erro ao deletar arquivo: "
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message + removeErr.Error()})
+		return
+	}
+	if !removed {
+		log.Printf("Outputs of %s are still referenced by deduplicated jobs", videoID)
+		c.JSON(http.StatusNoContent, nil)
 		return
 	}
 
-	// JSON response - allows frontend to handle URL as needed
-	c.JSON(http.StatusOK, gin.H{
-		"download_url": presignedURL,
-		"filename":     filename,
-		"expires_in":   3600,
-	})
+	// Frames of a deleted video are rendered again if it is restored.
+	if archive {
+		if err := ah.processorClient.DeleteFrames(videoID); err != nil {
+			log.Printf("Warning: Failed to clear cached frames of %s: %v", videoID, err)
+		}
+	}
+	c.JSON(http.StatusNoContent, nil)
 }
 
-func (ah *APIHandlers) downloadVideoFromFilesystem(c *gin.Context, filename string) {
-	filePath := filepath.Join(ah.config.OutputsDir, filename)
+// removeOutput moves an output to the trash, or deletes it without a trash retention.
+func (ah *APIHandlers) removeOutput(filename string) error {
+	if ah.config.TrashRetention == 0 {
+		return ah.config.Outputs.Delete(filename)
+	}
+	return ah.discard(filename)
+}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+// statOutput looks up a stored output, answering 404/500 itself when it cannot be used.
+func (ah *APIHandlers) statOutput(c *gin.Context, key string) (*storage.ObjectInfo, bool) {
+	info, err := ah.config.Outputs.Stat(key)
+	if errors.Is(err, storage.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar arquivo: " + err.Error()})
+		return nil, false
+	}
+	return info, true
+}
+
+// streamOutput serves a stored output through the API, straight from disk when possible.
+func (ah *APIHandlers) streamOutput(c *gin.Context, info *storage.ObjectInfo, contentType string) {
+	c.Header("Content-Type", contentType)
+
+	if localPath, ok := storage.LocalPath(ah.config.Outputs, info.Key); ok {
+		c.File(localPath)
 		return
 	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/zip")
-	c.File(filePath)
+	reader, err := ah.config.Outputs.Get(info.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler arquivo: " + err.Error()})
+		return
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close reader for %s: %v", info.Key, err)
+		}
+	}()
+
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, nil)
 }
 
-func (ah *APIHandlers) DeleteVideo(c *gin.Context) {
-	filename := c.Param("filename")
+// GetVideoFrame returns a single still at an arbitrary timestamp of a processed video.
+// The video can be referenced by its ID or by the name of its frames ZIP.
+func (ah *APIHandlers) GetVideoFrame(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
 
-	if ah.config.IsS3Enabled() {
-		ah.deleteVideoFromS3(c, filename)
-	} else {
-		ah.deleteVideoFromFilesystem(c, filename)
+	videoID := VideoIDFromParam(c.Param("filename"))
+	if videoID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do vídeo é obrigatório"})
+		return
 	}
-}
 
-func (ah *APIHandlers) deleteVideoFromS3(c *gin.Context, filename string) {
-	exists, err := ah.config.S3Service.FileExists(ah.config.S3Buckets.OutputsBucket, filename)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar arquivo no S3: " + err.Error()})
+	if c.Query("t") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetro t é obrigatório"})
 		return
 	}
 
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+	frame, err := ah.processorClient.GetFrame(videoID, c.Request.URL.Query())
+	if err != nil {
+		var procErr *clients.ProcessorError
+		if errors.As(err, &procErr) {
+			c.JSON(procErr.StatusCode, gin.H{"error": procErr.Message})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Erro ao obter frame: " + err.Error()})
 		return
 	}
 
-	if err := ah.config.S3Service.DeleteFile(ah.config.S3Buckets.OutputsBucket, filename); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar arquivo do S3: " + err.Error()})
+	sum := sha256.Sum256(frame.Data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	if c.GetHeader("If-None-Match") == etag {
+		c.AbortWithStatus(http.StatusNotModified)
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	c.Data(http.StatusOK, frame.ContentType, frame.Data)
 }
 
-func (ah *APIHandlers) deleteVideoFromFilesystem(c *gin.Context, filename string) {
-	filePath := filepath.Join(ah.config.OutputsDir, filename)
+// GetVideoHLS serves the HLS playlists of a processed video. Playlists are rewritten so
+// variant playlists go back through the API and segments point at presigned S3 URLs
+// (or the API itself in filesystem mode), letting private buckets stream in the browser.
+func (ah *APIHandlers) GetVideoHLS(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+	videoID := VideoIDFromParam(c.Param("filename"))
+	asset := c.Param("asset")
+
+	if videoID == "" || asset != path.Base(asset) || strings.Contains(asset, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo HLS inválido"})
 		return
 	}
 
-	if err := os.Remove(filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar arquivo"})
+	ext := path.Ext(asset)
+	if ext != ".m3u8" && ext != ".ts" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo HLS inválido"})
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	key := hlsPrefix + videoID + "/" + asset
+
+	if ext == ".ts" {
+		ah.serveHLSSegment(c, key)
+		return
+	}
+
+	content, err := ah.readOutput(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist não encontrada"})
+		return
+	}
+
+	playlist := ah.rewritePlaylist(string(content), videoID)
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
+}
+
+func (ah *APIHandlers) serveHLSSegment(c *gin.Context, key string) {
+	segmentURL, err := ah.config.Outputs.DownloadURL(key, 0)
+	if err == nil {
+		c.Redirect(http.StatusFound, segmentURL)
+		return
+	}
+	if !errors.Is(err, storage.ErrURLNotSupported) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar URL do segmento: " + err.Error()})
+		return
+	}
+
+	info, err := ah.config.Outputs.Stat(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segmento não encontrado"})
+		return
+	}
+
+	ah.streamOutput(c, info, "video/mp2t")
+}
+
+func (ah *APIHandlers) readOutput(key string) ([]byte, error) {
+	reader, err := ah.config.Outputs.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close reader for %s: %v", key, err)
+		}
+	}()
+
+	return io.ReadAll(reader)
+}
+
+func (ah *APIHandlers) rewritePlaylist(playlist, videoID string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		uri := strings.TrimSpace(line)
+		if uri == "" || strings.HasPrefix(uri, "#") || uri != path.Base(uri) {
+			continue
+		}
+
+		lines[i] = "/api/v1/videos/" + videoID + "/hls/" + uri
+		if path.Ext(uri) == ".m3u8" {
+			continue
+		}
+
+		segmentURL, err := ah.config.Outputs.DownloadURL(hlsPrefix+videoID+"/"+uri, 0)
+		if err == nil {
+			lines[i] = segmentURL
+		} else if !errors.Is(err, storage.ErrURLNotSupported) {
+			log.Printf("Warning: Failed to presign HLS segment %s: %v", uri, err)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OutputContentType maps stored output names to the content type served on download.
+func OutputContentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mp4":
+		return "video/mp4"
+	default:
+		return "application/zip"
+	}
+}
+
+// VideoIDFromParam accepts either a bare video ID or a frames ZIP name (frames_<id>.zip).
+func VideoIDFromParam(param string) string {
+	id := strings.TrimSuffix(filepath.Base(param), ".zip")
+	return strings.TrimPrefix(id, "frames_")
+}
+
+// newUploadKey names an upload after the video ID it will be processed under.
+func newUploadKey(filename string) string {
+	return fmt.Sprintf("%s_%s", storage.NewJobID(time.Now()), filepath.Base(filename))
 }
 
 func IsValidVideoFile(filename string) bool {
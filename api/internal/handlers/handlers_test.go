@@ -3,17 +3,24 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"video-processor/api/internal/clients"
 	"video-processor/api/internal/config"
 	"video-processor/api/internal/models"
 	baseConfig "video-processor/internal/config"
+	"video-processor/internal/dedup"
+	"video-processor/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -22,8 +29,37 @@ import (
 
 type MockProcessorClient struct {
 	healthCheckFunc        func() error
-	processVideoFunc       func(string, io.Reader) (*models.ProcessingResult, error)
-	processVideoFromS3Func func(string) (*models.ProcessingResult, error)
+	processVideoFunc       func(string, io.Reader, string) (*models.ProcessingResult, error)
+	processVideoFromS3Func func(string, string) (*models.ProcessingResult, error)
+	getFrameFunc           func(string, url.Values) (*models.FrameImage, error)
+	// deletedFrames lists the videos whose cached frames were dropped.
+	deletedFrames []string
+	tenant        string
+	jobID         string
+	// sha256 is the checksum passed with the last ProcessVideoFromS3 call.
+	sha256 string
+}
+
+func (m *MockProcessorClient) ForTenant(tenant string) clients.ProcessorClientInterface {
+	m.tenant = tenant
+	return m
+}
+
+func (m *MockProcessorClient) ForJob(jobID string) clients.ProcessorClientInterface {
+	m.jobID = jobID
+	return m
+}
+
+func (m *MockProcessorClient) GetFrame(videoID string, query url.Values) (*models.FrameImage, error) {
+	if m.getFrameFunc != nil {
+		return m.getFrameFunc(videoID, query)
+	}
+	return &models.FrameImage{ContentType: "image/jpeg", Data: []byte("jpeg")}, nil
+}
+
+func (m *MockProcessorClient) DeleteFrames(videoID string) error {
+	m.deletedFrames = append(m.deletedFrames, videoID)
+	return nil
 }
 
 func (m *MockProcessorClient) HealthCheck() error {
@@ -33,9 +69,9 @@ func (m *MockProcessorClient) HealthCheck() error {
 	return nil
 }
 
-func (m *MockProcessorClient) ProcessVideo(filename string, fileReader io.Reader) (*models.ProcessingResult, error) {
+func (m *MockProcessorClient) ProcessVideo(filename string, fileReader io.Reader, options string) (*models.ProcessingResult, error) {
 	if m.processVideoFunc != nil {
-		return m.processVideoFunc(filename, fileReader)
+		return m.processVideoFunc(filename, fileReader, options)
 	}
 	return &models.ProcessingResult{
 		Success:    true,
@@ -46,9 +82,10 @@ func (m *MockProcessorClient) ProcessVideo(filename string, fileReader io.Reader
 	}, nil
 }
 
-func (m *MockProcessorClient) ProcessVideoFromS3(s3Key string) (*models.ProcessingResult, error) {
+func (m *MockProcessorClient) ProcessVideoFromS3(s3Key, options, sha256 string) (*models.ProcessingResult, error) {
+	m.sha256 = sha256
 	if m.processVideoFromS3Func != nil {
-		return m.processVideoFromS3Func(s3Key)
+		return m.processVideoFromS3Func(s3Key, options)
 	}
 	return &models.ProcessingResult{
 		Success:    true,
@@ -77,6 +114,8 @@ func setupTestHandlers() (handlers *APIHandlers, cleanup func()) {
 			OutputsDir: outputsDir,
 			TempDir:    tempAPIDir,
 		},
+		Uploads:   storage.NewFilesystem(uploadsDir),
+		Outputs:   storage.NewFilesystem(outputsDir),
 		AWSConfig: nil,
 	}
 
@@ -204,7 +243,7 @@ func TestCreateVideo_ShouldReturnCreatedWhenVideoProcessingSucceeds(t *testing.T
 		healthCheckFunc: func() error {
 			return nil
 		},
-		processVideoFunc: func(filename string, fileReader io.Reader) (*models.ProcessingResult, error) {
+		processVideoFunc: func(filename string, fileReader io.Reader, options string) (*models.ProcessingResult, error) {
 			return &models.ProcessingResult{
 				Success:    true,
 				Message:    "Processamento concluído! 5 frames extraídos.",
@@ -251,7 +290,7 @@ func TestCreateVideo_ShouldReturnUnprocessableEntityWhenProcessingFails(t *testi
 		healthCheckFunc: func() error {
 			return nil
 		},
-		processVideoFunc: func(filename string, fileReader io.Reader) (*models.ProcessingResult, error) {
+		processVideoFunc: func(filename string, fileReader io.Reader, options string) (*models.ProcessingResult, error) {
 			return &models.ProcessingResult{
 				Success: false,
 				Message: "Erro ao processar vídeo: formato inválido",
@@ -754,3 +793,672 @@ func TestGetAPIHealth_ShouldVerifyAllRequiredDirectories(t *testing.T) {
 		assert.NotNil(t, dir["path"])
 	}
 }
+
+func TestGetVideoFrame_ShouldForwardRequestAndReturnImage(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	var gotID string
+	var gotQuery url.Values
+	handlers.processorClient = &MockProcessorClient{
+		getFrameFunc: func(videoID string, query url.Values) (*models.FrameImage, error) {
+			gotID = videoID
+			gotQuery = query
+			return &models.FrameImage{ContentType: "image/jpeg", Data: []byte("jpeg-bytes")}, nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_20240101_120000.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_20240101_120000.zip/frame?t=754.5&format=jpeg&width=1280", http.NoBody)
+
+	handlers.GetVideoFrame(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, "jpeg-bytes", w.Body.String())
+	assert.Equal(t, "20240101_120000", gotID)
+	assert.Equal(t, "754.5", gotQuery.Get("t"))
+	assert.Equal(t, "1280", gotQuery.Get("width"))
+}
+
+func TestGetVideoFrame_ShouldReturnNotModifiedForMatchingETag(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	handlers.processorClient = &MockProcessorClient{}
+	gin.SetMode(gin.TestMode)
+
+	first := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(first)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/frame?t=1", http.NoBody)
+	handlers.GetVideoFrame(c)
+	require.Equal(t, http.StatusOK, first.Code)
+
+	second := httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(second)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/frame?t=1", http.NoBody)
+	c.Request.Header.Set("If-None-Match", first.Header().Get("ETag"))
+	handlers.GetVideoFrame(c)
+
+	assert.Equal(t, http.StatusNotModified, second.Code)
+}
+
+func TestGetVideoFrame_ShouldRequireTimestamp(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/frame", http.NoBody)
+
+	handlers.GetVideoFrame(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetVideoFrame_ShouldPropagateProcessorStatus(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	handlers.processorClient = &MockProcessorClient{
+		getFrameFunc: func(string, url.Values) (*models.FrameImage, error) {
+			return nil, &clients.ProcessorError{StatusCode: http.StatusNotFound, Message: "Vídeo de origem não encontrado"}
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/frame?t=1", http.NoBody)
+
+	handlers.GetVideoFrame(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Vídeo de origem não encontrado")
+}
+
+func TestCreateVideo_ShouldForwardOptionsAndFillClipDownloadURLs(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	var gotOptions string
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(filename string, fileReader io.Reader, options string) (*models.ProcessingResult, error) {
+			gotOptions = options
+			return &models.ProcessingResult{
+				Success:    true,
+				ZipPath:    "frames_20240101_120000.zip",
+				FrameCount: 5,
+				Clips:      []models.ClipResult{{Filename: "clip_20240101_120000_01.mp4", Start: 10, End: 20, Mode: "copy"}},
+			}, nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "test.mp4")
+	require.NoError(t, err)
+	part.Write([]byte("fake video content"))
+	options := `{"clips":[{"start":"10","end":"20"}]}`
+	require.NoError(t, writer.WriteField("options", options))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/videos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, options, gotOptions)
+
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Clips, 1)
+	assert.Equal(t, "/api/v1/videos/clip_20240101_120000_01.mp4/download", response.Clips[0].DownloadURL)
+}
+
+func TestCreateVideo_ShouldRejectMalformedOptions(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "test.mp4")
+	require.NoError(t, err)
+	part.Write([]byte("fake video content"))
+	require.NoError(t, writer.WriteField("options", `{"clips":`))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/videos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetVideoDownload_ShouldServeClipsAsMP4(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	clipPath := filepath.Join(handlers.config.OutputsDir, "clip_20240101_120000_01.mp4")
+	require.NoError(t, os.WriteFile(clipPath, []byte("mp4"), 0600))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "clip_20240101_120000_01.mp4"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/clip_20240101_120000_01.mp4/download", http.NoBody)
+
+	handlers.GetVideoDownload(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+}
+
+func TestGetVideoHLS_ShouldRewritePlaylistURIsInFilesystemMode(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	hlsDir := filepath.Join(handlers.config.OutputsDir, "hls_20240101_120000")
+	require.NoError(t, os.MkdirAll(hlsDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(hlsDir, "master.m3u8"),
+		[]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=928000\n360p.m3u8\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(hlsDir, "360p.m3u8"),
+		[]byte("#EXTM3U\n#EXTINF:6.0,\n360p_000.ts\n#EXT-X-ENDLIST\n"), 0600))
+
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}, {Key: "asset", Value: "master.m3u8"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/hls/master.m3u8", http.NoBody)
+	handlers.GetVideoHLS(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/vnd.apple.mpegurl", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "/api/v1/videos/20240101_120000/hls/360p.m3u8")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}, {Key: "asset", Value: "360p.m3u8"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/hls/360p.m3u8", http.NoBody)
+	handlers.GetVideoHLS(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/api/v1/videos/20240101_120000/hls/360p_000.ts")
+	assert.Contains(t, w.Body.String(), "#EXT-X-ENDLIST")
+}
+
+func TestGetVideoHLS_ShouldRejectUnexpectedAssets(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+
+	for _, asset := range []string{"..", "frames.zip", "x.mp4"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "filename", Value: "20240101_120000"}, {Key: "asset", Value: asset}}
+		c.Request = httptest.NewRequest("GET", "/api/v1/videos/20240101_120000/hls/x", http.NoBody)
+
+		handlers.GetVideoHLS(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, asset)
+	}
+}
+
+func TestFillOutputURLs_ShouldExposeProxyAndPlaylist(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	result := &models.ProcessingResult{
+		ProxyPath:    "proxy_20240101_120000.mp4",
+		PlaylistPath: "hls_20240101_120000/master.m3u8",
+	}
+
+	handlers.fillOutputURLs(result)
+
+	assert.Equal(t, "/api/v1/videos/proxy_20240101_120000.mp4/download", result.ProxyURL)
+	assert.Equal(t, "/api/v1/videos/20240101_120000/hls/master.m3u8", result.PlaylistURL)
+}
+
+func newMultipartVideoRequest(t *testing.T, filename string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", filename)
+	require.NoError(t, err)
+	part.Write([]byte("fake video content"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/videos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCreateVideo_ShouldStageUploadAndProcessByKey(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	uploads := storage.NewMemory()
+	outputs := storage.NewMemory()
+	outputs.URLPrefix = "https://outputs.example.com/"
+	handlers.config.StageUploads = true
+	handlers.config.Uploads = uploads
+	handlers.config.Outputs = outputs
+
+	var processedKey string
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFromS3Func: func(key, options string) (*models.ProcessingResult, error) {
+			processedKey = key
+			return &models.ProcessingResult{Success: true, ZipPath: "frames_test.zip"}, nil
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.True(t, strings.HasSuffix(processedKey, "_test.mp4"))
+
+	_, err := uploads.Stat(processedKey)
+	assert.NoError(t, err)
+
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "https://outputs.example.com/frames_test.zip", response.DownloadURL)
+}
+
+func TestCreateVideo_ShouldRemoveStagedUploadWhenProcessorFails(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	uploads := storage.NewMemory()
+	handlers.config.StageUploads = true
+	handlers.config.Uploads = uploads
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFromS3Func: func(key, options string) (*models.ProcessingResult, error) {
+			return nil, fmt.Errorf("processor unreachable")
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	objects, err := uploads.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestGetVideoDownload_ShouldReturnDirectURLWhenStorageSupportsIt(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	outputs := storage.NewMemory()
+	outputs.URLPrefix = "https://outputs.example.com/"
+	require.NoError(t, outputs.Put("frames_test.zip", strings.NewReader("zip"), "application/zip"))
+	handlers.config.Outputs = outputs
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_test.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_test.zip/download?redirect=true", http.NoBody)
+
+	handlers.GetVideoDownload(c)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://outputs.example.com/frames_test.zip", w.Header().Get("Location"))
+}
+
+func TestGetVideoDownload_ShouldStreamFromNonLocalStorage(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	outputs := storage.NewMemory()
+	require.NoError(t, outputs.Put("frames_test.zip", strings.NewReader("zip-bytes"), "application/zip"))
+	handlers.config.Outputs = outputs
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_test.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_test.zip/download", http.NoBody)
+
+	handlers.GetVideoDownload(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Equal(t, "zip-bytes", w.Body.String())
+}
+
+func TestGetVideoDownload_ShouldDecryptEncryptedOutputs(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	keyring, err := storage.NewKeyring(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	outputs := storage.NewEncrypted(storage.NewFilesystem(handlers.config.OutputsDir), keyring)
+	require.NoError(t, outputs.Put("frames_test.zip", strings.NewReader("zip-bytes"), "application/zip"))
+	handlers.config.Outputs = outputs
+
+	onDisk, err := os.ReadFile(filepath.Join(handlers.config.OutputsDir, "frames_test.zip"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(onDisk), "zip-bytes")
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_test.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_test.zip/download", http.NoBody)
+
+	handlers.GetVideoDownload(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "9", w.Header().Get("Content-Length"))
+	assert.Equal(t, "zip-bytes", w.Body.String())
+}
+
+func TestGetVideos_ShouldListOnlyTopLevelArchives(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	outputs := storage.NewMemory()
+	for _, key := range []string{"frames_1.zip", "clip_1_01.mp4", "hls_1/master.m3u8", "nested/frames_2.zip"} {
+		require.NoError(t, outputs.Put(key, strings.NewReader("x"), ""))
+	}
+	handlers.config.Outputs = outputs
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos", http.NoBody)
+
+	handlers.GetVideos(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Videos []map[string]interface{} `json:"videos"`
+		Total  int                      `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Total)
+	assert.Equal(t, "frames_1.zip", response.Videos[0]["filename"])
+	assert.Equal(t, "/api/v1/videos/frames_1.zip/download", response.Videos[0]["download_url"])
+}
+
+func TestGetVideos_ShouldIncludeStoredMetadata(t *testing.T) {
+	for name, outputs := range map[string]storage.Storage{"filesystem": storage.NewFilesystem(t.TempDir()), "memory": storage.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			handlers, cleanup := setupTestHandlers()
+			defer cleanup()
+
+			require.NoError(t, storage.PutWithMetadata(outputs, "frames_1.zip", strings.NewReader("x"), "application/zip", map[string]string{
+				storage.MetaOriginalName: "entrevista.mp4",
+				storage.MetaSourceHash:   "abc123",
+				storage.MetaFrameCount:   "42",
+				storage.MetaOptions:      `{"proxy":true}`,
+				storage.MetaOwner:        "acme",
+			}))
+			require.NoError(t, outputs.Put("frames_2.zip", strings.NewReader("x"), "application/zip"))
+			handlers.config.Outputs = outputs
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/videos", http.NoBody)
+
+			handlers.GetVideos(c)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var response struct {
+				Videos []map[string]interface{} `json:"videos"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Videos, 2)
+
+			described := response.Videos[0]
+			assert.Equal(t, "entrevista.mp4", described["original_name"])
+			assert.Equal(t, "abc123", described["source_hash"])
+			assert.Equal(t, float64(42), described["frame_count"])
+			assert.Equal(t, map[string]interface{}{"proxy": true}, described["options"])
+			assert.Equal(t, "acme", described["owner"])
+
+			assert.NotContains(t, response.Videos[1], "original_name", "archives without metadata are still listed")
+		})
+	}
+}
+
+func TestGetVideos_ShouldPaginateWithCursor(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	outputs := storage.NewMemory()
+	for _, key := range []string{"clip_1_01.mp4", "frames_1.zip", "frames_2.zip", "frames_3.zip", "hls_1/master.m3u8", "proxy_1.mp4"} {
+		require.NoError(t, outputs.Put(key, strings.NewReader("x"), ""))
+	}
+	handlers.config.Outputs = outputs
+
+	fetch := func(query string) map[string]interface{} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/videos?"+query, http.NoBody)
+
+		handlers.GetVideos(c)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	filenames := func(response map[string]interface{}) []string {
+		var names []string
+		for _, video := range response["videos"].([]interface{}) {
+			names = append(names, video.(map[string]interface{})["filename"].(string))
+		}
+		return names
+	}
+
+	first := fetch("limit=2")
+	assert.Equal(t, []string{"frames_1.zip", "frames_2.zip"}, filenames(first))
+	cursor, ok := first["next_cursor"].(string)
+	require.True(t, ok)
+
+	second := fetch("limit=2&cursor=" + cursor)
+	assert.Equal(t, []string{"frames_3.zip"}, filenames(second))
+	assert.NotContains(t, second, "next_cursor")
+}
+
+func TestGetVideos_ShouldRejectInvalidPaginationParameters(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	for _, query := range []string{"limit=0", "limit=abc", "cursor=***"} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/videos?"+query, http.NoBody)
+
+		handlers.GetVideos(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestParsePageLimit_ShouldDefaultAndCap(t *testing.T) {
+	limit, err := ParsePageLimit("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPageSize, limit)
+
+	limit, err = ParsePageLimit("5000")
+	require.NoError(t, err)
+	assert.Equal(t, MaxPageSize, limit)
+}
+
+func TestCursor_ShouldRoundTrip(t *testing.T) {
+	key, err := DecodeCursor(EncodeCursor("frames_20240101_120000.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, "frames_20240101_120000.zip", key)
+}
+
+func newVideoUploadRequest(t *testing.T, filename string, size int) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", filename)
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte{0}, size))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/v1/videos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCreateVideo_ShouldRejectVideosAboveMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.MaxUploadSize = 1024
+
+	called := false
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			called = true
+			return &models.ProcessingResult{Success: true}, nil
+		},
+	}
+
+	// The video part is too large although the whole request fits the multipart allowance.
+	smallRequest := newVideoUploadRequest(t, "test.mp4", 4096)
+
+	// The declared length alone is enough to refuse the request.
+	declaredRequest := newVideoUploadRequest(t, "test.mp4", 2*multipartOverhead)
+
+	// Without a declared length the body is cut off while it is read.
+	chunkedRequest := newVideoUploadRequest(t, "test.mp4", 2*multipartOverhead)
+	chunkedRequest.ContentLength = -1
+
+	for name, req := range map[string]*http.Request{"part": smallRequest, "declared": declaredRequest, "chunked": chunkedRequest} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		handlers.CreateVideo(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, name)
+		var response models.ProcessingResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), name)
+		assert.Contains(t, response.Message, "tamanho máximo", name)
+	}
+	assert.False(t, called)
+}
+
+func TestCreateVideo_ShouldPassOnTooLargeFromProcessor(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			return nil, &clients.ProcessorError{StatusCode: http.StatusRequestEntityTooLarge, Message: "limite de 10 bytes"}
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newVideoUploadRequest(t, "test.mp4", 64)
+
+	handlers.CreateVideo(c)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "limite de 10 bytes")
+}
+
+func TestDeleteVideo_ShouldKeepArchiveSharedByDeduplicatedJobs(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	archive := filepath.Join(handlers.config.OutputsDir, "frames_20240101_120000.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("zip"), 0644))
+	_, err := handlers.dedup.Record(&dedup.Entry{Digest: "d1", VideoID: "20240101_120000", Result: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	_, err = handlers.dedup.Acquire("d1")
+	require.NoError(t, err)
+
+	deleteArchive := func() int {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{gin.Param{Key: "filename", Value: "frames_20240101_120000.zip"}}
+		handlers.DeleteVideo(c)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, deleteArchive())
+	assert.FileExists(t, archive)
+
+	assert.Equal(t, http.StatusNoContent, deleteArchive())
+	assert.NoFileExists(t, archive)
+}
+
+// failingDeletes is an outputs store whose deletes fail.
+type failingDeletes struct {
+	storage.Storage
+}
+
+func (failingDeletes) Delete(key string) error {
+	return errors.New("storage unavailable")
+}
+
+func TestDeleteVideo_ShouldKeepReferenceWhenArchiveCannotBeDeleted(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	archive := filepath.Join(handlers.config.OutputsDir, "frames_20240101_120000.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("zip"), 0644))
+	_, err := handlers.dedup.Record(&dedup.Entry{Digest: "d1", VideoID: "20240101_120000", Result: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	handlers.config.Outputs = failingDeletes{handlers.config.Outputs}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{gin.Param{Key: "filename", Value: "frames_20240101_120000.zip"}}
+	handlers.DeleteVideo(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Erro ao deletar arquivo")
+	assert.FileExists(t, archive)
+	_, err = handlers.dedup.Acquire("d1")
+	assert.NoError(t, err, "the archive still exists, so its dedup entry stays")
+}
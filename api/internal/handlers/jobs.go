@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/checksum"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobsPath is where the status of a job is polled, followed by its ID.
+const jobsPath = "/api/v1/jobs/"
+
+// jobResponse is a job as the API returns it, with a direct URL to its frames.
+type jobResponse struct {
+	*jobs.Job
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// acceptVideo stores the video in uploads storage under a new job, dispatches the job
+// and answers 202 with it. A video that does not match the checksum the client declared
+// is rejected before it is stored.
+func (ah *APIHandlers) acceptVideo(c *gin.Context, file io.Reader, filename, options, declared string) {
+	key := newUploadKey(filename)
+	ah = ah.startJob(key, filename, options, declared)
+	if ah.jobID == "" {
+		respondJobNotRecorded(c)
+		return
+	}
+
+	var metadata map[string]string
+	if declared != "" {
+		metadata = map[string]string{storage.MetaChecksum: declared}
+	}
+	hashed := checksum.NewReader(file, "")
+	if err := storage.PutWithMetadata(ah.config.Uploads, key, hashed, "", metadata); err != nil {
+		ah.finishJob(nil, err)
+		if errors.Is(err, checksum.ErrMismatch) {
+			respondChecksumMismatch(c, hashed.Sum())
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
+			Success: false,
+			Message: "Erro ao armazenar o vídeo: " + err.Error(),
+			JobID:   ah.jobID,
+		})
+		return
+	}
+
+	ah.queueJob(c, key, filename, options, hashed.Sum())
+}
+
+// queueJob dispatches the job the handlers are bound to for an upload already in
+// uploads storage and answers 202 with it, or 503 after removing the upload when the
+// job cannot be dispatched.
+func (ah *APIHandlers) queueJob(c *gin.Context, key, filename, options, sha256 string) {
+	if err := ah.dispatchJob(key, filename, options, sha256); err != nil {
+		ah.finishJob(nil, err)
+		if cleanupErr := ah.config.Uploads.Delete(key); cleanupErr != nil {
+			log.Printf("Warning: Failed to cleanup staged video %s: %v", key, cleanupErr)
+		}
+		c.JSON(http.StatusServiceUnavailable, models.ProcessingResult{
+			Success: false,
+			Message: "Fila de processamento indisponível: " + err.Error(),
+			JobID:   ah.jobID,
+		})
+		return
+	}
+
+	job, err := ah.config.Jobs.Get(ah.jobID)
+	if err != nil {
+		log.Printf("Warning: Failed to read job %s: %v", ah.jobID, err)
+		job = &jobs.Job{ID: ah.jobID, Status: jobs.StatusQueued}
+	}
+	log.Printf("Video %s accepted as job %s", key, ah.jobID)
+
+	c.Header("Location", jobsPath+ah.jobID)
+	c.JSON(http.StatusAccepted, jobResponse{Job: job})
+}
+
+// respondJobNotRecorded answers a video that cannot be accepted because its job could
+// not be written.
+func respondJobNotRecorded(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, models.ProcessingResult{
+		Success: false,
+		Message: "Erro ao registrar o job de processamento",
+	})
+}
+
+// dispatchJob hands a stored upload to the processor: as a message on the job queue
+// when there is one, or over HTTP in the background.
+func (ah *APIHandlers) dispatchJob(key, filename, options, sha256 string) error {
+	if ah.config.Queue == nil {
+		go ah.runJob(key, filename, options, sha256)
+		return nil
+	}
+	return ah.config.Queue.Enqueue(queue.Message{
+		JobID:      ah.jobID,
+		Tenant:     ah.tenant,
+		SourceKey:  key,
+		SourceName: filename,
+		Options:    options,
+		SHA256:     sha256,
+	})
+}
+
+// runJob processes a stored upload for the job the handlers are bound to, once one of
+// the job slots is free. The job stays queued until then.
+func (ah *APIHandlers) runJob(key, filename, options, sha256 string) {
+	ah.jobSlots <- struct{}{}
+	defer func() { <-ah.jobSlots }()
+
+	if _, err := ah.config.Jobs.Update(ah.jobID, jobs.Update{Status: jobs.StatusProcessing}, time.Now()); err != nil {
+		log.Printf("Warning: Failed to update job %s: %v", ah.jobID, err)
+	}
+	result, err := ah.processUploadedVideo(key, filename, options, sha256)
+	ah.finishJob(result, err)
+}
+
+// RecoverJobs dispatches again the jobs a previous run of the API left queued or
+// processing in the background, oldest first, and fails those whose upload is gone.
+// Jobs on a job queue outlive restarts by themselves and are left to it. Only a job
+// store private to this process is recovered: the unfinished jobs of a shared one may
+// be running on another instance.
+func (ah *APIHandlers) RecoverJobs() error {
+	if ah.config.Jobs == nil || ah.config.Queue != nil || !jobs.Exclusive(ah.config.Jobs) {
+		return nil
+	}
+
+	var interrupted []jobs.Job
+	for _, status := range []string{jobs.StatusQueued, jobs.StatusProcessing} {
+		listed, err := ah.config.Jobs.ListByStatus(status, 0)
+		if err != nil {
+			return fmt.Errorf("failed to list %s jobs: %w", status, err)
+		}
+		interrupted = append(interrupted, listed...)
+	}
+	interrupted = jobs.NewestFirst(interrupted, 0)
+
+	for i := len(interrupted) - 1; i >= 0; i-- {
+		ah.recoverJob(&interrupted[i])
+	}
+	if len(interrupted) > 0 {
+		log.Printf("Recovered %d interrupted jobs", len(interrupted))
+	}
+	return nil
+}
+
+func (ah *APIHandlers) recoverJob(job *jobs.Job) {
+	scoped, err := ah.withTenant(job.Tenant)
+	if err != nil {
+		ah.forJob(job.ID).finishJob(nil, errors.New("Tenant inválido: use letras minúsculas, números e hífens"))
+		return
+	}
+	scoped = scoped.forJob(job.ID)
+
+	if _, err := scoped.config.Uploads.Stat(job.SourceKey); err != nil {
+		log.Printf("Warning: Failed to recover job %s: upload %s: %v", job.ID, job.SourceKey, err)
+		scoped.finishJob(nil, errors.New("Processamento interrompido: o vídeo enviado não está mais disponível"))
+		return
+	}
+	go scoped.runJob(job.SourceKey, job.SourceName, job.Options, job.SHA256)
+}
+
+// GetJob returns the status of a job, every status it went through and, once it
+// completed, a summary of its outputs.
+func (ah *APIHandlers) GetJob(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !jobs.ValidID(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de job inválido"})
+		return
+	}
+	if ah.config.Jobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+
+	job, err := ah.config.Jobs.Get(id)
+	// Jobs of other tenants are answered as missing, like their outputs.
+	if errors.Is(err, jobs.ErrNotFound) || (err == nil && job.Tenant != ah.tenant) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao consultar job: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ah.jobResponse(job))
+}
+
+// GetJobs lists the most recent jobs, newest first, one page at a time. The status
+// parameter, repeated or comma-separated, keeps jobs in those statuses; without it jobs
+// in any status are listed. The opaque next_cursor of a response is passed back as
+// cursor to fetch the following page.
+func (ah *APIHandlers) GetJobs(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	limit, err := ParsePageLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	after, err := DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses, ok := jobStatuses(c.QueryArray("status"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Status inválido: use queued, processing, completed ou failed",
+		})
+		return
+	}
+
+	var found []jobs.Job
+	var next string
+	if ah.config.Jobs != nil {
+		found, next, err = ah.config.Jobs.ListByTenant(ah.tenant, statuses, limit, after)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar jobs: " + err.Error()})
+			return
+		}
+	}
+
+	results := make([]jobResponse, 0, len(found))
+	for i := range found {
+		results = append(results, ah.jobResponse(&found[i]))
+	}
+
+	response := gin.H{
+		"jobs":  results,
+		"total": len(results),
+		"limit": limit,
+	}
+	if next != "" {
+		response["next_cursor"] = EncodeCursor(next)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// jobStatuses reads the status filter; an empty filter selects every status.
+func jobStatuses(values []string) ([]string, bool) {
+	all := []string{jobs.StatusQueued, jobs.StatusProcessing, jobs.StatusCompleted, jobs.StatusFailed}
+
+	seen := map[string]bool{}
+	var statuses []string
+	for _, value := range values {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" || seen[status] {
+				continue
+			}
+			if !jobs.ValidStatus(status) {
+				return nil, false
+			}
+			seen[status] = true
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return all, true
+	}
+	return statuses, true
+}
+
+// jobResponse points a completed job at the URL its frames are downloaded from, as
+// processing results are when the storage hands out direct links.
+func (ah *APIHandlers) jobResponse(job *jobs.Job) jobResponse {
+	response := jobResponse{Job: job}
+	if job.Status == jobs.StatusCompleted && job.Result != nil && job.Result.ZipPath != "" {
+		result := models.ProcessingResult{ZipPath: job.Result.ZipPath}
+		ah.fillOutputURLs(&result)
+		response.DownloadURL = result.DownloadURL
+	}
+	return response
+}
+
+// startJob records a queued job for a video about to be processed and returns handlers
+// bound to it, whose processor requests name the job so the processor can update it.
+// Processing goes on untracked when no job store is configured or the record cannot be
+// written.
+func (ah *APIHandlers) startJob(sourceKey, sourceName, options, sha256 string) *APIHandlers {
+	if ah.config.Jobs == nil {
+		return ah
+	}
+
+	id, err := jobs.NewID()
+	if err != nil {
+		log.Printf("Warning: Failed to create job ID: %v", err)
+		return ah
+	}
+	job := jobs.New(id, time.Now())
+	job.Tenant = ah.tenant
+	job.Owner = optionsOwner(options)
+	job.SourceKey = sourceKey
+	job.SourceName = sourceName
+	job.Options = options
+	job.SHA256 = sha256
+	if err := ah.config.Jobs.Create(job); err != nil {
+		log.Printf("Warning: Failed to record job for %s: %v", sourceName, err)
+		return ah
+	}
+	return ah.forJob(id)
+}
+
+// forJob returns handlers bound to the job with id.
+func (ah *APIHandlers) forJob(id string) *APIHandlers {
+	scoped := *ah
+	scoped.processorClient = ah.processorClient.ForJob(id)
+	scoped.jobID = id
+	return &scoped
+}
+
+// finishJob closes the job with the outcome of processing, unless the processor already
+// did, and stamps result with the job ID.
+func (ah *APIHandlers) finishJob(result *models.ProcessingResult, err error) {
+	if ah.jobID == "" {
+		return
+	}
+	if result != nil {
+		result.JobID = ah.jobID
+	}
+
+	job, getErr := ah.config.Jobs.Get(ah.jobID)
+	if getErr != nil {
+		log.Printf("Warning: Failed to read job %s: %v", ah.jobID, getErr)
+		return
+	}
+	if jobs.Terminal(job.Status) {
+		return
+	}
+
+	update := jobs.Update{Status: jobs.StatusFailed}
+	switch {
+	case err != nil:
+		update.Error = err.Error()
+	case !result.Success:
+		update.Error = result.Message
+	default:
+		update.Status = jobs.StatusCompleted
+		update.Result = jobResult(result)
+	}
+	if _, err := ah.config.Jobs.Update(ah.jobID, update, time.Now()); err != nil && !errors.Is(err, jobs.ErrInvalidTransition) {
+		log.Printf("Warning: Failed to update job %s: %v", ah.jobID, err)
+	}
+}
+
+// jobResult summarizes a processing result for the job record.
+func jobResult(result *models.ProcessingResult) *jobs.Result {
+	return &jobs.Result{
+		Message:        result.Message,
+		VideoID:        result.VideoID,
+		ZipPath:        result.ZipPath,
+		FrameCount:     result.FrameCount,
+		Clips:          len(result.Clips),
+		ProxyPath:      result.ProxyPath,
+		PlaylistPath:   result.PlaylistPath,
+		Deduplicated:   result.Deduplicated,
+		SourceChecksum: result.SourceChecksum,
+	}
+}
+
+// jobProcessingResult reads the processing result of an ended job back from its record.
+func jobProcessingResult(job *jobs.Job) *models.ProcessingResult {
+	result := &models.ProcessingResult{
+		Success: job.Status == jobs.StatusCompleted,
+		Message: job.Error,
+		JobID:   job.ID,
+	}
+	if job.Result != nil {
+		if result.Success {
+			result.Message = job.Result.Message
+		}
+		result.VideoID = job.Result.VideoID
+		result.ZipPath = job.Result.ZipPath
+		result.FrameCount = job.Result.FrameCount
+		result.ProxyPath = job.Result.ProxyPath
+		result.PlaylistPath = job.Result.PlaylistPath
+		result.Deduplicated = job.Result.Deduplicated
+		result.SourceChecksum = job.Result.SourceChecksum
+	}
+	return result
+}
+
+// optionsOwner reads the owner from the options JSON; invalid options have none.
+func optionsOwner(options string) string {
+	var parsed struct {
+		Owner string `json:"owner"`
+	}
+	if options == "" || json.Unmarshal([]byte(options), &parsed) != nil {
+		return ""
+	}
+	return parsed.Owner
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uploadWithOptions(t *testing.T, handlers *APIHandlers, options string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "test.mp4")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("fake video content"))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteField("options", options))
+	require.NoError(t, writer.Close())
+
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/videos", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	handlers.CreateVideo(c)
+	return w
+}
+
+func setupJobHandlers(t *testing.T) (*APIHandlers, *jobs.MemoryRepository) {
+	handlers, cleanup := setupTestHandlers()
+	t.Cleanup(cleanup)
+	repository := jobs.NewMemoryRepository()
+	handlers.config.Jobs = repository
+	return handlers, repository
+}
+
+func callJobHandler(handler gin.HandlerFunc, target, id string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	if id != "" {
+		c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+	}
+	handler(c)
+	return w
+}
+
+// waitForJob waits for the background processing of a job to end.
+func waitForJob(t *testing.T, repository jobs.JobRepository, id string) *jobs.Job {
+	var job *jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = repository.Get(id)
+		return err == nil && jobs.Terminal(job.Status)
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestCreateVideo_ShouldAcceptVideoAsJob(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	mockClient := &MockProcessorClient{}
+	handlers.processorClient = mockClient
+
+	w := uploadWithOptions(t, handlers, `{"owner":"equipe-x"}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var accepted struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	require.True(t, jobs.ValidID(accepted.ID))
+	assert.Equal(t, "/api/v1/jobs/"+accepted.ID, w.Header().Get("Location"))
+	assert.Equal(t, jobs.StatusQueued, accepted.Status)
+	assert.Equal(t, accepted.ID, mockClient.jobID, "the processor is told the job")
+
+	job := waitForJob(t, repository, accepted.ID)
+	assert.Equal(t, jobs.StatusCompleted, job.Status)
+	assert.Equal(t, "equipe-x", job.Owner)
+	assert.Equal(t, "test.mp4", job.SourceName)
+	assert.Equal(t, `{"owner":"equipe-x"}`, job.Options)
+	assert.Equal(t, []string{jobs.StatusQueued, jobs.StatusProcessing, jobs.StatusCompleted}, jobStatusHistory(job))
+
+	w = callJobHandler(handlers.GetJob, w.Header().Get("Location"), accepted.ID)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Status      string      `json:"status"`
+		Result      jobs.Result `json:"result"`
+		DownloadURL string      `json:"download_url"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, jobs.StatusCompleted, response.Status)
+	assert.Equal(t, "frames_test.zip", response.Result.ZipPath)
+	assert.Equal(t, 5, response.Result.FrameCount)
+	assert.Empty(t, response.DownloadURL, "the filesystem hands out no direct links")
+}
+
+func TestCreateVideo_ShouldRecordFailedJob(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			return &models.ProcessingResult{Success: false, Message: "Erro ao processar vídeo: formato inválido"}, nil
+		},
+	}
+
+	w := uploadWithOptions(t, handlers, `{}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var accepted struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+
+	job := waitForJob(t, repository, accepted.ID)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Contains(t, job.Error, "formato inválido")
+}
+
+func TestCreateVideo_ShouldRunBackgroundJobsWithinTheirSlots(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.jobSlots = make(chan struct{}, 1)
+	release := make(chan struct{})
+	running := make(chan struct{}, 2)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(_ string, video io.Reader, _ string) (*models.ProcessingResult, error) {
+			running <- struct{}{}
+			<-release
+			_, err := io.Copy(io.Discard, video)
+			require.NoError(t, err)
+			return &models.ProcessingResult{Success: true, ZipPath: "frames_test.zip"}, nil
+		},
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		w := uploadWithOptions(t, handlers, `{}`)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var accepted jobs.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+		ids = append(ids, accepted.ID)
+	}
+
+	<-running
+	select {
+	case <-running:
+		t.Fatal("a second job ran while the only slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	queued := 0
+	for _, id := range ids {
+		job, err := repository.Get(id)
+		require.NoError(t, err)
+		if job.Status == jobs.StatusQueued {
+			queued++
+		}
+	}
+	assert.Equal(t, 1, queued, "the waiting job stays queued")
+
+	close(release)
+	for _, id := range ids {
+		assert.Equal(t, jobs.StatusCompleted, waitForJob(t, repository, id).Status)
+	}
+}
+
+func TestRecoverJobs_ShouldResumeInterruptedJobs(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.processorClient = &MockProcessorClient{}
+	now := time.Now()
+
+	resumed := jobs.New("0123456789abcdef0123456789abcdef", now)
+	resumed.SourceKey = "20240101_120000-0a1b2c3d_video.mp4"
+	resumed.SourceName = "video.mp4"
+	require.NoError(t, repository.Create(resumed))
+	require.NoError(t, handlers.config.Uploads.Put(resumed.SourceKey, bytes.NewReader([]byte("video")), "video/mp4"))
+	_, err := repository.Update(resumed.ID, jobs.Update{Status: jobs.StatusProcessing}, now)
+	require.NoError(t, err)
+
+	lost := jobs.New("fedcba9876543210fedcba9876543210", now)
+	lost.SourceKey = "20240101_120000-0d0e0f10_gone.mp4"
+	require.NoError(t, repository.Create(lost))
+
+	require.NoError(t, handlers.RecoverJobs())
+
+	assert.Equal(t, jobs.StatusCompleted, waitForJob(t, repository, resumed.ID).Status)
+	job := waitForJob(t, repository, lost.ID)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Contains(t, job.Error, "não está mais disponível")
+}
+
+func TestRecoverJobs_ShouldKeepTheDeclaredChecksum(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(_ string, video io.Reader, _ string) (*models.ProcessingResult, error) {
+			if _, err := io.Copy(io.Discard, video); err != nil {
+				return nil, err
+			}
+			return &models.ProcessingResult{Success: true}, nil
+		},
+	}
+
+	job := jobs.New("0123456789abcdef0123456789abcdef", time.Now())
+	job.SourceKey = "20240101_120000-0a1b2c3d_video.mp4"
+	job.SourceName = "video.mp4"
+	job.SHA256 = strings.Repeat("0", 64)
+	require.NoError(t, repository.Create(job))
+	require.NoError(t, handlers.config.Uploads.Put(job.SourceKey, bytes.NewReader([]byte("video")), "video/mp4"))
+
+	require.NoError(t, handlers.RecoverJobs())
+
+	recovered := waitForJob(t, repository, job.ID)
+	assert.Equal(t, jobs.StatusFailed, recovered.Status)
+	assert.Contains(t, recovered.Error, "checksum")
+}
+
+// sharedRepository stands for a job store other API instances also use.
+type sharedRepository struct {
+	*jobs.MemoryRepository
+}
+
+func TestRecoverJobs_ShouldLeaveJobsOfSharedStoresAlone(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.config.Jobs = sharedRepository{repository}
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			t.Error("a job another instance may be running was processed again")
+			return nil, errors.New("unexpected")
+		},
+	}
+	job := jobs.New("0123456789abcdef0123456789abcdef", time.Now())
+	job.SourceKey = "20240101_120000-0a1b2c3d_video.mp4"
+	require.NoError(t, repository.Create(job))
+	require.NoError(t, handlers.config.Uploads.Put(job.SourceKey, bytes.NewReader([]byte("video")), "video/mp4"))
+
+	require.NoError(t, handlers.RecoverJobs())
+
+	stored, err := repository.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusQueued, stored.Status)
+}
+
+func TestRecoverJobs_ShouldLeaveQueuedJobsToTheQueue(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.config.Queue = &recordingProducer{}
+	require.NoError(t, repository.Create(jobs.New("0123456789abcdef0123456789abcdef", time.Now())))
+
+	require.NoError(t, handlers.RecoverJobs())
+
+	job, err := repository.Get("0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusQueued, job.Status)
+}
+
+func TestGetJob_ShouldRejectInvalidAndUnknownIDs(t *testing.T) {
+	handlers, _ := setupJobHandlers(t)
+
+	w := callJobHandler(handlers.GetJob, "/api/v1/jobs/..", "..")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = callJobHandler(handlers.GetJob, "/api/v1/jobs/x", "0123456789abcdef0123456789abcdef")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetJob_ShouldReportProgressOfRunningJobs(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	id := "0123456789abcdef0123456789abcdef"
+	require.NoError(t, repository.Create(jobs.New(id, time.Now())))
+	_, err := repository.Update(id, jobs.Update{Status: jobs.StatusProcessing}, time.Now())
+	require.NoError(t, err)
+	_, err = repository.Update(id, jobs.Update{Progress: &jobs.Progress{Stage: jobs.StageArchiving, Frames: 30}}, time.Now())
+	require.NoError(t, err)
+
+	w := callJobHandler(handlers.GetJob, "/api/v1/jobs/"+id, id)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, &jobs.Progress{Stage: jobs.StageArchiving, Frames: 30}, response.Progress)
+}
+
+func TestGetJobs_ShouldFilterByStatus(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		require.NoError(t, repository.Create(jobs.New(id, start.Add(time.Duration(i)*time.Minute))))
+	}
+	_, err := repository.Update("b", jobs.Update{Status: jobs.StatusFailed, Error: "falhou"}, start)
+	require.NoError(t, err)
+
+	list := func(target string) []string {
+		w := callJobHandler(handlers.GetJobs, target, "")
+		require.Equal(t, http.StatusOK, w.Code, target)
+		var response struct {
+			Jobs []struct {
+				ID string `json:"id"`
+			} `json:"jobs"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		ids := []string{}
+		for _, job := range response.Jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"c", "b", "a"}, list("/api/v1/jobs"))
+	assert.Equal(t, []string{"b"}, list("/api/v1/jobs?status=failed"))
+	assert.Equal(t, []string{"c", "b"}, list("/api/v1/jobs?status=queued,failed&limit=2"))
+	assert.Equal(t, []string{"c", "a"}, list("/api/v1/jobs?status=queued&status=processing"))
+
+	w := callJobHandler(handlers.GetJobs, "/api/v1/jobs?status=done", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetJobs_ShouldPageWithCursor(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		require.NoError(t, repository.Create(jobs.New(id, start.Add(time.Duration(i)*time.Minute))))
+	}
+
+	type page struct {
+		Jobs []struct {
+			ID string `json:"id"`
+		} `json:"jobs"`
+		NextCursor string `json:"next_cursor"`
+	}
+	list := func(target string) page {
+		w := callJobHandler(handlers.GetJobs, target, "")
+		require.Equal(t, http.StatusOK, w.Code, target)
+		var response page
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	first := list("/api/v1/jobs?limit=2")
+	require.Len(t, first.Jobs, 2)
+	assert.Equal(t, "c", first.Jobs[0].ID)
+	assert.Equal(t, "b", first.Jobs[1].ID)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := list("/api/v1/jobs?limit=2&cursor=" + first.NextCursor)
+	require.Len(t, second.Jobs, 1)
+	assert.Equal(t, "a", second.Jobs[0].ID)
+	assert.Empty(t, second.NextCursor)
+
+	w := callJobHandler(handlers.GetJobs, "/api/v1/jobs?cursor=!!", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// recordingProducer keeps the messages enqueued on it, failing with err when set.
+type recordingProducer struct {
+	messages []queue.Message
+	err      error
+}
+
+func (p *recordingProducer) Enqueue(message queue.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func TestCreateVideo_ShouldEnqueueJobWhenQueueIsConfigured(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	producer := &recordingProducer{}
+	handlers.config.Queue = producer
+	handlers.processorClient = &MockProcessorClient{
+		healthCheckFunc: func() error { return errors.New("processor offline") },
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			t.Error("queued jobs must not be sent over HTTP")
+			return nil, errors.New("unexpected call")
+		},
+	}
+
+	w := uploadWithOptions(t, handlers, `{"proxy":true}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code, "the processor need not be up to queue a job")
+	require.Len(t, producer.messages, 1)
+	message := producer.messages[0]
+	assert.True(t, jobs.ValidID(message.JobID))
+	assert.Equal(t, "test.mp4", message.SourceName)
+	assert.Equal(t, `{"proxy":true}`, message.Options)
+	assert.Len(t, message.SHA256, 64)
+	_, err := handlers.config.Uploads.Stat(message.SourceKey)
+	assert.NoError(t, err, "the processor fetches the video from uploads storage")
+
+	job, err := repository.Get(message.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusQueued, job.Status)
+	assert.Equal(t, message.SourceKey, job.SourceKey)
+}
+
+func TestCreateVideo_ShouldFailJobWhenQueueIsUnavailable(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.config.Queue = &recordingProducer{err: errors.New("queue does not exist")}
+	handlers.processorClient = &MockProcessorClient{}
+
+	w := uploadWithOptions(t, handlers, `{}`)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	job, err := repository.Get(response.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	_, err = handlers.config.Uploads.Stat(job.SourceKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "the staged video is removed")
+}
+
+func jobStatusHistory(job *jobs.Job) []string {
+	var statuses []string
+	for _, transition := range job.Transitions {
+		statuses = append(statuses, transition.Status)
+	}
+	return statuses
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"video-processor/api/internal/clients"
+	"video-processor/internal/checksum"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+)
+
+// ProcessQueuedJob runs a job taken off the embedded job queue, sending its upload to
+// the processor over HTTP as a background job would be. When the processor cannot be
+// reached or fails on its side the upload is kept and the message left to be retried;
+// any other outcome ends the job.
+func (ah *APIHandlers) ProcessQueuedJob(_ context.Context, delivery *queue.Delivery) error {
+	message := delivery.Message
+	scoped, err := ah.withTenant(message.Tenant)
+	if err != nil {
+		log.Printf("Warning: Rejected queued job %s: invalid tenant %q", message.JobID, message.Tenant)
+		ah.forJob(message.JobID).finishJob(nil, errors.New("Tenant inválido: use letras minúsculas, números e hífens"))
+		return nil
+	}
+	scoped = scoped.forJob(message.JobID)
+
+	if _, err := scoped.config.Jobs.Update(scoped.jobID, jobs.Update{Status: jobs.StatusProcessing}, time.Now()); err != nil {
+		log.Printf("Warning: Failed to update job %s: %v", scoped.jobID, err)
+	}
+	result, err := scoped.sendUpload(message.SourceKey, message.SourceName, message.Options, message.SHA256)
+	if retryable(err) {
+		return err
+	}
+	scoped.releaseUpload(message.SourceKey, err)
+	scoped.finishJob(result, err)
+	log.Printf("Processed queued job %s (attempt %d)", message.JobID, delivery.Attempts)
+	return nil
+}
+
+// FailExhaustedJob ends failed a queued job whose last attempt failed, before its
+// message moves to the dead letters, and removes its upload.
+func (ah *APIHandlers) FailExhaustedJob(delivery *queue.Delivery, err error) {
+	scoped, tenantErr := ah.withTenant(delivery.Message.Tenant)
+	if tenantErr != nil {
+		return
+	}
+	scoped = scoped.forJob(delivery.Message.JobID)
+	scoped.releaseUpload(delivery.Message.SourceKey, err)
+	scoped.finishJob(nil, fmt.Errorf("%v (após %d tentativas)", err, delivery.Attempts))
+}
+
+// retryable reports whether processing failed for reasons another attempt may not meet:
+// the processor or the uploads store could not be reached, or the processor failed with
+// a server error. Rejected videos would fail again.
+func retryable(err error) bool {
+	if err == nil || errors.Is(err, checksum.ErrMismatch) {
+		return false
+	}
+	var procErr *clients.ProcessorError
+	if errors.As(err, &procErr) {
+		return procErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
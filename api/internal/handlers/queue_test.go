@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"video-processor/api/internal/clients"
+	"video-processor/api/internal/models"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// setupEmbeddedQueue has handlers enqueue jobs on a bolt queue and returns a worker
+// running them through ProcessQueuedJob.
+func setupEmbeddedQueue(t *testing.T, handlers *APIHandlers) (*queue.BoltQueue, *queue.Worker) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "jobs.db"), 0o600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	q, err := queue.NewBoltQueue(db, 3, time.Minute)
+	require.NoError(t, err)
+	handlers.config.Queue = q
+	return q, queue.NewWorker(q, handlers.ProcessQueuedJob, queue.Options{
+		Visibility:  time.Minute,
+		MaxAttempts: 3,
+		Exhausted:   handlers.FailExhaustedJob,
+	})
+}
+
+func TestProcessQueuedJob_ShouldRetryWhileProcessorIsUnreachable(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	q, worker := setupEmbeddedQueue(t, handlers)
+	calls := 0
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(_ string, video io.Reader, _ string) (*models.ProcessingResult, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("dial tcp 127.0.0.1:8082: connection refused")
+			}
+			_, err := io.Copy(io.Discard, video)
+			require.NoError(t, err)
+			return &models.ProcessingResult{Success: true, Message: "Processamento concluído", ZipPath: "frames_test.zip", FrameCount: 5}, nil
+		},
+	}
+
+	w := uploadWithOptions(t, handlers, `{}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	worker.Handle(context.Background(), delivery)
+
+	job, err := repository.Get(delivery.Message.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusProcessing, job.Status)
+	_, err = handlers.config.Uploads.Stat(delivery.Message.SourceKey)
+	assert.NoError(t, err, "the upload is kept for the next attempt")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	none, err := q.Receive(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, none, "the retry waits for the processor to come back")
+
+	// Let the retry delay pass.
+	require.NoError(t, q.Extend(delivery, 0))
+	retried, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, retried)
+	assert.Equal(t, 2, retried.Attempts)
+	worker.Handle(context.Background(), retried)
+
+	job, err = repository.Get(delivery.Message.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusCompleted, job.Status)
+	assert.Equal(t, "frames_test.zip", job.Result.ZipPath)
+	_, err = handlers.config.Uploads.Stat(delivery.Message.SourceKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "the streamed upload is removed once processed")
+}
+
+func TestProcessQueuedJob_ShouldFailRejectedVideosAtOnce(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	q, worker := setupEmbeddedQueue(t, handlers)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(string, io.Reader, string) (*models.ProcessingResult, error) {
+			return nil, &clients.ProcessorError{StatusCode: http.StatusRequestEntityTooLarge, Message: "Arquivo muito grande"}
+		},
+	}
+
+	w := uploadWithOptions(t, handlers, `{}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	worker.Handle(context.Background(), delivery)
+
+	job, err := repository.Get(delivery.Message.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Contains(t, job.Error, "Arquivo muito grande")
+	_, err = handlers.config.Uploads.Stat(delivery.Message.SourceKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Error(t, q.Ack(delivery), "the message was acknowledged")
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/dedup"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forTenant returns the handlers to serve a request with. When storage routing is
+// enabled they are a copy bound to the tenant named by the X-Tenant-ID header (the
+// default tenant without one): its uploads and outputs stores, its resumable uploads and
+// a processor client that forwards the tenant. An invalid tenant is answered with 400.
+func (ah *APIHandlers) forTenant(c *gin.Context) (*APIHandlers, bool) {
+	if router := ah.config.Router; router == nil || !router.Enabled() {
+		return ah, true
+	}
+
+	scoped, err := ah.withTenant(c.GetHeader(baseConfig.TenantHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant inválido: use letras minúsculas, números e hífens"})
+		return nil, false
+	}
+	return scoped, true
+}
+
+// withTenant returns the handlers bound to the tenant named tenant, as forTenant does
+// for requests, or an error when the name is invalid.
+func (ah *APIHandlers) withTenant(tenant string) (*APIHandlers, error) {
+	router := ah.config.Router
+	if router == nil || !router.Enabled() {
+		return ah, nil
+	}
+
+	tenant, err := router.Tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *ah.config
+	cfg.Uploads = router.Uploads(tenant)
+	cfg.Outputs = router.Outputs(tenant)
+	return &APIHandlers{
+		processorClient: ah.processorClient.ForTenant(tenant),
+		config:          &cfg,
+		resumable:       newResumableStore(&cfg),
+		resumableLocks:  ah.resumableLocks,
+		jobSlots:        ah.jobSlots,
+		dedup:           dedup.NewIndex(cfg.Outputs),
+		tenant:          tenant,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"video-processor/api/internal/models"
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTenantHandlers(t *testing.T) (handlers *APIHandlers, uploads, outputs *storage.Memory, cleanup func()) {
+	handlers, cleanup = setupTestHandlers()
+	uploads, outputs = storage.NewMemory(), storage.NewMemory()
+	router, err := storage.NewRouter(&baseConfig.RoutingConfig{KeyPrefix: "{tenant}/{yyyy}/{mm}/{job_id}/", DefaultTenant: "default"}, nil, nil, uploads, outputs)
+	require.NoError(t, err)
+	handlers.config.Uploads = uploads
+	handlers.config.Outputs = outputs
+	handlers.config.Router = router
+	return handlers, uploads, outputs, cleanup
+}
+
+func TestCreateVideo_ShouldStageUploadUnderTenantPrefix(t *testing.T) {
+	handlers, uploads, _, cleanup := setupTenantHandlers(t)
+	defer cleanup()
+	handlers.config.StageUploads = true
+
+	var processedKey string
+	mock := &MockProcessorClient{
+		processVideoFromS3Func: func(key, options string) (*models.ProcessingResult, error) {
+			processedKey = key
+			return &models.ProcessingResult{Success: true, ZipPath: "frames_test.zip"}, nil
+		},
+	}
+	handlers.processorClient = mock
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newMultipartVideoRequest(t, "test.mp4")
+	c.Request.Header.Set(baseConfig.TenantHeader, "acme")
+
+	handlers.CreateVideo(c)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "acme", mock.tenant, "the tenant is forwarded to the processor")
+	assert.True(t, strings.HasSuffix(processedKey, "_test.mp4"), "the processor gets the key without prefix")
+
+	jobID, at, ok := storage.JobID(processedKey)
+	require.True(t, ok)
+	_, err := uploads.Stat("acme/" + at.Format("2006/01") + "/" + jobID + "/" + processedKey)
+	assert.NoError(t, err)
+}
+
+func TestGetVideos_ShouldListOnlyTenantArchives(t *testing.T) {
+	handlers, _, outputs, cleanup := setupTenantHandlers(t)
+	defer cleanup()
+	require.NoError(t, outputs.Put("acme/2024/01/20240101_120000/frames_20240101_120000.zip", strings.NewReader("x"), ""))
+	require.NoError(t, outputs.Put("globex/2024/01/20240102_120000/frames_20240102_120000.zip", strings.NewReader("x"), ""))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos", http.NoBody)
+	c.Request.Header.Set(baseConfig.TenantHeader, "acme")
+
+	handlers.GetVideos(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Videos []map[string]interface{} `json:"videos"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Videos, 1)
+	assert.Equal(t, "frames_20240101_120000.zip", response.Videos[0]["filename"])
+}
+
+func TestGetVideoDownload_ShouldNotServeOtherTenantsOutputs(t *testing.T) {
+	handlers, _, outputs, cleanup := setupTenantHandlers(t)
+	defer cleanup()
+	require.NoError(t, outputs.Put("acme/2024/01/20240101_120000/frames_20240101_120000.zip", strings.NewReader("x"), ""))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "filename", Value: "frames_20240101_120000.zip"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos/frames_20240101_120000.zip/download", http.NoBody)
+	c.Request.Header.Set(baseConfig.TenantHeader, "globex")
+
+	handlers.GetVideoDownload(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestForTenant_ShouldRejectInvalidTenant(t *testing.T) {
+	handlers, _, _, cleanup := setupTenantHandlers(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/videos", http.NoBody)
+	c.Request.Header.Set(baseConfig.TenantHeader, "../acme")
+
+	handlers.GetVideos(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"video-processor/internal/storage"
+	"video-processor/internal/trash"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashBin returns the trash of the tenant the handlers serve.
+func (ah *APIHandlers) trashBin() *trash.Bin {
+	return trash.NewBin(ah.config.Outputs, ah.tenant)
+}
+
+// trashID names the trash item an output joins: the video it belongs to.
+func trashID(filename string) string {
+	if jobID, _, ok := storage.JobID(filename); ok {
+		return jobID
+	}
+	return VideoIDFromParam(filename)
+}
+
+// discard moves an output into the trash. A frames archive takes the other outputs of
+// its video along, so restoring it brings back the proxy, clips and HLS renditions.
+func (ah *APIHandlers) discard(filename string) error {
+	videoID := trashID(filename)
+	keys := []string{filename}
+	if filename == "frames_"+videoID+".zip" {
+		for _, prefix := range []string{"proxy_" + videoID, "clip_" + videoID, "hls_" + videoID + "/"} {
+			objects, err := ah.config.Outputs.List(prefix)
+			if err != nil {
+				return err
+			}
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+			}
+		}
+	}
+
+	now := time.Now()
+	item, err := ah.trashBin().Discard(videoID, keys, now, now.Add(ah.config.TrashRetention))
+	if err != nil {
+		return err
+	}
+	log.Printf("Moved %d outputs of %s to trash until %s", len(keys), videoID, item.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// GetTrash lists the deleted videos that can still be restored.
+func (ah *APIHandlers) GetTrash(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	items, err := ah.trashBin().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar lixeira: " + err.Error()})
+		return
+	}
+
+	results := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		results = append(results, trashItemResponse(item))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"videos":    results,
+		"total":     len(results),
+		"retention": ah.config.TrashRetention.String(),
+	})
+}
+
+// RestoreVideo moves a deleted video's outputs back out of the trash.
+func (ah *APIHandlers) RestoreVideo(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	videoID := VideoIDFromParam(c.Param("filename"))
+	if videoID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do vídeo é obrigatório"})
+		return
+	}
+
+	item, err := ah.trashBin().Restore(videoID)
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vídeo não encontrado na lixeira"})
+		return
+	case errors.Is(err, trash.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Já existem arquivos com o mesmo nome: " + err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao restaurar vídeo: " + err.Error()})
+		return
+	}
+
+	log.Printf("Restored %d outputs of %s from trash", len(item.Keys), videoID)
+	response := trashItemResponse(*item)
+	delete(response, "expires_at")
+	response["message"] = "Vídeo restaurado com sucesso"
+	c.JSON(http.StatusOK, response)
+}
+
+func trashItemResponse(item trash.Item) gin.H {
+	return gin.H{
+		"video_id":   item.VideoID,
+		"files":      item.Keys,
+		"size":       item.Size,
+		"deleted_at": item.DeletedAt.Format("2006-01-02 15:04:05"),
+		"expires_at": item.ExpiresAt.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTrashHandlers(t *testing.T) *APIHandlers {
+	handlers, cleanup := setupTestHandlers()
+	t.Cleanup(cleanup)
+	handlers.config.Outputs = storage.NewMemory()
+	handlers.config.TrashRetention = 7 * 24 * time.Hour
+	handlers.processorClient = &MockProcessorClient{}
+	return handlers
+}
+
+func callTrashHandler(handler gin.HandlerFunc, filename string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if filename != "" {
+		c.Params = gin.Params{gin.Param{Key: "filename", Value: filename}}
+	}
+	handler(c)
+	return w
+}
+
+func TestDeleteVideo_ShouldMoveOutputsToTrash(t *testing.T) {
+	handlers := setupTrashHandlers(t)
+	outputs := handlers.config.Outputs
+	for _, key := range []string{"frames_20240101_120000.zip", "proxy_20240101_120000.mp4", "hls_20240101_120000/master.m3u8", "frames_20240102_120000.zip"} {
+		require.NoError(t, outputs.Put(key, strings.NewReader(key), ""))
+	}
+
+	w := callTrashHandler(handlers.DeleteVideo, "frames_20240101_120000.zip")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	for _, key := range []string{"frames_20240101_120000.zip", "proxy_20240101_120000.mp4", "hls_20240101_120000/master.m3u8"} {
+		_, err := outputs.Stat(key)
+		assert.ErrorIs(t, err, storage.ErrNotFound, key)
+	}
+	_, err := outputs.Stat("frames_20240102_120000.zip")
+	assert.NoError(t, err, "other videos are untouched")
+	assert.Equal(t, []string{"20240101_120000"}, handlers.processorClient.(*MockProcessorClient).deletedFrames)
+
+	w = callTrashHandler(handlers.GetTrash, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Videos []struct {
+			VideoID string   `json:"video_id"`
+			Files   []string `json:"files"`
+		} `json:"videos"`
+		Total int `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 1, response.Total)
+	assert.Equal(t, "20240101_120000", response.Videos[0].VideoID)
+	assert.Len(t, response.Videos[0].Files, 3)
+
+	w = callTrashHandler(handlers.GetVideos, "")
+	assert.NotContains(t, w.Body.String(), "20240101_120000", "trashed archives are not listed")
+}
+
+func TestRestoreVideo_ShouldBringBackTrashedOutputs(t *testing.T) {
+	handlers := setupTrashHandlers(t)
+	outputs := handlers.config.Outputs
+	require.NoError(t, outputs.Put("frames_20240101_120000.zip", strings.NewReader("zip"), ""))
+	require.NoError(t, outputs.Put("proxy_20240101_120000.mp4", strings.NewReader("proxy"), ""))
+	require.Equal(t, http.StatusNoContent, callTrashHandler(handlers.DeleteVideo, "frames_20240101_120000.zip").Code)
+
+	w := callTrashHandler(handlers.RestoreVideo, "frames_20240101_120000.zip")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Vídeo restaurado com sucesso")
+	for _, key := range []string{"frames_20240101_120000.zip", "proxy_20240101_120000.mp4"} {
+		_, err := outputs.Stat(key)
+		assert.NoError(t, err, key)
+	}
+
+	w = callTrashHandler(handlers.RestoreVideo, "20240101_120000")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRestoreVideo_ShouldRefuseToOverwriteNewOutputs(t *testing.T) {
+	handlers := setupTrashHandlers(t)
+	outputs := handlers.config.Outputs
+	require.NoError(t, outputs.Put("frames_20240101_120000.zip", strings.NewReader("old"), ""))
+	require.Equal(t, http.StatusNoContent, callTrashHandler(handlers.DeleteVideo, "frames_20240101_120000.zip").Code)
+	require.NoError(t, outputs.Put("frames_20240101_120000.zip", strings.NewReader("new"), ""))
+
+	w := callTrashHandler(handlers.RestoreVideo, "20240101_120000")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
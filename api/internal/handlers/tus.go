@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"video-processor/api/internal/config"
+	"video-processor/api/internal/models"
+	"video-processor/api/internal/tus"
+	"video-processor/internal/checksum"
+	"video-processor/internal/jobs"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tusBasePath = "/api/v1/tus/"
+
+// newResumableStore keeps resumable uploads in multipart uploads when the uploads
+// storage can assemble them from parts, as S3 does, and in the temp directory otherwise.
+// Multipart uploads target the tenant's bucket and prefix, like any other upload.
+func newResumableStore(cfg *config.APIConfig) tus.Store {
+	if parts, ok := cfg.Uploads.(storage.PartUploader); ok {
+		return tus.NewS3Store(parts, cfg.Uploads)
+	}
+	return tus.NewFileStore(filepath.Join(cfg.TempDir, "tus"), cfg.Uploads)
+}
+
+// TusOptions answers tus discovery requests.
+func (ah *APIHandlers) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tus.Version)
+	c.Header("Tus-Version", tus.Version)
+	c.Header("Tus-Extension", tus.Extensions)
+	if ah.config.MaxUploadSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(ah.config.MaxUploadSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkTusResumable rejects requests from clients speaking another protocol version.
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tus.Version)
+	if c.GetHeader("Tus-Resumable") != tus.Version {
+		c.Header("Tus-Version", tus.Version)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Versão do protocolo tus não suportada"})
+		return false
+	}
+	return true
+}
+
+// CreateTusUpload starts a resumable upload (tus creation extension). The Upload-Metadata
+// header carries filename and, optionally, the processing options as JSON.
+func (ah *APIHandlers) CreateTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length inválido ou ausente"})
+		return
+	}
+	if ah.exceedsUploadLimit(length) {
+		c.Header("Tus-Max-Size", strconv.FormatInt(ah.config.MaxUploadSize, 10))
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ah.uploadTooLargeMessage()})
+		return
+	}
+
+	metadata, err := tus.ParseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata inválido: " + err.Error()})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if !IsValidVideoFile(filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de arquivo não suportado. Use: mp4, avi, mov, mkv"})
+		return
+	}
+	options := metadata["options"]
+	if options != "" && !json.Valid([]byte(options)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Opções de processamento inválidas: JSON malformado"})
+		return
+	}
+	sha256, ok := checksum.Normalize(metadata["sha256"])
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Checksum SHA-256 inválido: use 64 caracteres hexadecimais"})
+		return
+	}
+
+	id, err := tus.NewID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar upload: " + err.Error()})
+		return
+	}
+
+	upload := &tus.Upload{
+		ID:        id,
+		Key:       newUploadKey(filename),
+		Filename:  filepath.Base(filename),
+		Options:   options,
+		SHA256:    sha256,
+		Metadata:  c.GetHeader("Upload-Metadata"),
+		Tenant:    ah.tenant,
+		Length:    length,
+		Status:    tus.StatusUploading,
+		CreatedAt: time.Now(),
+	}
+	if err := ah.resumable.Create(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar upload: " + err.Error()})
+		return
+	}
+
+	log.Printf("Resumable upload %s created for %s (%d bytes)", id, upload.Key, length)
+	c.Header("Location", tusBasePath+id)
+	c.Status(http.StatusCreated)
+}
+
+// HeadTusUpload reports how many bytes of an upload have been received.
+func (ah *APIHandlers) HeadTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	upload, ok := ah.findTusUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload appends a chunk at Upload-Offset. The chunk that completes the upload
+// hands it to the processor in the background; GetTusUpload reports the outcome.
+func (ah *APIHandlers) PatchTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type deve ser application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset inválido ou ausente"})
+		return
+	}
+
+	lock := ah.resumableLock(c.Param("id"))
+	if !lock.TryLock() {
+		c.JSON(http.StatusLocked, gin.H{"error": "Upload em andamento em outra requisição"})
+		return
+	}
+	defer lock.Unlock()
+
+	upload, ok := ah.findTusUpload(c)
+	if !ok {
+		return
+	}
+	if upload.Status != tus.StatusUploading {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Upload já concluído"})
+		return
+	}
+	if offset != upload.Offset {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset não corresponde ao recebido pelo servidor"})
+		return
+	}
+
+	written, err := ah.resumable.WriteChunk(upload, c.Request.Body)
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if err != nil {
+		log.Printf("Warning: Resumable upload %s stopped at offset %d after %d bytes: %v", upload.ID, upload.Offset, written, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gravar dados do upload: " + err.Error()})
+		return
+	}
+
+	if upload.Finished() {
+		if err := ah.resumable.Finish(upload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao concluir upload: " + err.Error()})
+			return
+		}
+		upload.Status = tus.StatusProcessing
+		if err := ah.resumable.Save(upload); err != nil {
+			log.Printf("Warning: Failed to save resumable upload %s: %v", upload.ID, err)
+		}
+		log.Printf("Resumable upload %s completed: %s", upload.ID, upload.Key)
+		ah.resumableLocks.Delete(upload.ID)
+		go ah.processTusUpload(upload)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// processTusUpload processes a completed upload. With a job store the upload is
+// dispatched as a job, as CreateVideo does, and its result read from the job once it
+// ends; without one it is processed here and the result recorded.
+func (ah *APIHandlers) processTusUpload(upload *tus.Upload) {
+	ah = ah.startJob(upload.Key, upload.Filename, upload.Options, upload.SHA256)
+	if ah.jobID != "" {
+		upload.JobID = ah.jobID
+		err := ah.dispatchJob(upload.Key, upload.Filename, upload.Options, upload.SHA256)
+		if err == nil {
+			ah.saveTusUpload(upload)
+			return
+		}
+		ah.finishJob(nil, err)
+		if cleanupErr := ah.config.Uploads.Delete(upload.Key); cleanupErr != nil {
+			log.Printf("Warning: Failed to cleanup staged video %s: %v", upload.Key, cleanupErr)
+		}
+		ah.recordTusResult(upload, nil, fmt.Errorf("fila de processamento indisponível: %w", err))
+		return
+	}
+
+	result, err := ah.processUploadedVideo(upload.Key, upload.Filename, upload.Options, upload.SHA256)
+	ah.recordTusResult(upload, result, err)
+}
+
+// recordTusResult ends an upload with the outcome of its processing.
+func (ah *APIHandlers) recordTusResult(upload *tus.Upload, result *models.ProcessingResult, err error) {
+	if err != nil {
+		result = &models.ProcessingResult{Success: false, Message: "Erro ao processar vídeo: " + err.Error(), JobID: ah.jobID}
+	}
+
+	upload.Status = tus.StatusFailed
+	if result.Success {
+		ah.fillOutputURLs(result)
+		upload.Status = tus.StatusCompleted
+	}
+	upload.Result = result
+	ah.saveTusUpload(upload)
+}
+
+func (ah *APIHandlers) saveTusUpload(upload *tus.Upload) {
+	if err := ah.resumable.Save(upload); err != nil {
+		log.Printf("Warning: Failed to save result of resumable upload %s: %v", upload.ID, err)
+	}
+}
+
+// syncTusJob ends an upload whose job ended since it was dispatched, with the result
+// of the job.
+func (ah *APIHandlers) syncTusJob(upload *tus.Upload) {
+	if upload.Status != tus.StatusProcessing || upload.JobID == "" || ah.config.Jobs == nil {
+		return
+	}
+	job, err := ah.config.Jobs.Get(upload.JobID)
+	if err != nil {
+		log.Printf("Warning: Failed to read job %s: %v", upload.JobID, err)
+		return
+	}
+	if !jobs.Terminal(job.Status) {
+		return
+	}
+	ah.forJob(job.ID).recordTusResult(upload, jobProcessingResult(job), nil)
+}
+
+// GetTusUpload returns the state of an upload and, once processed, its result.
+func (ah *APIHandlers) GetTusUpload(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	upload, ok := ah.findTusUpload(c)
+	if !ok {
+		return
+	}
+	ah.syncTusJob(upload)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       upload.ID,
+		"job_id":   upload.JobID,
+		"key":      upload.Key,
+		"filename": upload.Filename,
+		"length":   upload.Length,
+		"offset":   upload.Offset,
+		"status":   upload.Status,
+		"result":   upload.Result,
+	})
+}
+
+// DeleteTusUpload discards an unfinished upload (tus termination extension).
+func (ah *APIHandlers) DeleteTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	lock := ah.resumableLock(c.Param("id"))
+	if !lock.TryLock() {
+		c.JSON(http.StatusLocked, gin.H{"error": "Upload em andamento em outra requisição"})
+		return
+	}
+	defer lock.Unlock()
+
+	upload, ok := ah.findTusUpload(c)
+	if !ok {
+		return
+	}
+	if upload.Status != tus.StatusUploading {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Upload já concluído"})
+		return
+	}
+
+	if err := ah.resumable.Terminate(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar upload: " + err.Error()})
+		return
+	}
+	ah.resumableLocks.Delete(upload.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// findTusUpload loads the upload named in the URL, answering 404/500 itself when it cannot.
+// Uploads of other tenants are not found.
+func (ah *APIHandlers) findTusUpload(c *gin.Context) (*tus.Upload, bool) {
+	upload, err := ah.resumable.Get(c.Param("id"))
+	if err == nil && upload.Tenant != ah.tenant {
+		// Upload state is shared; another tenant's uploads do not exist for this one.
+		err = tus.ErrNotFound
+	}
+	if errors.Is(err, tus.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload não encontrado"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar upload: " + err.Error()})
+		return nil, false
+	}
+	return upload, true
+}
+
+// resumableLock serializes writes to one upload within this instance.
+func (ah *APIHandlers) resumableLock(id string) *sync.Mutex {
+	lock, _ := ah.resumableLocks.LoadOrStore(id, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/api/internal/tus"
+	"video-processor/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tusRouter(handlers *APIHandlers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.OPTIONS("/api/v1/tus", handlers.TusOptions)
+	r.POST("/api/v1/tus", handlers.CreateTusUpload)
+	r.HEAD("/api/v1/tus/:id", handlers.HeadTusUpload)
+	r.PATCH("/api/v1/tus/:id", handlers.PatchTusUpload)
+	r.GET("/api/v1/tus/:id", handlers.GetTusUpload)
+	r.DELETE("/api/v1/tus/:id", handlers.DeleteTusUpload)
+	return r
+}
+
+func tusRequest(r *gin.Engine, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Tus-Resumable", tus.Version)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func tusMetadata(pairs ...string) string {
+	var encoded []string
+	for i := 0; i < len(pairs); i += 2 {
+		encoded = append(encoded, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+	}
+	return strings.Join(encoded, ",")
+}
+
+func patchHeaders(offset string) map[string]string {
+	return map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+}
+
+func TestTus_ShouldResumeUploadAndProcessIt(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.resumable = tus.NewFileStore(t.TempDir(), handlers.config.Uploads)
+
+	processed := make(chan string, 1)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(filename string, reader io.Reader, options string) (*models.ProcessingResult, error) {
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "video.mp4", filename)
+			assert.JSONEq(t, `{"fps":2}`, options)
+			processed <- string(data)
+			return &models.ProcessingResult{Success: true, ZipPath: "frames_test.zip"}, nil
+		},
+	}
+	r := tusRouter(handlers)
+
+	w := tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": tusMetadata("filename", "video.mp4", "options", `{"fps":2}`),
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/api/v1/tus/"))
+	assert.Equal(t, tus.Version, w.Header().Get("Tus-Resumable"))
+
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("01234"), patchHeaders("0"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	w = tusRequest(r, http.MethodHead, location, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "10", w.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("56789"), patchHeaders("0"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("56789"), patchHeaders("5"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Upload-Offset"))
+
+	select {
+	case data := <-processed:
+		assert.Equal(t, "0123456789", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload was not processed")
+	}
+
+	assert.Eventually(t, func() bool {
+		w = tusRequest(r, http.MethodGet, location, nil, nil)
+		var status struct {
+			Status string                   `json:"status"`
+			Result *models.ProcessingResult `json:"result"`
+		}
+		return json.Unmarshal(w.Body.Bytes(), &status) == nil && status.Status == tus.StatusCompleted && status.Result.Success
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("x"), patchHeaders("10"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestTus_ShouldProcessFinishedUploadsAsJobs(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	handlers.resumable = tus.NewFileStore(t.TempDir(), handlers.config.Uploads)
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFunc: func(_ string, reader io.Reader, _ string) (*models.ProcessingResult, error) {
+			_, err := io.Copy(io.Discard, reader)
+			require.NoError(t, err)
+			return &models.ProcessingResult{Success: true, Message: "Processamento concluído", ZipPath: "frames_test.zip", FrameCount: 3}, nil
+		},
+	}
+	r := tusRouter(handlers)
+
+	w := tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": tusMetadata("filename", "video.mp4"),
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("01234"), patchHeaders("0"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	var status struct {
+		Status string                   `json:"status"`
+		JobID  string                   `json:"job_id"`
+		Result *models.ProcessingResult `json:"result"`
+	}
+	require.Eventually(t, func() bool {
+		w = tusRequest(r, http.MethodGet, location, nil, nil)
+		return json.Unmarshal(w.Body.Bytes(), &status) == nil && status.Status == tus.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.True(t, jobs.ValidID(status.JobID))
+	job, err := repository.Get(status.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusCompleted, job.Status)
+	assert.True(t, status.Result.Success)
+	assert.Equal(t, "frames_test.zip", status.Result.ZipPath)
+	assert.Equal(t, 3, status.Result.FrameCount)
+	assert.Equal(t, status.JobID, status.Result.JobID)
+}
+
+func TestTus_ShouldAdvertiseProtocol(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	w := tusRequest(tusRouter(handlers), http.MethodOptions, "/api/v1/tus", nil, nil)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, tus.Version, w.Header().Get("Tus-Version"))
+	assert.Equal(t, tus.Extensions, w.Header().Get("Tus-Extension"))
+}
+
+func TestTus_ShouldRejectInvalidRequests(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.resumable = tus.NewFileStore(t.TempDir(), handlers.config.Uploads)
+	r := tusRouter(handlers)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tus", nil)
+	req.Header.Set("Upload-Length", "10")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Metadata": tusMetadata("filename", "video.mp4")})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Length": "10", "Upload-Metadata": tusMetadata("filename", "notes.txt")})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Length": "10", "Upload-Metadata": tusMetadata("filename", "video.mp4")})
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+
+	w = tusRequest(r, http.MethodPatch, location, strings.NewReader("01234"), map[string]string{"Content-Type": "application/octet-stream", "Upload-Offset": "0"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = tusRequest(r, http.MethodHead, "/api/v1/tus/0123456789abcdef0123456789abcdef", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTus_ShouldEnforceMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.resumable = tus.NewFileStore(t.TempDir(), handlers.config.Uploads)
+	handlers.config.MaxUploadSize = 10
+	r := tusRouter(handlers)
+
+	w := tusRequest(r, http.MethodOptions, "/api/v1/tus", nil, nil)
+	assert.Equal(t, "10", w.Header().Get("Tus-Max-Size"))
+
+	w = tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Length": "11", "Upload-Metadata": tusMetadata("filename", "video.mp4")})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Length": "10", "Upload-Metadata": tusMetadata("filename", "video.mp4")})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestTus_ShouldTerminateUnfinishedUploads(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.resumable = tus.NewFileStore(t.TempDir(), handlers.config.Uploads)
+	r := tusRouter(handlers)
+
+	w := tusRequest(r, http.MethodPost, "/api/v1/tus", nil, map[string]string{"Upload-Length": "10", "Upload-Metadata": tusMetadata("filename", "video.mp4")})
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+
+	w = tusRequest(r, http.MethodDelete, location, nil, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = tusRequest(r, http.MethodHead, location, nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	UploadURLExpiration = time.Hour
+
+	// Files above MultipartThreshold are uploaded in parts of at least DefaultPartSize,
+	// growing the part size when needed to stay within the S3 limit of 10,000 parts.
+	MultipartThreshold = 100 << 20
+	DefaultPartSize    = 64 << 20
+	MaxUploadParts     = 10000
+)
+
+// uploadKeyPattern matches keys issued by CreateUpload: <video ID>_<original name>.
+var uploadKeyPattern = regexp.MustCompile(`^\d{8}_\d{6}(-[0-9a-f]{8})?_[^/\\]+$`)
+
+// CreateUpload returns presigned requests that let the browser upload a video straight
+// to uploads storage. The client then calls CompleteUpload to start processing.
+func (ah *APIHandlers) CreateUpload(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	uploader, ok := ah.config.Uploads.(storage.DirectUploader)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Upload direto não suportado por este armazenamento. Use POST /api/v1/videos"})
+		return
+	}
+
+	var req models.UploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida: " + err.Error()})
+		return
+	}
+	if !IsValidVideoFile(req.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de arquivo não suportado. Use: mp4, avi, mov, mkv"})
+		return
+	}
+	if req.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tamanho do arquivo é obrigatório"})
+		return
+	}
+	if ah.exceedsUploadLimit(req.Size) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ah.uploadTooLargeMessage()})
+		return
+	}
+
+	key := newUploadKey(req.Filename)
+	target := models.UploadTarget{
+		Key:         key,
+		CompleteURL: "/api/v1/uploads/" + key + "/complete",
+		ExpiresAt:   time.Now().Add(UploadURLExpiration).Unix(),
+	}
+
+	if req.Size <= MultipartThreshold {
+		presigned, err := uploader.PresignPut(key, req.ContentType, UploadURLExpiration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar URL de upload: " + err.Error()})
+			return
+		}
+		target.Method = presigned.Method
+		target.URL = presigned.URL
+		target.Headers = presigned.Headers
+	} else {
+		partSize := PartSizeFor(req.Size)
+		partCount := int((req.Size + partSize - 1) / partSize)
+		upload, err := uploader.CreateMultipartUpload(key, req.ContentType, partCount, UploadURLExpiration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao iniciar upload multipart: " + err.Error()})
+			return
+		}
+		target.UploadID = upload.UploadID
+		target.PartSize = partSize
+		for _, part := range upload.Parts {
+			target.Parts = append(target.Parts, models.UploadPart{PartNumber: part.PartNumber, URL: part.URL})
+		}
+	}
+
+	log.Printf("Direct upload issued for %s (%d bytes)", key, req.Size)
+	c.JSON(http.StatusCreated, target)
+}
+
+// CompleteUpload finishes a direct upload, checks the stored object and processes it.
+// With a job store it answers 202 with the job tracking it, as CreateVideo does.
+func (ah *APIHandlers) CompleteUpload(c *gin.Context) {
+	ah, ok := ah.forTenant(c)
+	if !ok {
+		return
+	}
+
+	key := c.Param("key")
+	if !uploadKeyPattern.MatchString(key) || filepath.Base(key) != key || !IsValidVideoFile(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chave de upload inválida"})
+		return
+	}
+
+	var completion models.UploadCompletion
+	if err := c.ShouldBindJSON(&completion); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida: " + err.Error()})
+		return
+	}
+
+	options := ""
+	if len(completion.Options) > 0 && string(completion.Options) != "null" {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(completion.Options, &object); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Opções de processamento inválidas: JSON malformado"})
+			return
+		}
+		options = string(completion.Options)
+	}
+
+	sha256, ok := checksum.Normalize(completion.SHA256)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Checksum SHA-256 inválido: use 64 caracteres hexadecimais"})
+		return
+	}
+
+	if completion.UploadID != "" {
+		if !ah.completeMultipartUpload(c, key, completion) {
+			return
+		}
+	}
+
+	info, err := ah.config.Uploads.Stat(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload não encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar upload: " + err.Error()})
+		return
+	}
+	if info.Size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload vazio"})
+		return
+	}
+	if ah.exceedsUploadLimit(info.Size) {
+		// Presigned uploads cannot cap the body size, so oversized objects are dropped here.
+		if err := ah.config.Uploads.Delete(key); err != nil {
+			log.Printf("Warning: Failed to delete oversized upload %s: %v", key, err)
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ah.uploadTooLargeMessage()})
+		return
+	}
+	if completion.Size > 0 && info.Size != completion.Size {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Tamanho do upload não confere: esperado %d, recebido %d bytes", completion.Size, info.Size),
+		})
+		return
+	}
+
+	// Queued jobs wait for the processor, so only direct calls need it up.
+	if ah.config.Queue == nil {
+		if err := ah.processorClient.HealthCheck(); err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.ProcessingResult{
+				Success: false,
+				Message: "Serviço de processamento indisponível: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	log.Printf("Direct upload completed: %s (%d bytes)", key, info.Size)
+	_, filename, _ := storage.SplitUploadName(key)
+	if ah.config.Jobs != nil {
+		ah = ah.startJob(key, filename, options, sha256)
+		if ah.jobID == "" {
+			respondJobNotRecorded(c)
+			return
+		}
+		ah.queueJob(c, key, filename, options, sha256)
+		return
+	}
+
+	// Without a job store there is nothing to poll, so the video is processed in the request.
+	ah.processStoredVideo(c, key, options, sha256)
+}
+
+// completeMultipartUpload assembles the uploaded parts, answering the request itself on failure.
+func (ah *APIHandlers) completeMultipartUpload(c *gin.Context, key string, completion models.UploadCompletion) bool {
+	uploader, ok := ah.config.Uploads.(storage.DirectUploader)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Upload direto não suportado por este armazenamento"})
+		return false
+	}
+	if len(completion.Parts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lista de partes é obrigatória para upload multipart"})
+		return false
+	}
+
+	parts := make([]storage.CompletedPart, 0, len(completion.Parts))
+	for _, part := range completion.Parts {
+		if part.PartNumber < 1 || part.ETag == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parte inválida: part_number e etag são obrigatórios"})
+			return false
+		}
+		parts = append(parts, storage.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	if err := uploader.CompleteMultipartUpload(key, completion.UploadID, parts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao concluir upload multipart: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// PartSizeFor returns the part size used to upload size bytes in at most MaxUploadParts parts.
+func PartSizeFor(size int64) int64 {
+	partSize := int64(DefaultPartSize)
+	if minimum := (size + MaxUploadParts - 1) / MaxUploadParts; minimum > partSize {
+		partSize = minimum
+	}
+	return partSize
+}
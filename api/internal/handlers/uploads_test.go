@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/jobs"
+	"video-processor/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// directUploads is an in-memory store that hands out fake presigned requests.
+type directUploads struct {
+	*storage.Memory
+	partCounts map[string]int
+	completed  map[string][]storage.CompletedPart
+}
+
+func newDirectUploads() *directUploads {
+	return &directUploads{
+		Memory:     storage.NewMemory(),
+		partCounts: map[string]int{},
+		completed:  map[string][]storage.CompletedPart{},
+	}
+}
+
+func (d *directUploads) PresignPut(key, contentType string, expiration time.Duration) (*storage.PresignedRequest, error) {
+	return &storage.PresignedRequest{
+		Method:  http.MethodPut,
+		URL:     "https://uploads.example.com/" + key,
+		Headers: map[string]string{"Content-Type": contentType, "X-Amz-Server-Side-Encryption": "aws:kms"},
+	}, nil
+}
+
+func (d *directUploads) CreateMultipartUpload(key, contentType string, partCount int, expiration time.Duration) (*storage.MultipartUpload, error) {
+	d.partCounts[key] = partCount
+	upload := &storage.MultipartUpload{UploadID: "upload-1"}
+	for part := 1; part <= partCount; part++ {
+		upload.Parts = append(upload.Parts, storage.PresignedRequest{Method: http.MethodPut, URL: "https://uploads.example.com/" + key, PartNumber: part})
+	}
+	return upload, nil
+}
+
+func (d *directUploads) CompleteMultipartUpload(key, uploadID string, parts []storage.CompletedPart) error {
+	d.completed[uploadID] = parts
+	return d.Put(key, strings.NewReader("assembled video"), "video/mp4")
+}
+
+func (d *directUploads) AbortMultipartUpload(key, uploadID string) error {
+	return nil
+}
+
+func performJSON(t *testing.T, handler gin.HandlerFunc, params gin.Params, target, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = params
+	c.Request = httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w
+}
+
+func TestCreateUpload_ShouldReturnPresignedPutForSmallFiles(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.Uploads = newDirectUploads()
+
+	w := performJSON(t, handlers.CreateUpload, nil, "/api/v1/uploads", `{"filename":"video.mp4","content_type":"video/mp4","size":1048576}`)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var target models.UploadTarget
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &target))
+	assert.Regexp(t, `^\d{8}_\d{6}-[0-9a-f]{8}_video\.mp4$`, target.Key)
+	assert.Equal(t, http.MethodPut, target.Method)
+	assert.Equal(t, "https://uploads.example.com/"+target.Key, target.URL)
+	assert.Equal(t, "aws:kms", target.Headers["X-Amz-Server-Side-Encryption"])
+	assert.Equal(t, "/api/v1/uploads/"+target.Key+"/complete", target.CompleteURL)
+	assert.Empty(t, target.Parts)
+}
+
+func TestCreateUpload_ShouldSplitLargeFilesIntoParts(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+
+	w := performJSON(t, handlers.CreateUpload, nil, "/api/v1/uploads", `{"filename":"video.mov","size":200000000}`)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var target models.UploadTarget
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &target))
+	assert.Equal(t, "upload-1", target.UploadID)
+	assert.Equal(t, int64(DefaultPartSize), target.PartSize)
+	assert.Len(t, target.Parts, 3)
+	assert.Equal(t, 3, uploads.partCounts[target.Key])
+	assert.Empty(t, target.URL)
+}
+
+func TestCreateUpload_ShouldRejectInvalidRequests(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.Uploads = newDirectUploads()
+
+	for _, body := range []string{`{"filename":"notes.txt","size":10}`, `{"filename":"video.mp4"}`, `not json`} {
+		w := performJSON(t, handlers.CreateUpload, nil, "/api/v1/uploads", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestCreateUpload_ShouldRequireDirectUploadBackend(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	w := performJSON(t, handlers.CreateUpload, nil, "/api/v1/uploads", `{"filename":"video.mp4","size":10}`)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestCompleteUpload_ShouldVerifyObjectAndProcessIt(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), "video/mp4"))
+
+	var processedKey, processedOptions string
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFromS3Func: func(key, options string) (*models.ProcessingResult, error) {
+			processedKey, processedOptions = key, options
+			return &models.ProcessingResult{Success: true, ZipPath: "frames_20240101_120000.zip"}, nil
+		},
+	}
+
+	w := performJSON(t, handlers.CompleteUpload, gin.Params{{Key: "key", Value: "20240101_120000_video.mp4"}},
+		"/api/v1/uploads/20240101_120000_video.mp4/complete", `{"size":5,"options":{"fps":2}}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "20240101_120000_video.mp4", processedKey)
+	assert.JSONEq(t, `{"fps":2}`, processedOptions)
+}
+
+func TestCompleteUpload_ShouldQueueJobWhenJobsAreRecorded(t *testing.T) {
+	handlers, repository := setupJobHandlers(t)
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+	producer := &recordingProducer{}
+	handlers.config.Queue = producer
+	handlers.processorClient = &MockProcessorClient{
+		healthCheckFunc: func() error { return errors.New("processor offline") },
+	}
+	require.NoError(t, uploads.Put("20240101_120000-0a1b2c3d_video.mp4", strings.NewReader("video"), "video/mp4"))
+
+	w := performJSON(t, handlers.CompleteUpload, gin.Params{{Key: "key", Value: "20240101_120000-0a1b2c3d_video.mp4"}},
+		"/api/v1/uploads/20240101_120000-0a1b2c3d_video.mp4/complete", `{"size":5,"options":{"fps":2}}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, jobsPath+job.ID, w.Header().Get("Location"))
+	require.Len(t, producer.messages, 1)
+	message := producer.messages[0]
+	assert.Equal(t, job.ID, message.JobID)
+	assert.Equal(t, "20240101_120000-0a1b2c3d_video.mp4", message.SourceKey)
+	assert.Equal(t, "video.mp4", message.SourceName)
+	assert.JSONEq(t, `{"fps":2}`, message.Options)
+
+	stored, err := repository.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusQueued, stored.Status)
+}
+
+func TestCompleteUpload_ShouldAssembleMultipartUploads(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+	handlers.processorClient = &MockProcessorClient{}
+
+	w := performJSON(t, handlers.CompleteUpload, gin.Params{{Key: "key", Value: "20240101_120000_video.mp4"}},
+		"/api/v1/uploads/20240101_120000_video.mp4/complete",
+		`{"upload_id":"upload-1","parts":[{"part_number":1,"etag":"\"a\""},{"part_number":2,"etag":"\"b\""}]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []storage.CompletedPart{{PartNumber: 1, ETag: `"a"`}, {PartNumber: 2, ETag: `"b"`}}, uploads.completed["upload-1"])
+}
+
+func TestCompleteUpload_ShouldRejectMissingOrMismatchedObjects(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), "video/mp4"))
+
+	processed := false
+	handlers.processorClient = &MockProcessorClient{
+		processVideoFromS3Func: func(key, options string) (*models.ProcessingResult, error) {
+			processed = true
+			return &models.ProcessingResult{Success: true}, nil
+		},
+	}
+
+	tests := []struct {
+		key    string
+		body   string
+		status int
+	}{
+		{"20240101_120001_video.mp4", `{}`, http.StatusNotFound},
+		{"20240101_120000_video.mp4", `{"size":999}`, http.StatusBadRequest},
+		{"20240101_120000_video.mp4", `{"options":"fps"}`, http.StatusBadRequest},
+		{"20240101_120000_video.mp4", `{"upload_id":"upload-1"}`, http.StatusBadRequest},
+		{"video.mp4", `{}`, http.StatusBadRequest},
+		{"20240101_120000_notes.txt", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := performJSON(t, handlers.CompleteUpload, gin.Params{{Key: "key", Value: tt.key}}, "/api/v1/uploads/"+tt.key+"/complete", tt.body)
+		assert.Equal(t, tt.status, w.Code, tt.key+" "+tt.body)
+	}
+	assert.False(t, processed)
+}
+
+func TestCreateUpload_ShouldRejectFilesAboveMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.Uploads = newDirectUploads()
+	handlers.config.MaxUploadSize = 1 << 20
+
+	w := performJSON(t, handlers.CreateUpload, nil, "/api/v1/uploads", `{"filename":"video.mp4","size":1048577}`)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestCompleteUpload_ShouldDeleteObjectsAboveMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	uploads := newDirectUploads()
+	handlers.config.Uploads = uploads
+	handlers.config.MaxUploadSize = 4
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), "video/mp4"))
+
+	w := performJSON(t, handlers.CompleteUpload, gin.Params{{Key: "key", Value: "20240101_120000_video.mp4"}},
+		"/api/v1/uploads/20240101_120000_video.mp4/complete", `{}`)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	_, err := uploads.Stat("20240101_120000_video.mp4")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestPartSizeFor_ShouldStayWithinPartLimit(t *testing.T) {
+	assert.Equal(t, int64(DefaultPartSize), PartSizeFor(200<<20))
+
+	huge := int64(2) << 40
+	partSize := PartSizeFor(huge)
+	assert.LessOrEqual(t, (huge+partSize-1)/partSize, int64(MaxUploadParts))
+}
@@ -1,10 +1,82 @@
 package models
 
+import "encoding/json"
+
 type ProcessingResult struct {
-	Success     bool     `json:"success"`
-	Message     string   `json:"message"`
-	ZipPath     string   `json:"zip_path,omitempty"`
-	DownloadURL string   `json:"download_url,omitempty"`
-	FrameCount  int      `json:"frame_count,omitempty"`
-	Images      []string `json:"images,omitempty"`
+	Success      bool         `json:"success"`
+	Message      string       `json:"message"`
+	VideoID      string       `json:"video_id,omitempty"`
+	ZipPath      string       `json:"zip_path,omitempty"`
+	DownloadURL  string       `json:"download_url,omitempty"`
+	FrameCount   int          `json:"frame_count,omitempty"`
+	Images       []string     `json:"images,omitempty"`
+	Clips        []ClipResult `json:"clips,omitempty"`
+	ProxyPath    string       `json:"proxy_path,omitempty"`
+	ProxyURL     string       `json:"proxy_url,omitempty"`
+	PlaylistPath string       `json:"playlist_path,omitempty"`
+	PlaylistURL  string       `json:"playlist_url,omitempty"`
+	// Deduplicated is set when the outputs of an identical earlier job were reused.
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// SourceChecksum is the hex SHA-256 of the processed video; Checksums holds that of
+	// every stored output, by key.
+	SourceChecksum string            `json:"source_checksum,omitempty"`
+	Checksums      map[string]string `json:"checksums,omitempty"`
+	// JobID names the job record of the processing, when jobs are recorded.
+	JobID string `json:"job_id,omitempty"`
+}
+
+// ClipResult describes an exported MP4 clip stored next to the frames archive.
+type ClipResult struct {
+	Filename    string  `json:"filename"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Mode        string  `json:"mode"`
+	DownloadURL string  `json:"download_url,omitempty"`
+}
+
+// FrameImage is a single rendered still returned by the processor.
+type FrameImage struct {
+	ContentType string
+	Data        []byte
+}
+
+// UploadRequest asks for presigned URLs to send a video straight to uploads storage.
+type UploadRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+// UploadTarget tells the client where to send the video: a single presigned PUT, or one
+// presigned PUT per part of a multipart upload.
+type UploadTarget struct {
+	Key         string            `json:"key"`
+	Method      string            `json:"method,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	UploadID    string            `json:"upload_id,omitempty"`
+	PartSize    int64             `json:"part_size,omitempty"`
+	Parts       []UploadPart      `json:"parts,omitempty"`
+	CompleteURL string            `json:"complete_url"`
+	ExpiresAt   int64             `json:"expires_at"`
+}
+
+type UploadPart struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// UploadCompletion confirms a direct upload. Multipart uploads list the ETag of every part.
+type UploadCompletion struct {
+	UploadID string          `json:"upload_id,omitempty"`
+	Parts    []CompletedPart `json:"parts,omitempty"`
+	Size     int64           `json:"size,omitempty"`
+	Options  json.RawMessage `json:"options,omitempty"`
+	// SHA256 is the hex SHA-256 of the video; the processor rejects a stored copy that differs.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type CompletedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
 }
@@ -0,0 +1,127 @@
+package tus
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"video-processor/internal/storage"
+)
+
+// FileStore appends upload data to files in a local directory and moves them into the
+// uploads storage once complete.
+type FileStore struct {
+	dir     string
+	records records
+	uploads storage.Storage
+}
+
+func NewFileStore(dir string, uploads storage.Storage) *FileStore {
+	return &FileStore{
+		dir:     dir,
+		records: records{store: storage.NewFilesystem(dir)},
+		uploads: uploads,
+	}
+}
+
+func (fs *FileStore) dataPath(id string) string {
+	return filepath.Join(fs.dir, id+".bin")
+}
+
+func (fs *FileStore) Create(upload *Upload) error {
+	if err := os.MkdirAll(fs.dir, 0750); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Clean(fs.dataPath(upload.ID)))
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return fs.records.save(upload)
+}
+
+func (fs *FileStore) Get(id string) (*Upload, error) {
+	return fs.records.get(id)
+}
+
+func (fs *FileStore) Save(upload *Upload) error {
+	return fs.records.save(upload)
+}
+
+func (fs *FileStore) WriteChunk(upload *Upload, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(filepath.Clean(fs.dataPath(upload.ID)), os.O_WRONLY, 0600)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Warning: Failed to close upload data %s: %v", upload.ID, err)
+		}
+	}()
+
+	// Drop anything past the saved offset, left over from a write that failed to save.
+	if err := file.Truncate(upload.Offset); err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(upload.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, copyErr := io.Copy(file, io.LimitReader(body, upload.Length-upload.Offset))
+	if written > 0 {
+		if err := file.Sync(); err != nil {
+			return 0, err
+		}
+		upload.Offset += written
+		if err := fs.records.save(upload); err != nil {
+			return written, err
+		}
+	}
+
+	return written, copyErr
+}
+
+// Finish renames the data file into the uploads directory when both live on local disk,
+// and copies it into the uploads storage otherwise.
+func (fs *FileStore) Finish(upload *Upload) error {
+	dataPath := fs.dataPath(upload.ID)
+
+	if target, ok := storage.LocalPath(fs.uploads, upload.Key); ok {
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return err
+		}
+		if err := os.Rename(dataPath, target); err == nil {
+			return nil
+		}
+	}
+
+	file, err := os.Open(filepath.Clean(dataPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Warning: Failed to close upload data %s: %v", upload.ID, err)
+		}
+	}()
+
+	if err := fs.uploads.Put(upload.Key, file, ""); err != nil {
+		return err
+	}
+	return os.Remove(dataPath)
+}
+
+func (fs *FileStore) Terminate(upload *Upload) error {
+	if err := os.Remove(fs.dataPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.records.delete(upload.ID)
+}
@@ -0,0 +1,187 @@
+package tus
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+
+	"video-processor/internal/storage"
+)
+
+const (
+	// S3 rejects parts below 5 MiB except the last one and allows at most 10,000 parts.
+	DefaultPartSize = 16 << 20
+	MaxParts        = 10000
+
+	s3Prefix = ".tus/"
+)
+
+// S3Store streams upload data into a multipart upload of the uploads storage, as S3
+// assembles them, for the final key. Upload state and the tail that does not fill a
+// part yet live in the objects storage, so any API instance can resume an upload.
+type S3Store struct {
+	parts   storage.PartUploader
+	objects storage.Storage
+	records records
+}
+
+// NewS3Store returns a store sending parts to parts, which is usually the same uploads
+// storage as objects, routed to the tenant's bucket and prefix.
+func NewS3Store(parts storage.PartUploader, objects storage.Storage) *S3Store {
+	return &S3Store{
+		parts:   parts,
+		objects: objects,
+		records: records{store: objects, prefix: s3Prefix},
+	}
+}
+
+func incompleteKey(id string) string {
+	return s3Prefix + id + ".part"
+}
+
+// PartSizeFor returns the part size that fits length bytes in at most MaxParts parts.
+func PartSizeFor(length int64) int64 {
+	partSize := int64(DefaultPartSize)
+	if minimum := (length + MaxParts - 1) / MaxParts; minimum > partSize {
+		partSize = minimum
+	}
+	return partSize
+}
+
+func (ss *S3Store) Create(upload *Upload) error {
+	multipart, err := ss.parts.CreateMultipartUpload(upload.Key, "", 0, 0)
+	if err != nil {
+		return err
+	}
+	upload.MultipartID = multipart.UploadID
+	upload.PartSize = PartSizeFor(upload.Length)
+
+	return ss.records.save(upload)
+}
+
+func (ss *S3Store) Get(id string) (*Upload, error) {
+	return ss.records.get(id)
+}
+
+func (ss *S3Store) Save(upload *Upload) error {
+	return ss.records.save(upload)
+}
+
+// WriteChunk cuts the incoming bytes, prefixed by the stored tail, into parts. Full parts
+// and the final part go to the multipart upload; what is left is stored as the new tail.
+func (ss *S3Store) WriteChunk(upload *Upload, body io.Reader) (int64, error) {
+	buf := make([]byte, 0, upload.PartSize)
+	if upload.IncompleteSize > 0 {
+		tail, err := ss.readIncomplete(upload.ID)
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, tail...)
+	}
+	hadTail := len(buf) > 0
+
+	body = io.LimitReader(body, upload.Length-upload.Offset)
+	received := int64(0)
+	var writeErr error
+	for {
+		n, err := io.ReadFull(body, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		received += int64(n)
+
+		final := upload.Offset+received == upload.Length
+		if len(buf) == cap(buf) || (final && len(buf) > 0) {
+			if writeErr = ss.uploadPart(upload, buf); writeErr != nil {
+				break
+			}
+			buf = buf[:0]
+		}
+
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				writeErr = err
+			}
+			break
+		}
+		if final {
+			break
+		}
+	}
+
+	if len(buf) > 0 {
+		if err := ss.objects.Put(incompleteKey(upload.ID), bytes.NewReader(buf), ""); err != nil {
+			// The tail is lost; the client resumes from the end of the last part.
+			buf = buf[:0]
+			if writeErr == nil {
+				writeErr = err
+			}
+		}
+	} else if hadTail {
+		if err := ss.objects.Delete(incompleteKey(upload.ID)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Warning: Failed to delete upload tail %s: %v", upload.ID, err)
+		}
+	}
+
+	previous := upload.Offset
+	upload.Offset = partsSize(upload.Parts) + int64(len(buf))
+	upload.IncompleteSize = int64(len(buf))
+	if err := ss.records.save(upload); err != nil && writeErr == nil {
+		writeErr = err
+	}
+
+	if upload.Offset < previous {
+		return 0, writeErr
+	}
+	return upload.Offset - previous, writeErr
+}
+
+func (ss *S3Store) uploadPart(upload *Upload, data []byte) error {
+	number := int64(len(upload.Parts) + 1)
+	etag, err := ss.parts.UploadPart(upload.Key, upload.MultipartID, int(number), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	upload.Parts = append(upload.Parts, Part{Number: number, ETag: etag, Size: int64(len(data))})
+	return nil
+}
+
+func (ss *S3Store) readIncomplete(id string) ([]byte, error) {
+	reader, err := ss.objects.Get(incompleteKey(id))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close upload tail %s: %v", id, err)
+		}
+	}()
+	return io.ReadAll(reader)
+}
+
+func partsSize(parts []Part) int64 {
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+	}
+	return size
+}
+
+func (ss *S3Store) Finish(upload *Upload) error {
+	parts := make([]storage.CompletedPart, 0, len(upload.Parts))
+	for _, part := range upload.Parts {
+		parts = append(parts, storage.CompletedPart{PartNumber: int(part.Number), ETag: part.ETag})
+	}
+	return ss.parts.CompleteMultipartUpload(upload.Key, upload.MultipartID, parts)
+}
+
+func (ss *S3Store) Terminate(upload *Upload) error {
+	if upload.MultipartID != "" {
+		if err := ss.parts.AbortMultipartUpload(upload.Key, upload.MultipartID); err != nil {
+			return err
+		}
+	}
+	if err := ss.objects.Delete(incompleteKey(upload.ID)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return ss.records.delete(upload.ID)
+}
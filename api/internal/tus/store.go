@@ -0,0 +1,178 @@
+// Package tus keeps the state of resumable uploads made with the tus 1.0 protocol.
+// Finished uploads land in the uploads storage under the same key CreateVideo uses.
+package tus
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"video-processor/api/internal/models"
+	"video-processor/internal/storage"
+)
+
+const (
+	Version    = "1.0.0"
+	Extensions = "creation,termination"
+
+	StatusUploading  = "uploading"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// ErrNotFound is returned when an upload ID is unknown.
+var ErrNotFound = errors.New("upload not found")
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Upload is the persisted state of a resumable upload.
+type Upload struct {
+	ID       string                   `json:"id"`
+	Key      string                   `json:"key"`
+	Filename string                   `json:"filename"`
+	Options  string                   `json:"options,omitempty"`
+	SHA256   string                   `json:"sha256,omitempty"`
+	Metadata string                   `json:"metadata,omitempty"`
+	Tenant   string                   `json:"tenant,omitempty"`
+	Length   int64                    `json:"length"`
+	Offset   int64                    `json:"offset"`
+	Status   string                   `json:"status"`
+	Result   *models.ProcessingResult `json:"result,omitempty"`
+	// JobID names the job processing the finished upload, when jobs are recorded.
+	JobID     string    `json:"job_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// S3 multipart state. Bytes that do not fill a whole part yet are kept in a
+	// separate object of IncompleteSize bytes until the next PATCH completes the part.
+	MultipartID    string `json:"multipart_id,omitempty"`
+	PartSize       int64  `json:"part_size,omitempty"`
+	Parts          []Part `json:"parts,omitempty"`
+	IncompleteSize int64  `json:"incomplete_size,omitempty"`
+}
+
+type Part struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// Finished reports whether every byte of the upload has been received.
+func (u *Upload) Finished() bool {
+	return u.Offset == u.Length
+}
+
+// Store persists uploads and their data.
+type Store interface {
+	Create(upload *Upload) error
+	Get(id string) (*Upload, error)
+	Save(upload *Upload) error
+	// WriteChunk appends body at upload.Offset, never past upload.Length, and saves the
+	// new offset. Bytes received before a read error are kept and counted.
+	WriteChunk(upload *Upload, body io.Reader) (int64, error)
+	// Finish moves the completed data to upload.Key in the uploads storage.
+	Finish(upload *Upload) error
+	// Terminate discards an unfinished upload and its state.
+	Terminate(upload *Upload) error
+}
+
+// NewID returns a random upload ID.
+func NewID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// ParseMetadata decodes an Upload-Metadata header: comma-separated "key base64value" pairs.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid metadata pair %q", pair)
+		}
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %s: %w", fields[0], err)
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+
+	return metadata, nil
+}
+
+// records keeps upload state as JSON objects in a storage backend.
+type records struct {
+	store  storage.Storage
+	prefix string
+}
+
+func (r records) key(id string) string {
+	return r.prefix + id + ".info"
+}
+
+func (r records) get(id string) (*Upload, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+
+	reader, err := r.store.Get(r.key(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close upload record %s: %v", id, err)
+		}
+	}()
+
+	var upload Upload
+	if err := json.NewDecoder(reader).Decode(&upload); err != nil {
+		return nil, fmt.Errorf("failed to decode upload %s: %w", id, err)
+	}
+	return &upload, nil
+}
+
+func (r records) save(upload *Upload) error {
+	upload.UpdatedAt = time.Now()
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return r.store.Put(r.key(upload.ID), bytes.NewReader(data), "application/json")
+}
+
+func (r records) delete(id string) error {
+	err := r.store.Delete(r.key(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+package tus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpload(t *testing.T, length int64) *Upload {
+	id, err := NewID()
+	require.NoError(t, err)
+	return &Upload{ID: id, Key: "20240101_120000_video.mp4", Filename: "video.mp4", Length: length, Status: StatusUploading, CreatedAt: time.Now()}
+}
+
+// failingReader returns its data and then an error, like a dropped connection.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestParseMetadata(t *testing.T) {
+	metadata, err := ParseMetadata("filename dmlkZW8ubXA0,options eyJmcHMiOjJ9,flag")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "video.mp4", "options": `{"fps":2}`, "flag": ""}, metadata)
+
+	_, err = ParseMetadata("filename not-base64!")
+	assert.Error(t, err)
+}
+
+func TestFileStore_ResumesAfterInterruptedChunk(t *testing.T) {
+	dir := t.TempDir()
+	uploadsDir := filepath.Join(dir, "uploads")
+	store := NewFileStore(filepath.Join(dir, "tus"), storage.NewFilesystem(uploadsDir))
+
+	upload := newUpload(t, 10)
+	require.NoError(t, store.Create(upload))
+
+	written, err := store.WriteChunk(upload, &failingReader{data: []byte("0123")})
+	assert.Error(t, err)
+	assert.Equal(t, int64(4), written)
+
+	reloaded, err := store.Get(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), reloaded.Offset)
+
+	written, err = store.WriteChunk(reloaded, strings.NewReader("456789-extra"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), written)
+	assert.True(t, reloaded.Finished())
+
+	require.NoError(t, store.Finish(reloaded))
+	data, err := os.ReadFile(filepath.Join(uploadsDir, upload.Key))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestFileStore_TerminateRemovesState(t *testing.T) {
+	store := NewFileStore(t.TempDir(), storage.NewMemory())
+	upload := newUpload(t, 10)
+	require.NoError(t, store.Create(upload))
+
+	require.NoError(t, store.Terminate(upload))
+
+	_, err := store.Get(upload.ID)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestStore_GetRejectsMalformedIDs(t *testing.T) {
+	store := NewFileStore(t.TempDir(), storage.NewMemory())
+
+	_, err := store.Get("../../etc/passwd")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+// fakeMultipart records parts in memory like an S3 multipart upload.
+type fakeMultipart struct {
+	parts     map[int][]byte
+	completed []byte
+	aborted   bool
+	failPart  int
+}
+
+func (f *fakeMultipart) PresignPut(key, contentType string, expiration time.Duration) (*storage.PresignedRequest, error) {
+	return nil, errors.New("not presigned")
+}
+
+func (f *fakeMultipart) CreateMultipartUpload(key, contentType string, partCount int, expiration time.Duration) (*storage.MultipartUpload, error) {
+	f.parts = map[int][]byte{}
+	return &storage.MultipartUpload{UploadID: "multipart-1"}, nil
+}
+
+func (f *fakeMultipart) UploadPart(key, uploadID string, partNumber int, body io.ReadSeeker) (string, error) {
+	if partNumber == f.failPart {
+		f.failPart = 0
+		return "", errors.New("part upload failed")
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	f.parts[partNumber] = data
+	return fmt.Sprintf(`"etag-%d"`, partNumber), nil
+}
+
+func (f *fakeMultipart) CompleteMultipartUpload(key, uploadID string, parts []storage.CompletedPart) error {
+	var assembled bytes.Buffer
+	for i, part := range parts {
+		if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, part.PartNumber) {
+			return errors.New("invalid part list")
+		}
+		assembled.Write(f.parts[part.PartNumber])
+	}
+	f.completed = assembled.Bytes()
+	return nil
+}
+
+func (f *fakeMultipart) AbortMultipartUpload(key, uploadID string) error {
+	f.aborted = true
+	return nil
+}
+
+func TestS3Store_CutsArbitraryChunksIntoParts(t *testing.T) {
+	client := &fakeMultipart{}
+	objects := storage.NewMemory()
+	store := NewS3Store(client, objects)
+
+	content := bytes.Repeat([]byte("abcdefghij"), 5)
+	upload := newUpload(t, int64(len(content)))
+	require.NoError(t, store.Create(upload))
+	upload.PartSize = 16
+
+	// Chunks that do not line up with parts leave a tail for the next PATCH.
+	for _, chunk := range [][]byte{content[:7], content[7:30], content[30:]} {
+		_, err := store.WriteChunk(upload, bytes.NewReader(chunk))
+		require.NoError(t, err)
+
+		reloaded, err := store.Get(upload.ID)
+		require.NoError(t, err)
+		assert.Equal(t, upload.Offset, reloaded.Offset)
+		upload = reloaded
+	}
+
+	require.True(t, upload.Finished())
+	assert.Zero(t, upload.IncompleteSize)
+	require.Len(t, upload.Parts, 4)
+	assert.Equal(t, int64(2), upload.Parts[3].Size)
+
+	require.NoError(t, store.Finish(upload))
+	assert.Equal(t, content, client.completed)
+	_, err := objects.Stat(incompleteKey(upload.ID))
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+}
+
+func TestS3Store_KeepsBytesWhenPartUploadFails(t *testing.T) {
+	client := &fakeMultipart{}
+	store := NewS3Store(client, storage.NewMemory())
+
+	content := bytes.Repeat([]byte("0123456789"), 4)
+	upload := newUpload(t, int64(len(content)))
+	require.NoError(t, store.Create(upload))
+	upload.PartSize = 16
+	client.failPart = 2
+
+	_, err := store.WriteChunk(upload, bytes.NewReader(content[:35]))
+	assert.Error(t, err)
+	assert.Equal(t, int64(32), upload.Offset)
+	assert.Equal(t, int64(16), upload.IncompleteSize)
+
+	_, err = store.WriteChunk(upload, bytes.NewReader(content[32:]))
+	require.NoError(t, err)
+	require.True(t, upload.Finished())
+	require.NoError(t, store.Finish(upload))
+	assert.Equal(t, content, client.completed)
+}
+
+func TestS3Store_TerminateAbortsMultipartUpload(t *testing.T) {
+	client := &fakeMultipart{}
+	store := NewS3Store(client, storage.NewMemory())
+	upload := newUpload(t, 100)
+	require.NoError(t, store.Create(upload))
+
+	require.NoError(t, store.Terminate(upload))
+
+	assert.True(t, client.aborted)
+	_, err := store.Get(upload.ID)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestPartSizeFor(t *testing.T) {
+	assert.Equal(t, int64(DefaultPartSize), PartSizeFor(2<<30))
+
+	huge := int64(500) << 30
+	partSize := PartSizeFor(huge)
+	assert.LessOrEqual(t, (huge+partSize-1)/partSize, int64(MaxParts))
+}
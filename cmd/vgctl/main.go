@@ -0,0 +1,224 @@
+// Command vgctl runs maintenance tasks against VideoGrinder storage. It reads the same
+// environment as the services.
+//
+//	vgctl migrate-storage -from fs -to s3 [-store outputs] [-prefix frames_] [-parallel 8] [-dry-run] [-verify] [-journal migrate.journal]
+//	vgctl reconcile [-grace 24h] [-delete]
+//	vgctl index-jobs
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/jobs"
+	"video-processor/internal/migrate"
+	"video-processor/internal/reconcile"
+	"video-processor/internal/storage"
+)
+
+const (
+	backendFilesystem = "fs"
+	backendS3         = "s3"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "migrate-storage":
+		os.Exit(migrateStorage(os.Args[2:]))
+	case "reconcile":
+		os.Exit(reconcileOrphans(os.Args[2:]))
+	case "index-jobs":
+		os.Exit(indexJobs())
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Uso: vgctl <comando> [opções]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Comandos:")
+	fmt.Fprintln(os.Stderr, "  migrate-storage   copia uploads/outputs entre filesystem (fs) e S3 (s3)")
+	fmt.Fprintln(os.Stderr, "  reconcile         lista ou apaga uploads, outputs e temporários órfãos")
+	fmt.Fprintln(os.Stderr, "  index-jobs        inclui no tenant-status-index os jobs do DynamoDB gravados antes dele")
+}
+
+// migrateStorage copies the chosen stores between backends and returns the exit code.
+func migrateStorage(args []string) int {
+	flags := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	from := flags.String("from", backendFilesystem, "backend de origem: fs ou s3")
+	to := flags.String("to", backendS3, "backend de destino: fs ou s3")
+	store := flags.String("store", "outputs", "o que migrar: outputs, uploads ou all")
+	prefix := flags.String("prefix", "", "migra apenas chaves com este prefixo")
+	parallel := flags.Int("parallel", 4, "objetos copiados em paralelo")
+	dryRun := flags.Bool("dry-run", false, "lista o que seria copiado sem gravar nada")
+	verify := flags.Bool("verify", true, "relê cada cópia no destino e confere o SHA-256")
+	journal := flags.String("journal", "", "arquivo com as chaves já migradas, para retomar uma migração interrompida")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *from == *to {
+		fmt.Fprintln(os.Stderr, "Origem e destino precisam ser diferentes")
+		return 2
+	}
+	stores, ok := map[string][]string{
+		"outputs": {"outputs"},
+		"uploads": {"uploads"},
+		"all":     {"uploads", "outputs"},
+	}[*store]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Valor inválido para -store: %s\n", *store)
+		return 2
+	}
+
+	backends := map[string]map[string]storage.Storage{}
+	for _, name := range []string{*from, *to} {
+		uploads, outputs, err := openBackend(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao configurar backend %s: %v\n", name, err)
+			return 1
+		}
+		backends[name] = map[string]storage.Storage{"uploads": uploads, "outputs": outputs}
+	}
+
+	exitCode := 0
+	for _, name := range stores {
+		opts := migrate.Options{
+			Prefix:      *prefix,
+			Parallelism: *parallel,
+			DryRun:      *dryRun,
+			Verify:      *verify,
+		}
+		if *journal != "" {
+			// Each store keeps its own journal, as both may hold the same keys.
+			opts.Journal = *journal + "." + name
+		}
+
+		fmt.Printf("🚚 Migrando %s de %s para %s\n", name, *from, *to)
+		report, err := migrate.New(backends[*from][name], backends[*to][name], opts).Run()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao migrar %s: %v\n", name, err)
+			exitCode = 1
+		}
+		if report == nil {
+			continue
+		}
+
+		action := "copiados"
+		if *dryRun {
+			action = "a copiar"
+		}
+		fmt.Printf("✅ %s: %d %s (%d bytes), %d já presentes, %d falhas\n",
+			name, report.Copied, action, report.Bytes, report.Skipped, len(report.Failures))
+		for _, failure := range report.Failures {
+			fmt.Fprintf(os.Stderr, "   ❌ %s: %v\n", failure.Key, failure.Err)
+		}
+		if len(report.Failures) > 0 {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// reconcileOrphans reports orphaned objects of the configured storage, deleting them
+// with -delete, and returns the exit code.
+func reconcileOrphans(args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	grace := flags.Duration("grace", reconcile.DefaultGrace, "idade mínima de um órfão")
+	remove := flags.Bool("delete", false, "apaga os órfãos encontrados em vez de só listá-los")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	awsConfig := baseConfig.NewAWSConfig()
+	s3Service, err := baseConfig.NewS3Service(awsConfig)
+	if err != nil {
+		s3Service = nil
+	}
+	dirs := baseConfig.NewDirectoryConfig()
+	uploads, outputs, err := storage.NewBackends(dirs, awsConfig, s3Service)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao configurar storage: %v\n", err)
+		return 1
+	}
+
+	// Uploads and outputs of jobs still queued or processing are kept, so the job store
+	// must be readable; the bolt store is locked while the API runs.
+	repository, err := jobs.Open(baseConfig.GetEnv("JOB_STORE", ""), awsConfig, s3Service != nil, baseConfig.NewEmbeddedConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao configurar job store: %v\n", err)
+		return 1
+	}
+
+	report, err := reconcile.New(uploads, outputs, repository, dirs.TempDir, reconcile.Options{Grace: *grace, Delete: *remove}).Run(time.Now())
+	exitCode := 0
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao reconciliar: %v\n", err)
+		exitCode = 1
+	}
+	if report == nil {
+		return exitCode
+	}
+
+	var total int64
+	for _, orphan := range report.Orphans {
+		total += orphan.Size
+		fmt.Printf("%-6s  %12d  %s  %s\n", orphan.Kind, orphan.Size, orphan.LastModified.Format(time.RFC3339), orphan.Key)
+	}
+	fmt.Printf("🧹 %d órfãos (%d bytes), %d apagados\n", len(report.Orphans), total, report.Deleted)
+	if *remove && report.Deleted < len(report.Orphans) {
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// indexJobs backfills the tenant listings of jobs stored in DynamoDB before
+// jobs.TenantStatusIndex existed and returns the exit code.
+func indexJobs() int {
+	awsConfig := baseConfig.NewAWSConfig()
+	client, err := baseConfig.NewDynamoDBClient(awsConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao configurar DynamoDB: %v\n", err)
+		return 1
+	}
+
+	indexed, err := jobs.NewDynamoDBRepository(client, awsConfig.DynamoDB.VideoJobsTable).IndexListings()
+	fmt.Printf("🗂️ %d jobs incluídos em %s\n", indexed, jobs.TenantStatusIndex)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao indexar jobs: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// openBackend returns the uploads and outputs stores of a backend, configured from the
+// environment like the services.
+func openBackend(name string) (uploads, outputs storage.Storage, err error) {
+	switch name {
+	case backendFilesystem:
+		return storage.NewLocalBackends(baseConfig.NewDirectoryConfig())
+	case backendS3:
+		awsConfig := baseConfig.NewAWSConfig()
+		s3Service, err := baseConfig.NewS3Service(awsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		uploads, outputs = storage.NewS3Backends(awsConfig, s3Service)
+		return uploads, outputs, nil
+	default:
+		return nil, nil, fmt.Errorf("backend desconhecido %q: use fs ou s3", name)
+	}
+}
@@ -0,0 +1,110 @@
+// Package checksum follows a video and its outputs through each hop with SHA-256: the
+// client, the API, storage and the processor each compute the digest of the bytes they
+// saw and compare it with the one the previous hop declared. Digests travel as
+// lowercase hex.
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Header carries a hex SHA-256 on requests and responses.
+const Header = "X-Checksum-SHA256"
+
+// ErrMismatch is returned when content does not hash to the digest declared for it.
+var ErrMismatch = errors.New("checksum mismatch")
+
+var hexPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Normalize lowercases a declared digest and reports whether it is a hex SHA-256.
+// An empty digest is valid and means nothing was declared.
+func Normalize(digest string) (string, bool) {
+	digest = strings.ToLower(strings.TrimSpace(digest))
+	return digest, digest == "" || hexPattern.MatchString(digest)
+}
+
+// Verify compares the digest computed for content with the one declared for it. An
+// empty declaration verifies anything.
+func Verify(declared, computed string) error {
+	if declared == "" || declared == computed {
+		return nil
+	}
+	return fmt.Errorf("%w: expected %s, got %s", ErrMismatch, declared, computed)
+}
+
+// Base64 converts a hex digest to the base64 form S3 checksum headers and the HTTP
+// Digest header use.
+func Base64(digest string) (string, error) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 %q", digest)
+	}
+	return base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// File returns the digest of a local file.
+func File(path string) (string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Warning: Failed to close file %s: %v", path, err)
+		}
+	}()
+
+	reader := NewReader(file, "")
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", err
+	}
+	return reader.Sum(), nil
+}
+
+// Reader hashes the bytes read through it. With a declared digest, reaching the end of
+// content that hashes differently fails with ErrMismatch instead of io.EOF, so a copy
+// or an upload streaming from it fails too.
+type Reader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	declared string
+	mismatch bool
+}
+
+func NewReader(reader io.Reader, declared string) *Reader {
+	return &Reader{reader: reader, hash: sha256.New(), declared: declared}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if verifyErr := Verify(r.declared, r.Sum()); verifyErr != nil {
+			r.mismatch = true
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+// Sum returns the digest of the bytes read so far.
+func (r *Reader) Sum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// Mismatch reports whether the content ended up not matching the declared digest. It
+// lets callers recognize the failure through layers that do not wrap errors.
+func (r *Reader) Mismatch() bool {
+	return r.mismatch
+}
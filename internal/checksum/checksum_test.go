@@ -0,0 +1,66 @@
+package checksum
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helloDigest is the SHA-256 of "hello".
+const helloDigest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestNormalize(t *testing.T) {
+	digest, ok := Normalize(" " + strings.ToUpper(helloDigest) + " ")
+	assert.True(t, ok)
+	assert.Equal(t, helloDigest, digest)
+
+	_, ok = Normalize("")
+	assert.True(t, ok)
+	_, ok = Normalize("abc")
+	assert.False(t, ok)
+}
+
+func TestReader_HashesContent(t *testing.T) {
+	reader := NewReader(strings.NewReader("hello"), "")
+
+	data, err := io.ReadAll(reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, helloDigest, reader.Sum())
+	assert.False(t, reader.Mismatch())
+}
+
+func TestReader_FailsOnMismatchAtEnd(t *testing.T) {
+	reader := NewReader(strings.NewReader("hellO"), helloDigest)
+
+	_, err := io.ReadAll(reader)
+
+	assert.True(t, errors.Is(err, ErrMismatch))
+	assert.True(t, reader.Mismatch())
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0600))
+
+	digest, err := File(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, helloDigest, digest)
+}
+
+func TestBase64(t *testing.T) {
+	encoded, err := Base64(helloDigest)
+	require.NoError(t, err)
+	assert.Equal(t, "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=", encoded)
+
+	_, err = Base64("abc")
+	assert.Error(t, err)
+}
@@ -1,39 +1,120 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const DefaultLocalStackEndpoint = "http://localhost:4566"
 
+// Storage providers. Everything but AWS is reached through EndpointURL; only LocalStack
+// also emulates DynamoDB and SQS.
+const (
+	ProviderAWS        = "aws"
+	ProviderLocalStack = "localstack"
+	ProviderMinIO      = "minio"
+	ProviderGeneric    = "generic"
+)
+
+// Addressing styles for bucket URLs.
+const (
+	AddressingPath    = "path"
+	AddressingVirtual = "virtual"
+)
+
 type AWSConfig struct {
 	Region           string
 	AccessKeyID      string
 	SecretAccessKey  string
 	EndpointURL      string
 	ExternalURL      string // Browser-accessible URL (different from internal EndpointURL)
+	Provider         string // aws, localstack, minio or generic; see ProviderType
+	AddressingStyle  string // path or virtual; empty picks the provider default
+	TLSSkipVerify    bool   // Accept any certificate from a custom endpoint
+	TLSCAFile        string // PEM bundle trusted in addition to the system roots
 	S3Buckets        S3Config
 	DynamoDB         DynamoDBConfig
 	SQS              SQSConfig
 	PresignedTimeout time.Duration // Configurable timeout for presigned URLs
 }
 
+// Server-side encryption modes, using the values S3 expects in x-amz-server-side-encryption.
+const (
+	EncryptionNone   = ""
+	EncryptionSSES3  = "AES256"
+	EncryptionSSEKMS = "aws:kms"
+)
+
 type S3Config struct {
-	UploadsBucket string
-	OutputsBucket string
+	UploadsBucket     string
+	OutputsBucket     string
+	UploadsEncryption BucketEncryption
+	OutputsEncryption BucketEncryption
+	// Bucket name templates such as "videogrinder-{tenant}-outputs" give each tenant a
+	// bucket of its own; empty keeps every tenant in the buckets above.
+	UploadsBucketTemplate string
+	OutputsBucketTemplate string
+}
+
+// BucketEncryption describes how objects written to a bucket are encrypted at rest.
+// KMSKeyID accepts a key ID, key ARN or alias ARN; empty uses the AWS managed aws/s3 key.
+type BucketEncryption struct {
+	Mode     string
+	KMSKeyID string
+}
+
+func (e BucketEncryption) Enabled() bool {
+	return e.Mode != EncryptionNone
+}
+
+// EncryptionFor returns the encryption settings configured for bucket. Tenant buckets
+// share the settings of the default bucket they stand in for.
+func (c S3Config) EncryptionFor(bucket string) BucketEncryption {
+	switch {
+	case bucket == c.UploadsBucket || matchesBucketTemplate(c.UploadsBucketTemplate, bucket):
+		return c.UploadsEncryption
+	case bucket == c.OutputsBucket || matchesBucketTemplate(c.OutputsBucketTemplate, bucket):
+		return c.OutputsEncryption
+	default:
+		return BucketEncryption{}
+	}
+}
+
+// matchesBucketTemplate reports whether bucket is template rendered for some tenant.
+func matchesBucketTemplate(template, bucket string) bool {
+	prefix, suffix, ok := strings.Cut(template, TenantVariable)
+	return ok && len(bucket) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(bucket, prefix) && strings.HasSuffix(bucket, suffix)
 }
 
 type DynamoDBConfig struct {
 	VideoJobsTable string
 }
 
+// Defaults for consuming the video processing queue.
+const (
+	DefaultSQSMaxReceiveCount   = 5
+	DefaultSQSVisibilityTimeout = time.Minute
+)
+
 type SQSConfig struct {
 	VideoProcessingQueue    string
 	VideoProcessingDLQQueue string
+	// MaxReceiveCount is how often a message is delivered before the redrive policy
+	// moves it to the DLQ.
+	MaxReceiveCount int
+	// VisibilityTimeout hides a received message from other consumers; workers extend
+	// it while they process the message.
+	VisibilityTimeout time.Duration
 }
 
 func NewAWSConfig() *AWSConfig {
@@ -43,9 +124,18 @@ func NewAWSConfig() *AWSConfig {
 		SecretAccessKey: GetEnv("AWS_SECRET_ACCESS_KEY", ""),
 		EndpointURL:     GetEnv("AWS_ENDPOINT_URL", ""),
 		ExternalURL:     GetEnv("AWS_EXTERNAL_URL", ""), // New: browser-accessible URL
+		Provider:        parseProvider(GetEnv("S3_PROVIDER", "")),
+		AddressingStyle: parseAddressingStyle(GetEnv("S3_ADDRESSING_STYLE", "")),
+		TLSSkipVerify:   GetEnv("S3_TLS_SKIP_VERIFY", "false") == "true",
+		TLSCAFile:       GetEnv("S3_TLS_CA_FILE", ""),
 		S3Buckets: S3Config{
-			UploadsBucket: GetEnv("S3_BUCKET_UPLOADS", "videogrinder-uploads"),
-			OutputsBucket: GetEnv("S3_BUCKET_OUTPUTS", "videogrinder-outputs"),
+			UploadsBucket:     GetEnv("S3_BUCKET_UPLOADS", "videogrinder-uploads"),
+			OutputsBucket:     GetEnv("S3_BUCKET_OUTPUTS", "videogrinder-outputs"),
+			UploadsEncryption: bucketEncryptionFromEnv("S3_UPLOADS"),
+			OutputsEncryption: bucketEncryptionFromEnv("S3_OUTPUTS"),
+
+			UploadsBucketTemplate: GetEnv("S3_BUCKET_UPLOADS_TEMPLATE", ""),
+			OutputsBucketTemplate: GetEnv("S3_BUCKET_OUTPUTS_TEMPLATE", ""),
 		},
 		DynamoDB: DynamoDBConfig{
 			VideoJobsTable: GetEnv("DYNAMODB_TABLE_VIDEO_JOBS", "video-jobs"),
@@ -53,6 +143,8 @@ func NewAWSConfig() *AWSConfig {
 		SQS: SQSConfig{
 			VideoProcessingQueue:    GetEnv("SQS_QUEUE_VIDEO_PROCESSING", "video-processing-queue"),
 			VideoProcessingDLQQueue: GetEnv("SQS_QUEUE_VIDEO_PROCESSING_DLQ", "video-processing-dlq"),
+			MaxReceiveCount:         parseMaxReceiveCount(GetEnv("SQS_MAX_RECEIVE_COUNT", "5")),
+			VisibilityTimeout:       parseVisibilityTimeout(GetEnv("SQS_VISIBILITY_TIMEOUT", "1m")),
 		},
 		PresignedTimeout: parseDuration(GetEnv("AWS_PRESIGNED_TIMEOUT", "1h")),
 	}
@@ -68,44 +160,187 @@ func parseDuration(s string) time.Duration {
 	return d
 }
 
+// parseMaxReceiveCount reads the redrive threshold, which SQS accepts from 1 to 1000.
+func parseMaxReceiveCount(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 || n > 1000 {
+		log.Printf("Warning: Invalid SQS max receive count %s, using default %d", value, DefaultSQSMaxReceiveCount)
+		return DefaultSQSMaxReceiveCount
+	}
+	return n
+}
+
+// parseVisibilityTimeout reads a visibility timeout in whole seconds, up to the 12h
+// SQS allows.
+func parseVisibilityTimeout(value string) time.Duration {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d < time.Second || d > 12*time.Hour {
+		log.Printf("Warning: Invalid visibility timeout %s, using default %s", value, DefaultSQSVisibilityTimeout)
+		return DefaultSQSVisibilityTimeout
+	}
+	return d.Truncate(time.Second)
+}
+
+// bucketEncryptionFromEnv reads <prefix>_ENCRYPTION and <prefix>_KMS_KEY_ID, falling back
+// to the S3_ENCRYPTION and S3_KMS_KEY_ID defaults shared by every bucket.
+func bucketEncryptionFromEnv(prefix string) BucketEncryption {
+	mode := GetEnv(prefix+"_ENCRYPTION", GetEnv("S3_ENCRYPTION", "none"))
+	keyID := GetEnv(prefix+"_KMS_KEY_ID", GetEnv("S3_KMS_KEY_ID", ""))
+	return parseBucketEncryption(mode, keyID)
+}
+
+// parseBucketEncryption maps a configured mode to its S3 value. Unknown modes fall back
+// to SSE-S3 so a typo never silently disables encryption.
+func parseBucketEncryption(mode, keyID string) BucketEncryption {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "none":
+		return BucketEncryption{}
+	case "sse-s3", "aes256":
+		return BucketEncryption{Mode: EncryptionSSES3}
+	case "sse-kms", "aws:kms", "kms":
+		return BucketEncryption{Mode: EncryptionSSEKMS, KMSKeyID: strings.TrimSpace(keyID)}
+	default:
+		log.Printf("Warning: Invalid S3 encryption mode %s, using SSE-S3", mode)
+		return BucketEncryption{Mode: EncryptionSSES3}
+	}
+}
+
+// parseProvider normalizes S3_PROVIDER. Unknown values fall back to the default so a typo
+// behaves like an unset provider instead of a half-configured one.
+func parseProvider(value string) string {
+	provider := strings.ToLower(strings.TrimSpace(value))
+	switch provider {
+	case "", ProviderAWS, ProviderLocalStack, ProviderMinIO, ProviderGeneric:
+		return provider
+	default:
+		log.Printf("Warning: Invalid S3 provider %s, using the default", value)
+		return ""
+	}
+}
+
+func parseAddressingStyle(value string) string {
+	style := strings.ToLower(strings.TrimSpace(value))
+	switch style {
+	case "", AddressingPath, AddressingVirtual:
+		return style
+	default:
+		log.Printf("Warning: Invalid S3 addressing style %s, using the provider default", value)
+		return ""
+	}
+}
+
+// ProviderType returns the configured provider. Without one, a custom endpoint is taken
+// to be LocalStack, as it was before providers could be set.
+func (c *AWSConfig) ProviderType() string {
+	if c.Provider != "" {
+		return c.Provider
+	}
+	if c.EndpointURL != "" {
+		return ProviderLocalStack
+	}
+	return ProviderAWS
+}
+
 func (c *AWSConfig) IsLocalStack() bool {
-	return c.EndpointURL != ""
+	return c.ProviderType() == ProviderLocalStack
+}
+
+// HasCustomEndpoint reports whether S3 is reached through EndpointURL instead of AWS.
+func (c *AWSConfig) HasCustomEndpoint() bool {
+	return c.ProviderType() != ProviderAWS && c.EndpointURL != ""
+}
+
+// UsePathStyle reports whether buckets are addressed as endpoint/bucket/key. Custom
+// endpoints default to it since they rarely have wildcard DNS for bucket subdomains.
+func (c *AWSConfig) UsePathStyle() bool {
+	if c.AddressingStyle != "" {
+		return c.AddressingStyle == AddressingPath
+	}
+	return c.HasCustomEndpoint()
 }
 
 func (c *AWSConfig) GetS3Endpoint() string {
-	if c.IsLocalStack() {
+	if c.HasCustomEndpoint() {
 		return c.EndpointURL
 	}
 	return fmt.Sprintf("https://s3.%s.amazonaws.com", c.Region)
 }
 
 func (c *AWSConfig) GetDynamoDBEndpoint() string {
-	if c.IsLocalStack() {
+	if c.IsLocalStack() && c.EndpointURL != "" {
 		return c.EndpointURL
 	}
 	return fmt.Sprintf("https://dynamodb.%s.amazonaws.com", c.Region)
 }
 
 func (c *AWSConfig) GetSQSEndpoint() string {
-	if c.IsLocalStack() {
+	if c.IsLocalStack() && c.EndpointURL != "" {
 		return c.EndpointURL
 	}
 	return fmt.Sprintf("https://sqs.%s.amazonaws.com", c.Region)
 }
 
+// HTTPClient returns a client applying the TLS settings of the custom endpoint.
+func (c *AWSConfig) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+	if !c.TLSSkipVerify && c.TLSCAFile == "" {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.TLSSkipVerify, // #nosec G402
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(c.TLSCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read S3 CA file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in S3 CA file %s", c.TLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client, nil
+}
+
+// healthPath is the unauthenticated liveness endpoint of each provider. Generic
+// providers have none, so any answer from the endpoint root counts as reachable.
+func healthPath(provider string) string {
+	switch provider {
+	case ProviderLocalStack:
+		return "/_localstack/health"
+	case ProviderMinIO:
+		return "/minio/health/live"
+	default:
+		return "/"
+	}
+}
+
+// CheckHealth probes the custom endpoint with the provider's health check. AWS itself
+// is not probed; failures there surface from the S3 calls.
 func (c *AWSConfig) CheckHealth() error {
-	if !c.IsLocalStack() {
+	if !c.HasCustomEndpoint() {
 		return nil
 	}
+	provider := c.ProviderType()
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	client, err := c.HTTPClient(5 * time.Second)
+	if err != nil {
+		return err
 	}
 
-	healthURL := fmt.Sprintf("%s/health", c.EndpointURL)
-	resp, err := client.Get(healthURL)
+	resp, err := client.Get(strings.TrimSuffix(c.EndpointURL, "/") + healthPath(provider))
 	if err != nil {
-		return fmt.Errorf("failed to connect to LocalStack: %w", err)
+		return fmt.Errorf("failed to connect to %s: %w", provider, err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -113,8 +348,13 @@ func (c *AWSConfig) CheckHealth() error {
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("LocalStack health check failed: status %d", resp.StatusCode)
+	healthy := resp.StatusCode == http.StatusOK
+	if provider == ProviderGeneric {
+		// S3 answers an anonymous request to the root with 403 or similar.
+		healthy = resp.StatusCode < http.StatusInternalServerError
+	}
+	if !healthy {
+		return fmt.Errorf("%s health check failed: status %d", provider, resp.StatusCode)
 	}
 
 	return nil
@@ -139,7 +379,7 @@ func (c *AWSConfig) ValidateURL(rawURL string) error {
 	}
 
 	// Security First: Enforce HTTPS in production
-	if !c.IsLocalStack() && parsedURL.Scheme != "https" {
+	if !c.HasCustomEndpoint() && parsedURL.Scheme != "https" {
 		return fmt.Errorf("HTTPS required in production, got: %s", parsedURL.Scheme)
 	}
 
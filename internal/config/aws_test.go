@@ -1,7 +1,12 @@
 package config
 
 import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -75,6 +80,115 @@ func TestIsLocalStack(t *testing.T) {
 	}
 }
 
+func TestProviderType(t *testing.T) {
+	tests := []struct {
+		config       *AWSConfig
+		provider     string
+		customS3     bool
+		pathStyle    bool
+		dynamoDBHost string
+	}{
+		{&AWSConfig{Region: "us-east-1"}, ProviderAWS, false, false, "https://dynamodb.us-east-1.amazonaws.com"},
+		{&AWSConfig{Region: "us-east-1", AddressingStyle: AddressingPath}, ProviderAWS, false, true, "https://dynamodb.us-east-1.amazonaws.com"},
+		{&AWSConfig{Region: "us-east-1", EndpointURL: DefaultLocalStackEndpoint}, ProviderLocalStack, true, true, DefaultLocalStackEndpoint},
+		{&AWSConfig{Region: "us-east-1", EndpointURL: "http://minio:9000", Provider: ProviderMinIO}, ProviderMinIO, true, true, "https://dynamodb.us-east-1.amazonaws.com"},
+		{&AWSConfig{Region: "us-east-1", EndpointURL: "https://s3.example.com", Provider: ProviderGeneric, AddressingStyle: AddressingVirtual}, ProviderGeneric, true, false, "https://dynamodb.us-east-1.amazonaws.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.config.AddressingStyle, func(t *testing.T) {
+			if got := tt.config.ProviderType(); got != tt.provider {
+				t.Errorf("ProviderType() = %s, want %s", got, tt.provider)
+			}
+			if got := tt.config.HasCustomEndpoint(); got != tt.customS3 {
+				t.Errorf("HasCustomEndpoint() = %v, want %v", got, tt.customS3)
+			}
+			if got := tt.config.UsePathStyle(); got != tt.pathStyle {
+				t.Errorf("UsePathStyle() = %v, want %v", got, tt.pathStyle)
+			}
+			if got := tt.config.GetDynamoDBEndpoint(); got != tt.dynamoDBHost {
+				t.Errorf("GetDynamoDBEndpoint() = %s, want %s", got, tt.dynamoDBHost)
+			}
+		})
+	}
+}
+
+func TestParseProvider(t *testing.T) {
+	if got := parseProvider(" MinIO "); got != ProviderMinIO {
+		t.Errorf("parseProvider(MinIO) = %s, want %s", got, ProviderMinIO)
+	}
+	if got := parseProvider("ceph"); got != "" {
+		t.Errorf("parseProvider(ceph) = %s, want the default", got)
+	}
+	if got := parseAddressingStyle("Path"); got != AddressingPath {
+		t.Errorf("parseAddressingStyle(Path) = %s, want %s", got, AddressingPath)
+	}
+}
+
+func TestCheckHealth_ProbesProviderEndpoint(t *testing.T) {
+	tests := []struct {
+		provider string
+		path     string
+		status   int
+		healthy  bool
+	}{
+		{ProviderLocalStack, "/_localstack/health", http.StatusOK, true},
+		{ProviderMinIO, "/minio/health/live", http.StatusOK, true},
+		{ProviderMinIO, "/minio/health/live", http.StatusServiceUnavailable, false},
+		{ProviderGeneric, "/", http.StatusForbidden, true},
+		{ProviderGeneric, "/", http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.provider, tt.status), func(t *testing.T) {
+			var requested string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := (&AWSConfig{Provider: tt.provider, EndpointURL: server.URL}).CheckHealth()
+
+			if requested != tt.path {
+				t.Errorf("Expected health check on %s, got %s", tt.path, requested)
+			}
+			if tt.healthy && err != nil {
+				t.Errorf("CheckHealth() unexpected error: %v", err)
+			}
+			if !tt.healthy && err == nil {
+				t.Error("CheckHealth() expected an error")
+			}
+		})
+	}
+}
+
+func TestCheckHealth_TrustsConfiguredCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	config := &AWSConfig{Provider: ProviderMinIO, EndpointURL: server.URL}
+	if err := config.CheckHealth(); err == nil {
+		t.Fatal("Expected the self-signed certificate to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.TLSCAFile = caFile
+	if err := config.CheckHealth(); err != nil {
+		t.Errorf("CheckHealth() with CA file unexpected error: %v", err)
+	}
+
+	config.TLSCAFile = ""
+	config.TLSSkipVerify = true
+	if err := config.CheckHealth(); err != nil {
+		t.Errorf("CheckHealth() skipping verification unexpected error: %v", err)
+	}
+}
+
 func TestGetExternalEndpoint(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -206,6 +320,28 @@ func TestParseDuration(t *testing.T) {
 	}
 }
 
+func TestParseSQSSettings(t *testing.T) {
+	counts := map[string]int{"3": 3, "1000": 1000, "0": 5, "1001": 5, "x": 5}
+	for input, expected := range counts {
+		if result := parseMaxReceiveCount(input); result != expected {
+			t.Errorf("parseMaxReceiveCount(%s) = %d, want %d", input, result, expected)
+		}
+	}
+
+	timeouts := map[string]time.Duration{
+		"30s":     30 * time.Second,
+		"90500ms": 90 * time.Second,
+		"500ms":   time.Minute,
+		"13h":     time.Minute,
+		"invalid": time.Minute,
+	}
+	for input, expected := range timeouts {
+		if result := parseVisibilityTimeout(input); result != expected {
+			t.Errorf("parseVisibilityTimeout(%s) = %v, want %v", input, result, expected)
+		}
+	}
+}
+
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) &&
@@ -273,3 +409,83 @@ func TestGetSQSEndpoint(t *testing.T) {
 		t.Errorf("Expected LocalStack endpoint %s, got %s", DefaultLocalStackEndpoint, config.GetSQSEndpoint())
 	}
 }
+
+func TestParseBucketEncryption(t *testing.T) {
+	tests := []struct {
+		mode     string
+		keyID    string
+		expected BucketEncryption
+	}{
+		{"", "", BucketEncryption{}},
+		{"none", "", BucketEncryption{}},
+		{"SSE-S3", "", BucketEncryption{Mode: EncryptionSSES3}},
+		{"aes256", "ignored", BucketEncryption{Mode: EncryptionSSES3}},
+		{"sse-kms", "", BucketEncryption{Mode: EncryptionSSEKMS}},
+		{"aws:kms", " arn:aws:kms:us-east-1:000000000000:key/abc ", BucketEncryption{Mode: EncryptionSSEKMS, KMSKeyID: "arn:aws:kms:us-east-1:000000000000:key/abc"}},
+		{"invalid", "", BucketEncryption{Mode: EncryptionSSES3}}, // fallback keeps encryption on
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			result := parseBucketEncryption(tt.mode, tt.keyID)
+			if result != tt.expected {
+				t.Errorf("parseBucketEncryption(%s, %s) = %+v, want %+v", tt.mode, tt.keyID, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBucketEncryptionFromEnvironment(t *testing.T) {
+	os.Setenv("S3_ENCRYPTION", "sse-s3")
+	os.Setenv("S3_OUTPUTS_ENCRYPTION", "sse-kms")
+	os.Setenv("S3_OUTPUTS_KMS_KEY_ID", "alias/outputs")
+	defer func() {
+		os.Unsetenv("S3_ENCRYPTION")
+		os.Unsetenv("S3_OUTPUTS_ENCRYPTION")
+		os.Unsetenv("S3_OUTPUTS_KMS_KEY_ID")
+	}()
+
+	config := NewAWSConfig()
+
+	uploads := config.S3Buckets.EncryptionFor(config.S3Buckets.UploadsBucket)
+	if uploads != (BucketEncryption{Mode: EncryptionSSES3}) {
+		t.Errorf("Expected uploads bucket to inherit SSE-S3, got %+v", uploads)
+	}
+
+	outputs := config.S3Buckets.EncryptionFor(config.S3Buckets.OutputsBucket)
+	if outputs != (BucketEncryption{Mode: EncryptionSSEKMS, KMSKeyID: "alias/outputs"}) {
+		t.Errorf("Expected outputs bucket to use its KMS key, got %+v", outputs)
+	}
+
+	if config.S3Buckets.EncryptionFor("other-bucket").Enabled() {
+		t.Error("Expected unknown bucket to have no encryption settings")
+	}
+}
+
+func TestS3Config_EncryptionForTenantBuckets(t *testing.T) {
+	buckets := S3Config{
+		OutputsBucket:         "videogrinder-outputs",
+		OutputsEncryption:     BucketEncryption{Mode: EncryptionSSEKMS, KMSKeyID: "alias/outputs"},
+		OutputsBucketTemplate: "videogrinder-{tenant}-outputs",
+	}
+
+	if got := buckets.EncryptionFor("videogrinder-acme-outputs"); got != buckets.OutputsEncryption {
+		t.Errorf("Expected tenant bucket to use the outputs settings, got %+v", got)
+	}
+	if buckets.EncryptionFor("videogrinder--outputs").Enabled() {
+		t.Error("Expected a bucket rendered without tenant not to match")
+	}
+}
+
+func TestNewRoutingConfig(t *testing.T) {
+	t.Setenv("STORAGE_KEY_PREFIX", "{tenant}/{yyyy}/{mm}/{job_id}/")
+
+	routing := NewRoutingConfig()
+
+	if routing.KeyPrefix != "{tenant}/{yyyy}/{mm}/{job_id}/" {
+		t.Errorf("Expected key prefix from STORAGE_KEY_PREFIX, got %q", routing.KeyPrefix)
+	}
+	if routing.DefaultTenant != DefaultTenant {
+		t.Errorf("Expected default tenant %q, got %q", DefaultTenant, routing.DefaultTenant)
+	}
+}
@@ -1,21 +1,31 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// DefaultMaxUploadSize is the largest video accepted when MAX_UPLOAD_SIZE is not set.
+const DefaultMaxUploadSize int64 = 10 << 30
+
 type DirectoryConfig struct {
 	UploadsDir string
 	OutputsDir string
 	TempDir    string
+	// OutputsKeyFile enables encryption at rest for outputs stored in OutputsDir.
+	OutputsKeyFile string
 }
 
 func NewDirectoryConfig() *DirectoryConfig {
 	return &DirectoryConfig{
-		UploadsDir: GetEnv("UPLOADS_DIR", "uploads"),
-		OutputsDir: GetEnv("OUTPUTS_DIR", "outputs"),
-		TempDir:    GetEnv("TEMP_DIR", "temp"),
+		UploadsDir:     GetEnv("UPLOADS_DIR", "uploads"),
+		OutputsDir:     GetEnv("OUTPUTS_DIR", "outputs"),
+		TempDir:        GetEnv("TEMP_DIR", "temp"),
+		OutputsKeyFile: GetEnv("OUTPUTS_ENCRYPTION_KEY_FILE", ""),
 	}
 }
 
@@ -34,3 +44,53 @@ func GetEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// sizeUnits maps the suffixes accepted by ParseSize to their multiplier.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize reads a byte count such as "500MB", "2GB" or "1048576". Units are binary
+// (1KB = 1024 bytes). Zero means no limit; invalid values fall back to the default.
+func ParseSize(value string, fallback int64) int64 {
+	number := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/multiplier {
+		log.Printf("Warning: Invalid size %s, using default %d bytes", value, fallback)
+		return fallback
+	}
+	return n * multiplier
+}
+
+// ParseTTL reads a retention period such as "72h" or "30d". Zero means no expiry.
+func ParseTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid retention %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid retention %q", value)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1048576": 1 << 20,
+		"500MB":   500 << 20,
+		"2gb":     2 << 30,
+		"64 KB":   64 << 10,
+		"10B":     10,
+		"1TB":     1 << 40,
+		"0":       0,
+	}
+	for value, expected := range cases {
+		if got := ParseSize(value, 42); got != expected {
+			t.Errorf("ParseSize(%q) = %d, expected %d", value, got, expected)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "-1MB", "1.5GB", "99999999TB"} {
+		if got := ParseSize(value, 42); got != 42 {
+			t.Errorf("ParseSize(%q) = %d, expected fallback 42", value, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Defaults for the embedded job database.
+const (
+	DefaultEmbeddedMaxAttempts = 5
+	DefaultEmbeddedWorkers     = 2
+)
+
+// boltLockTimeout bounds the wait for the lock of a database file another process holds.
+const boltLockTimeout = 5 * time.Second
+
+// EmbeddedConfig places the bolt database that holds jobs and the job queue when
+// JOB_STORE or JOB_QUEUE is "bolt", for installs without DynamoDB or SQS.
+type EmbeddedConfig struct {
+	// Path is the database file. Bolt locks it, so only one process can open it.
+	Path string
+	// MaxAttempts is how often a queued job is delivered before it moves to the dead
+	// letters.
+	MaxAttempts int
+	// VisibilityTimeout hides a received job from other workers; workers extend it
+	// while they process the job.
+	VisibilityTimeout time.Duration
+	// Workers is how many queued jobs are processed at once, and how many jobs the API
+	// runs at once in the background when there is no job queue.
+	Workers int
+}
+
+func NewEmbeddedConfig() *EmbeddedConfig {
+	return &EmbeddedConfig{
+		Path:              GetEnv("JOB_DB_PATH", filepath.Join("data", "jobs.db")),
+		MaxAttempts:       parseCount("JOB_MAX_ATTEMPTS", GetEnv("JOB_MAX_ATTEMPTS", "5"), DefaultEmbeddedMaxAttempts),
+		VisibilityTimeout: parseVisibilityTimeout(GetEnv("JOB_VISIBILITY_TIMEOUT", "1m")),
+		Workers:           parseCount("JOB_WORKERS", GetEnv("JOB_WORKERS", "2"), DefaultEmbeddedWorkers),
+	}
+}
+
+// parseCount reads a positive count, falling back when value is anything else.
+func parseCount(name, value string, fallback int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 1 {
+		log.Printf("Warning: Invalid %s %s, using default %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+var (
+	boltMu  sync.Mutex
+	boltDBs = map[string]*bolt.DB{}
+)
+
+// OpenBoltDB opens the bolt database at path, creating it and its directory. The job
+// store and the job queue share one database, so every call with the same path
+// returns the same handle.
+func OpenBoltDB(path string) (*bolt.DB, error) {
+	boltMu.Lock()
+	defer boltMu.Unlock()
+
+	if db, ok := boltDBs[path]; ok {
+		return db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create directory of %s: %w", path, err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job database %s: %w", path, err)
+	}
+	boltDBs[path] = db
+	return db, nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCount(t *testing.T) {
+	counts := map[string]int{"3": 3, " 8 ": 8, "0": 5, "-1": 5, "x": 5}
+	for input, expected := range counts {
+		if result := parseCount("JOB_MAX_ATTEMPTS", input, 5); result != expected {
+			t.Errorf("parseCount(%s) = %d, want %d", input, result, expected)
+		}
+	}
+}
+
+func TestOpenBoltDB_SharesTheDatabaseOfAPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "jobs.db")
+
+	first, err := OpenBoltDB(path)
+	if err != nil {
+		t.Fatalf("OpenBoltDB(%s) failed: %v", path, err)
+	}
+	second, err := OpenBoltDB(path)
+	if err != nil {
+		t.Fatalf("OpenBoltDB(%s) failed: %v", path, err)
+	}
+	if first != second {
+		t.Errorf("OpenBoltDB(%s) opened the database twice", path)
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// NewDynamoDBClient connects to DynamoDB, through EndpointURL on LocalStack.
+func NewDynamoDBClient(awsConfig *AWSConfig) (*dynamodb.DynamoDB, error) {
+	sess, err := createServiceSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return dynamodb.New(sess, aws.NewConfig().WithEndpoint(awsConfig.GetDynamoDBEndpoint())), nil
+}
+
+// createServiceSession returns a session for the AWS services other than S3. Only
+// LocalStack emulates them, so other S3 providers still reach AWS for these.
+func createServiceSession(awsConfig *AWSConfig) (*session.Session, error) {
+	config := &aws.Config{
+		Region: aws.String(awsConfig.Region),
+	}
+
+	if awsConfig.IsLocalStack() && awsConfig.EndpointURL != "" {
+		config.Credentials = credentials.NewStaticCredentials(
+			awsConfig.AccessKeyID,
+			awsConfig.SecretAccessKey,
+			"",
+		)
+
+		httpClient, err := awsConfig.HTTPClient(0)
+		if err != nil {
+			return nil, err
+		}
+		config.HTTPClient = httpClient
+	}
+
+	return session.NewSession(config)
+}
@@ -0,0 +1,32 @@
+package config
+
+// Variables of key prefix and bucket name templates. Date variables come from the job
+// ID, which is the upload timestamp.
+const (
+	TenantVariable = "{tenant}"
+	YearVariable   = "{yyyy}"
+	MonthVariable  = "{mm}"
+	DayVariable    = "{dd}"
+	JobIDVariable  = "{job_id}"
+)
+
+// DefaultTenant owns requests that do not name a tenant.
+const DefaultTenant = "default"
+
+// TenantHeader names the tenant of an API request. The API forwards it to the processor.
+const TenantHeader = "X-Tenant-ID"
+
+// RoutingConfig places each tenant's objects within a bucket. KeyPrefix is a template
+// such as "{tenant}/{yyyy}/{mm}/{job_id}/"; empty keeps every object at the bucket root,
+// as before tenants existed. Bucket templates live in S3Config.
+type RoutingConfig struct {
+	KeyPrefix     string
+	DefaultTenant string
+}
+
+func NewRoutingConfig() *RoutingConfig {
+	return &RoutingConfig{
+		KeyPrefix:     GetEnv("STORAGE_KEY_PREFIX", ""),
+		DefaultTenant: GetEnv("DEFAULT_TENANT", DefaultTenant),
+	}
+}
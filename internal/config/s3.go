@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -41,15 +44,21 @@ func createAWSSession(awsConfig *AWSConfig) (*session.Session, error) {
 		Region: aws.String(awsConfig.Region),
 	}
 
-	if awsConfig.IsLocalStack() {
+	if awsConfig.HasCustomEndpoint() {
 		config.Endpoint = aws.String(awsConfig.EndpointURL)
-		config.S3ForcePathStyle = aws.Bool(true)
 		config.Credentials = credentials.NewStaticCredentials(
 			awsConfig.AccessKeyID,
 			awsConfig.SecretAccessKey,
 			"",
 		)
+
+		httpClient, err := awsConfig.HTTPClient(0)
+		if err != nil {
+			return nil, err
+		}
+		config.HTTPClient = httpClient
 	}
+	config.S3ForcePathStyle = aws.Bool(awsConfig.UsePathStyle())
 
 	return session.NewSession(config)
 }
@@ -59,6 +68,14 @@ func (s *S3Service) UploadFile(bucket, key string, body io.Reader) error {
 }
 
 func (s *S3Service) UploadFileWithContentType(bucket, key string, body io.Reader, contentType string) error {
+	return s.UploadFileWithMetadata(bucket, key, body, contentType, nil, nil, "")
+}
+
+// UploadFileWithMetadata uploads key with user metadata (x-amz-meta-*) and object tags.
+// Metadata values must already be safe to send as HTTP header values. A non-empty
+// checksumSHA256 (base64) is sent as x-amz-checksum-sha256 for S3 to verify; S3 only
+// checks it on uploads small enough to go in a single part.
+func (s *S3Service) UploadFileWithMetadata(bucket, key string, body io.Reader, contentType string, metadata, tags map[string]string, checksumSHA256 string) error {
 	// Set appropriate content type based on file extension if not provided
 	if contentType == "" {
 		if strings.HasSuffix(strings.ToLower(key), ".zip") {
@@ -70,11 +87,17 @@ func (s *S3Service) UploadFileWithContentType(bucket, key string, body io.Reader
 		}
 	}
 
+	sse, kmsKeyID := s.encryptionParams(bucket)
 	_, err := s.uploader.Upload(&s3manager.UploadInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(key),
-		Body:        body,
-		ContentType: aws.String(contentType),
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 body,
+		ContentType:          aws.String(contentType),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+		Metadata:             aws.StringMap(metadata),
+		Tagging:              tagging(tags),
+		ChecksumSHA256:       optionalString(checksumSHA256),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to upload file to S3: %w", err)
@@ -84,6 +107,26 @@ func (s *S3Service) UploadFileWithContentType(bucket, key string, body io.Reader
 	return nil
 }
 
+// tagging encodes tags as the URL query the x-amz-tagging header expects.
+func tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return aws.String(values.Encode())
+}
+
+// optionalString leaves empty values out of a request.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
 func (s *S3Service) DownloadFile(bucket, key string) (io.ReadCloser, error) {
 	result, err := s.client.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(bucket),
@@ -110,16 +153,13 @@ func (s *S3Service) DeleteFile(bucket, key string) error {
 }
 
 func (s *S3Service) ListFiles(bucket, prefix string) ([]string, error) {
-	result, err := s.client.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
-		Prefix: aws.String(prefix),
-	})
+	objects, err := s.ListObjects(bucket, prefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list files from S3: %w", err)
+		return nil, err
 	}
 
-	var files []string
-	for _, obj := range result.Contents {
+	files := make([]string, 0, len(objects))
+	for _, obj := range objects {
 		if obj.Key != nil {
 			files = append(files, *obj.Key)
 		}
@@ -128,6 +168,44 @@ func (s *S3Service) ListFiles(bucket, prefix string) ([]string, error) {
 	return files, nil
 }
 
+// ListObjects returns every object under prefix, following continuation tokens.
+func (s *S3Service) ListObjects(bucket, prefix string) ([]*s3.Object, error) {
+	var objects []*s3.Object
+
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		objects = append(objects, page.Contents...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files from S3: %w", err)
+	}
+
+	return objects, nil
+}
+
+// ListObjectsPage returns up to maxKeys objects under prefix whose keys sort after
+// startAfter, and whether more objects follow.
+func (s *S3Service) ListObjectsPage(bucket, prefix, startAfter string, maxKeys int64) ([]*s3.Object, bool, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(maxKeys),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+
+	result, err := s.client.ListObjectsV2(input)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list files from S3: %w", err)
+	}
+
+	return result.Contents, aws.BoolValue(result.IsTruncated), nil
+}
+
 func (s *S3Service) FileExists(bucket, key string) (bool, error) {
 	_, err := s.client.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
@@ -155,58 +233,172 @@ func (s *S3Service) GetFileInfo(bucket, key string) (*s3.HeadObjectOutput, error
 	return result, nil
 }
 
+// GeneratePresignedURL returns a GET URL for key. Objects encrypted with SSE-S3 or
+// SSE-KMS need no extra headers on download; SigV4 signing covers the KMS case.
 func (s *S3Service) GeneratePresignedURL(bucket, key string, expiration time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	urlStr, _, err := s.presign(req, expiration)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Generated presigned URL for s3://%s/%s (expires in %v)", bucket, key, expiration)
+	return urlStr, nil
+}
+
+// GeneratePresignedPutURL returns a PUT URL for key along with the headers the client
+// must send with the upload, which include the bucket's server-side encryption settings.
+func (s *S3Service) GeneratePresignedPutURL(bucket, key, contentType string, expiration time.Duration) (string, http.Header, error) {
+	sse, kmsKeyID := s.encryptionParams(bucket)
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	req, _ := s.client.PutObjectRequest(input)
+	urlStr, headers, err := s.presign(req, expiration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.Printf("Generated presigned upload URL for s3://%s/%s (expires in %v)", bucket, key, expiration)
+	return urlStr, headers, nil
+}
+
+func (s *S3Service) presign(req *request.Request, expiration time.Duration) (string, http.Header, error) {
 	// Use configurable timeout instead of hardcoded expiration
 	if expiration == 0 {
 		expiration = s.config.PresignedTimeout
 	}
 
-	if s.config.IsLocalStack() {
-		// For LocalStack, generate proper presigned URL using AWS SDK
-		req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
-			Bucket: aws.String(bucket),
-			Key:    aws.String(key),
-		})
-
-		// Override endpoint for browser-accessible URL
-		if s.config.ExternalURL != "" {
-			req.HTTPRequest.URL.Host = strings.Replace(s.config.ExternalURL, "http://", "", 1)
-			req.HTTPRequest.URL.Host = strings.Replace(req.HTTPRequest.URL.Host, "https://", "", 1)
-		} else {
-			// Default LocalStack external URL for browser access
-			req.HTTPRequest.URL.Host = "localhost:4566"
+	if s.config.HasCustomEndpoint() {
+		// Sign for the browser-accessible endpoint, which may differ from the one the
+		// services reach (e.g. localstack:4566 inside Docker).
+		external, err := url.Parse(s.config.GetExternalEndpoint())
+		if err != nil || external.Host == "" {
+			return "", nil, fmt.Errorf("invalid external S3 URL %q", s.config.GetExternalEndpoint())
 		}
-		req.HTTPRequest.URL.Scheme = "http" // LocalStack uses HTTP
+		req.HTTPRequest.URL.Scheme = external.Scheme
+		req.HTTPRequest.URL.Host = external.Host
+	}
 
-		urlStr, err := req.Presign(expiration)
-		if err != nil {
-			return "", fmt.Errorf("failed to generate LocalStack presigned URL: %w", err)
-		}
+	urlStr, signed, err := req.PresignRequest(expiration)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
 
-		// Security First: Validate generated URL
-		if err := s.config.ValidateURL(urlStr); err != nil {
-			return "", fmt.Errorf("generated URL failed security validation: %w", err)
+	// The signer returns lower-case keys; canonicalize them so callers can use Header.Get.
+	headers := make(http.Header, len(signed))
+	for name, values := range signed {
+		for _, value := range values {
+			headers.Add(name, value)
 		}
+	}
 
-		return urlStr, nil
+	// Security First: Validate generated URL
+	if err := s.config.ValidateURL(urlStr); err != nil {
+		return "", nil, fmt.Errorf("generated URL failed security validation: %w", err)
 	}
 
-	// For real AWS, generate standard presigned URL
-	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+	return urlStr, headers, nil
+}
+
+// encryptionParams returns the SSE request parameters for bucket, or nils when the
+// bucket relies on its default encryption.
+func (s *S3Service) encryptionParams(bucket string) (*string, *string) {
+	encryption := s.config.S3Buckets.EncryptionFor(bucket)
+	if !encryption.Enabled() {
+		return nil, nil
+	}
+	if encryption.Mode == EncryptionSSEKMS && encryption.KMSKeyID != "" {
+		return aws.String(encryption.Mode), aws.String(encryption.KMSKeyID)
+	}
+	return aws.String(encryption.Mode), nil
+}
+
+// CreateMultipartUpload starts a multipart upload for key with the bucket's encryption
+// settings and returns its upload ID.
+func (s *S3Service) CreateMultipartUpload(bucket, key, contentType string) (string, error) {
+	sse, kmsKeyID := s.encryptionParams(bucket)
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		ServerSideEncryption: sse,
+		SSEKMSKeyId:          kmsKeyID,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	result, err := s.client.CreateMultipartUpload(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	return aws.StringValue(result.UploadId), nil
+}
+
+// GeneratePresignedPartURL returns a PUT URL for one part of a multipart upload.
+func (s *S3Service) GeneratePresignedPartURL(bucket, key, uploadID string, partNumber int64, expiration time.Duration) (string, error) {
+	req, _ := s.client.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
 	})
 
-	urlStr, err := req.Presign(expiration)
+	urlStr, _, err := s.presign(req, expiration)
+	return urlStr, err
+}
+
+// UploadPart uploads one part of a multipart upload and returns its ETag.
+func (s *S3Service) UploadPart(bucket, key, uploadID string, partNumber int64, body io.ReadSeeker) (string, error) {
+	result, err := s.client.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
+		Body:       body,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
 	}
 
-	// Security First: Validate generated URL
-	if err := s.config.ValidateURL(urlStr); err != nil {
-		return "", fmt.Errorf("generated URL failed security validation: %w", err)
+	return aws.StringValue(result.ETag), nil
+}
+
+func (s *S3Service) CompleteMultipartUpload(bucket, key, uploadID string, parts []*s3.CompletedPart) error {
+	_, err := s.client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
 	}
 
-	log.Printf("Generated presigned URL for s3://%s/%s (expires in %v)", bucket, key, expiration)
-	return urlStr, nil
+	log.Printf("Successfully completed multipart upload to s3://%s/%s", bucket, key)
+	return nil
+}
+
+func (s *S3Service) AbortMultipartUpload(bucket, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
 }
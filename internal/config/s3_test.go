@@ -0,0 +1,119 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestS3Service(t *testing.T, encryption BucketEncryption) *S3Service {
+	t.Helper()
+	service, err := NewS3Service(&AWSConfig{
+		Region:          "us-east-1",
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		EndpointURL:     DefaultLocalStackEndpoint,
+		S3Buckets: S3Config{
+			UploadsBucket:     "uploads",
+			OutputsBucket:     "outputs",
+			UploadsEncryption: encryption,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewS3Service() error = %v", err)
+	}
+	return service
+}
+
+func TestGeneratePresignedPutURL_SignsKMSHeaders(t *testing.T) {
+	keyARN := "arn:aws:kms:us-east-1:000000000000:alias/videogrinder-s3"
+	service := newTestS3Service(t, BucketEncryption{Mode: EncryptionSSEKMS, KMSKeyID: keyARN})
+
+	urlStr, headers, err := service.GeneratePresignedPutURL("uploads", "video.mp4", "video/mp4", time.Minute)
+	if err != nil {
+		t.Fatalf("GeneratePresignedPutURL() error = %v", err)
+	}
+
+	if got := headers.Get("X-Amz-Server-Side-Encryption"); got != EncryptionSSEKMS {
+		t.Errorf("Expected SSE header %s, got %q", EncryptionSSEKMS, got)
+	}
+	if got := headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != keyARN {
+		t.Errorf("Expected KMS key header %s, got %q", keyARN, got)
+	}
+
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		t.Fatalf("invalid presigned URL: %v", err)
+	}
+	signed := parsed.Query().Get("X-Amz-SignedHeaders")
+	if !containsString(signed, "x-amz-server-side-encryption") {
+		t.Errorf("Expected encryption headers to be signed, got %s", signed)
+	}
+}
+
+func TestGeneratePresignedPutURL_UnencryptedBucket(t *testing.T) {
+	service := newTestS3Service(t, BucketEncryption{Mode: EncryptionSSES3})
+
+	_, headers, err := service.GeneratePresignedPutURL("outputs", "frames.zip", "", time.Minute)
+	if err != nil {
+		t.Fatalf("GeneratePresignedPutURL() error = %v", err)
+	}
+
+	if got := headers.Get("X-Amz-Server-Side-Encryption"); got != "" {
+		t.Errorf("Expected no SSE header for unencrypted bucket, got %q", got)
+	}
+}
+
+func TestGeneratePresignedURL_UsesExternalURLOfProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *AWSConfig
+		expected string
+	}{
+		{
+			name:     "minio behind TLS proxy",
+			config:   &AWSConfig{Provider: ProviderMinIO, EndpointURL: "http://minio:9000", ExternalURL: "https://files.example.com"},
+			expected: "https://files.example.com/outputs/frames.zip",
+		},
+		{
+			name:     "generic without external URL",
+			config:   &AWSConfig{Provider: ProviderGeneric, EndpointURL: "https://ceph.internal:7480"},
+			expected: "https://ceph.internal:7480/outputs/frames.zip",
+		},
+		{
+			name:     "localstack default",
+			config:   &AWSConfig{EndpointURL: "http://localstack:4566"},
+			expected: DefaultLocalStackEndpoint + "/outputs/frames.zip",
+		},
+		{
+			name:     "minio with virtual-hosted buckets",
+			config:   &AWSConfig{Provider: ProviderMinIO, EndpointURL: "https://s3.example.com", AddressingStyle: AddressingVirtual},
+			expected: "https://outputs.s3.example.com/frames.zip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Region = "us-east-1"
+			tt.config.AccessKeyID = "test"
+			tt.config.SecretAccessKey = "test"
+			service, err := NewS3Service(tt.config)
+			if err != nil {
+				t.Fatalf("NewS3Service() error = %v", err)
+			}
+
+			urlStr, err := service.GeneratePresignedURL("outputs", "frames.zip", time.Minute)
+			if err != nil {
+				t.Fatalf("GeneratePresignedURL() error = %v", err)
+			}
+			parsed, err := url.Parse(urlStr)
+			if err != nil {
+				t.Fatalf("invalid presigned URL: %v", err)
+			}
+			parsed.RawQuery = ""
+			if parsed.String() != tt.expected {
+				t.Errorf("Expected presigned URL for %s, got %s", tt.expected, parsed.String())
+			}
+		})
+	}
+}
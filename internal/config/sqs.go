@@ -0,0 +1,17 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// NewSQSClient connects to SQS, through EndpointURL on LocalStack.
+func NewSQSClient(awsConfig *AWSConfig) (*sqs.SQS, error) {
+	sess, err := createServiceSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return sqs.New(sess, aws.NewConfig().WithEndpoint(awsConfig.GetSQSEndpoint())), nil
+}
@@ -0,0 +1,299 @@
+// Package dedup indexes processing results by source content and effective options, so a
+// video that was already processed the same way reuses the outputs of the first job.
+// Every job served from an entry holds a reference; outputs go away with the last one.
+package dedup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"video-processor/internal/storage"
+)
+
+// Prefix holds the index inside the outputs storage, away from the archive listing.
+const Prefix = ".dedup/"
+
+// ErrNotFound is returned when no result is indexed under a digest.
+var ErrNotFound = errors.New("dedup entry not found")
+
+// Entry is the result of the job that produced the shared outputs.
+type Entry struct {
+	Digest    string          `json:"digest"`
+	VideoID   string          `json:"video_id"`
+	Result    json.RawMessage `json:"result"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Index keeps entries, references and the video-to-entry mapping as small objects.
+// References are separate objects rather than a counter, so the processor taking one
+// and the API dropping one never overwrite each other.
+type Index struct {
+	store storage.Storage
+	mu    sync.Mutex
+}
+
+func NewIndex(store storage.Storage) *Index {
+	return &Index{store: store}
+}
+
+// Digest identifies a job by the SHA-256 of its source and the options that shaped the outputs.
+func Digest(sourceHash string, fingerprint []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(sourceHash))
+	hash.Write([]byte{0})
+	hash.Write(fingerprint)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func entryKey(digest string) string {
+	return Prefix + "entries/" + digest + ".json"
+}
+
+func refsPrefix(digest string) string {
+	return Prefix + "refs/" + digest + "/"
+}
+
+func videoKey(videoID string) string {
+	return Prefix + "videos/" + videoID
+}
+
+// Lookup returns the entry indexed under digest.
+func (ix *Index) Lookup(digest string) (*Entry, error) {
+	reader, err := ix.store.Get(entryKey(digest))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close dedup entry %s: %v", digest, err)
+		}
+	}()
+
+	var entry Entry
+	if err := json.NewDecoder(reader).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode dedup entry %s: %w", digest, err)
+	}
+	return &entry, nil
+}
+
+// Record indexes the outputs of a finished job and takes their first reference. It
+// returns false without indexing anything when the digest is already taken.
+func (ix *Index) Record(entry *Entry) (bool, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if _, err := ix.Lookup(entry.Digest); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	if err := ix.put(videoKey(entry.VideoID), []byte(entry.Digest)); err != nil {
+		return false, err
+	}
+	if err := ix.addRef(entry.Digest); err != nil {
+		return false, err
+	}
+	if err := ix.putEntry(entry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Acquire takes a reference on the entry under digest. It returns ErrNotFound, holding
+// nothing, when the entry is missing or its last reference was released meanwhile.
+func (ix *Index) Acquire(digest string) (*Entry, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if _, err := ix.Lookup(digest); err != nil {
+		return nil, err
+	}
+	if err := ix.addRef(digest); err != nil {
+		return nil, err
+	}
+
+	// Release deletes the entry before the outputs and checks for new references
+	// afterwards, so an entry still present here means the outputs are kept.
+	entry, err := ix.Lookup(digest)
+	if err != nil {
+		if dropErr := ix.dropRef(digest); dropErr != nil {
+			log.Printf("Warning: Failed to drop dedup reference on %s: %v", digest, dropErr)
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Drop gives back a reference taken by Acquire whose result was not used.
+func (ix *Index) Drop(digest string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.dropRef(digest)
+}
+
+// Release gives back one reference on the outputs of videoID. When it is the last one,
+// or the video is not indexed, it calls remove to delete the outputs and drops the
+// reference only once they are gone; a failed remove leaves the index as it was. It
+// reports whether the outputs were removed.
+func (ix *Index) Release(videoID string, remove func() error) (bool, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	data, err := ix.get(videoKey(videoID))
+	if errors.Is(err, storage.ErrNotFound) {
+		if err := remove(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	digest := string(data)
+
+	if remaining, err := ix.refCount(digest); err != nil {
+		return false, err
+	} else if remaining > 1 {
+		return false, ix.dropRef(digest)
+	}
+
+	// Deleting the entry first keeps jobs from taking a reference on outputs about to go.
+	entry, err := ix.Lookup(digest)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if err := ix.deleteIfExists(entryKey(digest)); err != nil {
+		return false, err
+	}
+	restore := func() {
+		if entry == nil {
+			return
+		}
+		if err := ix.putEntry(entry); err != nil {
+			log.Printf("Warning: Failed to restore dedup entry %s: %v", digest, err)
+		}
+	}
+
+	// A job may have taken a reference between the count and the delete; put the entry
+	// back and keep the outputs for it.
+	if remaining, err := ix.refCount(digest); err != nil || remaining > 1 {
+		restore()
+		if err != nil {
+			return false, err
+		}
+		return false, ix.dropRef(digest)
+	}
+
+	if err := remove(); err != nil {
+		restore()
+		return false, err
+	}
+
+	if err := ix.dropRef(digest); err != nil {
+		log.Printf("Warning: Failed to drop dedup reference on %s: %v", digest, err)
+	}
+	if err := ix.deleteIfExists(videoKey(videoID)); err != nil {
+		log.Printf("Warning: Failed to delete dedup mapping for %s: %v", videoID, err)
+	}
+	return true, nil
+}
+
+// Forget removes an entry whose outputs no longer exist, with all its references.
+func (ix *Index) Forget(digest string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	entry, err := ix.Lookup(digest)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err := ix.deleteIfExists(entryKey(digest)); err != nil {
+		return err
+	}
+
+	refs, err := ix.store.List(refsPrefix(digest))
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := ix.deleteIfExists(ref.Key); err != nil {
+			return err
+		}
+	}
+
+	if entry != nil {
+		return ix.deleteIfExists(videoKey(entry.VideoID))
+	}
+	return nil
+}
+
+func (ix *Index) refCount(digest string) (int, error) {
+	refs, err := ix.store.List(refsPrefix(digest))
+	return len(refs), err
+}
+
+func (ix *Index) addRef(digest string) error {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	ref := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(buf))
+	return ix.put(refsPrefix(digest)+ref, nil)
+}
+
+// dropRef deletes the oldest reference on digest.
+func (ix *Index) dropRef(digest string) error {
+	refs, err := ix.store.List(refsPrefix(digest))
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+	return ix.deleteIfExists(refs[0].Key)
+}
+
+func (ix *Index) putEntry(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return ix.store.Put(entryKey(entry.Digest), bytes.NewReader(data), "application/json")
+}
+
+func (ix *Index) put(key string, data []byte) error {
+	return ix.store.Put(key, bytes.NewReader(data), "")
+}
+
+func (ix *Index) get(key string) ([]byte, error) {
+	reader, err := ix.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close dedup object %s: %v", key, err)
+		}
+	}()
+	data, err := io.ReadAll(reader)
+	return []byte(strings.TrimSpace(string(data))), err
+}
+
+func (ix *Index) deleteIfExists(key string) error {
+	if err := ix.store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return nil
+}
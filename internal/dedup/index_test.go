@@ -0,0 +1,114 @@
+package dedup
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stores(t *testing.T) map[string]storage.Storage {
+	return map[string]storage.Storage{
+		"filesystem": storage.NewFilesystem(t.TempDir()),
+		"memory":     storage.NewMemory(),
+	}
+}
+
+func TestDigest_ShouldDependOnSourceAndOptions(t *testing.T) {
+	base := Digest("abc", []byte(`{"hls":true}`))
+
+	assert.Len(t, base, 64)
+	assert.Equal(t, base, Digest("abc", []byte(`{"hls":true}`)))
+	assert.NotEqual(t, base, Digest("abd", []byte(`{"hls":true}`)))
+	assert.NotEqual(t, base, Digest("abc", []byte(`{"hls":false}`)))
+}
+
+func TestIndex_ShouldReleaseOutputsWithLastReference(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ix := NewIndex(store)
+			entry := &Entry{Digest: "d1", VideoID: "20240101_120000", Result: json.RawMessage(`{"zip_path":"frames_20240101_120000.zip"}`)}
+
+			recorded, err := ix.Record(entry)
+			require.NoError(t, err)
+			assert.True(t, recorded)
+
+			recorded, err = ix.Record(&Entry{Digest: "d1", VideoID: "20240101_130000"})
+			require.NoError(t, err)
+			assert.False(t, recorded, "an indexed digest keeps its first result")
+
+			acquired, err := ix.Acquire("d1")
+			require.NoError(t, err)
+			assert.Equal(t, "20240101_120000", acquired.VideoID)
+			assert.JSONEq(t, `{"zip_path":"frames_20240101_120000.zip"}`, string(acquired.Result))
+
+			removed, err := ix.Release("20240101_120000", failRemove(t))
+			require.NoError(t, err)
+			assert.False(t, removed, "the second job still uses the outputs")
+
+			removed, err = ix.Release("20240101_120000", func() error { return nil })
+			require.NoError(t, err)
+			assert.True(t, removed)
+
+			_, err = ix.Lookup("d1")
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = ix.Acquire("d1")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestIndex_ShouldLetUnindexedVideosBeDeleted(t *testing.T) {
+	ix := NewIndex(storage.NewMemory())
+
+	removed, err := ix.Release("20240101_120000", func() error { return nil })
+
+	require.NoError(t, err)
+	assert.True(t, removed)
+}
+
+func TestIndex_ShouldKeepReferenceWhenRemoveFails(t *testing.T) {
+	store := storage.NewMemory()
+	ix := NewIndex(store)
+	_, err := ix.Record(&Entry{Digest: "d1", VideoID: "20240101_120000"})
+	require.NoError(t, err)
+
+	removeErr := errors.New("trash unavailable")
+	removed, err := ix.Release("20240101_120000", func() error { return removeErr })
+	assert.ErrorIs(t, err, removeErr)
+	assert.False(t, removed)
+
+	acquired, err := ix.Acquire("d1")
+	require.NoError(t, err, "the outputs still exist, so the entry stays")
+	assert.Equal(t, "20240101_120000", acquired.VideoID)
+	count, err := ix.refCount("d1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+// failRemove fails the test if Release removes outputs that are still referenced.
+func failRemove(t *testing.T) func() error {
+	return func() error {
+		t.Error("outputs removed while still referenced")
+		return nil
+	}
+}
+
+func TestIndex_ShouldForgetEntriesAndReferences(t *testing.T) {
+	store := storage.NewMemory()
+	ix := NewIndex(store)
+	_, err := ix.Record(&Entry{Digest: "d1", VideoID: "20240101_120000"})
+	require.NoError(t, err)
+	_, err = ix.Acquire("d1")
+	require.NoError(t, err)
+
+	require.NoError(t, ix.Forget("d1"))
+
+	objects, err := store.List(Prefix)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
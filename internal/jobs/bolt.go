@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the jobs in a bolt database: every job by ID, the IDs of the jobs in each
+// status, and the IDs of the jobs of each tenant in each status by position.
+var (
+	boltJobsBucket      = []byte("jobs")
+	boltStatusesBucket  = []byte("job-statuses")
+	boltPositionsBucket = []byte("job-positions")
+)
+
+// BoltRepository stores jobs in an embedded bolt database file, for installs without
+// DynamoDB. Bolt allows a single writer at a time, so updates never race; the database
+// is locked by the process that opened it, which must be the only one writing jobs.
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// boltJob is a job as stored, keeping the version the JSON of a Job leaves out.
+type boltJob struct {
+	Job
+	Version int `json:"version"`
+}
+
+// NewBoltRepository stores jobs in db, creating its buckets. Jobs stored before the
+// positions bucket existed are indexed into it.
+func NewBoltRepository(db *bolt.DB) (*BoltRepository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		jobs, err := tx.CreateBucketIfNotExists(boltJobsBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltStatusesBucket); err != nil {
+			return err
+		}
+		if tx.Bucket(boltPositionsBucket) != nil {
+			return nil
+		}
+		if _, err := tx.CreateBucket(boltPositionsBucket); err != nil {
+			return err
+		}
+		return jobs.ForEach(func(id, _ []byte) error {
+			job, err := getBoltJob(tx, string(id))
+			if err != nil {
+				return err
+			}
+			return putBoltPosition(tx, job)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job buckets: %w", err)
+	}
+	return &BoltRepository{db: db}, nil
+}
+
+func (b *BoltRepository) Create(job *Job) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltJobsBucket).Get([]byte(job.ID)) != nil {
+			return ErrExists
+		}
+		return putBoltJob(tx, clone(*job), "")
+	})
+}
+
+func (b *BoltRepository) Get(id string) (*Job, error) {
+	var job *Job
+	err := b.db.View(func(tx *bolt.Tx) error {
+		stored, err := getBoltJob(tx, id)
+		job = stored
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (b *BoltRepository) Update(id string, update Update, at time.Time) (*Job, error) {
+	var job *Job
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		stored, err := getBoltJob(tx, id)
+		if err != nil {
+			return err
+		}
+		previous := stored.Status
+		if err := apply(stored, update, at); err != nil {
+			return err
+		}
+		stored.Version++
+		job = stored
+		return putBoltJob(tx, clone(*stored), previous)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (b *BoltRepository) ListByStatus(status string, limit int) ([]Job, error) {
+	result := []Job{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(boltStatusesBucket).Bucket([]byte(status))
+		if ids == nil {
+			return nil
+		}
+		return ids.ForEach(func(id, _ []byte) error {
+			job, err := getBoltJob(tx, string(id))
+			if err != nil {
+				return err
+			}
+			result = append(result, *job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewestFirst(result, limit), nil
+}
+
+func (b *BoltRepository) ListByTenant(tenant string, statuses []string, limit int, after string) ([]Job, string, error) {
+	listings := make([][]Job, 0, len(statuses))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for _, status := range statuses {
+			listing, err := listBoltPositions(tx, tenantStatus(tenant, status), limit, after)
+			if err != nil {
+				return err
+			}
+			listings = append(listings, listing)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	page, next := mergePages(listings, limit)
+	return page, next, nil
+}
+
+// listBoltPositions reads up to limit+1 jobs of a listing before after, most recent
+// first; a limit below 1 reads them all.
+func listBoltPositions(tx *bolt.Tx, listing string, limit int, after string) ([]Job, error) {
+	positions := tx.Bucket(boltPositionsBucket).Bucket([]byte(listing))
+	if positions == nil {
+		return nil, nil
+	}
+
+	cursor := positions.Cursor()
+	key, id := cursor.Last()
+	if after != "" {
+		// Seek lands on the first position at or past after; the page starts before it.
+		if key, _ = cursor.Seek([]byte(after)); key == nil {
+			key, id = cursor.Last()
+		} else {
+			key, id = cursor.Prev()
+		}
+	}
+
+	var result []Job
+	for ; key != nil && (limit < 1 || len(result) <= limit); key, id = cursor.Prev() {
+		job, err := getBoltJob(tx, string(id))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *job)
+	}
+	return result, nil
+}
+
+func getBoltJob(tx *bolt.Tx, id string) (*Job, error) {
+	data := tx.Bucket(boltJobsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var stored boltJob
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %w", id, err)
+	}
+	stored.Job.Version = stored.Version
+	return &stored.Job, nil
+}
+
+// putBoltJob writes job and moves its ID from the indexes of previous, when set, to
+// the indexes of its status.
+func putBoltJob(tx *bolt.Tx, job Job, previous string) error {
+	data, err := json.Marshal(boltJob{Job: job, Version: job.Version})
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltJobsBucket).Put([]byte(job.ID), data); err != nil {
+		return err
+	}
+
+	statuses := tx.Bucket(boltStatusesBucket)
+	if previous != "" && previous != job.Status {
+		if ids := statuses.Bucket([]byte(previous)); ids != nil {
+			if err := ids.Delete([]byte(job.ID)); err != nil {
+				return err
+			}
+		}
+		if positions := tx.Bucket(boltPositionsBucket).Bucket([]byte(tenantStatus(job.Tenant, previous))); positions != nil {
+			if err := positions.Delete([]byte(position(&job))); err != nil {
+				return err
+			}
+		}
+	}
+	ids, err := statuses.CreateBucketIfNotExists([]byte(job.Status))
+	if err != nil {
+		return err
+	}
+	if err := ids.Put([]byte(job.ID), []byte{}); err != nil {
+		return err
+	}
+	return putBoltPosition(tx, &job)
+}
+
+// putBoltPosition indexes job in the listing of its tenant and status.
+func putBoltPosition(tx *bolt.Tx, job *Job) error {
+	positions, err := tx.Bucket(boltPositionsBucket).CreateBucketIfNotExists([]byte(tenantStatus(job.Tenant, job.Status)))
+	if err != nil {
+		return err
+	}
+	return positions.Put([]byte(position(job)), []byte(job.ID))
+}
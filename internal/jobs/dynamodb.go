@@ -0,0 +1,300 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// StatusIndex is the global secondary index of the jobs table keyed by status.
+const StatusIndex = "status-index"
+
+// TenantStatusIndex is the global secondary index of the jobs table that lists the
+// jobs of a tenant in a status by position: partition key "tenant_status", sort key
+// "position". Jobs stored before it existed lack those attributes until IndexListings
+// backfills them.
+const TenantStatusIndex = "tenant-status-index"
+
+// Attributes of the job items that key TenantStatusIndex.
+const (
+	tenantStatusAttribute = "tenant_status"
+	positionAttribute     = "position"
+)
+
+// updateAttempts bounds how often an update is retried after losing a race with
+// another writer of the same job.
+const updateAttempts = 5
+
+// DynamoDBRepository stores jobs in a DynamoDB table keyed by "id", with StatusIndex
+// on "status" and TenantStatusIndex, as localstack-init.sh creates it. Each item
+// carries a version; updates only succeed against the version they read, so concurrent
+// writers never lose a transition.
+type DynamoDBRepository struct {
+	client dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+func NewDynamoDBRepository(client dynamodbiface.DynamoDBAPI, table string) *DynamoDBRepository {
+	return &DynamoDBRepository{client: client, table: table}
+}
+
+func (d *DynamoDBRepository) Create(job *Job) error {
+	err := d.put(job, &dynamodb.PutItemInput{
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+	})
+	if conditionFailed(err) {
+		return ErrExists
+	}
+	return err
+}
+
+func (d *DynamoDBRepository) Get(id string) (*Job, error) {
+	output, err := d.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job %s: %w", id, err)
+	}
+	if len(output.Item) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var job Job
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+func (d *DynamoDBRepository) Update(id string, update Update, at time.Time) (*Job, error) {
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		job, err := d.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		read := job.Version
+		if err := apply(job, update, at); err != nil {
+			return nil, err
+		}
+		job.Version++
+
+		err = d.put(job, &dynamodb.PutItemInput{
+			ConditionExpression:      aws.String("#version = :version"),
+			ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":version": {N: aws.String(strconv.Itoa(read))},
+			},
+		})
+		if conditionFailed(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, fmt.Errorf("failed to update job %s: too many concurrent updates", id)
+}
+
+// ListByStatus queries StatusIndex. The index has no sort key, so every job in status
+// is read before the most recent are picked.
+func (d *DynamoDBRepository) ListByStatus(status string, limit int) ([]Job, error) {
+	result := []Job{}
+	var decodeErr error
+	err := d.client.QueryPages(&dynamodb.QueryInput{
+		TableName:                aws.String(d.table),
+		IndexName:                aws.String(StatusIndex),
+		KeyConditionExpression:   aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var jobs []Job
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &jobs); decodeErr != nil {
+			return false
+		}
+		result = append(result, jobs...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s jobs: %w", status, err)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode %s jobs: %w", status, decodeErr)
+	}
+	return NewestFirst(result, limit), nil
+}
+
+// ListByTenant queries TenantStatusIndex once per status, reading up to limit+1 jobs
+// from each. While the table lacks the index, or it is still being built, the jobs are
+// read through StatusIndex instead.
+func (d *DynamoDBRepository) ListByTenant(tenant string, statuses []string, limit int, after string) ([]Job, string, error) {
+	listings := make([][]Job, 0, len(statuses))
+	for _, status := range statuses {
+		listing, err := d.queryTenantStatus(tenantStatus(tenant, status), limit, after)
+		if indexUnavailable(err) {
+			log.Printf("Warning: Failed to query %s, listing jobs through %s: %v", TenantStatusIndex, StatusIndex, err)
+			return d.listByStatus(tenant, statuses, limit, after)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list %s jobs: %w", status, err)
+		}
+		listings = append(listings, listing)
+	}
+	page, next := mergePages(listings, limit)
+	return page, next, nil
+}
+
+// listByStatus pages the jobs of tenant like ListByTenant, reading every job in
+// statuses through StatusIndex.
+func (d *DynamoDBRepository) listByStatus(tenant string, statuses []string, limit int, after string) ([]Job, string, error) {
+	listings := make([][]Job, 0, len(statuses))
+	for _, status := range statuses {
+		found, err := d.ListByStatus(status, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		var listing []Job
+		for _, job := range found {
+			if job.Tenant == tenant && (after == "" || position(&job) < after) {
+				listing = append(listing, job)
+			}
+		}
+		listings = append(listings, listing)
+	}
+	page, next := mergePages(listings, limit)
+	return page, next, nil
+}
+
+// IndexListings backfills the attributes that key TenantStatusIndex on jobs stored
+// before the index existed, so they are listed again, and returns how many jobs it
+// updated. Jobs updated meanwhile were indexed by that update and are skipped.
+func (d *DynamoDBRepository) IndexListings() (int, error) {
+	indexed := 0
+	var pageErr error
+	err := d.client.ScanPages(&dynamodb.ScanInput{
+		TableName:                aws.String(d.table),
+		FilterExpression:         aws.String("attribute_not_exists(#listing)"),
+		ExpressionAttributeNames: map[string]*string{"#listing": aws.String(tenantStatusAttribute)},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var jobs []Job
+		if pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &jobs); pageErr != nil {
+			pageErr = fmt.Errorf("failed to decode jobs: %w", pageErr)
+			return false
+		}
+		for i := range jobs {
+			err := d.put(&jobs[i], &dynamodb.PutItemInput{
+				ConditionExpression:      aws.String("#version = :version"),
+				ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":version": {N: aws.String(strconv.Itoa(jobs[i].Version))},
+				},
+			})
+			if conditionFailed(err) {
+				continue
+			}
+			if err != nil {
+				pageErr = err
+				return false
+			}
+			indexed++
+		}
+		return true
+	})
+	if err != nil {
+		return indexed, fmt.Errorf("failed to scan jobs: %w", err)
+	}
+	return indexed, pageErr
+}
+
+// queryTenantStatus reads up to limit+1 jobs of a listing before after, most recent
+// first; a limit below 1 reads them all.
+func (d *DynamoDBRepository) queryTenantStatus(listing string, limit int, after string) ([]Job, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.table),
+		IndexName:              aws.String(TenantStatusIndex),
+		KeyConditionExpression: aws.String("#listing = :listing"),
+		ExpressionAttributeNames: map[string]*string{
+			"#listing": aws.String(tenantStatusAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":listing": {S: aws.String(listing)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if after != "" {
+		input.KeyConditionExpression = aws.String("#listing = :listing AND #position < :after")
+		input.ExpressionAttributeNames["#position"] = aws.String(positionAttribute)
+		input.ExpressionAttributeValues[":after"] = &dynamodb.AttributeValue{S: aws.String(after)}
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(int64(limit + 1))
+	}
+
+	var result []Job
+	var decodeErr error
+	err := d.client.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var jobs []Job
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &jobs); decodeErr != nil {
+			return false
+		}
+		result = append(result, jobs...)
+		return limit < 1 || len(result) <= limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	if limit > 0 && len(result) > limit+1 {
+		result = result[:limit+1]
+	}
+	return result, nil
+}
+
+// put writes job with the condition set on input, along with the attributes that key
+// TenantStatusIndex.
+func (d *DynamoDBRepository) put(job *Job, input *dynamodb.PutItemInput) error {
+	item, err := dynamodbattribute.MarshalMap(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", job.ID, err)
+	}
+	item[tenantStatusAttribute] = &dynamodb.AttributeValue{S: aws.String(tenantStatus(job.Tenant, job.Status))}
+	item[positionAttribute] = &dynamodb.AttributeValue{S: aws.String(position(job))}
+	input.TableName = aws.String(d.table)
+	input.Item = item
+
+	if _, err := d.client.PutItem(input); err != nil {
+		if conditionFailed(err) {
+			return err
+		}
+		return fmt.Errorf("failed to store job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// indexUnavailable reports whether a query failed because the index it reads does not
+// exist or is still being built.
+func indexUnavailable(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "ValidationException" && strings.Contains(awsErr.Message(), "index")
+}
+
+func conditionFailed(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
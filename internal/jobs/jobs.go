@@ -0,0 +1,244 @@
+// Package jobs records processing jobs: who submitted what, with which options, every
+// status the job went through and how it ended. The API creates a job when a video is
+// uploaded and the processor moves it along as it works.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Header carries the job ID from the API to the processor.
+const Header = "X-Job-ID"
+
+// Job statuses. A job is queued when the upload is accepted and ends completed or failed.
+const (
+	StatusQueued     = "queued"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Stages a processing job reports progress through, in order. Stages the options do
+// not ask for are skipped.
+const (
+	StageExtractingFrames = "extracting_frames"
+	StageArchiving        = "archiving"
+	StageExportingClips   = "exporting_clips"
+	StageRenditions       = "encoding_renditions"
+)
+
+var (
+	// ErrNotFound is returned for a job ID no job has.
+	ErrNotFound = errors.New("job not found")
+	// ErrExists is returned when creating a job under an ID already taken.
+	ErrExists = errors.New("job already exists")
+	// ErrInvalidTransition is returned when a job cannot move to the requested status.
+	ErrInvalidTransition = errors.New("invalid job status transition")
+)
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// transitions lists the statuses a job may move to from each status. A failed job may
+// be picked up again, and a job may report processing more than once when a delivery
+// is retried.
+var transitions = map[string][]string{
+	StatusQueued:     {StatusProcessing, StatusCompleted, StatusFailed},
+	StatusProcessing: {StatusProcessing, StatusCompleted, StatusFailed},
+	StatusFailed:     {StatusQueued, StatusProcessing},
+	StatusCompleted:  {},
+}
+
+// Job is the record of one processing job. Options holds the options JSON as the
+// client sent it and SHA256 the checksum it declared for the video, if any.
+type Job struct {
+	ID          string       `json:"id" dynamodbav:"id"`
+	Tenant      string       `json:"tenant,omitempty" dynamodbav:"tenant,omitempty"`
+	Owner       string       `json:"owner,omitempty" dynamodbav:"owner,omitempty"`
+	SourceKey   string       `json:"source_key,omitempty" dynamodbav:"source_key,omitempty"`
+	SourceName  string       `json:"source_name,omitempty" dynamodbav:"source_name,omitempty"`
+	Options     string       `json:"options,omitempty" dynamodbav:"options,omitempty"`
+	SHA256      string       `json:"sha256,omitempty" dynamodbav:"sha256,omitempty"`
+	Status      string       `json:"status" dynamodbav:"status"`
+	Transitions []Transition `json:"transitions" dynamodbav:"transitions"`
+	Result      *Result      `json:"result,omitempty" dynamodbav:"result,omitempty"`
+	Error       string       `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Progress    *Progress    `json:"progress,omitempty" dynamodbav:"progress,omitempty"`
+	CreatedAt   time.Time    `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" dynamodbav:"updated_at"`
+	// Version guards concurrent updates of stored jobs.
+	Version int `json:"-" dynamodbav:"version"`
+}
+
+// Transition is a status a job entered and when.
+type Transition struct {
+	Status string    `json:"status" dynamodbav:"status"`
+	At     time.Time `json:"at" dynamodbav:"at"`
+}
+
+// Progress is how far a job got: the stage it is in and, once they were extracted, how
+// many frames it has.
+type Progress struct {
+	Stage  string `json:"stage" dynamodbav:"stage"`
+	Frames int    `json:"frames,omitempty" dynamodbav:"frames,omitempty"`
+}
+
+// Result summarizes the outputs of a finished job.
+type Result struct {
+	Message        string `json:"message,omitempty" dynamodbav:"message,omitempty"`
+	VideoID        string `json:"video_id,omitempty" dynamodbav:"video_id,omitempty"`
+	ZipPath        string `json:"zip_path,omitempty" dynamodbav:"zip_path,omitempty"`
+	FrameCount     int    `json:"frame_count,omitempty" dynamodbav:"frame_count,omitempty"`
+	Clips          int    `json:"clips,omitempty" dynamodbav:"clips,omitempty"`
+	ProxyPath      string `json:"proxy_path,omitempty" dynamodbav:"proxy_path,omitempty"`
+	PlaylistPath   string `json:"playlist_path,omitempty" dynamodbav:"playlist_path,omitempty"`
+	Deduplicated   bool   `json:"deduplicated,omitempty" dynamodbav:"deduplicated,omitempty"`
+	SourceChecksum string `json:"source_checksum,omitempty" dynamodbav:"source_checksum,omitempty"`
+}
+
+// Update moves a job to Status. Result and Error are recorded with it; SourceKey, when
+// set, replaces the one the job was created with. An update without Status only
+// records Progress on a job that has not ended, adding no transition.
+type Update struct {
+	Status    string
+	Result    *Result
+	Error     string
+	SourceKey string
+	Progress  *Progress
+}
+
+// JobRepository stores jobs. Implementations are safe for concurrent use.
+type JobRepository interface {
+	// Create stores a new job, failing with ErrExists when its ID is taken.
+	Create(job *Job) error
+	// Get returns the job with id or ErrNotFound.
+	Get(id string) (*Job, error)
+	// Update applies update to the job with id at the given time and returns the job
+	// as stored.
+	Update(id string, update Update, at time.Time) (*Job, error)
+	// ListByStatus returns up to limit jobs in status, most recent first; a limit
+	// below 1 returns them all.
+	ListByStatus(status string, limit int) ([]Job, error)
+	// ListByTenant returns a page of up to limit jobs of tenant in any of statuses,
+	// most recent first, starting after the position after ("" starts from the most
+	// recent). next is the position the following page starts after, "" once there
+	// are no more jobs.
+	ListByTenant(tenant string, statuses []string, limit int, after string) (page []Job, next string, err error)
+}
+
+// positionLayout orders the positions of jobs by creation as strings.
+const positionLayout = "20060102T150405.000000000Z"
+
+// position is where a job stands in the listings of its tenant: when it was created,
+// then its ID to order jobs created together.
+func position(job *Job) string {
+	return job.CreatedAt.UTC().Format(positionLayout) + "#" + job.ID
+}
+
+// tenantStatus names the listing of the jobs of tenant in status.
+func tenantStatus(tenant, status string) string {
+	return tenant + "#" + status
+}
+
+// mergePages merges the jobs after the same position in several listings, each most
+// recent first and holding up to limit+1 jobs, into one page of up to limit jobs and
+// the position the following page starts after. A limit below 1 keeps every job.
+func mergePages(listings [][]Job, limit int) ([]Job, string) {
+	var merged []Job
+	for _, listing := range listings {
+		merged = append(merged, listing...)
+	}
+	sort.Slice(merged, func(i, j int) bool { return position(&merged[i]) > position(&merged[j]) })
+	if limit < 1 || len(merged) <= limit {
+		return merged, ""
+	}
+	merged = merged[:limit]
+	return merged, position(&merged[limit-1])
+}
+
+// New returns a queued job created at now.
+func New(id string, now time.Time) *Job {
+	return &Job{
+		ID:          id,
+		Status:      StatusQueued,
+		Transitions: []Transition{{Status: StatusQueued, At: now}},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// NewID returns a random job ID.
+func NewID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ValidID reports whether id looks like an ID NewID returns.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// ValidStatus reports whether status is one a job can be in.
+func ValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// Terminal reports whether a job in status has ended. A failed job may still be retried.
+func Terminal(status string) bool {
+	return status == StatusCompleted || status == StatusFailed
+}
+
+// apply moves job to the status of update, checking the transition is allowed.
+func apply(job *Job, update Update, at time.Time) error {
+	if update.Status == "" {
+		if Terminal(job.Status) || update.Progress == nil {
+			return fmt.Errorf("%w: progress of a %s job", ErrInvalidTransition, job.Status)
+		}
+		job.Progress = update.Progress
+		job.UpdatedAt = at
+		return nil
+	}
+
+	allowed := false
+	for _, status := range transitions[job.Status] {
+		if status == update.Status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, job.Status, update.Status)
+	}
+
+	job.Status = update.Status
+	job.Transitions = append(job.Transitions, Transition{Status: update.Status, At: at})
+	job.UpdatedAt = at
+	if update.SourceKey != "" {
+		job.SourceKey = update.SourceKey
+	}
+	if update.Result != nil {
+		job.Result = update.Result
+	}
+	if update.Progress != nil {
+		job.Progress = update.Progress
+	}
+	switch update.Status {
+	case StatusQueued:
+		// A retried job starts over.
+		job.Progress = nil
+	case StatusFailed:
+		job.Error = update.Error
+	case StatusCompleted:
+		job.Error = ""
+	}
+	return nil
+}
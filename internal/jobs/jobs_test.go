@@ -0,0 +1,463 @@
+package jobs
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// fakeDynamoDB keeps items in memory and evaluates the conditions DynamoDBRepository
+// writes with. Without tenantIndex, queries of TenantStatusIndex fail as on a table
+// created before the index.
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	mu          sync.Mutex
+	items       map[string]map[string]*dynamodb.AttributeValue
+	tenantIndex bool
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}, tenantIndex: true}
+}
+
+func (f *fakeDynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[aws.StringValue(input.Key["id"].S)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := aws.StringValue(input.Item["id"].S)
+	existing, exists := f.items[id]
+	switch aws.StringValue(input.ConditionExpression) {
+	case "attribute_not_exists(#id)":
+		if exists {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+		}
+	case "#version = :version":
+		if !exists || aws.StringValue(existing["version"].N) != aws.StringValue(input.ExpressionAttributeValues[":version"].N) {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "version", nil)
+		}
+	}
+	f.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) QueryPages(input *dynamodb.QueryInput, visit func(*dynamodb.QueryOutput, bool) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if aws.StringValue(input.IndexName) == TenantStatusIndex {
+		if !f.tenantIndex {
+			return awserr.New("ValidationException", "The table does not have the specified index: "+TenantStatusIndex, nil)
+		}
+		f.queryTenantStatus(input, visit)
+		return nil
+	}
+
+	status := aws.StringValue(input.ExpressionAttributeValues[":status"].S)
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range f.items {
+		if aws.StringValue(item["status"].S) == status {
+			items = append(items, item)
+		}
+	}
+	// Two pages, to exercise pagination.
+	half := len(items) / 2
+	if visit(&dynamodb.QueryOutput{Items: items[:half]}, false) {
+		visit(&dynamodb.QueryOutput{Items: items[half:]}, true)
+	}
+	return nil
+}
+
+// ScanPages serves the scan for items without the attributes of TenantStatusIndex. The
+// items are copied first, as the visitor writes back to the table.
+func (f *fakeDynamoDB) ScanPages(input *dynamodb.ScanInput, visit func(*dynamodb.ScanOutput, bool) bool) error {
+	f.mu.Lock()
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range f.items {
+		if _, indexed := item[tenantStatusAttribute]; !indexed {
+			items = append(items, item)
+		}
+	}
+	f.mu.Unlock()
+	visit(&dynamodb.ScanOutput{Items: items}, true)
+	return nil
+}
+
+// queryTenantStatus serves TenantStatusIndex queries in descending position order, in
+// pages of the query limit.
+func (f *fakeDynamoDB) queryTenantStatus(input *dynamodb.QueryInput, visit func(*dynamodb.QueryOutput, bool) bool) {
+	listing := aws.StringValue(input.ExpressionAttributeValues[":listing"].S)
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range f.items {
+		if indexed, ok := item[tenantStatusAttribute]; !ok || aws.StringValue(indexed.S) != listing {
+			continue
+		}
+		if after, ok := input.ExpressionAttributeValues[":after"]; ok && aws.StringValue(item[positionAttribute].S) >= aws.StringValue(after.S) {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return aws.StringValue(items[i][positionAttribute].S) > aws.StringValue(items[j][positionAttribute].S)
+	})
+
+	size := len(items)
+	if input.Limit != nil {
+		size = int(aws.Int64Value(input.Limit))
+	}
+	for len(items) > size {
+		if !visit(&dynamodb.QueryOutput{Items: items[:size]}, false) {
+			return
+		}
+		items = items[size:]
+	}
+	visit(&dynamodb.QueryOutput{Items: items}, true)
+}
+
+func repositories() map[string]func(t *testing.T) JobRepository {
+	return map[string]func(t *testing.T) JobRepository{
+		"memory":   func(*testing.T) JobRepository { return NewMemoryRepository() },
+		"dynamodb": func(*testing.T) JobRepository { return NewDynamoDBRepository(newFakeDynamoDB(), "video-jobs") },
+		"bolt":     newTestBoltRepository,
+	}
+}
+
+func newTestBoltRepository(t *testing.T) JobRepository {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "jobs.db"), 0o600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repository, err := NewBoltRepository(db)
+	require.NoError(t, err)
+	return repository
+}
+
+func TestJobRepository_CreateAndGet(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			job := New("0123456789abcdef0123456789abcdef", now)
+			job.Owner = "equipe-x"
+			job.SourceKey = "20240101_120000_video.mp4"
+			job.Options = `{"proxy":true}`
+
+			require.NoError(t, repository.Create(job))
+			assert.ErrorIs(t, repository.Create(job), ErrExists)
+
+			stored, err := repository.Get(job.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "equipe-x", stored.Owner)
+			assert.Equal(t, `{"proxy":true}`, stored.Options)
+			assert.Equal(t, StatusQueued, stored.Status)
+			assert.True(t, now.Equal(stored.CreatedAt))
+
+			_, err = repository.Get("ffffffffffffffffffffffffffffffff")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestJobRepository_UpdateRecordsTransitions(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			require.NoError(t, repository.Create(New("job", now)))
+
+			_, err := repository.Update("job", Update{Status: StatusProcessing}, now.Add(time.Second))
+			require.NoError(t, err)
+			job, err := repository.Update("job", Update{
+				Status: StatusCompleted,
+				Result: &Result{VideoID: "20240101_120000", ZipPath: "frames_20240101_120000.zip", FrameCount: 42},
+			}, now.Add(time.Minute))
+
+			require.NoError(t, err)
+			assert.Equal(t, StatusCompleted, job.Status)
+			stored, err := repository.Get("job")
+			require.NoError(t, err)
+			assert.Equal(t, []string{StatusQueued, StatusProcessing, StatusCompleted}, statuses(stored))
+			assert.Equal(t, 42, stored.Result.FrameCount)
+			assert.True(t, now.Add(time.Minute).Equal(stored.UpdatedAt))
+
+			_, err = repository.Update("job", Update{Status: StatusProcessing}, now.Add(time.Hour))
+			assert.ErrorIs(t, err, ErrInvalidTransition, "completed jobs stay completed")
+			_, err = repository.Update("missing", Update{Status: StatusProcessing}, now)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestJobRepository_FailedJobsKeepTheirError(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			now := time.Now()
+			require.NoError(t, repository.Create(New("job", now)))
+
+			job, err := repository.Update("job", Update{Status: StatusFailed, Error: "ffmpeg exited with status 1"}, now)
+
+			require.NoError(t, err)
+			assert.Equal(t, "ffmpeg exited with status 1", job.Error)
+			assert.True(t, Terminal(job.Status))
+
+			job, err = repository.Update("job", Update{Status: StatusProcessing}, now)
+			require.NoError(t, err, "failed jobs can be retried")
+			job, err = repository.Update("job", Update{Status: StatusCompleted}, now)
+			require.NoError(t, err)
+			assert.Empty(t, job.Error)
+		})
+	}
+}
+
+func TestJobRepository_RecordsProgressWithoutTransitions(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			now := time.Now()
+			require.NoError(t, repository.Create(New("job", now)))
+			_, err := repository.Update("job", Update{Status: StatusProcessing}, now)
+			require.NoError(t, err)
+
+			_, err = repository.Update("job", Update{Progress: &Progress{Stage: StageExtractingFrames}}, now)
+			require.NoError(t, err)
+			job, err := repository.Update("job", Update{Progress: &Progress{Stage: StageArchiving, Frames: 12}}, now)
+
+			require.NoError(t, err)
+			assert.Equal(t, StatusProcessing, job.Status)
+			stored, err := repository.Get("job")
+			require.NoError(t, err)
+			assert.Equal(t, &Progress{Stage: StageArchiving, Frames: 12}, stored.Progress)
+			assert.Equal(t, []string{StatusQueued, StatusProcessing}, statuses(stored))
+
+			_, err = repository.Update("job", Update{Status: StatusFailed, Error: "ffmpeg exited with status 1"}, now)
+			require.NoError(t, err)
+			_, err = repository.Update("job", Update{Progress: &Progress{Stage: StageRenditions}}, now)
+			assert.ErrorIs(t, err, ErrInvalidTransition, "ended jobs make no progress")
+
+			job, err = repository.Update("job", Update{Status: StatusQueued}, now)
+			require.NoError(t, err)
+			assert.Nil(t, job.Progress, "a retried job starts over")
+		})
+	}
+}
+
+func TestJobRepository_ListByStatus(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i, id := range []string{"a", "b", "c", "d"} {
+				require.NoError(t, repository.Create(New(id, start.Add(time.Duration(i)*time.Minute))))
+			}
+			_, err := repository.Update("c", Update{Status: StatusProcessing}, start)
+			require.NoError(t, err)
+
+			queued, err := repository.ListByStatus(StatusQueued, 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"d", "b", "a"}, ids(queued))
+
+			latest, err := repository.ListByStatus(StatusQueued, 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"d", "b"}, ids(latest))
+
+			failed, err := repository.ListByStatus(StatusFailed, 0)
+			require.NoError(t, err)
+			assert.Empty(t, failed)
+		})
+	}
+}
+
+func TestJobRepository_ListByTenantPages(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i, id := range []string{"a", "b", "c", "d", "e"} {
+				job := New(id, start.Add(time.Duration(i)*time.Minute))
+				job.Tenant = "acme"
+				require.NoError(t, repository.Create(job))
+			}
+			other := New("x", start.Add(time.Hour))
+			other.Tenant = "globex"
+			require.NoError(t, repository.Create(other))
+			_, err := repository.Update("d", Update{Status: StatusFailed, Error: "falhou"}, start)
+			require.NoError(t, err)
+			_, err = repository.Update("b", Update{Status: StatusProcessing}, start)
+			require.NoError(t, err)
+
+			statuses := []string{StatusQueued, StatusFailed}
+			page, next, err := repository.ListByTenant("acme", statuses, 2, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"e", "d"}, ids(page))
+			require.NotEmpty(t, next)
+
+			page, next, err = repository.ListByTenant("acme", statuses, 2, next)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"c", "a"}, ids(page))
+			assert.Empty(t, next, "a page that ends the listing has no next position")
+
+			page, _, err = repository.ListByTenant("acme", []string{StatusProcessing}, 10, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b"}, ids(page))
+
+			page, _, err = repository.ListByTenant("", statuses, 10, "")
+			require.NoError(t, err)
+			assert.Empty(t, page, "jobs of other tenants are not listed")
+		})
+	}
+}
+
+func TestJobRepository_ConcurrentUpdatesKeepEveryTransition(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			repository := newRepository(t)
+			now := time.Now()
+			require.NoError(t, repository.Create(New("job", now)))
+
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := repository.Update("job", Update{Status: StatusProcessing}, now)
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			job, err := repository.Get("job")
+			require.NoError(t, err)
+			assert.Len(t, job.Transitions, 4)
+		})
+	}
+}
+
+func TestValidID(t *testing.T) {
+	id, err := NewID()
+	require.NoError(t, err)
+	assert.True(t, ValidID(id))
+	assert.False(t, ValidID("../etc/passwd"))
+	assert.False(t, ValidID("20240101_120000"))
+}
+
+func statuses(job *Job) []string {
+	var result []string
+	for _, transition := range job.Transitions {
+		result = append(result, transition.Status)
+	}
+	return result
+}
+
+func ids(jobs []Job) []string {
+	var result []string
+	for _, job := range jobs {
+		result = append(result, job.ID)
+	}
+	return result
+}
+
+func TestBoltRepository_KeepsJobsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	repository, err := NewBoltRepository(db)
+	require.NoError(t, err)
+	require.NoError(t, repository.Create(New("job", now)))
+	_, err = repository.Update("job", Update{Status: StatusProcessing}, now.Add(time.Second))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	repository, err = NewBoltRepository(db)
+	require.NoError(t, err)
+
+	job, err := repository.Get("job")
+	require.NoError(t, err)
+	assert.Equal(t, StatusProcessing, job.Status)
+	assert.Equal(t, 1, job.Version)
+	processing, err := repository.ListByStatus(StatusProcessing, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job"}, ids(processing))
+	queued, err := repository.ListByStatus(StatusQueued, 0)
+	require.NoError(t, err)
+	assert.Empty(t, queued, "the status index follows the job")
+}
+
+func TestDynamoDBRepository_ListsJobsStoredBeforeTheTenantIndex(t *testing.T) {
+	client := newFakeDynamoDB()
+	repository := NewDynamoDBRepository(client, "video-jobs")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		job := New(id, start.Add(time.Duration(i)*time.Minute))
+		job.Tenant = "acme"
+		require.NoError(t, repository.Create(job))
+	}
+	_, err := repository.Update("a", Update{Status: StatusCompleted}, start)
+	require.NoError(t, err)
+	for _, item := range client.items {
+		delete(item, tenantStatusAttribute)
+		delete(item, positionAttribute)
+	}
+	statuses := []string{StatusQueued, StatusCompleted}
+
+	client.tenantIndex = false
+	page, next, err := repository.ListByTenant("acme", statuses, 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, ids(page), "without the index jobs are listed through the status index")
+	page, _, err = repository.ListByTenant("acme", statuses, 2, next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(page))
+
+	client.tenantIndex = true
+	page, _, err = repository.ListByTenant("acme", statuses, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, page, "the new index holds no job stored before it")
+
+	indexed, err := repository.IndexListings()
+	require.NoError(t, err)
+	assert.Equal(t, 3, indexed)
+	page, _, err = repository.ListByTenant("acme", statuses, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a"}, ids(page), "completed jobs are listed again once backfilled")
+
+	indexed, err = repository.IndexListings()
+	require.NoError(t, err)
+	assert.Zero(t, indexed)
+}
+
+func TestBoltRepository_IndexesJobsStoredBeforePositions(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "jobs.db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	repository, err := NewBoltRepository(db)
+	require.NoError(t, err)
+	require.NoError(t, repository.Create(New("job", time.Now())))
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(boltPositionsBucket) }))
+
+	repository, err = NewBoltRepository(db)
+	require.NoError(t, err)
+
+	page, _, err := repository.ListByTenant("", []string{StatusQueued}, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job"}, ids(page))
+}
@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps jobs in memory. It serves tests and single-process setups;
+// jobs are lost on restart and not shared between the API and the processor.
+type MemoryRepository struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{jobs: map[string]Job{}}
+}
+
+func (m *MemoryRepository) Create(job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.jobs[job.ID]; ok {
+		return ErrExists
+	}
+	m.jobs[job.ID] = clone(*job)
+	return nil
+}
+
+func (m *MemoryRepository) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job = clone(job)
+	return &job, nil
+}
+
+func (m *MemoryRepository) Update(id string, update Update, at time.Time) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job = clone(job)
+	if err := apply(&job, update, at); err != nil {
+		return nil, err
+	}
+	job.Version++
+	m.jobs[id] = job
+
+	job = clone(job)
+	return &job, nil
+}
+
+func (m *MemoryRepository) ListByStatus(status string, limit int) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := []Job{}
+	for _, job := range m.jobs {
+		if job.Status == status {
+			result = append(result, clone(job))
+		}
+	}
+	return NewestFirst(result, limit), nil
+}
+
+func (m *MemoryRepository) ListByTenant(tenant string, statuses []string, limit int, after string) ([]Job, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	listings := make([][]Job, 0, len(statuses))
+	for _, status := range statuses {
+		var listing []Job
+		for _, job := range m.jobs {
+			if job.Tenant == tenant && job.Status == status && (after == "" || position(&job) < after) {
+				listing = append(listing, clone(job))
+			}
+		}
+		listings = append(listings, listing)
+	}
+	page, next := mergePages(listings, limit)
+	return page, next, nil
+}
+
+// clone copies a job so callers never share its slices with the repository.
+func clone(job Job) Job {
+	job.Transitions = append([]Transition(nil), job.Transitions...)
+	if job.Result != nil {
+		result := *job.Result
+		job.Result = &result
+	}
+	if job.Progress != nil {
+		progress := *job.Progress
+		job.Progress = &progress
+	}
+	return job
+}
+
+// NewestFirst sorts jobs by creation, most recent first, and keeps up to limit of them.
+func NewestFirst(jobs []Job, limit int) []Job {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
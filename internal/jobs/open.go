@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"fmt"
+
+	"video-processor/internal/config"
+)
+
+// Backends a JobRepository can be opened on, named by JOB_STORE. BackendBolt keeps jobs
+// in the embedded database of the process that opens it.
+const (
+	BackendMemory   = "memory"
+	BackendDynamoDB = "dynamodb"
+	BackendBolt     = "bolt"
+)
+
+// DefaultBackend picks DynamoDB when S3 is in use on AWS or LocalStack, which also
+// serve it, and memory otherwise.
+func DefaultBackend(awsConfig *config.AWSConfig, s3Enabled bool) string {
+	if s3Enabled {
+		switch awsConfig.ProviderType() {
+		case config.ProviderAWS, config.ProviderLocalStack:
+			return BackendDynamoDB
+		}
+	}
+	return BackendMemory
+}
+
+// Exclusive reports whether repository is private to the process that opened it, as
+// the memory and bolt repositories are, so the unfinished jobs in it can only have been
+// left by an earlier run of that process.
+func Exclusive(repository JobRepository) bool {
+	switch repository.(type) {
+	case *MemoryRepository, *BoltRepository:
+		return true
+	}
+	return false
+}
+
+// Open returns the repository of backend; empty picks DefaultBackend.
+func Open(backend string, awsConfig *config.AWSConfig, s3Enabled bool, embedded *config.EmbeddedConfig) (JobRepository, error) {
+	if backend == "" {
+		backend = DefaultBackend(awsConfig, s3Enabled)
+	}
+
+	switch backend {
+	case BackendMemory:
+		return NewMemoryRepository(), nil
+	case BackendDynamoDB:
+		client, err := config.NewDynamoDBClient(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return NewDynamoDBRepository(client, awsConfig.DynamoDB.VideoJobsTable), nil
+	case BackendBolt:
+		db, err := config.OpenBoltDB(embedded.Path)
+		if err != nil {
+			return nil, err
+		}
+		repository, err := NewBoltRepository(db)
+		if err != nil {
+			return nil, err
+		}
+		return repository, nil
+	default:
+		return nil, fmt.Errorf("unknown job store %q: use %s, %s or %s", backend, BackendMemory, BackendDynamoDB, BackendBolt)
+	}
+}
@@ -0,0 +1,316 @@
+// Package migrate copies stored objects from one backend to another, such as outputs
+// from the filesystem into S3, keeping their metadata and checking every copy against
+// the SHA-256 of the original.
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+)
+
+// pageSize is how many keys are listed from the source at a time.
+const pageSize = 1000
+
+// Options tune a migration.
+type Options struct {
+	// Prefix limits the migration to keys starting with it.
+	Prefix string
+	// Parallelism is how many objects are copied at once; below 1 means 1.
+	Parallelism int
+	// DryRun reports what would be copied without writing anything.
+	DryRun bool
+	// Verify reads every copy back from the destination and compares its SHA-256.
+	Verify bool
+	// Journal is a file recording each migrated key, one per line. Keys it already lists
+	// are skipped, so an interrupted migration resumes where it stopped.
+	Journal string
+}
+
+// Failure is an object that could not be migrated.
+type Failure struct {
+	Key string
+	Err error
+}
+
+// Report sums up a migration. In a dry run Copied counts the objects that would be copied.
+type Report struct {
+	Copied   int
+	Skipped  int
+	Bytes    int64
+	Failures []Failure
+}
+
+// Migrator copies objects from source to destination.
+type Migrator struct {
+	source      storage.Storage
+	destination storage.Storage
+	opts        Options
+}
+
+func New(source, destination storage.Storage, opts Options) *Migrator {
+	if opts.Parallelism < 1 {
+		opts.Parallelism = 1
+	}
+	return &Migrator{source: source, destination: destination, opts: opts}
+}
+
+// outcome is what happened to a single object.
+type outcome int
+
+const (
+	copied outcome = iota
+	skipped
+)
+
+// Run migrates every object under the prefix. Objects that fail are listed in the report
+// and do not stop the others; the error is for failures to list the source or to use the
+// journal.
+func (m *Migrator) Run() (*Report, error) {
+	done, err := readJournal(m.opts.Journal)
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *os.File
+	if m.opts.Journal != "" && !m.opts.DryRun {
+		journal, err = os.OpenFile(filepath.Clean(m.opts.Journal), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open journal: %w", err)
+		}
+		defer func() {
+			if err := journal.Close(); err != nil {
+				log.Printf("Warning: Failed to close journal: %v", err)
+			}
+		}()
+	}
+
+	report := &Report{}
+	var mu sync.Mutex
+	record := func(object storage.ObjectInfo, result outcome, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			log.Printf("Warning: Failed to migrate %s: %v", object.Key, err)
+			report.Failures = append(report.Failures, Failure{Key: object.Key, Err: err})
+			return
+		case result == skipped:
+			report.Skipped++
+		default:
+			report.Copied++
+			report.Bytes += object.Size
+		}
+		if journal != nil {
+			if _, err := journal.WriteString(object.Key + "\n"); err != nil {
+				log.Printf("Warning: Failed to journal %s: %v", object.Key, err)
+			}
+		}
+	}
+
+	objects := make(chan storage.ObjectInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < m.opts.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range objects {
+				result, err := m.migrate(object)
+				record(object, result, err)
+			}
+		}()
+	}
+
+	listErr := m.list(func(object storage.ObjectInfo) {
+		if done[object.Key] {
+			mu.Lock()
+			report.Skipped++
+			mu.Unlock()
+			return
+		}
+		objects <- object
+	})
+	close(objects)
+	wg.Wait()
+
+	if listErr != nil {
+		return report, fmt.Errorf("failed to list source objects: %w", listErr)
+	}
+	return report, nil
+}
+
+// list pages through the source objects under the prefix.
+func (m *Migrator) list(visit func(storage.ObjectInfo)) error {
+	cursor := ""
+	for {
+		page, more, err := m.source.ListPage(m.opts.Prefix, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, object := range page {
+			visit(object)
+		}
+		if !more || len(page) == 0 {
+			return nil
+		}
+		cursor = page[len(page)-1].Key
+	}
+}
+
+// migrate copies one object unless the destination already holds the same bytes.
+func (m *Migrator) migrate(object storage.ObjectInfo) (outcome, error) {
+	info, err := m.source.Stat(object.Key)
+	if err != nil {
+		return 0, err
+	}
+	sum, err := storedChecksum(m.source, info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash source: %w", err)
+	}
+
+	existing, err := m.destination.Stat(object.Key)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return 0, err
+	case existing.Size == info.Size:
+		existingSum, err := storedChecksum(m.destination, existing)
+		if err != nil {
+			return 0, fmt.Errorf("failed to hash destination: %w", err)
+		}
+		if existingSum == sum {
+			return skipped, nil
+		}
+	}
+
+	if m.opts.DryRun {
+		return copied, nil
+	}
+
+	if err := m.copy(info, sum); err != nil {
+		return 0, err
+	}
+
+	if m.opts.Verify {
+		if err := m.verify(info.Key, sum); err != nil {
+			if deleteErr := m.destination.Delete(info.Key); deleteErr != nil {
+				log.Printf("Warning: Failed to delete unverified copy of %s: %v", info.Key, deleteErr)
+			}
+			return 0, err
+		}
+	}
+	return copied, nil
+}
+
+// copy writes the object and its metadata to the destination. The checksum travels as
+// metadata, so the destination rejects bytes that do not match it.
+func (m *Migrator) copy(info *storage.ObjectInfo, sum string) error {
+	metadata := make(map[string]string, len(info.Metadata)+1)
+	for key, value := range info.Metadata {
+		metadata[key] = value
+	}
+	metadata[storage.MetaChecksum] = sum
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(info.Key))
+	}
+
+	reader, err := m.source.Get(info.Key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close source reader for %s: %v", info.Key, err)
+		}
+	}()
+
+	return storage.PutWithMetadata(m.destination, info.Key, reader, contentType, metadata)
+}
+
+// verify reads the copy back from the destination and compares its SHA-256 with sum.
+func (m *Migrator) verify(key, sum string) error {
+	copySum, err := hashObject(m.destination, key)
+	if err != nil {
+		return fmt.Errorf("failed to read back copy: %w", err)
+	}
+	if err := checksum.Verify(sum, copySum); err != nil {
+		return fmt.Errorf("copy of %s: %w", key, err)
+	}
+	return nil
+}
+
+// storedChecksum returns the hex SHA-256 of an object: the one stored with it when there
+// is one, otherwise computed from the file in place or from its content.
+func storedChecksum(store storage.Storage, info *storage.ObjectInfo) (string, error) {
+	if sum, ok := checksum.Normalize(info.Metadata[storage.MetaChecksum]); ok && sum != "" {
+		return sum, nil
+	}
+	if localPath, ok := storage.LocalPath(store, info.Key); ok {
+		return checksum.File(localPath)
+	}
+	return hashObject(store, info.Key)
+}
+
+// hashObject reads an object through and returns its hex SHA-256.
+func hashObject(store storage.Storage, key string) (string, error) {
+	reader, err := store.Get(key)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close reader for %s: %v", key, err)
+		}
+	}()
+
+	hashed := checksum.NewReader(reader, "")
+	if _, err := io.Copy(io.Discard, hashed); err != nil {
+		return "", err
+	}
+	return hashed.Sum(), nil
+}
+
+// readJournal returns the keys a previous run migrated. A missing journal is empty.
+func readJournal(journalPath string) (map[string]bool, error) {
+	done := map[string]bool{}
+	if journalPath == "" {
+		return done, nil
+	}
+
+	file, err := os.Open(filepath.Clean(journalPath))
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Warning: Failed to close journal: %v", err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			done[key] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return done, nil
+}
@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedOutputs(t *testing.T) *storage.Filesystem {
+	source := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, storage.PutWithMetadata(source, "frames_20240101_120000.zip", strings.NewReader("zip one"), "",
+		map[string]string{storage.MetaOwner: "acme", storage.MetaFrameCount: "3"}))
+	require.NoError(t, source.Put("frames_20240102_120000.zip", strings.NewReader("zip two"), ""))
+	require.NoError(t, source.Put("hls_20240101_120000/master.m3u8", strings.NewReader("#EXTM3U"), ""))
+	return source
+}
+
+func readAll(t *testing.T, store storage.Storage, key string) string {
+	reader, err := store.Get(key)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMigrator_CopiesObjectsAndMetadata(t *testing.T) {
+	source, destination := seedOutputs(t), storage.NewMemory()
+
+	report, err := New(source, destination, Options{Parallelism: 2, Verify: true}).Run()
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Copied)
+	assert.Empty(t, report.Failures)
+	assert.Equal(t, "zip one", readAll(t, destination, "frames_20240101_120000.zip"))
+	assert.Equal(t, "#EXTM3U", readAll(t, destination, "hls_20240101_120000/master.m3u8"))
+
+	info, err := destination.Stat("frames_20240101_120000.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", info.Metadata[storage.MetaOwner], "sidecar metadata is carried over")
+	assert.Len(t, info.Metadata[storage.MetaChecksum], 64)
+}
+
+func TestMigrator_DryRunWritesNothing(t *testing.T) {
+	source, destination := seedOutputs(t), storage.NewMemory()
+	journal := filepath.Join(t.TempDir(), "journal")
+
+	report, err := New(source, destination, Options{DryRun: true, Journal: journal}).Run()
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Copied)
+	objects, err := destination.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+	assert.NoFileExists(t, journal)
+}
+
+func TestMigrator_ResumesFromJournal(t *testing.T) {
+	source, destination := seedOutputs(t), storage.NewMemory()
+	journal := filepath.Join(t.TempDir(), "journal")
+	require.NoError(t, os.WriteFile(journal, []byte("frames_20240101_120000.zip\n"), 0600))
+
+	report, err := New(source, destination, Options{Journal: journal}).Run()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Copied)
+	assert.Equal(t, 1, report.Skipped)
+	_, err = destination.Stat("frames_20240101_120000.zip")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "journaled keys are not copied again")
+
+	data, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	assert.Len(t, strings.Fields(string(data)), 3)
+
+	report, err = New(source, destination, Options{Journal: journal}).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Copied)
+	assert.Equal(t, 3, report.Skipped)
+}
+
+func TestMigrator_SkipsIdenticalCopiesAndReplacesOthers(t *testing.T) {
+	source, destination := seedOutputs(t), storage.NewMemory()
+	require.NoError(t, destination.Put("frames_20240101_120000.zip", strings.NewReader("zip one"), ""))
+	require.NoError(t, destination.Put("frames_20240102_120000.zip", strings.NewReader("zip 2!!"), ""))
+
+	report, err := New(source, destination, Options{Prefix: "frames_"}).Run()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Copied)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, "zip two", readAll(t, destination, "frames_20240102_120000.zip"))
+}
+
+// corrupting hands back altered bytes, like a bucket that damaged what it stored.
+type corrupting struct {
+	*storage.Memory
+}
+
+func (c corrupting) Get(key string) (io.ReadCloser, error) {
+	reader, err := c.Memory.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(append(data, '!'))), nil
+}
+
+func TestMigrator_VerifyRejectsCorruptedCopies(t *testing.T) {
+	source, destination := seedOutputs(t), corrupting{storage.NewMemory()}
+
+	report, err := New(source, destination, Options{Prefix: "frames_20240102", Verify: true}).Run()
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Copied)
+	require.Len(t, report.Failures, 1)
+	assert.Equal(t, "frames_20240102_120000.zip", report.Failures[0].Key)
+	_, err = destination.Stat("frames_20240102_120000.zip")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "the unverified copy is removed")
+}
@@ -0,0 +1,273 @@
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the job queue in a bolt database: pending messages by sequence, and the
+// messages that ran out of attempts.
+var (
+	boltQueueBucket = []byte("queue")
+	boltDeadBucket  = []byte("queue-dead")
+)
+
+// boltWait is how long a receive waits for a message, as long as an SQS long poll.
+const boltWait = 20 * time.Second
+
+// boltPollInterval is how often a waiting receive looks for messages that became
+// visible again.
+const boltPollInterval = time.Second
+
+// errStaleReceipt is returned for a delivery whose message was acknowledged or handed
+// out again since it was received.
+var errStaleReceipt = errors.New("receipt handle is stale")
+
+// BoltQueue is a Queue in an embedded bolt database, for installs without SQS. Like an
+// SQS queue with a redrive policy it hides received messages until their visibility
+// timeout passes and moves a message to its dead letters once it was received
+// maxAttempts times. Messages survive restarts; one taken by a worker that stopped
+// is handed out again once its visibility timeout passes.
+type BoltQueue struct {
+	db          *bolt.DB
+	maxAttempts int
+	visibility  time.Duration
+	wait        time.Duration
+	retryBase   time.Duration
+	// notify wakes a waiting receive when a message is enqueued or released.
+	notify chan struct{}
+}
+
+// boltMessage is a message as stored, with its deliveries so far and when it can be
+// received again.
+type boltMessage struct {
+	Message   Message   `json:"message"`
+	Attempts  int       `json:"attempts"`
+	VisibleAt time.Time `json:"visible_at"`
+}
+
+// NewBoltQueue returns the queue in db, creating its buckets. Received messages are
+// hidden for visibility.
+func NewBoltQueue(db *bolt.DB, maxAttempts int, visibility time.Duration) (*BoltQueue, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltQueueBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltDeadBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create queue buckets: %w", err)
+	}
+	return &BoltQueue{
+		db:          db,
+		maxAttempts: maxAttempts,
+		visibility:  visibility,
+		wait:        boltWait,
+		retryBase:   defaultRetryBase,
+		notify:      make(chan struct{}, 1),
+	}, nil
+}
+
+func (q *BoltQueue) Enqueue(message Message) error {
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltQueueBucket)
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return putBoltMessage(bucket, sequence, boltMessage{Message: message, VisibleAt: time.Now()})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job %s: %w", message.JobID, err)
+	}
+	q.wake()
+	return nil
+}
+
+// Receive waits for a visible message, oldest first, and hides it for the visibility
+// timeout. Messages that ran out of attempts, or cannot be decoded, are moved to the
+// dead letters on the way.
+func (q *BoltQueue) Receive(ctx context.Context) (*Delivery, error) {
+	deadline := time.NewTimer(q.wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(boltPollInterval)
+	defer ticker.Stop()
+
+	for {
+		delivery, err := q.take(time.Now())
+		if err != nil || delivery != nil {
+			return delivery, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-deadline.C:
+			return nil, nil
+		case <-ticker.C:
+		case <-q.notify:
+		}
+	}
+}
+
+func (q *BoltQueue) take(now time.Time) (*Delivery, error) {
+	var delivery *Delivery
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltQueueBucket)
+		var dead [][]byte
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			var stored boltMessage
+			if err := json.Unmarshal(value, &stored); err != nil {
+				log.Printf("Warning: Failed to decode message %d: %v", binary.BigEndian.Uint64(key), err)
+				dead = append(dead, key)
+				continue
+			}
+			if stored.VisibleAt.After(now) {
+				continue
+			}
+			if q.maxAttempts > 0 && stored.Attempts >= q.maxAttempts {
+				dead = append(dead, key)
+				continue
+			}
+
+			stored.Attempts++
+			stored.VisibleAt = now.Add(q.visibility)
+			sequence := binary.BigEndian.Uint64(key)
+			if err := putBoltMessage(bucket, sequence, stored); err != nil {
+				return err
+			}
+			delivery = &Delivery{Message: stored.Message, Receipt: boltReceipt(sequence, stored.Attempts), Attempts: stored.Attempts}
+			break
+		}
+		return moveToDead(tx, dead)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive from queue: %w", err)
+	}
+	return delivery, nil
+}
+
+// moveToDead moves the messages under keys to the dead letters. Keys are collected
+// first, since deleting under a cursor can skip the message after it.
+func moveToDead(tx *bolt.Tx, keys [][]byte) error {
+	bucket := tx.Bucket(boltQueueBucket)
+	deadBucket := tx.Bucket(boltDeadBucket)
+	for _, key := range keys {
+		key = append([]byte(nil), key...)
+		if err := deadBucket.Put(key, append([]byte(nil), bucket.Get(key)...)); err != nil {
+			return err
+		}
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *BoltQueue) Extend(delivery *Delivery, timeout time.Duration) error {
+	if err := q.update(delivery, func(stored *boltMessage) { stored.VisibleAt = time.Now().Add(timeout) }); err != nil {
+		return fmt.Errorf("failed to change visibility of job %s: %w", delivery.Message.JobID, err)
+	}
+	return nil
+}
+
+func (q *BoltQueue) Ack(delivery *Delivery) error {
+	if err := q.update(delivery, nil); err != nil {
+		return fmt.Errorf("failed to delete message of job %s: %w", delivery.Message.JobID, err)
+	}
+	return nil
+}
+
+func (q *BoltQueue) Nack(delivery *Delivery) error {
+	visibleAt := time.Now().Add(retryDelay(q.retryBase, delivery.Attempts))
+	if err := q.update(delivery, func(stored *boltMessage) { stored.VisibleAt = visibleAt }); err != nil {
+		return fmt.Errorf("failed to change visibility of job %s: %w", delivery.Message.JobID, err)
+	}
+	q.wake()
+	return nil
+}
+
+// update applies change to the message delivery was received from, or deletes it
+// when change is nil. It fails with errStaleReceipt when the message was handed out
+// again since.
+func (q *BoltQueue) update(delivery *Delivery, change func(*boltMessage)) error {
+	sequence, attempts, ok := parseBoltReceipt(delivery.Receipt)
+	if !ok {
+		return errStaleReceipt
+	}
+	return q.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltQueueBucket)
+		key := boltKey(sequence)
+		value := bucket.Get(key)
+		if value == nil {
+			return errStaleReceipt
+		}
+		var stored boltMessage
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return err
+		}
+		if stored.Attempts != attempts {
+			return errStaleReceipt
+		}
+		if change == nil {
+			return bucket.Delete(key)
+		}
+		change(&stored)
+		return putBoltMessage(bucket, sequence, stored)
+	})
+}
+
+// wake lets a waiting receive look for messages at once.
+func (q *BoltQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func putBoltMessage(bucket *bolt.Bucket, sequence uint64, stored boltMessage) error {
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(boltKey(sequence), value)
+}
+
+// boltKey orders messages by the sequence they were enqueued in.
+func boltKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+// boltReceipt names a delivery by its message and attempt, so a receipt is only good
+// until the message is handed out again.
+func boltReceipt(sequence uint64, attempts int) string {
+	return strconv.FormatUint(sequence, 10) + ":" + strconv.Itoa(attempts)
+}
+
+func parseBoltReceipt(receipt string) (uint64, int, bool) {
+	rawSequence, rawAttempts, ok := strings.Cut(receipt, ":")
+	if !ok {
+		return 0, 0, false
+	}
+	sequence, err := strconv.ParseUint(rawSequence, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	attempts, err := strconv.Atoi(rawAttempts)
+	if err != nil {
+		return 0, 0, false
+	}
+	return sequence, attempts, true
+}
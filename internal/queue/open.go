@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"fmt"
+
+	"video-processor/internal/config"
+)
+
+// Backends jobs can be dispatched through, named by JOB_QUEUE. With BackendHTTP the API
+// calls the processor directly and no queue is opened. BackendBolt keeps the queue in
+// the embedded database of the process that opens it.
+const (
+	BackendHTTP = "http"
+	BackendSQS  = "sqs"
+	BackendBolt = "bolt"
+)
+
+// Open returns the queue of backend, or nil for BackendHTTP and empty.
+func Open(backend string, awsConfig *config.AWSConfig, embedded *config.EmbeddedConfig) (Queue, error) {
+	switch backend {
+	case "", BackendHTTP:
+		return nil, nil
+	case BackendSQS:
+		client, err := config.NewSQSClient(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+		sqsConfig := awsConfig.SQS
+		sqsQueue, err := OpenSQS(client, sqsConfig.VideoProcessingQueue, sqsConfig.VideoProcessingDLQQueue, sqsConfig.MaxReceiveCount, sqsConfig.VisibilityTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return sqsQueue, nil
+	case BackendBolt:
+		db, err := config.OpenBoltDB(embedded.Path)
+		if err != nil {
+			return nil, err
+		}
+		boltQueue, err := NewBoltQueue(db, embedded.MaxAttempts, embedded.VisibilityTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return boltQueue, nil
+	default:
+		return nil, fmt.Errorf("unknown job queue %q: use %s, %s or %s", backend, BackendHTTP, BackendSQS, BackendBolt)
+	}
+}
@@ -0,0 +1,75 @@
+// Package queue hands processing jobs from the API to the processor. The API enqueues a
+// message for each accepted video and processor workers take messages off the queue,
+// keeping them hidden from other workers while they process and retrying them until
+// they are moved to a dead-letter queue.
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Message describes a job to process: the stored upload, and everything the processor
+// needs to process it as the API would have over HTTP.
+type Message struct {
+	JobID      string `json:"job_id"`
+	Tenant     string `json:"tenant,omitempty"`
+	SourceKey  string `json:"source_key"`
+	SourceName string `json:"source_name,omitempty"`
+	Options    string `json:"options,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
+}
+
+// Delivery is a message received from a queue. Attempts counts its deliveries,
+// including this one.
+type Delivery struct {
+	Message  Message
+	Receipt  string
+	Attempts int
+}
+
+// Producer enqueues job messages.
+type Producer interface {
+	Enqueue(message Message) error
+}
+
+// Consumer hands out job messages. A received message stays hidden from other
+// consumers until its visibility timeout passes; it is deleted once acknowledged.
+type Consumer interface {
+	// Receive waits for a message until ctx is done or the queue's wait time passes,
+	// returning nil when none arrived.
+	Receive(ctx context.Context) (*Delivery, error)
+	// Extend keeps a received message hidden for timeout from now.
+	Extend(delivery *Delivery, timeout time.Duration) error
+	// Ack deletes a processed message.
+	Ack(delivery *Delivery) error
+	// Nack makes a message visible again to be retried, after a delay that grows with
+	// its attempts.
+	Nack(delivery *Delivery) error
+}
+
+// Retries of a failed message wait defaultRetryBase after the first attempt and twice
+// as long after each further one, up to maxRetryDelay, so a processor that is down for
+// a restart does not see every attempt at a job fail within moments.
+const (
+	defaultRetryBase = 30 * time.Second
+	maxRetryDelay    = 15 * time.Minute
+)
+
+// retryDelay is how long a message stays hidden after its attempts-th delivery failed.
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Queue is both ends of a job queue.
+type Queue interface {
+	Producer
+	Consumer
+}
@@ -0,0 +1,421 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// fakeSQS keeps the messages of a single queue in memory. Messages are visible
+// until received; ChangeMessageVisibility with 0 makes them visible again, and any
+// other timeout keeps them hidden and is recorded in timeouts.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	mu          sync.Mutex
+	attributes  map[string]map[string]*string
+	messages    []*fakeMessage
+	extensions  int
+	timeouts    []int64
+	nextReceipt int
+}
+
+type fakeMessage struct {
+	body     string
+	receipt  string
+	received int
+	visible  bool
+	deleted  bool
+}
+
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{attributes: map[string]map[string]*string{}}
+}
+
+func (f *fakeSQS) GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://localstack:4566/000000000000/" + aws.StringValue(input.QueueName))}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(input *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	arn := "arn:aws:sqs:us-east-1:000000000000:" + aws.StringValue(input.QueueUrl)[len("http://localstack:4566/000000000000/"):]
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{sqs.QueueAttributeNameQueueArn: aws.String(arn)}}, nil
+}
+
+func (f *fakeSQS) SetQueueAttributes(input *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attributes[aws.StringValue(input.QueueUrl)] = input.Attributes
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *fakeSQS) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, &fakeMessage{body: aws.StringValue(input.MessageBody), visible: true})
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(_ aws.Context, _ *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, message := range f.messages {
+		if message.visible && !message.deleted {
+			f.nextReceipt++
+			message.visible = false
+			message.received++
+			message.receipt = strconv.Itoa(f.nextReceipt)
+			return &sqs.ReceiveMessageOutput{Messages: []*sqs.Message{{
+				Body:          aws.String(message.body),
+				ReceiptHandle: aws.String(message.receipt),
+				Attributes: map[string]*string{
+					sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(strconv.Itoa(message.received)),
+				},
+			}}}, nil
+		}
+	}
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	message := f.find(aws.StringValue(input.ReceiptHandle))
+	if message == nil {
+		return nil, errors.New("receipt handle is invalid")
+	}
+	if aws.Int64Value(input.VisibilityTimeout) == 0 {
+		message.visible = true
+	} else {
+		f.extensions++
+		f.timeouts = append(f.timeouts, aws.Int64Value(input.VisibilityTimeout))
+	}
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	message := f.find(aws.StringValue(input.ReceiptHandle))
+	if message == nil {
+		return nil, errors.New("receipt handle is invalid")
+	}
+	message.deleted = true
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) find(receipt string) *fakeMessage {
+	for _, message := range f.messages {
+		if message.receipt == receipt && !message.deleted {
+			return message
+		}
+	}
+	return nil
+}
+
+func (f *fakeSQS) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, message := range f.messages {
+		if !message.deleted {
+			count++
+		}
+	}
+	return count
+}
+
+func TestOpenSQS_SetsRedrivePolicy(t *testing.T) {
+	client := newFakeSQS()
+
+	q, err := OpenSQS(client, "video-processing-queue", "video-processing-dlq", 3, time.Minute)
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localstack:4566/000000000000/video-processing-queue", q.url)
+	var policy map[string]string
+	require.NoError(t, json.Unmarshal([]byte(aws.StringValue(client.attributes[q.url][sqs.QueueAttributeNameRedrivePolicy])), &policy))
+	assert.Equal(t, "arn:aws:sqs:us-east-1:000000000000:video-processing-dlq", policy["deadLetterTargetArn"])
+	assert.Equal(t, "3", policy["maxReceiveCount"])
+}
+
+func TestSQSQueue_DeliversMessagesUntilAcknowledged(t *testing.T) {
+	client := newFakeSQS()
+	q := NewSQSQueue(client, "queue", time.Minute)
+	q.retryBase = 0
+	message := Message{JobID: "job", Tenant: "acme", SourceKey: "20240101_120000_video.mp4", Options: `{"proxy":true}`}
+
+	require.NoError(t, q.Enqueue(message))
+
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	assert.Equal(t, message, delivery.Message)
+	assert.Equal(t, 1, delivery.Attempts)
+
+	none, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none, "received messages are hidden")
+
+	require.NoError(t, q.Nack(delivery))
+	retried, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, retried)
+	assert.Equal(t, 2, retried.Attempts)
+
+	require.NoError(t, q.Ack(retried))
+	assert.Zero(t, client.pending())
+}
+
+func TestSQSQueue_DelaysRetriesByAttempt(t *testing.T) {
+	client := newFakeSQS()
+	q := NewSQSQueue(client, "queue", time.Minute)
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+
+	require.NoError(t, q.Nack(delivery))
+	none, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none, "a failed message waits before it is retried")
+
+	delivery.Attempts = 3
+	require.NoError(t, q.Nack(delivery))
+	delivery.Attempts = 10
+	require.NoError(t, q.Nack(delivery))
+	assert.Equal(t, []int64{30, 120, 900}, client.timeouts, "the wait doubles with each attempt, up to a cap")
+}
+
+func TestWorker_AcknowledgesProcessedMessages(t *testing.T) {
+	client := newFakeSQS()
+	q := NewSQSQueue(client, "queue", time.Minute)
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []string
+	worker := NewWorker(q, func(_ context.Context, delivery *Delivery) error {
+		handled = append(handled, delivery.Message.JobID)
+		cancel()
+		return nil
+	}, Options{Visibility: time.Minute})
+
+	worker.Run(ctx)
+
+	assert.Equal(t, []string{"job"}, handled)
+	assert.Zero(t, client.pending())
+}
+
+func TestWorker_ExtendsVisibilityWhileProcessing(t *testing.T) {
+	client := newFakeSQS()
+	q := NewSQSQueue(client, "queue", time.Minute)
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+
+	worker := NewWorker(q, func(context.Context, *Delivery) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}, Options{Visibility: 30 * time.Millisecond})
+
+	worker.Handle(context.Background(), delivery)
+
+	assert.GreaterOrEqual(t, client.extensions, 2)
+	assert.Zero(t, client.pending())
+}
+
+func TestWorker_RetriesFailuresAndReportsTheLastAttempt(t *testing.T) {
+	client := newFakeSQS()
+	q := NewSQSQueue(client, "queue", time.Minute)
+	q.retryBase = 0
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+
+	var exhausted []int
+	worker := NewWorker(q, func(context.Context, *Delivery) error {
+		return errors.New("uploads store unavailable")
+	}, Options{
+		Visibility:  time.Minute,
+		MaxAttempts: 2,
+		Exhausted: func(delivery *Delivery, err error) {
+			exhausted = append(exhausted, delivery.Attempts)
+		},
+	})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		delivery, err := q.Receive(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, delivery, "failed messages are released for another attempt")
+		worker.Handle(context.Background(), delivery)
+	}
+
+	assert.Equal(t, []int{2}, exhausted)
+	assert.Equal(t, 1, client.pending(), "the queue's redrive policy takes it from here")
+}
+
+func newTestBoltQueue(t *testing.T, path string, maxAttempts int) *BoltQueue {
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	q, err := NewBoltQueue(db, maxAttempts, time.Minute)
+	require.NoError(t, err)
+	q.wait = 10 * time.Millisecond
+	q.retryBase = 0
+	return q
+}
+
+// boltCounts returns how many messages are pending and dead in q.
+func boltCounts(t *testing.T, q *BoltQueue) (pending, dead int) {
+	require.NoError(t, q.db.View(func(tx *bolt.Tx) error {
+		pending = tx.Bucket(boltQueueBucket).Stats().KeyN
+		dead = tx.Bucket(boltDeadBucket).Stats().KeyN
+		return nil
+	}))
+	return pending, dead
+}
+
+func TestBoltQueue_DeliversMessagesUntilAcknowledged(t *testing.T) {
+	q := newTestBoltQueue(t, filepath.Join(t.TempDir(), "jobs.db"), 5)
+	first := Message{JobID: "first", Tenant: "acme", SourceKey: "20240101_120000_video.mp4", Options: `{"proxy":true}`}
+	require.NoError(t, q.Enqueue(first))
+	require.NoError(t, q.Enqueue(Message{JobID: "second"}))
+
+	delivery, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	assert.Equal(t, first, delivery.Message, "messages are delivered in order")
+	assert.Equal(t, 1, delivery.Attempts)
+
+	second, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, second)
+	assert.Equal(t, "second", second.Message.JobID)
+	none, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none, "received messages are hidden")
+
+	require.NoError(t, q.Nack(delivery))
+	retried, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, retried)
+	assert.Equal(t, 2, retried.Attempts)
+	assert.Error(t, q.Ack(delivery), "a receipt is only good until the message is handed out again")
+
+	require.NoError(t, q.Ack(retried))
+	require.NoError(t, q.Ack(second))
+	pending, dead := boltCounts(t, q)
+	assert.Zero(t, pending)
+	assert.Zero(t, dead)
+}
+
+func TestBoltQueue_RedeliversMessagesOfStoppedWorkers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	q := newTestBoltQueue(t, path, 5)
+	q.visibility = 20 * time.Millisecond
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+	taken, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, taken)
+	require.NoError(t, q.db.Close())
+
+	reopened := newTestBoltQueue(t, path, 5)
+	time.Sleep(30 * time.Millisecond)
+	delivery, err := reopened.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, delivery, "the message outlives the process and its visibility timeout")
+	assert.Equal(t, "job", delivery.Message.JobID)
+	assert.Equal(t, 2, delivery.Attempts)
+}
+
+func TestBoltQueue_DelaysRetriesByAttempt(t *testing.T) {
+	q := newTestBoltQueue(t, filepath.Join(t.TempDir(), "jobs.db"), 5)
+	q.retryBase = 40 * time.Millisecond
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+
+	first, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	require.NoError(t, q.Nack(first))
+	none, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none, "a failed message waits before it is retried")
+
+	time.Sleep(50 * time.Millisecond)
+	second, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, second, "the message is retried once the delay passed")
+	assert.Equal(t, 2, second.Attempts)
+
+	require.NoError(t, q.Nack(second))
+	time.Sleep(50 * time.Millisecond)
+	none, err = q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none, "the second retry waits twice as long")
+	time.Sleep(40 * time.Millisecond)
+	third, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, third)
+	assert.Equal(t, 3, third.Attempts)
+}
+
+func TestBoltQueue_MovesExhaustedMessagesToDeadLetters(t *testing.T) {
+	q := newTestBoltQueue(t, filepath.Join(t.TempDir(), "jobs.db"), 2)
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+
+	var exhausted []int
+	worker := NewWorker(q, func(context.Context, *Delivery) error {
+		return errors.New("processor unavailable")
+	}, Options{
+		Visibility:  time.Minute,
+		MaxAttempts: 2,
+		Exhausted: func(delivery *Delivery, err error) {
+			exhausted = append(exhausted, delivery.Attempts)
+		},
+	})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		delivery, err := q.Receive(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, delivery, "failed messages are released for another attempt")
+		worker.Handle(context.Background(), delivery)
+	}
+
+	none, err := q.Receive(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	assert.Equal(t, []int{2}, exhausted)
+	pending, dead := boltCounts(t, q)
+	assert.Zero(t, pending)
+	assert.Equal(t, 1, dead)
+}
+
+func TestBoltQueue_WakesWaitingReceivers(t *testing.T) {
+	q := newTestBoltQueue(t, filepath.Join(t.TempDir(), "jobs.db"), 5)
+	q.wait = time.Minute
+
+	received := make(chan *Delivery)
+	go func() {
+		delivery, _ := q.Receive(context.Background())
+		received <- delivery
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, q.Enqueue(Message{JobID: "job"}))
+
+	select {
+	case delivery := <-received:
+		require.NotNil(t, delivery)
+		assert.Equal(t, "job", delivery.Message.JobID)
+	case <-time.After(boltPollInterval / 2):
+		t.Fatal("the receiver was not woken by the enqueued message")
+	}
+}
@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// sqsWaitSeconds is the long-polling wait of each receive, the most SQS allows.
+const sqsWaitSeconds = 20
+
+// SQSQueue is a Queue on an SQS queue, whose redrive policy moves messages to the
+// dead-letter queue after too many deliveries.
+type SQSQueue struct {
+	client     sqsiface.SQSAPI
+	url        string
+	visibility time.Duration
+	retryBase  time.Duration
+}
+
+// NewSQSQueue returns the queue at url, hiding received messages for visibility.
+func NewSQSQueue(client sqsiface.SQSAPI, url string, visibility time.Duration) *SQSQueue {
+	return &SQSQueue{client: client, url: url, visibility: visibility, retryBase: defaultRetryBase}
+}
+
+// OpenSQS looks up the queue and dead-letter queue by name and sets the redrive policy
+// that moves a message to the dead-letter queue once it was received maxReceiveCount
+// times.
+func OpenSQS(client sqsiface.SQSAPI, name, dlqName string, maxReceiveCount int, visibility time.Duration) (*SQSQueue, error) {
+	url, err := queueURL(client, name)
+	if err != nil {
+		return nil, err
+	}
+	dlqURL, err := queueURL(client, dlqName)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(dlqURL),
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ARN of queue %s: %w", dlqName, err)
+	}
+	policy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": aws.StringValue(attributes.Attributes[sqs.QueueAttributeNameQueueArn]),
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		QueueUrl:   aws.String(url),
+		Attributes: map[string]*string{sqs.QueueAttributeNameRedrivePolicy: aws.String(string(policy))},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to set redrive policy of queue %s: %w", name, err)
+	}
+
+	return NewSQSQueue(client, url, visibility), nil
+}
+
+func queueURL(client sqsiface.SQSAPI, name string) (string, error) {
+	output, err := client.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", fmt.Errorf("failed to find queue %s: %w", name, err)
+	}
+	return aws.StringValue(output.QueueUrl), nil
+}
+
+func (q *SQSQueue) Enqueue(message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := q.client.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.url),
+		MessageBody: aws.String(string(body)),
+	}); err != nil {
+		return fmt.Errorf("failed to enqueue job %s: %w", message.JobID, err)
+	}
+	return nil
+}
+
+// Receive long-polls for a message. A message that cannot be decoded is left on the
+// queue, so the redrive policy moves it to the dead-letter queue.
+func (q *SQSQueue) Receive(ctx context.Context) (*Delivery, error) {
+	output, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.url),
+		MaxNumberOfMessages: aws.Int64(1),
+		WaitTimeSeconds:     aws.Int64(sqsWaitSeconds),
+		VisibilityTimeout:   aws.Int64(int64(q.visibility / time.Second)),
+		AttributeNames:      aws.StringSlice([]string{sqs.MessageSystemAttributeNameApproximateReceiveCount}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive from queue: %w", err)
+	}
+	if len(output.Messages) == 0 {
+		return nil, nil
+	}
+
+	received := output.Messages[0]
+	var message Message
+	if err := json.Unmarshal([]byte(aws.StringValue(received.Body)), &message); err != nil {
+		log.Printf("Warning: Failed to decode message %s: %v", aws.StringValue(received.MessageId), err)
+		return nil, nil
+	}
+	attempts, err := strconv.Atoi(aws.StringValue(received.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil {
+		attempts = 1
+	}
+	return &Delivery{Message: message, Receipt: aws.StringValue(received.ReceiptHandle), Attempts: attempts}, nil
+}
+
+// Extend rounds timeout up to the whole seconds SQS counts in, so an extension never
+// makes the message visible.
+func (q *SQSQueue) Extend(delivery *Delivery, timeout time.Duration) error {
+	return q.changeVisibility(delivery, (timeout + time.Second - 1).Truncate(time.Second))
+}
+
+func (q *SQSQueue) Ack(delivery *Delivery) error {
+	if _, err := q.client.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(delivery.Receipt),
+	}); err != nil {
+		return fmt.Errorf("failed to delete message of job %s: %w", delivery.Message.JobID, err)
+	}
+	return nil
+}
+
+func (q *SQSQueue) Nack(delivery *Delivery) error {
+	return q.changeVisibility(delivery, retryDelay(q.retryBase, delivery.Attempts))
+}
+
+func (q *SQSQueue) changeVisibility(delivery *Delivery, timeout time.Duration) error {
+	if _, err := q.client.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.url),
+		ReceiptHandle:     aws.String(delivery.Receipt),
+		VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+	}); err != nil {
+		return fmt.Errorf("failed to change visibility of job %s: %w", delivery.Message.JobID, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// receiveBackoff is how long a worker waits after the queue failed to answer.
+const receiveBackoff = 5 * time.Second
+
+// defaultVisibility hides messages when Options leaves Visibility unset.
+const defaultVisibility = time.Minute
+
+// Handler processes a job message. An error leaves the message to be retried.
+type Handler func(ctx context.Context, delivery *Delivery) error
+
+// Options tune a Worker.
+type Options struct {
+	// Visibility is how long a message stays hidden; the worker extends it every third
+	// of that while the handler runs.
+	Visibility time.Duration
+	// MaxAttempts is the delivery after which the queue gives up on a message.
+	MaxAttempts int
+	// Exhausted is called when the last attempt at a message failed, before the queue
+	// moves it to the dead-letter queue.
+	Exhausted func(delivery *Delivery, err error)
+}
+
+// Worker takes messages off a Consumer one at a time and runs them through a Handler.
+type Worker struct {
+	consumer Consumer
+	handler  Handler
+	opts     Options
+}
+
+func NewWorker(consumer Consumer, handler Handler, opts Options) *Worker {
+	if opts.Visibility <= 0 {
+		opts.Visibility = defaultVisibility
+	}
+	return &Worker{consumer: consumer, handler: handler, opts: opts}
+}
+
+// Run processes messages until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := w.consumer.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Warning: Failed to receive job message: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(receiveBackoff):
+			}
+			continue
+		}
+		if delivery != nil {
+			w.Handle(ctx, delivery)
+		}
+	}
+}
+
+// Handle processes one delivery, keeping it hidden while the handler runs, and deletes
+// it once processed or releases it to be retried.
+func (w *Worker) Handle(ctx context.Context, delivery *Delivery) {
+	stop := w.heartbeat(delivery)
+	err := w.handler(ctx, delivery)
+	stop()
+
+	if err == nil {
+		if err := w.consumer.Ack(delivery); err != nil {
+			log.Printf("Warning: Failed to acknowledge job %s: %v", delivery.Message.JobID, err)
+		}
+		return
+	}
+
+	log.Printf("Warning: Failed to process job %s (attempt %d): %v", delivery.Message.JobID, delivery.Attempts, err)
+	if w.opts.MaxAttempts > 0 && delivery.Attempts >= w.opts.MaxAttempts && w.opts.Exhausted != nil {
+		w.opts.Exhausted(delivery, err)
+	}
+	if err := w.consumer.Nack(delivery); err != nil {
+		log.Printf("Warning: Failed to release job %s: %v", delivery.Message.JobID, err)
+	}
+}
+
+// heartbeat extends the visibility of delivery until the returned function is called.
+func (w *Worker) heartbeat(delivery *Delivery) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(w.opts.Visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.consumer.Extend(delivery, w.opts.Visibility); err != nil {
+					log.Printf("Warning: Failed to extend visibility of job %s: %v", delivery.Message.JobID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
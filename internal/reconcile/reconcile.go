@@ -0,0 +1,298 @@
+// Package reconcile finds objects that no job will ever clean up: uploads left behind
+// when the API or the processor died before a job finished, per-job temp files, and
+// outputs whose frames archive is gone. Objects of jobs still queued or processing are
+// left alone. It reports orphans and, when asked, deletes them.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"video-processor/internal/jobs"
+	"video-processor/internal/retention"
+	"video-processor/internal/storage"
+)
+
+// Kinds of orphans.
+const (
+	KindUpload = "upload"
+	KindOutput = "output"
+	KindTemp   = "temp"
+)
+
+// DefaultGrace is how old an object must be before it counts as orphaned, leaving
+// running jobs alone.
+const DefaultGrace = 24 * time.Hour
+
+// outputSegment matches the path segment naming a job output: the frames archive, the
+// proxy, a clip or the HLS directory.
+var outputSegment = regexp.MustCompile(`^(frames|proxy|clip|hls)_(\d{8}_\d{6}(?:-[0-9a-f]{8})?)`)
+
+// Orphan is an object or temp entry nothing refers to.
+type Orphan struct {
+	Kind         string
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Options tune a reconciliation.
+type Options struct {
+	// Grace is the minimum age of an orphan; zero uses DefaultGrace.
+	Grace time.Duration
+	// Delete removes the orphans found; otherwise they are only reported.
+	Delete bool
+}
+
+// Report lists the orphans found and how many of them were deleted.
+type Report struct {
+	Orphans []Orphan
+	Deleted int
+}
+
+// Reconciler scans the shared uploads and outputs stores, which hold every tenant's
+// objects under their prefixes, and a temp directory. Any of them may be left out.
+// Buckets of tenants with their own are not scanned. The job repository, when there is
+// one, keeps the objects of unfinished jobs.
+type Reconciler struct {
+	uploads storage.Storage
+	outputs storage.Storage
+	jobs    jobs.JobRepository
+	tempDir string
+	opts    Options
+}
+
+func New(uploads, outputs storage.Storage, repository jobs.JobRepository, tempDir string, opts Options) *Reconciler {
+	if opts.Grace <= 0 {
+		opts.Grace = DefaultGrace
+	}
+	return &Reconciler{uploads: uploads, outputs: outputs, jobs: repository, tempDir: tempDir, opts: opts}
+}
+
+// Run reconciles everything last modified before now minus the grace period. Scopes that
+// cannot be listed are skipped and reported in the error; the others still run.
+func (r *Reconciler) Run(now time.Time) (*Report, error) {
+	cutoff := now.Add(-r.opts.Grace)
+	report := &Report{}
+
+	var errs []error
+	for _, scope := range []struct {
+		enabled bool
+		find    func(time.Time) ([]Orphan, error)
+		name    string
+	}{
+		{r.uploads != nil, r.orphanedUploads, "uploads"},
+		{r.outputs != nil, r.orphanedOutputs, "outputs"},
+		{r.tempDir != "", r.staleTempEntries, "temp dir"},
+	} {
+		if !scope.enabled {
+			continue
+		}
+		orphans, err := scope.find(cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconcile %s: %w", scope.name, err))
+			continue
+		}
+		report.Orphans = append(report.Orphans, orphans...)
+	}
+
+	if r.opts.Delete {
+		for _, orphan := range report.Orphans {
+			if err := r.remove(orphan); err != nil {
+				log.Printf("Warning: Failed to delete orphaned %s %s: %v", orphan.Kind, orphan.Key, err)
+				continue
+			}
+			report.Deleted++
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// orphanedUploads lists uploads no unfinished job is processing and no retention record
+// keeps. Files of a retained or unfinished video, such as its redaction regions, share
+// its job ID and stay too.
+func (r *Reconciler) orphanedUploads(cutoff time.Time) ([]Orphan, error) {
+	retained, err := r.retainedJobs()
+	if err != nil {
+		return nil, err
+	}
+	active, err := r.activeJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := r.uploads.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	for _, object := range objects {
+		if hidden(object.Key) || !object.LastModified.Before(cutoff) {
+			continue
+		}
+		if jobID, _, ok := storage.JobID(object.Key); ok && (retained[jobID] || active[jobID]) {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: KindUpload, Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+	}
+	return orphans, nil
+}
+
+// retainedJobs returns the job IDs of retained sources.
+func (r *Reconciler) retainedJobs() (map[string]bool, error) {
+	records, err := r.uploads.List(retention.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list retention records: %w", err)
+	}
+
+	retained := map[string]bool{}
+	for _, object := range records {
+		record, err := retention.Load(r.uploads, object.Key)
+		if err != nil {
+			// Keeping everything is safer than guessing what an unreadable record held.
+			return nil, fmt.Errorf("failed to read retention record %s: %w", object.Key, err)
+		}
+		if record.VideoID != "" {
+			retained[record.VideoID] = true
+		}
+		if jobID, _, ok := storage.JobID(record.Key); ok {
+			retained[jobID] = true
+		}
+	}
+	return retained, nil
+}
+
+// activeJobs returns the job IDs of the uploads of jobs still queued or processing,
+// which a queue may retry long after they were stored.
+func (r *Reconciler) activeJobs() (map[string]bool, error) {
+	active := map[string]bool{}
+	if r.jobs == nil {
+		return active, nil
+	}
+	for _, status := range []string{jobs.StatusQueued, jobs.StatusProcessing} {
+		listed, err := r.jobs.ListByStatus(status, 0)
+		if err != nil {
+			// Without the list, any upload may belong to a job about to be retried.
+			return nil, fmt.Errorf("failed to list %s jobs: %w", status, err)
+		}
+		for _, job := range listed {
+			if jobID, _, ok := storage.JobID(job.SourceKey); ok {
+				active[jobID] = true
+			}
+		}
+	}
+	return active, nil
+}
+
+// orphanedOutputs lists proxies, clips and HLS files whose frames archive is gone and
+// whose job is not queued or processing. The archive is written first and is what the
+// API lists, so it stands as the record of a finished job. Objects that are not job
+// outputs, such as overlay images, are left alone.
+func (r *Reconciler) orphanedOutputs(cutoff time.Time) ([]Orphan, error) {
+	active, err := r.activeJobs()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := r.outputs.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		keys[object.Key] = true
+	}
+
+	var orphans []Orphan
+	for _, object := range objects {
+		if hidden(object.Key) || !object.LastModified.Before(cutoff) {
+			continue
+		}
+		archive, jobID, ok := archiveKey(object.Key)
+		if !ok || archive == object.Key || keys[archive] || active[jobID] {
+			continue
+		}
+		orphans = append(orphans, Orphan{Kind: KindOutput, Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+	}
+	return orphans, nil
+}
+
+// archiveKey returns the key of the frames archive an output belongs to, next to it
+// below the same tenant prefix, and the job ID of the output.
+func archiveKey(key string) (string, string, bool) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		match := outputSegment.FindStringSubmatch(segment)
+		if match == nil {
+			continue
+		}
+		return strings.Join(append(segments[:i:i], "frames_"+match[2]+".zip"), "/"), match[2], true
+	}
+	return "", "", false
+}
+
+// staleTempEntries lists what jobs leave directly in the temp directory: work
+// directories named after the job ID and downloaded or uploaded videos prefixed with it.
+// Caches and resumable uploads live in directories of their own and are not touched.
+func (r *Reconciler) staleTempEntries(cutoff time.Time) ([]Orphan, error) {
+	entries, err := os.ReadDir(r.tempDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	for _, entry := range entries {
+		name := entry.Name()
+		jobID, _, ok := storage.JobID(name)
+		if !ok || !(strings.HasPrefix(name, jobID) || strings.HasPrefix(name, "temp_"+jobID)) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Before(cutoff) {
+			continue
+		}
+		orphan := Orphan{Kind: KindTemp, Key: filepath.Join(r.tempDir, name), LastModified: info.ModTime()}
+		if !info.IsDir() {
+			orphan.Size = info.Size()
+		}
+		orphans = append(orphans, orphan)
+	}
+	return orphans, nil
+}
+
+func (r *Reconciler) remove(orphan Orphan) error {
+	switch orphan.Kind {
+	case KindUpload:
+		return deleteIfExists(r.uploads, orphan.Key)
+	case KindOutput:
+		return deleteIfExists(r.outputs, orphan.Key)
+	default:
+		return os.RemoveAll(orphan.Key)
+	}
+}
+
+func deleteIfExists(store storage.Storage, key string) error {
+	if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// hidden reports whether a key belongs to an index the services keep (dedup entries,
+// retention records, resumable uploads).
+func hidden(key string) bool {
+	return strings.HasPrefix(key, ".") || strings.Contains(key, "/.")
+}
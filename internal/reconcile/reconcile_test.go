@@ -0,0 +1,178 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/jobs"
+	"video-processor/internal/retention"
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func put(t *testing.T, store storage.Storage, keys ...string) {
+	for _, key := range keys {
+		require.NoError(t, store.Put(key, strings.NewReader(key), ""))
+	}
+}
+
+func retain(t *testing.T, uploads storage.Storage, tenant, key, videoID string) {
+	data, err := json.Marshal(retention.Record{Key: key, VideoID: videoID, Tenant: tenant, RetainedAt: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, uploads.Put(retention.Key(tenant, key), strings.NewReader(string(data)), "application/json"))
+}
+
+func orphanKeys(report *Report, kind string) []string {
+	var keys []string
+	for _, orphan := range report.Orphans {
+		if orphan.Kind == kind {
+			keys = append(keys, orphan.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReconciler_FindsOrphanedUploads(t *testing.T) {
+	uploads := storage.NewMemory()
+	put(t, uploads,
+		"20240101_120000_abandoned.mp4",
+		"20240102_120000_retained.mp4",
+		"redactions/20240102_120000.json",
+		"acme/2024/01/20240103_120000/20240103_120000_retained.mp4",
+		"acme/2024/01/20240104_120000/20240104_120000_abandoned.mp4",
+	)
+	retain(t, uploads, "", "20240102_120000_retained.mp4", "20240102_120000")
+	retain(t, uploads, "acme", "20240103_120000_retained.mp4", "20240103_120000")
+
+	report, err := New(uploads, nil, nil, "", Options{}).Run(time.Now().Add(48 * time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"20240101_120000_abandoned.mp4",
+		"acme/2024/01/20240104_120000/20240104_120000_abandoned.mp4",
+	}, orphanKeys(report, KindUpload))
+	assert.Zero(t, report.Deleted, "report mode deletes nothing")
+	_, err = uploads.Stat("20240101_120000_abandoned.mp4")
+	assert.NoError(t, err)
+}
+
+func TestReconciler_KeepsObjectsOfUnfinishedJobs(t *testing.T) {
+	uploads, outputs := storage.NewMemory(), storage.NewMemory()
+	put(t, uploads,
+		"20240101_120000-0a1b2c3d_queued.mp4",
+		"acme/2024/01/20240102_120000-0d0e0f10/20240102_120000-0d0e0f10_processing.mp4",
+		"20240103_120000-01020304_done.mp4",
+	)
+	put(t, outputs,
+		"acme/2024/01/20240102_120000-0d0e0f10/proxy_20240102_120000-0d0e0f10.mp4",
+		"proxy_20240103_120000-01020304.mp4",
+	)
+
+	repository := jobs.NewMemoryRepository()
+	start := time.Now()
+	for i, key := range []string{
+		"20240101_120000-0a1b2c3d_queued.mp4",
+		"20240102_120000-0d0e0f10_processing.mp4",
+		"20240103_120000-01020304_done.mp4",
+	} {
+		job := jobs.New(fmt.Sprintf("%032d", i), start)
+		job.SourceKey = key
+		require.NoError(t, repository.Create(job))
+	}
+	_, err := repository.Update(fmt.Sprintf("%032d", 1), jobs.Update{Status: jobs.StatusProcessing}, start)
+	require.NoError(t, err)
+	_, err = repository.Update(fmt.Sprintf("%032d", 2), jobs.Update{Status: jobs.StatusCompleted}, start)
+	require.NoError(t, err)
+
+	report, err := New(uploads, outputs, repository, "", Options{}).Run(start.Add(48 * time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240103_120000-01020304_done.mp4"}, orphanKeys(report, KindUpload))
+	assert.Equal(t, []string{"proxy_20240103_120000-01020304.mp4"}, orphanKeys(report, KindOutput))
+}
+
+func TestReconciler_RespectsGracePeriod(t *testing.T) {
+	uploads := storage.NewMemory()
+	put(t, uploads, "20240101_120000_processing.mp4")
+
+	report, err := New(uploads, nil, nil, "", Options{Grace: time.Hour, Delete: true}).Run(time.Now())
+
+	require.NoError(t, err)
+	assert.Empty(t, report.Orphans, "uploads younger than the grace period may still be processing")
+}
+
+func TestReconciler_FindsOutputsWithoutArchive(t *testing.T) {
+	outputs := storage.NewMemory()
+	put(t, outputs,
+		"frames_20240101_120000.zip",
+		"proxy_20240101_120000.mp4",
+		"clip_20240102_120000_01.mp4",
+		"hls_20240102_120000/master.m3u8",
+		"acme/20240103_120000/frames_20240103_120000.zip",
+		"acme/20240103_120000/hls_20240103_120000/720p.m3u8",
+		"acme/20240104_120000/proxy_20240104_120000.mp4",
+		"branding/logo.png",
+		".dedup/entries/abc",
+	)
+
+	report, err := New(nil, outputs, nil, "", Options{Delete: true}).Run(time.Now().Add(48 * time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"acme/20240104_120000/proxy_20240104_120000.mp4",
+		"clip_20240102_120000_01.mp4",
+		"hls_20240102_120000/master.m3u8",
+	}, orphanKeys(report, KindOutput))
+	assert.Equal(t, 3, report.Deleted)
+
+	remaining, err := outputs.List("")
+	require.NoError(t, err)
+	assert.Len(t, remaining, 6)
+}
+
+func TestReconciler_RemovesStaleTempEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, dir := range []string{"20240101_120000", "frame-cache", "tus"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(tempDir, dir), 0750))
+	}
+	for _, file := range []string{"20240101_120000_video.mp4", "temp_20240101_120000_video.mp4"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, file), []byte("video"), 0600))
+	}
+
+	report, err := New(nil, nil, nil, tempDir, Options{Delete: true}).Run(time.Now().Add(48 * time.Hour))
+
+	require.NoError(t, err)
+	assert.Len(t, orphanKeys(report, KindTemp), 3)
+	assert.Equal(t, 3, report.Deleted)
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"frame-cache", "tus"}, names)
+}
+
+func TestArchiveKey(t *testing.T) {
+	for key, archive := range map[string]string{
+		"proxy_20240101_120000.mp4":                                "frames_20240101_120000.zip",
+		"hls_20240101_120000/720p/segment_000.ts":                  "frames_20240101_120000.zip",
+		"acme/2024/01/20240101_120000/clip_20240101_120000_02.mp4": "acme/2024/01/20240101_120000/frames_20240101_120000.zip",
+	} {
+		got, _, ok := archiveKey(key)
+		require.True(t, ok, key)
+		assert.Equal(t, archive, got)
+	}
+
+	_, _, ok := archiveKey("branding/logo.png")
+	assert.False(t, ok)
+}
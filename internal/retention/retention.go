@@ -0,0 +1,53 @@
+// Package retention describes the records that keep source videos in uploads storage
+// after processing. The processor writes and expires them; maintenance tools read them to
+// tell retained sources from leftovers.
+package retention
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"video-processor/internal/storage"
+)
+
+// Prefix holds one record per retained source in the uploads storage.
+const Prefix = ".retention/"
+
+// Record marks a retained source video. A zero ExpiresAt defers to the SOURCE_RETENTION
+// setting at sweep time, so changing it applies to existing sources.
+type Record struct {
+	Key        string    `json:"key"`
+	VideoID    string    `json:"video_id"`
+	Tenant     string    `json:"tenant,omitempty"`
+	RetainedAt time.Time `json:"retained_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Key names the record of a source. Records of all tenants share one index, so the
+// tenant is part of the name.
+func Key(tenant, sourceKey string) string {
+	if tenant != "" {
+		return Prefix + tenant + "/" + sourceKey + ".json"
+	}
+	return Prefix + sourceKey + ".json"
+}
+
+// Load reads the record stored under key.
+func Load(store storage.Storage, key string) (*Record, error) {
+	reader, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage reader: %v", err)
+		}
+	}()
+
+	var record Record
+	if err := json.NewDecoder(reader).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+
+	"video-processor/internal/config"
+)
+
+// NewBackends returns the uploads and outputs stores: S3 buckets when an S3 service is
+// configured, otherwise the local directories. Local outputs are encrypted at rest when
+// a key file is configured.
+func NewBackends(dirs *config.DirectoryConfig, awsConfig *config.AWSConfig, s3Service *config.S3Service) (uploads, outputs Storage, err error) {
+	if s3Service != nil {
+		uploads, outputs = NewS3Backends(awsConfig, s3Service)
+		return uploads, outputs, nil
+	}
+	return NewLocalBackends(dirs)
+}
+
+// NewS3Backends returns the uploads and outputs buckets.
+func NewS3Backends(awsConfig *config.AWSConfig, s3Service *config.S3Service) (uploads, outputs Storage) {
+	return NewS3(s3Service, awsConfig.S3Buckets.UploadsBucket), NewS3(s3Service, awsConfig.S3Buckets.OutputsBucket)
+}
+
+// NewLocalBackends returns the uploads and outputs directories, encrypting outputs at
+// rest when a key file is configured.
+func NewLocalBackends(dirs *config.DirectoryConfig) (uploads, outputs Storage, err error) {
+	outputs = NewFilesystem(dirs.OutputsDir)
+	if dirs.OutputsKeyFile != "" {
+		keyring, err := LoadKeyring(dirs.OutputsKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load outputs encryption key: %w", err)
+		}
+		outputs = NewEncrypted(outputs, keyring)
+	}
+
+	return NewFilesystem(dirs.UploadsDir), outputs, nil
+}
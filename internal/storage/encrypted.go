@@ -0,0 +1,598 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Encrypted objects start with a fixed-size header followed by a stream of AES-GCM chunks:
+//
+//	magic(4) | key ID(8) | wrap nonce(12) | wrapped data key(48) | stream nonce prefix(7)
+//
+// Every object gets a random data key, wrapped with the active master key from the key
+// file. Chunk nonces are prefix | counter | last-chunk flag so truncated, reordered or
+// spliced streams fail authentication.
+const (
+	encryptionMagic     = "VGE1"
+	keyIDSize           = 8
+	dataKeySize         = 32
+	gcmNonceSize        = 12
+	gcmTagSize          = 16
+	streamPrefixSize    = 7
+	wrappedDataKeySize  = dataKeySize + gcmTagSize
+	encryptedHeaderSize = len(encryptionMagic) + keyIDSize + gcmNonceSize + wrappedDataKeySize + streamPrefixSize
+
+	// EncryptedChunkSize is the plaintext size of each sealed chunk.
+	EncryptedChunkSize = 64 * 1024
+)
+
+// ErrDecryption is returned when an object cannot be authenticated with any known key.
+var ErrDecryption = errors.New("failed to decrypt object")
+
+// Keyring holds the master keys used to wrap data keys. The first key encrypts new
+// objects; the remaining ones are kept so objects written before a rotation stay readable.
+type Keyring struct {
+	keys   map[[keyIDSize]byte]cipher.AEAD
+	active [keyIDSize]byte
+}
+
+// LoadKeyring reads a key file with one base64-encoded 32-byte key per line. Blank lines
+// and lines starting with # are ignored. To rotate, add the new key as the first line.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var keys [][]byte
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("invalid key on line %d of %s: expected base64-encoded %d bytes", i+1, path, dataKeySize)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(keys...)
+}
+
+// NewKeyring builds a keyring from raw 32-byte keys, the first one being active.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key file contains no keys")
+	}
+
+	kr := &Keyring{keys: make(map[[keyIDSize]byte]cipher.AEAD, len(keys))}
+	for i, key := range keys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		kr.keys[id] = aead
+		if i == 0 {
+			kr.active = id
+		}
+	}
+
+	return kr, nil
+}
+
+// keyID identifies a master key without revealing it.
+func keyID(key []byte) [keyIDSize]byte {
+	var id [keyIDSize]byte
+	sum := sha256.Sum256(key)
+	copy(id[:], sum[:])
+	return id
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypted wraps a store and encrypts everything written to it. Objects written before
+// encryption was enabled are served as-is until Rewrap encrypts them.
+type Encrypted struct {
+	inner   Storage
+	keyring *Keyring
+}
+
+func NewEncrypted(inner Storage, keyring *Keyring) *Encrypted {
+	return &Encrypted{inner: inner, keyring: keyring}
+}
+
+func (e *Encrypted) Put(key string, body io.Reader, contentType string) error {
+	header, aead, prefix, err := e.newHeader()
+	if err != nil {
+		return err
+	}
+	return e.inner.Put(key, io.MultiReader(bytes.NewReader(header), newSealReader(body, aead, prefix)), contentType)
+}
+
+// newHeader generates a data key for a new object and returns its header.
+func (e *Encrypted) newHeader() ([]byte, cipher.AEAD, []byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, nil, nil, err
+	}
+
+	header, err := e.wrapHeader(dataKey, prefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return header, aead, prefix, nil
+}
+
+// wrapHeader seals dataKey with the active master key.
+func (e *Encrypted) wrapHeader(dataKey, prefix []byte) ([]byte, error) {
+	header := make([]byte, 0, encryptedHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, e.keyring.active[:]...)
+
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	header = e.keyring.keys[e.keyring.active].Seal(header, nonce, dataKey, header[:len(encryptionMagic)+keyIDSize])
+
+	return append(header, prefix...), nil
+}
+
+// parsedHeader is the decoded header of an encrypted object.
+type parsedHeader struct {
+	keyID   [keyIDSize]byte
+	dataKey []byte
+	prefix  []byte
+}
+
+func (e *Encrypted) parseHeader(header []byte) (*parsedHeader, error) {
+	var id [keyIDSize]byte
+	copy(id[:], header[len(encryptionMagic):])
+	master, ok := e.keyring.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %x", ErrDecryption, id)
+	}
+
+	offset := len(encryptionMagic) + keyIDSize
+	nonce := header[offset : offset+gcmNonceSize]
+	wrapped := header[offset+gcmNonceSize : offset+gcmNonceSize+wrappedDataKeySize]
+	dataKey, err := master.Open(nil, nonce, wrapped, header[:offset])
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key: %v", ErrDecryption, err)
+	}
+
+	return &parsedHeader{keyID: id, dataKey: dataKey, prefix: header[encryptedHeaderSize-streamPrefixSize:]}, nil
+}
+
+// readHeader reads the header of an object. It returns nil and a reader positioned at
+// the start of the object when the object is not encrypted.
+func readHeader(body io.Reader) ([]byte, *bufio.Reader, error) {
+	reader := bufio.NewReaderSize(body, EncryptedChunkSize+gcmTagSize)
+	peek, err := reader.Peek(encryptedHeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if len(peek) < encryptedHeaderSize || string(peek[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, reader, nil
+	}
+
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+	return header, reader, nil
+}
+
+func (e *Encrypted) Get(key string) (io.ReadCloser, error) {
+	body, err := e.inner.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := e.decrypt(body)
+	if err != nil {
+		closeQuietly(body, key)
+		return nil, err
+	}
+	return &readCloser{Reader: reader, Closer: body}, nil
+}
+
+// decrypt returns the plaintext of an object body, which passes through unchanged when
+// it was stored before encryption was enabled.
+func (e *Encrypted) decrypt(body io.Reader) (io.Reader, error) {
+	header, reader, err := readHeader(body)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return reader, nil
+	}
+
+	parsed, err := e.parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(parsed.dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return newOpenReader(reader, aead, parsed.prefix), nil
+}
+
+// PutWithMetadata encrypts the metadata as well, since it names the source and owner.
+// The inner store keeps it as a single sealed entry.
+func (e *Encrypted) PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error {
+	sealed, err := e.sealMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	header, aead, prefix, err := e.newHeader()
+	if err != nil {
+		return err
+	}
+	return PutWithMetadata(e.inner, key, io.MultiReader(bytes.NewReader(header), newSealReader(body, aead, prefix)), contentType, sealed)
+}
+
+// sealedMetadataKey holds the encrypted metadata of an object in the inner store.
+const sealedMetadataKey = "sealed"
+
+func (e *Encrypted) sealMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	header, aead, prefix, err := e.newHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed bytes.Buffer
+	sealed.Write(header)
+	if _, err := io.Copy(&sealed, newSealReader(bytes.NewReader(data), aead, prefix)); err != nil {
+		return nil, err
+	}
+	return map[string]string{sealedMetadataKey: base64.StdEncoding.EncodeToString(sealed.Bytes())}, nil
+}
+
+// openMetadata decrypts metadata written by PutWithMetadata; anything else is returned as-is.
+func (e *Encrypted) openMetadata(metadata map[string]string) (map[string]string, error) {
+	sealed, ok := metadata[sealedMetadataKey]
+	if !ok {
+		return metadata, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrDecryption, err)
+	}
+	reader, err := e.decrypt(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var opened map[string]string
+	if err := json.Unmarshal(data, &opened); err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrDecryption, err)
+	}
+	return opened, nil
+}
+
+// Stat reports the plaintext size of the object.
+func (e *Encrypted) Stat(key string) (*ObjectInfo, error) {
+	info, err := e.inner.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Metadata, err = e.openMetadata(info.Metadata); err != nil {
+		return nil, err
+	}
+	return e.plaintextInfo(*info)
+}
+
+func (e *Encrypted) List(prefix string) ([]ObjectInfo, error) {
+	objects, err := e.inner.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return e.plaintextInfos(objects)
+}
+
+func (e *Encrypted) ListPage(prefix, startAfter string, limit int) ([]ObjectInfo, bool, error) {
+	objects, more, err := e.inner.ListPage(prefix, startAfter, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	objects, err = e.plaintextInfos(objects)
+	return objects, more, err
+}
+
+func (e *Encrypted) plaintextInfos(objects []ObjectInfo) ([]ObjectInfo, error) {
+	for i := range objects {
+		info, err := e.plaintextInfo(objects[i])
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = *info
+	}
+	return objects, nil
+}
+
+func (e *Encrypted) plaintextInfo(info ObjectInfo) (*ObjectInfo, error) {
+	if info.Size < int64(encryptedHeaderSize+gcmTagSize) {
+		return &info, nil
+	}
+
+	body, err := e.inner.Get(info.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer closeQuietly(body, info.Key)
+
+	magic := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(body, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) == encryptionMagic {
+		info.Size = plaintextSize(info.Size)
+	}
+	return &info, nil
+}
+
+// plaintextSize derives the plaintext length from the stored length of an encrypted object.
+func plaintextSize(stored int64) int64 {
+	body := stored - int64(encryptedHeaderSize)
+	sealedChunk := int64(EncryptedChunkSize + gcmTagSize)
+	size := body / sealedChunk * EncryptedChunkSize
+	if rest := body % sealedChunk; rest > 0 {
+		size += rest - gcmTagSize
+	}
+	return size
+}
+
+func (e *Encrypted) Delete(key string) error {
+	return e.inner.Delete(key)
+}
+
+func (e *Encrypted) DownloadURL(key string, expiration time.Duration) (string, error) {
+	return "", ErrURLNotSupported
+}
+
+// Rewrap re-encrypts the data key of key with the active master key, and encrypts the
+// object if it was stored in plaintext. The chunk stream is copied unchanged, so rotation
+// does not decrypt the content. It reports whether the object was rewritten.
+func (e *Encrypted) Rewrap(key string) (bool, error) {
+	body, err := e.inner.Get(key)
+	if err != nil {
+		return false, err
+	}
+	defer closeQuietly(body, key)
+
+	info, err := e.inner.Stat(key)
+	if err != nil {
+		return false, err
+	}
+
+	header, reader, err := readHeader(body)
+	if err != nil {
+		return false, err
+	}
+	metadata, err := e.openMetadata(info.Metadata)
+	if err != nil {
+		return false, err
+	}
+	if header == nil {
+		return true, PutWithMetadata(e, key, reader, info.ContentType, metadata)
+	}
+
+	parsed, err := e.parseHeader(header)
+	if err != nil {
+		return false, err
+	}
+	if parsed.keyID == e.keyring.active {
+		return false, nil
+	}
+
+	newHeader, err := e.wrapHeader(parsed.dataKey, parsed.prefix)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := e.sealMetadata(metadata)
+	if err != nil {
+		return false, err
+	}
+	return true, PutWithMetadata(e.inner, key, io.MultiReader(bytes.NewReader(newHeader), reader), info.ContentType, sealed)
+}
+
+// RewrapAll rewraps every object under prefix and returns how many were rewritten.
+func (e *Encrypted) RewrapAll(prefix string) (int, error) {
+	objects, err := e.inner.List(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, object := range objects {
+		changed, err := e.Rewrap(object.Key)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to rewrap %s: %w", object.Key, err)
+		}
+		if changed {
+			rewritten++
+		}
+	}
+	return rewritten, nil
+}
+
+// chunkNonce builds the nonce of chunk counter: prefix | big-endian counter | last flag.
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, gcmNonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+	if last {
+		nonce[gcmNonceSize-1] = 1
+	}
+	return nonce
+}
+
+// sealReader encrypts src chunk by chunk as it is read. The final chunk is flagged in
+// its nonce, and is empty when the plaintext is empty.
+type sealReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	sealed  []byte
+	pending []byte
+	done    bool
+}
+
+func newSealReader(src io.Reader, aead cipher.AEAD, prefix []byte) *sealReader {
+	return &sealReader{
+		src:    bufio.NewReaderSize(src, EncryptedChunkSize),
+		aead:   aead,
+		prefix: prefix,
+		plain:  make([]byte, EncryptedChunkSize),
+		sealed: make([]byte, 0, EncryptedChunkSize+gcmTagSize),
+	}
+}
+
+func (r *sealReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.src, r.plain)
+		last := false
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			last = true
+		case err != nil:
+			return 0, err
+		default:
+			if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+				last = true
+			} else if peekErr != nil {
+				return 0, peekErr
+			}
+		}
+
+		if !last && r.counter == ^uint32(0) {
+			return 0, errors.New("object too large to encrypt")
+		}
+		r.pending = r.aead.Seal(r.sealed[:0], chunkNonce(r.prefix, r.counter, last), r.plain[:n], nil)
+		r.counter++
+		r.done = last
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// openReader authenticates and decrypts a chunk stream produced by sealReader.
+type openReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	sealed  []byte
+	pending []byte
+	done    bool
+}
+
+func newOpenReader(src *bufio.Reader, aead cipher.AEAD, prefix []byte) *openReader {
+	return &openReader{
+		src:    src,
+		aead:   aead,
+		prefix: prefix,
+		sealed: make([]byte, EncryptedChunkSize+gcmTagSize),
+	}
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.src, r.sealed)
+		last := false
+		switch {
+		case err == io.EOF:
+			return 0, fmt.Errorf("%w: stream truncated", ErrDecryption)
+		case err == io.ErrUnexpectedEOF:
+			last = true
+		case err != nil:
+			return 0, err
+		default:
+			if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+				last = true
+			} else if peekErr != nil {
+				return 0, peekErr
+			}
+		}
+
+		plain, err := r.aead.Open(r.sealed[:0], chunkNonce(r.prefix, r.counter, last), r.sealed[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("%w: chunk %d: %v", ErrDecryption, r.counter, err)
+		}
+		r.pending = plain
+		r.counter++
+		r.done = last
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func closeQuietly(c io.Closer, key string) {
+	if err := c.Close(); err != nil {
+		log.Printf("Warning: Failed to close object %s: %v", key, err)
+	}
+}
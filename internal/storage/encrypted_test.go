@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) []byte {
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func newTestKeyring(t *testing.T, count int) *Keyring {
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = newTestKey(t)
+	}
+	keyring, err := NewKeyring(keys...)
+	require.NoError(t, err)
+	return keyring
+}
+
+func readObject(t *testing.T, store Storage, key string) []byte {
+	reader, err := store.Get(key)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
+
+func TestEncrypted_RoundTripAcrossChunkBoundaries(t *testing.T) {
+	root := t.TempDir()
+	store := NewEncrypted(NewFilesystem(root), newTestKeyring(t, 1))
+
+	for _, size := range []int{0, 1, EncryptedChunkSize - 1, EncryptedChunkSize, EncryptedChunkSize + 1, 3*EncryptedChunkSize + 17} {
+		plaintext := bytes.Repeat([]byte("f"), size)
+		require.NoError(t, store.Put("frames.zip", bytes.NewReader(plaintext), "application/zip"))
+
+		assert.Equal(t, plaintext, readObject(t, store, "frames.zip"), "size %d", size)
+
+		info, err := store.Stat("frames.zip")
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), info.Size)
+
+		onDisk, err := os.ReadFile(filepath.Join(root, "frames.zip"))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(onDisk, []byte(encryptionMagic)))
+		// A byte or two of plaintext shows up in random ciphertext by chance.
+		if size > gcmTagSize {
+			assert.NotContains(t, string(onDisk), string(plaintext))
+		}
+	}
+}
+
+func TestEncrypted_DetectsTamperingAndTruncation(t *testing.T) {
+	root := t.TempDir()
+	store := NewEncrypted(NewFilesystem(root), newTestKeyring(t, 1))
+	require.NoError(t, store.Put("frames.zip", bytes.NewReader(bytes.Repeat([]byte("x"), 2*EncryptedChunkSize+10)), ""))
+
+	path := filepath.Join(root, "frames.zip")
+	original, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	tampered := append([]byte(nil), original...)
+	tampered[encryptedHeaderSize+5] ^= 0xff
+	require.NoError(t, os.WriteFile(path, tampered, 0600))
+	reader, err := store.Get("frames.zip")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.True(t, errors.Is(err, ErrDecryption))
+	require.NoError(t, reader.Close())
+
+	// Dropping the final chunk must not yield a shorter, valid-looking object.
+	truncated := original[:encryptedHeaderSize+EncryptedChunkSize+gcmTagSize]
+	require.NoError(t, os.WriteFile(path, truncated, 0600))
+	reader, err = store.Get("frames.zip")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.True(t, errors.Is(err, ErrDecryption))
+	require.NoError(t, reader.Close())
+}
+
+func TestEncrypted_RotationKeepsOldObjectsReadable(t *testing.T) {
+	root := t.TempDir()
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+
+	oldRing, err := NewKeyring(oldKey)
+	require.NoError(t, err)
+	require.NoError(t, NewEncrypted(NewFilesystem(root), oldRing).Put("frames.zip", strings.NewReader("frames"), ""))
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	store := NewEncrypted(NewFilesystem(root), rotated)
+	assert.Equal(t, "frames", string(readObject(t, store, "frames.zip")))
+
+	changed, err := store.Rewrap("frames.zip")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = store.Rewrap("frames.zip")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Once rewrapped, the old key can be retired.
+	newOnly, err := NewKeyring(newKey)
+	require.NoError(t, err)
+	assert.Equal(t, "frames", string(readObject(t, NewEncrypted(NewFilesystem(root), newOnly), "frames.zip")))
+
+	_, err = NewEncrypted(NewFilesystem(root), oldRing).Get("frames.zip")
+	assert.True(t, errors.Is(err, ErrDecryption))
+}
+
+func TestEncrypted_ServesAndEncryptsLegacyPlaintext(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "frames_1.zip"), []byte("legacy zip"), 0600))
+	store := NewEncrypted(NewFilesystem(root), newTestKeyring(t, 1))
+
+	assert.Equal(t, "legacy zip", string(readObject(t, store, "frames_1.zip")))
+
+	rewritten, err := store.RewrapAll("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, rewritten)
+
+	onDisk, err := os.ReadFile(filepath.Join(root, "frames_1.zip"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(onDisk, []byte(encryptionMagic)))
+	assert.Equal(t, "legacy zip", string(readObject(t, store, "frames_1.zip")))
+}
+
+func TestEncrypted_HasNoLocalPath(t *testing.T) {
+	_, ok := LocalPath(NewEncrypted(NewFilesystem(t.TempDir()), newTestKeyring(t, 1)), "frames.zip")
+	assert.False(t, ok)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir := t.TempDir()
+	active, previous := newTestKey(t), newTestKey(t)
+	path := filepath.Join(dir, "outputs.key")
+	content := "# active key first\n" + base64.StdEncoding.EncodeToString(active) + "\n\n" + base64.StdEncoding.EncodeToString(previous) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	keyring, err := LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Equal(t, keyID(active), keyring.active)
+	assert.Len(t, keyring.keys, 2)
+
+	require.NoError(t, os.WriteFile(path, []byte("not-a-key\n"), 0600))
+	_, err = LoadKeyring(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("# empty\n"), 0600))
+	_, err = LoadKeyring(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	partialSuffix = ".partial"
+
+	// metadataSuffix names the sidecar holding an object's metadata. Sidecars are hidden
+	// files next to the object, so List never returns them.
+	metadataSuffix = ".meta.json"
+)
+
+// Filesystem stores objects as files below a root directory.
+type Filesystem struct {
+	root string
+}
+
+func NewFilesystem(root string) *Filesystem {
+	return &Filesystem{root: root}
+}
+
+// LocalPath resolves key to a path confined to the root directory.
+func (fs *Filesystem) LocalPath(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+
+	rootAbs, err := filepath.Abs(fs.root)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(rootAbs, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, rootAbs+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+
+	return path, nil
+}
+
+func (fs *Filesystem) Put(key string, body io.Reader, contentType string) error {
+	path, err := fs.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, body); err != nil {
+		return err
+	}
+	return removeSidecar(path)
+}
+
+// PutWithMetadata stores the object, then its metadata as a JSON sidecar.
+func (fs *Filesystem) PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error {
+	path, err := fs.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, body); err != nil {
+		return err
+	}
+	return writeFile(sidecarPath(path), bytes.NewReader(data))
+}
+
+func sidecarPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+metadataSuffix)
+}
+
+func readSidecar(path string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Clean(sidecarPath(path)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata sidecar for %s: %w", path, err)
+	}
+	return metadata, nil
+}
+
+func removeSidecar(path string) error {
+	if err := os.Remove(sidecarPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeFile writes body to path, creating parent directories as needed.
+func writeFile(path string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	// Write under a temporary name of its own first so readers never see a partial
+	// object, and concurrent writers of the same key never write into one file.
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+partialSuffix)
+	if err != nil {
+		return err
+	}
+	partialPath := file.Name()
+	// CreateTemp makes the file private; objects stay readable as os.Create left them.
+	if err := file.Chmod(0644); err != nil {
+		log.Printf("Warning: Failed to set permissions of %s: %v", partialPath, err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("Warning: Failed to close file %s: %v", partialPath, closeErr)
+		}
+		if removeErr := os.Remove(partialPath); removeErr != nil {
+			log.Printf("Warning: Failed to remove partial file %s: %v", partialPath, removeErr)
+		}
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partialPath, path)
+}
+
+func (fs *Filesystem) Get(key string) (io.ReadCloser, error) {
+	path, err := fs.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (fs *Filesystem) Stat(key string) (*ObjectInfo, error) {
+	path, err := fs.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := readSidecar(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), Metadata: metadata}, nil
+}
+
+// List walks the root and returns every object whose key starts with prefix, sorted by key.
+func (fs *Filesystem) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.Walk(fs.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), partialSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(fs.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (fs *Filesystem) ListPage(prefix, startAfter string, limit int) ([]ObjectInfo, bool, error) {
+	objects, err := fs.List(prefix)
+	if err != nil {
+		return nil, false, err
+	}
+	page, more := pageOf(objects, startAfter, limit)
+	return page, more, nil
+}
+
+func (fs *Filesystem) Delete(key string) error {
+	path, err := fs.LocalPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return removeSidecar(path)
+}
+
+func (fs *Filesystem) DownloadURL(key string, expiration time.Duration) (string, error) {
+	return "", ErrURLNotSupported
+}
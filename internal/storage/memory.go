@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+	metadata     map[string]string
+}
+
+// Memory keeps objects in a map. It is meant for tests.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+
+	// URLPrefix, when set, makes DownloadURL return URLPrefix+key instead of ErrURLNotSupported.
+	URLPrefix string
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) Put(key string, body io.Reader, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, contentType: contentType, lastModified: time.Now()}
+	return nil
+}
+
+func (m *Memory) PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, contentType: contentType, lastModified: time.Now(), metadata: copyMetadata(metadata)}
+	return nil
+}
+
+func (m *Memory) Get(key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (m *Memory) Stat(key string) (*ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: int64(len(obj.data)), LastModified: obj.lastModified, ContentType: obj.contentType, Metadata: copyMetadata(obj.metadata)}, nil
+}
+
+func (m *Memory) List(prefix string) ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	objects := make([]ObjectInfo, 0, len(m.objects))
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectInfo{Key: key, Size: int64(len(obj.data)), LastModified: obj.lastModified, ContentType: obj.contentType})
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *Memory) ListPage(prefix, startAfter string, limit int) ([]ObjectInfo, bool, error) {
+	objects, err := m.List(prefix)
+	if err != nil {
+		return nil, false, err
+	}
+	page, more := pageOf(objects, startAfter, limit)
+	return page, more, nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) DownloadURL(key string, expiration time.Duration) (string, error) {
+	if m.URLPrefix == "" {
+		return "", ErrURLNotSupported
+	}
+	return m.URLPrefix + key, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"unicode"
+
+	"video-processor/internal/checksum"
+)
+
+// Metadata keys the services store with their outputs.
+const (
+	MetaOriginalName = "original-name"
+	MetaSourceHash   = "source-hash"
+	MetaFrameCount   = "frame-count"
+	MetaOptions      = "options"
+	MetaOwner        = "owner"
+	// MetaChecksum is the hex SHA-256 of the content. Writes that carry it are verified:
+	// content that hashes differently fails with checksum.ErrMismatch.
+	MetaChecksum = "sha256"
+)
+
+// TaggedMetadata lists the metadata keys also written as object tags where the backend
+// has them, so lifecycle rules and cost reports can filter on them. Options are left
+// out: JSON does not fit the characters tags allow.
+var TaggedMetadata = []string{MetaOriginalName, MetaSourceHash, MetaFrameCount, MetaOwner, MetaChecksum}
+
+// MetadataWriter is implemented by backends that keep user metadata with an object.
+// Stat returns it in ObjectInfo.Metadata; a plain Put drops any metadata written before.
+type MetadataWriter interface {
+	PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error
+}
+
+// PutWithMetadata stores body under key with metadata, falling back to a plain Put when
+// the backend cannot keep metadata. A MetaChecksum entry is checked against the bytes
+// read from body.
+func PutWithMetadata(s Storage, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	if digest := metadata[MetaChecksum]; digest != "" {
+		body = checksum.NewReader(body, digest)
+	}
+	if mw, ok := s.(MetadataWriter); ok && len(metadata) > 0 {
+		return mw.PutWithMetadata(key, body, contentType, metadata)
+	}
+	return s.Put(key, body, contentType)
+}
+
+// tagValue replaces the characters S3 rejects in tag values and applies its length limit.
+func tagValue(value string) string {
+	const maxTagValue = 256
+
+	runes := []rune(value)
+	if len(runes) > maxTagValue {
+		runes = runes[:maxTagValue]
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" +-=._:/@", r) {
+			runes[i] = '_'
+		}
+	}
+	return string(runes)
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
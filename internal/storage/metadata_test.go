@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"video-processor/internal/checksum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_PutWithMetadata(t *testing.T) {
+	metadata := map[string]string{
+		MetaOriginalName: "férias na praia.mp4",
+		MetaFrameCount:   "42",
+		MetaOptions:      `{"proxy":true}`,
+	}
+
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, PutWithMetadata(store, "frames_1.zip", strings.NewReader("zip"), "application/zip", metadata))
+
+			info, err := store.Stat("frames_1.zip")
+			require.NoError(t, err)
+			assert.Equal(t, metadata, info.Metadata)
+			assert.Equal(t, "zip", string(readObject(t, store, "frames_1.zip")))
+
+			objects, err := store.List("")
+			require.NoError(t, err)
+			require.Len(t, objects, 1, "sidecars stay out of listings")
+
+			require.NoError(t, store.Put("frames_1.zip", strings.NewReader("zip"), "application/zip"))
+			info, err = store.Stat("frames_1.zip")
+			require.NoError(t, err)
+			assert.Empty(t, info.Metadata, "a plain Put replaces the metadata")
+		})
+	}
+}
+
+func TestStorage_PutWithMetadataVerifiesChecksum(t *testing.T) {
+	const helloDigest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, PutWithMetadata(store, "hello.txt", strings.NewReader("hello"), "", map[string]string{MetaChecksum: helloDigest}))
+
+			err := PutWithMetadata(store, "corrupt.txt", strings.NewReader("hellO"), "", map[string]string{MetaChecksum: helloDigest})
+			assert.ErrorIs(t, err, checksum.ErrMismatch)
+			_, err = store.Stat("corrupt.txt")
+			assert.ErrorIs(t, err, ErrNotFound, "a mismatched body is not stored")
+		})
+	}
+}
+
+func TestFilesystem_DeleteRemovesMetadataSidecar(t *testing.T) {
+	root := t.TempDir()
+	store := NewFilesystem(root)
+	require.NoError(t, store.PutWithMetadata("hls_1/frames.zip", strings.NewReader("zip"), "", map[string]string{MetaOwner: "acme"}))
+	_, err := os.Stat(filepath.Join(root, "hls_1", ".frames.zip"+metadataSuffix))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Delete("hls_1/frames.zip"))
+
+	_, err = os.Stat(filepath.Join(root, "hls_1", ".frames.zip"+metadataSuffix))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEncrypted_SealsMetadata(t *testing.T) {
+	root := t.TempDir()
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	oldRing, err := NewKeyring(oldKey)
+	require.NoError(t, err)
+	metadata := map[string]string{MetaOriginalName: "confidencial.mp4"}
+	require.NoError(t, NewEncrypted(NewFilesystem(root), oldRing).PutWithMetadata("frames.zip", strings.NewReader("frames"), "", metadata))
+
+	sidecar, err := os.ReadFile(filepath.Join(root, ".frames.zip"+metadataSuffix))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sidecar), "confidencial")
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	changed, err := NewEncrypted(NewFilesystem(root), rotated).Rewrap("frames.zip")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	newOnly, err := NewKeyring(newKey)
+	require.NoError(t, err)
+	info, err := NewEncrypted(NewFilesystem(root), newOnly).Stat("frames.zip")
+	require.NoError(t, err)
+	assert.Equal(t, metadata, info.Metadata)
+}
+
+func TestTagValue(t *testing.T) {
+	assert.Equal(t, "férias na praia _1_.mp4", tagValue("férias na praia (1).mp4"))
+	assert.Equal(t, "user@example.com", tagValue("user@example.com"))
+	assert.Len(t, []rune(tagValue(strings.Repeat("á", 300))), 256)
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"video-processor/internal/config"
+)
+
+// MaxTenantLength keeps rendered bucket names within S3's 63 characters.
+const MaxTenantLength = 40
+
+var (
+	tenantPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	jobIDPattern  = regexp.MustCompile(`\d{8}_\d{6}(-[0-9a-f]{8})?`)
+
+	jobVariables = []string{config.YearVariable, config.MonthVariable, config.DayVariable, config.JobIDVariable}
+)
+
+// jobIDLayout is the upload timestamp video IDs start with. A random suffix follows it,
+// so jobs started within the same second do not share keys or work directories; IDs
+// issued before it was added are the bare timestamp.
+const jobIDLayout = "20060102_150405"
+
+// NewJobID returns a video ID for a job started at: "20240101_120000-1a2b3c4d".
+func NewJobID(at time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		binary.BigEndian.PutUint32(suffix, uint32(time.Now().UnixNano()))
+	}
+	return at.Format(jobIDLayout) + "-" + hex.EncodeToString(suffix)
+}
+
+// ValidTenant reports whether tenant can appear in keys and bucket names.
+func ValidTenant(tenant string) bool {
+	return len(tenant) <= MaxTenantLength && tenantPattern.MatchString(tenant)
+}
+
+// JobID returns the job a key belongs to, found as the first video ID in it:
+// "20240101_120000-1a2b3c4d_video.mp4", "frames_20240101_120000-1a2b3c4d.zip",
+// "hls_20240101_120000/...".
+func JobID(key string) (string, time.Time, bool) {
+	for _, match := range jobIDPattern.FindAllString(key, -1) {
+		if at, err := time.Parse(jobIDLayout, match[:len(jobIDLayout)]); err == nil {
+			return match, at, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// ValidJobID reports whether id is a video ID and nothing else, as NewJobID returns it
+// or, for jobs started before it added a suffix, the bare timestamp.
+func ValidJobID(id string) bool {
+	jobID, _, ok := JobID(id)
+	return ok && jobID == id
+}
+
+// SplitUploadName splits an upload name, "<video ID>_<original name>", as the services
+// name uploads, into the video ID and the client's filename.
+func SplitUploadName(name string) (jobID, filename string, ok bool) {
+	jobID, _, ok = JobID(name)
+	if !ok || !strings.HasPrefix(name, jobID+"_") || len(name) == len(jobID)+1 {
+		return "", name, false
+	}
+	return jobID, name[len(jobID)+1:], true
+}
+
+// keyTemplate is a parsed key prefix template.
+type keyTemplate struct {
+	pattern string
+	// root is the pattern up to the first job variable; keys that belong to no job
+	// (overlay images, for instance) are stored right below it.
+	root string
+	// depth counts the path segments the job variables add below root.
+	depth int
+}
+
+func parseKeyTemplate(pattern string) (keyTemplate, error) {
+	if pattern == "" {
+		return keyTemplate{}, nil
+	}
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	if strings.HasPrefix(pattern, "/") || strings.HasPrefix(pattern, ".") || strings.Contains(pattern, "//") {
+		return keyTemplate{}, fmt.Errorf("invalid key prefix template %q", pattern)
+	}
+
+	bare := strings.ReplaceAll(pattern, config.TenantVariable, "")
+	first := len(pattern)
+	for _, variable := range jobVariables {
+		bare = strings.ReplaceAll(bare, variable, "")
+		if i := strings.Index(pattern, variable); i >= 0 && i < first {
+			first = i
+		}
+	}
+	if strings.ContainsAny(bare, "{}") {
+		return keyTemplate{}, fmt.Errorf("unknown variable in key prefix template %q", pattern)
+	}
+
+	root := pattern[:strings.LastIndex(pattern[:first], "/")+1]
+	return keyTemplate{pattern: pattern, root: root, depth: strings.Count(pattern[len(root):], "/")}, nil
+}
+
+func (t keyTemplate) rootFor(tenant string) string {
+	return strings.ReplaceAll(t.root, config.TenantVariable, tenant)
+}
+
+func (t keyTemplate) jobFor(tenant, jobID string, at time.Time) string {
+	return strings.NewReplacer(
+		config.TenantVariable, tenant,
+		config.YearVariable, at.Format("2006"),
+		config.MonthVariable, at.Format("01"),
+		config.DayVariable, at.Format("02"),
+		config.JobIDVariable, jobID,
+	).Replace(t.pattern)
+}
+
+// Router resolves where each tenant's uploads and outputs live: the key prefix rendered
+// from STORAGE_KEY_PREFIX and, with bucket templates, a bucket of the tenant's own.
+// Every storage call the API and the processor make for a request goes through the
+// stores it returns.
+type Router struct {
+	template      keyTemplate
+	defaultTenant string
+	uploads       Storage
+	outputs       Storage
+
+	// Set only when S3 is in use.
+	buckets   config.S3Config
+	newBucket func(bucket string) Storage
+}
+
+// NewRouter routes over the default uploads and outputs stores. Bucket templates only
+// apply to S3; without an S3 service they are ignored.
+func NewRouter(routing *config.RoutingConfig, awsConfig *config.AWSConfig, s3Service *config.S3Service, uploads, outputs Storage) (*Router, error) {
+	template, err := parseKeyTemplate(routing.KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidTenant(routing.DefaultTenant) {
+		return nil, fmt.Errorf("invalid default tenant %q", routing.DefaultTenant)
+	}
+
+	router := &Router{template: template, defaultTenant: routing.DefaultTenant, uploads: uploads, outputs: outputs}
+	if awsConfig == nil {
+		return router, nil
+	}
+
+	buckets := awsConfig.S3Buckets
+	for _, bucketTemplate := range []string{buckets.UploadsBucketTemplate, buckets.OutputsBucketTemplate} {
+		if bucketTemplate != "" && strings.Count(bucketTemplate, config.TenantVariable) != 1 {
+			return nil, fmt.Errorf("bucket template %q must contain %s once", bucketTemplate, config.TenantVariable)
+		}
+	}
+	if s3Service == nil {
+		if buckets.UploadsBucketTemplate != "" || buckets.OutputsBucketTemplate != "" {
+			log.Printf("Warning: Bucket templates ignored without S3")
+		}
+		return router, nil
+	}
+
+	router.buckets = buckets
+	router.newBucket = func(bucket string) Storage { return NewS3(s3Service, bucket) }
+	return router, nil
+}
+
+// Enabled reports whether any tenant is stored apart from the default layout.
+func (r *Router) Enabled() bool {
+	return r.template.pattern != "" || r.buckets.UploadsBucketTemplate != "" || r.buckets.OutputsBucketTemplate != ""
+}
+
+// Tenant validates the tenant a request names, standing in the default tenant for none.
+func (r *Router) Tenant(tenant string) (string, error) {
+	if tenant == "" {
+		return r.defaultTenant, nil
+	}
+	if !ValidTenant(tenant) {
+		return "", fmt.Errorf("invalid tenant %q", tenant)
+	}
+	return tenant, nil
+}
+
+// Uploads returns the uploads store of a tenant validated by Tenant.
+func (r *Router) Uploads(tenant string) Storage {
+	return r.route(r.uploads, r.uploadsBucket(tenant), tenant)
+}
+
+// Outputs returns the outputs store of a tenant validated by Tenant.
+func (r *Router) Outputs(tenant string) Storage {
+	return r.route(r.outputs, r.bucketFor(r.buckets.OutputsBucketTemplate, tenant), tenant)
+}
+
+func (r *Router) uploadsBucket(tenant string) string {
+	return r.bucketFor(r.buckets.UploadsBucketTemplate, tenant)
+}
+
+func (r *Router) bucketFor(template, tenant string) string {
+	if template == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, config.TenantVariable, tenant)
+}
+
+func (r *Router) route(shared Storage, bucket, tenant string) Storage {
+	if !r.Enabled() {
+		return shared
+	}
+	store := shared
+	if bucket != "" && r.newBucket != nil {
+		store = r.newBucket(bucket)
+	}
+
+	routed := &Routed{store: store, shared: shared, tenant: tenant, template: r.template}
+	if _, ok := store.(PartUploader); ok {
+		return &routedPartUploader{&routedUploader{routed}}
+	}
+	if _, ok := store.(DirectUploader); ok {
+		return &routedUploader{routed}
+	}
+	return routed
+}
+
+func prefixFor(template keyTemplate, tenant, key string) string {
+	if template.pattern == "" {
+		return ""
+	}
+	if jobID, at, ok := JobID(key); ok {
+		return template.jobFor(tenant, jobID, at)
+	}
+	return template.rootFor(tenant)
+}
+
+// Routed is the view of one tenant: callers use the same keys as without tenants and
+// Routed stores them under the tenant's prefixes. Keys starting with "." are indexes the
+// services keep (dedup entries, retention records, resumable uploads); they stay in the
+// shared store so a single sweeper sees every tenant's.
+type Routed struct {
+	store    Storage
+	shared   Storage
+	tenant   string
+	template keyTemplate
+}
+
+func (r *Routed) route(key string) (Storage, string) {
+	if strings.HasPrefix(key, ".") {
+		return r.shared, key
+	}
+	return r.store, prefixFor(r.template, r.tenant, key) + key
+}
+
+// logical maps a key found below the tenant's root back to the key callers use.
+func (r *Routed) logical(physical string) (string, bool) {
+	rest, ok := strings.CutPrefix(physical, r.template.rootFor(r.tenant))
+	if !ok {
+		return "", false
+	}
+	if parts := strings.SplitN(rest, "/", r.template.depth+1); r.template.depth > 0 && len(parts) == r.template.depth+1 {
+		key := parts[r.template.depth]
+		if prefixFor(r.template, r.tenant, key)+key == physical {
+			return key, true
+		}
+	}
+	return rest, true
+}
+
+// listRoute picks the store and physical prefix covering every key that starts with
+// prefix: the job's own prefix when prefix names a job, the tenant's root otherwise.
+func (r *Routed) listRoute(prefix string) (Storage, string) {
+	if strings.HasPrefix(prefix, ".") {
+		return r.shared, prefix
+	}
+	if jobID, at, ok := JobID(prefix); ok && r.template.pattern != "" {
+		return r.store, r.template.jobFor(r.tenant, jobID, at) + prefix
+	}
+	return r.store, r.template.rootFor(r.tenant)
+}
+
+func (r *Routed) logicalObjects(objects []ObjectInfo, prefix string) []ObjectInfo {
+	result := make([]ObjectInfo, 0, len(objects))
+	for _, object := range objects {
+		key, ok := r.logical(object.Key)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		object.Key = key
+		result = append(result, object)
+	}
+	return result
+}
+
+func (r *Routed) Put(key string, body io.Reader, contentType string) error {
+	store, physical := r.route(key)
+	return store.Put(physical, body, contentType)
+}
+
+func (r *Routed) PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error {
+	store, physical := r.route(key)
+	return PutWithMetadata(store, physical, body, contentType, metadata)
+}
+
+func (r *Routed) Get(key string) (io.ReadCloser, error) {
+	store, physical := r.route(key)
+	return store.Get(physical)
+}
+
+func (r *Routed) Stat(key string) (*ObjectInfo, error) {
+	store, physical := r.route(key)
+	info, err := store.Stat(physical)
+	if err != nil {
+		return nil, err
+	}
+	info.Key = key
+	return info, nil
+}
+
+// List returns the tenant's keys under prefix, sorted like the other backends.
+func (r *Routed) List(prefix string) ([]ObjectInfo, error) {
+	store, physical := r.listRoute(prefix)
+	objects, err := store.List(physical)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(prefix, ".") {
+		return objects, nil
+	}
+
+	objects = r.logicalObjects(objects, prefix)
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// ListPage pages in the order keys are stored, which groups them by job. startAfter is
+// a key from an earlier page and is routed like any other key, so cursors stay valid.
+func (r *Routed) ListPage(prefix, startAfter string, limit int) ([]ObjectInfo, bool, error) {
+	store, physical := r.listRoute(prefix)
+	if strings.HasPrefix(prefix, ".") {
+		return store.ListPage(prefix, startAfter, limit)
+	}
+
+	after := ""
+	if startAfter != "" {
+		_, after = r.route(startAfter)
+	}
+	for {
+		objects, more, err := store.ListPage(physical, after, limit)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(objects) > 0 {
+			after = objects[len(objects)-1].Key
+		}
+		// Keys outside prefix are skipped; keep reading rather than return an empty page
+		// that callers would take as the end of the listing.
+		if page := r.logicalObjects(objects, prefix); len(page) > 0 || !more {
+			return page, more, nil
+		}
+	}
+}
+
+func (r *Routed) Delete(key string) error {
+	store, physical := r.route(key)
+	return store.Delete(physical)
+}
+
+func (r *Routed) DownloadURL(key string, expiration time.Duration) (string, error) {
+	store, physical := r.route(key)
+	return store.DownloadURL(physical, expiration)
+}
+
+// LocalPath exposes the files of a local backend, see the LocalPath function.
+func (r *Routed) LocalPath(key string) (string, error) {
+	store, physical := r.route(key)
+	if path, ok := LocalPath(store, physical); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("%s is not stored in a local file", key)
+}
+
+// routedUploader adds direct uploads to a Routed store whose backend supports them.
+type routedUploader struct {
+	*Routed
+}
+
+func (r *routedUploader) uploader(key string) (DirectUploader, string) {
+	store, physical := r.route(key)
+	return store.(DirectUploader), physical
+}
+
+func (r *routedUploader) PresignPut(key, contentType string, expiration time.Duration) (*PresignedRequest, error) {
+	uploader, physical := r.uploader(key)
+	return uploader.PresignPut(physical, contentType, expiration)
+}
+
+func (r *routedUploader) CreateMultipartUpload(key, contentType string, partCount int, expiration time.Duration) (*MultipartUpload, error) {
+	uploader, physical := r.uploader(key)
+	return uploader.CreateMultipartUpload(physical, contentType, partCount, expiration)
+}
+
+func (r *routedUploader) CompleteMultipartUpload(key, uploadID string, parts []CompletedPart) error {
+	uploader, physical := r.uploader(key)
+	return uploader.CompleteMultipartUpload(physical, uploadID, parts)
+}
+
+func (r *routedUploader) AbortMultipartUpload(key, uploadID string) error {
+	uploader, physical := r.uploader(key)
+	return uploader.AbortMultipartUpload(physical, uploadID)
+}
+
+// routedPartUploader adds uploads of single parts to a Routed store whose backend
+// supports them.
+type routedPartUploader struct {
+	*routedUploader
+}
+
+func (r *routedPartUploader) UploadPart(key, uploadID string, partNumber int, body io.ReadSeeker) (string, error) {
+	store, physical := r.route(key)
+	return store.(PartUploader).UploadPart(physical, uploadID, partNumber, body)
+}
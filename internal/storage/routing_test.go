@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T, prefix string, uploads, outputs Storage) *Router {
+	router, err := NewRouter(&config.RoutingConfig{KeyPrefix: prefix, DefaultTenant: config.DefaultTenant}, nil, nil, uploads, outputs)
+	require.NoError(t, err)
+	return router
+}
+
+func keysOf(objects []ObjectInfo) []string {
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func TestParseKeyTemplate(t *testing.T) {
+	template, err := parseKeyTemplate("{tenant}/{yyyy}/{mm}/{job_id}")
+	require.NoError(t, err)
+	assert.Equal(t, "{tenant}/{yyyy}/{mm}/{job_id}/", template.pattern)
+	assert.Equal(t, "{tenant}/", template.root)
+	assert.Equal(t, 3, template.depth)
+
+	template, err = parseKeyTemplate("prod/{tenant}/")
+	require.NoError(t, err)
+	assert.Equal(t, "prod/{tenant}/", template.root)
+	assert.Equal(t, 0, template.depth)
+
+	for _, invalid := range []string{"/{tenant}/", "{tenant}//{job_id}/", "{project}/", ".hidden/"} {
+		_, err := parseKeyTemplate(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestJobID(t *testing.T) {
+	for _, key := range []string{"20240315_101500_video.mp4", "frames_20240315_101500.zip", "hls_20240315_101500/720p/segment_000.ts"} {
+		jobID, at, ok := JobID(key)
+		require.True(t, ok, key)
+		assert.Equal(t, "20240315_101500", jobID)
+		assert.Equal(t, 2024, at.Year())
+	}
+
+	for _, key := range []string{"20240315_101500-0a1b2c3d_video.mp4", "frames_20240315_101500-0a1b2c3d.zip", "clip_20240315_101500-0a1b2c3d_1.mp4"} {
+		jobID, _, ok := JobID(key)
+		require.True(t, ok, key)
+		assert.Equal(t, "20240315_101500-0a1b2c3d", jobID)
+	}
+
+	_, _, ok := JobID("logo.png")
+	assert.False(t, ok)
+	_, _, ok = JobID("frames_20241399_999999.zip")
+	assert.False(t, ok, "not a valid timestamp")
+}
+
+func TestNewJobID_IsUniqueWithinASecond(t *testing.T) {
+	at := time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)
+
+	first, second := NewJobID(at), NewJobID(at)
+
+	assert.NotEqual(t, first, second)
+	jobID, parsed, ok := JobID(first + "_video.mp4")
+	require.True(t, ok)
+	assert.Equal(t, first, jobID)
+	assert.True(t, parsed.Equal(at))
+}
+
+func TestValidJobID(t *testing.T) {
+	assert.True(t, ValidJobID("20240315_101500-0a1b2c3d"))
+	assert.True(t, ValidJobID("20240315_101500"), "IDs issued before the random suffix")
+	for _, invalid := range []string{"", "../20240315_101500", "20240315_101500/..", "20240315_101500-0a1b", "20240315_101500_video.mp4", "20241399_999999"} {
+		assert.False(t, ValidJobID(invalid), invalid)
+	}
+}
+
+func TestSplitUploadName(t *testing.T) {
+	jobID, filename, ok := SplitUploadName("20240315_101500-0a1b2c3d_my_video.mp4")
+	assert.True(t, ok)
+	assert.Equal(t, "20240315_101500-0a1b2c3d", jobID)
+	assert.Equal(t, "my_video.mp4", filename)
+
+	jobID, filename, ok = SplitUploadName("20240315_101500_video.mp4")
+	assert.True(t, ok, "names issued before the random suffix")
+	assert.Equal(t, "20240315_101500", jobID)
+	assert.Equal(t, "video.mp4", filename)
+
+	_, filename, ok = SplitUploadName("video.mp4")
+	assert.False(t, ok)
+	assert.Equal(t, "video.mp4", filename)
+}
+
+func TestValidTenant(t *testing.T) {
+	assert.True(t, ValidTenant("acme"))
+	assert.True(t, ValidTenant("acme-staging-2"))
+	for _, invalid := range []string{"", "Acme", "-acme", "acme-", "acme/prod", "a_b", strings.Repeat("a", MaxTenantLength+1)} {
+		assert.False(t, ValidTenant(invalid), invalid)
+	}
+}
+
+func TestRouter_Disabled(t *testing.T) {
+	uploads, outputs := NewMemory(), NewMemory()
+	router := newTestRouter(t, "", uploads, outputs)
+
+	assert.False(t, router.Enabled())
+	assert.Same(t, uploads, router.Uploads("acme"))
+	assert.Same(t, outputs, router.Outputs("acme"))
+}
+
+func TestRouter_Tenant(t *testing.T) {
+	router := newTestRouter(t, "{tenant}/", NewMemory(), NewMemory())
+
+	tenant, err := router.Tenant("")
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultTenant, tenant)
+
+	_, err = router.Tenant("../other")
+	assert.Error(t, err)
+}
+
+func TestRouted_StoresKeysUnderTenantPrefixes(t *testing.T) {
+	shared := NewMemory()
+	router := newTestRouter(t, "{tenant}/{yyyy}/{mm}/{job_id}/", NewMemory(), shared)
+	acme := router.Outputs("acme")
+
+	for _, key := range []string{"frames_20240315_101500.zip", "hls_20240315_101500/master.m3u8", "logo.png", ".dedup/entries/abc"} {
+		require.NoError(t, acme.Put(key, strings.NewReader(key), ""))
+	}
+
+	physical, err := shared.List("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		".dedup/entries/abc",
+		"acme/2024/03/20240315_101500/frames_20240315_101500.zip",
+		"acme/2024/03/20240315_101500/hls_20240315_101500/master.m3u8",
+		"acme/logo.png",
+	}, keysOf(physical))
+
+	info, err := acme.Stat("hls_20240315_101500/master.m3u8")
+	require.NoError(t, err)
+	assert.Equal(t, "hls_20240315_101500/master.m3u8", info.Key)
+
+	all, err := acme.List("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frames_20240315_101500.zip", "hls_20240315_101500/master.m3u8", "logo.png"}, keysOf(all))
+
+	hls, err := acme.List("hls_20240315_101500/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hls_20240315_101500/master.m3u8"}, keysOf(hls))
+
+	index, err := acme.List(".dedup/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".dedup/entries/abc"}, keysOf(index))
+
+	require.NoError(t, acme.Delete("frames_20240315_101500.zip"))
+	_, err = shared.Stat("acme/2024/03/20240315_101500/frames_20240315_101500.zip")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRouted_IsolatesTenants(t *testing.T) {
+	router := newTestRouter(t, "{tenant}/{job_id}/", NewMemory(), NewMemory())
+	acme, globex := router.Outputs("acme"), router.Outputs("globex")
+	require.NoError(t, acme.Put("frames_20240315_101500.zip", strings.NewReader("acme"), ""))
+
+	_, err := globex.Stat("frames_20240315_101500.zip")
+	assert.ErrorIs(t, err, ErrNotFound)
+	objects, err := globex.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestRouted_ListPageKeepsCursorAcrossJobs(t *testing.T) {
+	router := newTestRouter(t, "{tenant}/{job_id}/", NewMemory(), NewMemory())
+	acme := router.Outputs("acme")
+	keys := []string{"frames_20240101_120000.zip", "proxy_20240101_120000.mp4", "frames_20240102_120000.zip", "frames_20240103_120000.zip"}
+	for _, key := range keys {
+		require.NoError(t, acme.Put(key, strings.NewReader(key), ""))
+	}
+
+	var listed []string
+	cursor := ""
+	for {
+		page, more, err := acme.ListPage("frames_", cursor, 1)
+		require.NoError(t, err)
+		listed = append(listed, keysOf(page)...)
+		if !more || len(page) == 0 {
+			break
+		}
+		cursor = page[len(page)-1].Key
+	}
+	assert.Equal(t, []string{"frames_20240101_120000.zip", "frames_20240102_120000.zip", "frames_20240103_120000.zip"}, listed)
+}
+
+func TestRouted_LocalPathAndDirectUploads(t *testing.T) {
+	root := t.TempDir()
+	router := newTestRouter(t, "{tenant}/", NewFilesystem(root), NewMemory())
+	uploads := router.Uploads("acme")
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), ""))
+
+	path, ok := LocalPath(uploads, "20240101_120000_video.mp4")
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(path, "acme/20240101_120000_video.mp4"), path)
+
+	_, ok = uploads.(DirectUploader)
+	assert.False(t, ok, "the filesystem takes no direct uploads")
+}
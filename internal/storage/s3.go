@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 stores objects in a single bucket through the shared S3Service.
+type S3 struct {
+	service *config.S3Service
+	bucket  string
+}
+
+func NewS3(service *config.S3Service, bucket string) *S3 {
+	return &S3{service: service, bucket: bucket}
+}
+
+func (s *S3) Put(key string, body io.Reader, contentType string) error {
+	return s.service.UploadFileWithContentType(s.bucket, key, body, contentType)
+}
+
+// PutWithMetadata writes metadata as x-amz-meta-* headers, URL-encoded so any value is
+// a valid header, and the TaggedMetadata entries as object tags. A MetaChecksum entry is
+// also sent as x-amz-checksum-sha256, so S3 rejects a body that does not match it.
+func (s *S3) PutWithMetadata(key string, body io.Reader, contentType string, metadata map[string]string) error {
+	encoded := make(map[string]string, len(metadata))
+	for name, value := range metadata {
+		encoded[name] = url.QueryEscape(value)
+	}
+
+	tags := make(map[string]string)
+	for _, name := range TaggedMetadata {
+		if value, ok := metadata[name]; ok && value != "" {
+			tags[name] = tagValue(value)
+		}
+	}
+
+	var sum string
+	if digest := metadata[MetaChecksum]; digest != "" {
+		var err error
+		if sum, err = checksum.Base64(digest); err != nil {
+			return err
+		}
+	}
+
+	return s.service.UploadFileWithMetadata(s.bucket, key, body, contentType, encoded, tags, sum)
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	reader, err := s.service.DownloadFile(s.bucket, key)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	return reader, err
+}
+
+func (s *S3) Stat(key string) (*ObjectInfo, error) {
+	head, err := s.service.GetFileInfo(s.bucket, key)
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(head.ContentLength),
+		LastModified: aws.TimeValue(head.LastModified),
+		ContentType:  aws.StringValue(head.ContentType),
+		Metadata:     decodeMetadata(head.Metadata),
+	}, nil
+}
+
+// decodeMetadata reverses the encoding of PutWithMetadata. The SDK canonicalizes header
+// names, so keys are lowercased back; values written by other tools are kept as-is.
+func decodeMetadata(headers map[string]*string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string, len(headers))
+	for name, value := range headers {
+		decoded, err := url.QueryUnescape(aws.StringValue(value))
+		if err != nil {
+			decoded = aws.StringValue(value)
+		}
+		metadata[strings.ToLower(name)] = decoded
+	}
+	return metadata
+}
+
+func (s *S3) List(prefix string) ([]ObjectInfo, error) {
+	objects, err := s.service.ListObjects(s.bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return objectInfos(objects), nil
+}
+
+// ListPage maps directly onto one ListObjectsV2 call using StartAfter, so a page costs
+// a single request and carries size and timestamps without per-object HEADs.
+func (s *S3) ListPage(prefix, startAfter string, limit int) ([]ObjectInfo, bool, error) {
+	objects, more, err := s.service.ListObjectsPage(s.bucket, prefix, startAfter, int64(limit))
+	if err != nil {
+		return nil, false, err
+	}
+	return objectInfos(objects), more, nil
+}
+
+func objectInfos(objects []*s3.Object) []ObjectInfo {
+	infos := make([]ObjectInfo, 0, len(objects))
+	for _, obj := range objects {
+		infos = append(infos, ObjectInfo{
+			Key:          aws.StringValue(obj.Key),
+			Size:         aws.Int64Value(obj.Size),
+			LastModified: aws.TimeValue(obj.LastModified),
+		})
+	}
+	return infos
+}
+
+func (s *S3) Delete(key string) error {
+	return s.service.DeleteFile(s.bucket, key)
+}
+
+func (s *S3) DownloadURL(key string, expiration time.Duration) (string, error) {
+	return s.service.GeneratePresignedURL(s.bucket, key, expiration)
+}
+
+func (s *S3) PresignPut(key, contentType string, expiration time.Duration) (*PresignedRequest, error) {
+	url, headers, err := s.service.GeneratePresignedPutURL(s.bucket, key, contentType, expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]string, len(headers))
+	for name := range headers {
+		flat[name] = headers.Get(name)
+	}
+	return &PresignedRequest{Method: http.MethodPut, URL: url, Headers: flat}, nil
+}
+
+// CreateMultipartUpload starts the upload and presigns every part; the bucket's
+// encryption is fixed when the upload is created, so parts need no extra headers.
+func (s *S3) CreateMultipartUpload(key, contentType string, partCount int, expiration time.Duration) (*MultipartUpload, error) {
+	uploadID, err := s.service.CreateMultipartUpload(s.bucket, key, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &MultipartUpload{UploadID: uploadID, Parts: make([]PresignedRequest, 0, partCount)}
+	for part := 1; part <= partCount; part++ {
+		url, err := s.service.GeneratePresignedPartURL(s.bucket, key, uploadID, int64(part), expiration)
+		if err != nil {
+			if abortErr := s.service.AbortMultipartUpload(s.bucket, key, uploadID); abortErr != nil {
+				log.Printf("Warning: Failed to abort multipart upload %s: %v", uploadID, abortErr)
+			}
+			return nil, err
+		}
+		upload.Parts = append(upload.Parts, PresignedRequest{Method: http.MethodPut, URL: url, PartNumber: part})
+	}
+
+	return upload, nil
+}
+
+func (s *S3) UploadPart(key, uploadID string, partNumber int, body io.ReadSeeker) (string, error) {
+	return s.service.UploadPart(s.bucket, key, uploadID, int64(partNumber), body)
+}
+
+func (s *S3) CompleteMultipartUpload(key, uploadID string, parts []CompletedPart) error {
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, &s3.CompletedPart{
+			PartNumber: aws.Int64(int64(part.PartNumber)),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+	return s.service.CompleteMultipartUpload(s.bucket, key, uploadID, completed)
+}
+
+func (s *S3) AbortMultipartUpload(key, uploadID string) error {
+	return s.service.AbortMultipartUpload(s.bucket, key, uploadID)
+}
+
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case s3.ErrCodeNoSuchKey, "NotFound":
+		return true
+	}
+	return false
+}
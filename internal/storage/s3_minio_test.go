@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMinIOStore runs against the MinIO container started by `make test-minio`. The test
+// is skipped unless MINIO_ENDPOINT points at one.
+func newMinIOStore(t *testing.T) (*S3, *s3.S3) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT not set; run `make test-minio` to start a MinIO container")
+	}
+
+	awsConfig := &config.AWSConfig{
+		Region:           "us-east-1",
+		AccessKeyID:      config.GetEnv("MINIO_ROOT_USER", "minioadmin"),
+		SecretAccessKey:  config.GetEnv("MINIO_ROOT_PASSWORD", "minioadmin"),
+		EndpointURL:      endpoint,
+		Provider:         config.ProviderMinIO,
+		PresignedTimeout: time.Minute,
+	}
+	require.NoError(t, awsConfig.CheckHealth())
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String(awsConfig.Region),
+		Endpoint:         aws.String(endpoint),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials(awsConfig.AccessKeyID, awsConfig.SecretAccessKey, ""),
+	})
+	require.NoError(t, err)
+	admin := s3.New(sess)
+
+	bucket := fmt.Sprintf("videogrinder-test-%d", time.Now().UnixNano())
+	_, err = admin.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		objects, err := admin.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+		if err == nil {
+			for _, object := range objects.Contents {
+				_, _ = admin.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: object.Key})
+			}
+		}
+		_, _ = admin.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	})
+
+	service, err := config.NewS3Service(awsConfig)
+	require.NoError(t, err)
+	return NewS3(service, bucket), admin
+}
+
+func TestS3_MinIO_ObjectLifecycle(t *testing.T) {
+	store, admin := newMinIOStore(t)
+	metadata := map[string]string{
+		MetaOriginalName: "férias (1).mp4",
+		MetaFrameCount:   "42",
+		MetaOptions:      `{"proxy":true}`,
+	}
+
+	require.NoError(t, store.PutWithMetadata("frames_1.zip", strings.NewReader("zip"), "application/zip", metadata))
+	require.NoError(t, store.Put("hls_1/master.m3u8", strings.NewReader("#EXTM3U"), ""))
+
+	info, err := store.Stat("frames_1.zip")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Size)
+	assert.Equal(t, "application/zip", info.ContentType)
+	assert.Equal(t, metadata, info.Metadata)
+
+	tagging, err := admin.GetObjectTagging(&s3.GetObjectTaggingInput{Bucket: aws.String(store.bucket), Key: aws.String("frames_1.zip")})
+	require.NoError(t, err)
+	tags := make(map[string]string)
+	for _, tag := range tagging.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	assert.Equal(t, map[string]string{MetaOriginalName: "férias _1_.mp4", MetaFrameCount: "42"}, tags)
+
+	page, more, err := store.ListPage("", "", 1)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, "frames_1.zip", page[0].Key)
+
+	downloadURL, err := store.DownloadURL("frames_1.zip", time.Minute)
+	require.NoError(t, err)
+	resp, err := http.Get(downloadURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "zip", string(body))
+
+	require.NoError(t, store.Delete("frames_1.zip"))
+	_, err = store.Stat("frames_1.zip")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get("frames_1.zip")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestS3_MinIO_PresignedUpload(t *testing.T) {
+	store, _ := newMinIOStore(t)
+
+	presigned, err := store.PresignPut("20240101_120000_video.mp4", "video/mp4", time.Minute)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(presigned.Method, presigned.URL, strings.NewReader("video"))
+	require.NoError(t, err)
+	for name, value := range presigned.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "video/mp4")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info, err := store.Stat("20240101_120000_video.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+}
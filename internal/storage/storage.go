@@ -0,0 +1,103 @@
+// Package storage abstracts where uploads and outputs live so handlers and services
+// work the same against the local filesystem, S3 or memory.
+package storage
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist in the store.
+var ErrNotFound = errors.New("object not found")
+
+// ErrURLNotSupported is returned by DownloadURL when the backend cannot hand out direct
+// links and the content has to be served through the API instead.
+var ErrURLNotSupported = errors.New("download URLs not supported by this storage")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ContentType  string
+	// Metadata is the user metadata stored with the object; only Stat fills it.
+	Metadata map[string]string
+}
+
+// Storage is a flat key/value object store. Keys use forward slashes regardless of backend.
+type Storage interface {
+	Put(key string, body io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Stat(key string) (*ObjectInfo, error)
+	List(prefix string) ([]ObjectInfo, error)
+	ListPage(prefix, startAfter string, limit int) (objects []ObjectInfo, more bool, err error)
+	Delete(key string) error
+	DownloadURL(key string, expiration time.Duration) (string, error)
+}
+
+// DirectUploader is implemented by backends that accept uploads straight from clients,
+// so large files never pass through the services.
+type DirectUploader interface {
+	PresignPut(key, contentType string, expiration time.Duration) (*PresignedRequest, error)
+	CreateMultipartUpload(key, contentType string, partCount int, expiration time.Duration) (*MultipartUpload, error)
+	CompleteMultipartUpload(key, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(key, uploadID string) error
+}
+
+// PartUploader is a DirectUploader the services can also send parts to themselves, for
+// uploads they receive piece by piece, such as resumable ones. A multipart upload
+// created with no parts to presign is filled this way.
+type PartUploader interface {
+	DirectUploader
+	UploadPart(key, uploadID string, partNumber int, body io.ReadSeeker) (etag string, err error)
+}
+
+// PresignedRequest is a request a client can send without credentials.
+type PresignedRequest struct {
+	Method     string
+	URL        string
+	Headers    map[string]string
+	PartNumber int
+}
+
+// MultipartUpload lists one presigned request per part of a started multipart upload.
+type MultipartUpload struct {
+	UploadID string
+	Parts    []PresignedRequest
+}
+
+// CompletedPart identifies an uploaded part by the ETag the backend returned for it.
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// pageOf returns the slice of a key-sorted listing that follows startAfter, capped at limit.
+func pageOf(objects []ObjectInfo, startAfter string, limit int) ([]ObjectInfo, bool) {
+	start := sort.Search(len(objects), func(i int) bool { return objects[i].Key > startAfter })
+	objects = objects[start:]
+	if limit <= 0 || len(objects) <= limit {
+		return objects, false
+	}
+	return objects[:limit], true
+}
+
+type localPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// LocalPath returns the on-disk path of key when the backend keeps objects as local
+// files, letting callers such as FFmpeg read them without a copy.
+func LocalPath(s Storage, key string) (string, bool) {
+	lp, ok := s.(localPather)
+	if !ok {
+		return "", false
+	}
+	path, err := lp.LocalPath(key)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func backends(t *testing.T) map[string]Storage {
+	return map[string]Storage{
+		"filesystem": NewFilesystem(t.TempDir()),
+		"memory":     NewMemory(),
+		"encrypted":  NewEncrypted(NewFilesystem(t.TempDir()), newTestKeyring(t, 1)),
+	}
+}
+
+func TestStorage_PutGetStatDelete(t *testing.T) {
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Put("hls_1/master.m3u8", strings.NewReader("#EXTM3U"), "application/vnd.apple.mpegurl"))
+
+			reader, err := store.Get("hls_1/master.m3u8")
+			require.NoError(t, err)
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, "#EXTM3U", string(data))
+
+			info, err := store.Stat("hls_1/master.m3u8")
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), info.Size)
+			assert.False(t, info.LastModified.IsZero())
+
+			require.NoError(t, store.Delete("hls_1/master.m3u8"))
+
+			_, err = store.Stat("hls_1/master.m3u8")
+			assert.True(t, errors.Is(err, ErrNotFound))
+			_, err = store.Get("hls_1/master.m3u8")
+			assert.True(t, errors.Is(err, ErrNotFound))
+			assert.True(t, errors.Is(store.Delete("hls_1/master.m3u8"), ErrNotFound))
+		})
+	}
+}
+
+func TestStorage_ListFiltersByPrefixAndSorts(t *testing.T) {
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"frames_2.zip", "frames_1.zip", "clip_1_01.mp4", "hls_1/360p.m3u8"} {
+				require.NoError(t, store.Put(key, strings.NewReader(key), ""))
+			}
+
+			all, err := store.List("")
+			require.NoError(t, err)
+			assert.Len(t, all, 4)
+
+			frames, err := store.List("frames_")
+			require.NoError(t, err)
+			require.Len(t, frames, 2)
+			assert.Equal(t, "frames_1.zip", frames[0].Key)
+			assert.Equal(t, "frames_2.zip", frames[1].Key)
+
+			nested, err := store.List("hls_1/")
+			require.NoError(t, err)
+			require.Len(t, nested, 1)
+			assert.Equal(t, "hls_1/360p.m3u8", nested[0].Key)
+		})
+	}
+}
+
+func TestFilesystem_RejectsKeysOutsideRoot(t *testing.T) {
+	store := NewFilesystem(t.TempDir())
+
+	assert.Error(t, store.Put("../escape.zip", strings.NewReader("x"), ""))
+	assert.Error(t, store.Put("/etc/passwd", strings.NewReader("x"), ""))
+	_, err := store.Get("../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestFilesystem_ListSkipsHiddenAndPartialFiles(t *testing.T) {
+	root := t.TempDir()
+	store := NewFilesystem(root)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".health_check_test"), []byte("x"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "frames_1.zip.partial"), []byte("x"), 0600))
+	require.NoError(t, store.Put("frames_1.zip", strings.NewReader("zip"), "application/zip"))
+
+	objects, err := store.List("")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "frames_1.zip", objects[0].Key)
+}
+
+func TestFilesystem_ConcurrentPutsOfOneKeyKeepAWholeObject(t *testing.T) {
+	root := t.TempDir()
+	store := NewFilesystem(root)
+	bodies := []string{strings.Repeat("a", 1<<20), strings.Repeat("b", 1<<20)}
+
+	var wg sync.WaitGroup
+	for _, body := range bodies {
+		wg.Add(1)
+		go func(body string) {
+			defer wg.Done()
+			assert.NoError(t, store.Put("upload_1.mp4", strings.NewReader(body), ""))
+		}(body)
+	}
+	wg.Wait()
+
+	data := string(readObject(t, store, "upload_1.mp4"))
+	assert.Contains(t, bodies, data)
+	partials, err := filepath.Glob(filepath.Join(root, "*"+partialSuffix))
+	require.NoError(t, err)
+	assert.Empty(t, partials)
+}
+
+func TestFilesystem_ListMissingRootIsEmpty(t *testing.T) {
+	store := NewFilesystem(filepath.Join(t.TempDir(), "missing"))
+
+	objects, err := store.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestDownloadURL(t *testing.T) {
+	_, err := NewFilesystem(t.TempDir()).DownloadURL("frames_1.zip", 0)
+	assert.True(t, errors.Is(err, ErrURLNotSupported))
+
+	memory := NewMemory()
+	_, err = memory.DownloadURL("frames_1.zip", 0)
+	assert.True(t, errors.Is(err, ErrURLNotSupported))
+
+	memory.URLPrefix = "https://cdn.example.com/"
+	url, err := memory.DownloadURL("frames_1.zip", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/frames_1.zip", url)
+}
+
+func TestLocalPath(t *testing.T) {
+	root := t.TempDir()
+
+	path, ok := LocalPath(NewFilesystem(root), "uploads/video.mp4")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(root, "uploads", "video.mp4"), path)
+
+	_, ok = LocalPath(NewMemory(), "video.mp4")
+	assert.False(t, ok)
+
+	_, ok = LocalPath(NewFilesystem(root), "../video.mp4")
+	assert.False(t, ok)
+}
+
+func TestStorage_ListPageFollowsStartAfter(t *testing.T) {
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a.zip", "b.zip", "c.zip"} {
+				require.NoError(t, store.Put(key, strings.NewReader(key), ""))
+			}
+
+			page, more, err := store.ListPage("", "", 2)
+			require.NoError(t, err)
+			assert.True(t, more)
+			require.Len(t, page, 2)
+			assert.Equal(t, "b.zip", page[1].Key)
+
+			page, more, err = store.ListPage("", "b.zip", 2)
+			require.NoError(t, err)
+			assert.False(t, more)
+			require.Len(t, page, 1)
+			assert.Equal(t, "c.zip", page[0].Key)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package trash keeps deleted outputs around for a while so they can be restored. The
+// API moves a video's outputs into the trash instead of deleting them, and the
+// processor's sweeper purges what has been there longer than the trash retention.
+package trash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"video-processor/internal/storage"
+)
+
+// Prefix holds the trash inside the outputs storage, away from the archive listing. Like
+// the other indexes it lives in the shared store, so one sweeper purges every tenant's.
+const Prefix = ".trash/"
+
+var (
+	// ErrNotFound is returned when a video is not in the trash.
+	ErrNotFound = errors.New("video not found in trash")
+	// ErrConflict is returned when restoring would overwrite outputs stored since.
+	ErrConflict = errors.New("outputs already exist")
+)
+
+// Item is a deleted video: the output keys moved into the trash and when they go for good.
+type Item struct {
+	VideoID   string    `json:"video_id"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Keys      []string  `json:"keys"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func itemsPrefix(tenant string) string {
+	if tenant != "" {
+		return Prefix + "items/" + tenant + "/"
+	}
+	return Prefix + "items/"
+}
+
+func itemKey(tenant, videoID string) string {
+	return itemsPrefix(tenant) + videoID + ".json"
+}
+
+// objectKey names the trashed copy of an output. Outputs of all tenants share the
+// trash, so the tenant is part of the name.
+func objectKey(tenant, key string) string {
+	if tenant != "" {
+		return Prefix + "objects/" + tenant + "/" + key
+	}
+	return Prefix + "objects/" + key
+}
+
+// Bin is the trash of one tenant. Its store is the tenant's view of the outputs, which
+// keeps the trash itself in the shared store.
+type Bin struct {
+	store  storage.Storage
+	tenant string
+}
+
+func NewBin(store storage.Storage, tenant string) *Bin {
+	return &Bin{store: store, tenant: tenant}
+}
+
+// Discard moves outputs of videoID into the trash until expiresAt. Outputs discarded
+// separately, such as a proxy and later its archive, join the same item. The item is
+// recorded before the outputs are removed, so a failure never loses one.
+func (b *Bin) Discard(videoID string, keys []string, now, expiresAt time.Time) (*Item, error) {
+	item, err := b.Get(videoID)
+	if errors.Is(err, ErrNotFound) {
+		item = &Item{VideoID: videoID, Tenant: b.tenant}
+	} else if err != nil {
+		return nil, err
+	}
+	item.DeletedAt = now
+	item.ExpiresAt = expiresAt
+
+	trashed := map[string]bool{}
+	for _, key := range item.Keys {
+		trashed[key] = true
+	}
+	for _, key := range keys {
+		size, err := move(b.store, key, objectKey(b.tenant, key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to move %s to trash: %w", key, err)
+		}
+		if !trashed[key] {
+			trashed[key] = true
+			item.Keys = append(item.Keys, key)
+			item.Size += size
+		}
+	}
+	sort.Strings(item.Keys)
+
+	if err := b.put(item); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := deleteIfExists(b.store, key); err != nil {
+			log.Printf("Warning: Failed to delete trashed output %s: %v", key, err)
+		}
+	}
+	return item, nil
+}
+
+// Get returns the trashed item of videoID.
+func (b *Bin) Get(videoID string) (*Item, error) {
+	return load(b.store, itemKey(b.tenant, videoID))
+}
+
+// List returns the tenant's trashed videos, most recently deleted first.
+func (b *Bin) List() ([]Item, error) {
+	objects, err := b.store.List(itemsPrefix(b.tenant))
+	if err != nil {
+		return nil, err
+	}
+
+	items := []Item{}
+	for _, object := range objects {
+		item, err := load(b.store, object.Key)
+		if err != nil {
+			log.Printf("Warning: Failed to read trash item %s: %v", object.Key, err)
+			continue
+		}
+		// Without a tenant the prefix also covers every tenant's items.
+		if item.Tenant != b.tenant {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Restore moves the outputs of videoID back where they were and drops the item. It
+// returns ErrConflict, restoring nothing, when any of them exists again.
+func (b *Bin) Restore(videoID string) (*Item, error) {
+	item, err := b.Get(videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range item.Keys {
+		if _, err := b.store.Stat(key); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrConflict, key)
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	for _, key := range item.Keys {
+		if _, err := move(b.store, objectKey(b.tenant, key), key); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", key, err)
+		}
+	}
+	if err := deleteIfExists(b.store, itemKey(b.tenant, videoID)); err != nil {
+		return nil, err
+	}
+	for _, key := range item.Keys {
+		if err := deleteIfExists(b.store, objectKey(b.tenant, key)); err != nil {
+			log.Printf("Warning: Failed to delete restored trash copy %s: %v", key, err)
+		}
+	}
+	return item, nil
+}
+
+// Purge permanently deletes the items of every tenant that expired by now from the
+// shared outputs store and returns how many were purged. Items that fail are logged and
+// retried on the next purge.
+func Purge(store storage.Storage, now time.Time) (int, error) {
+	objects, err := store.List(itemsPrefix(""))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	purged := 0
+	for _, object := range objects {
+		item, err := load(store, object.Key)
+		if err != nil {
+			log.Printf("Warning: Failed to read trash item %s: %v", object.Key, err)
+			continue
+		}
+		if now.Before(item.ExpiresAt) {
+			continue
+		}
+		if err := purge(store, object.Key, item); err != nil {
+			log.Printf("Warning: Failed to purge %s from trash: %v", item.VideoID, err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		log.Printf("Purged %d videos from trash", purged)
+	}
+	return purged, nil
+}
+
+func purge(store storage.Storage, key string, item *Item) error {
+	for _, output := range item.Keys {
+		if err := deleteIfExists(store, objectKey(item.Tenant, output)); err != nil {
+			return err
+		}
+	}
+	return deleteIfExists(store, key)
+}
+
+func (b *Bin) put(item *Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.store.Put(itemKey(b.tenant, item.VideoID), bytes.NewReader(data), "application/json")
+}
+
+func load(store storage.Storage, key string) (*Item, error) {
+	reader, err := store.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close trash item %s: %v", key, err)
+		}
+	}()
+
+	var item Item
+	if err := json.NewDecoder(reader).Decode(&item); err != nil {
+		return nil, fmt.Errorf("failed to decode trash item %s: %w", key, err)
+	}
+	return &item, nil
+}
+
+// move copies an object with its metadata to another key of the same store and returns
+// its size. The original is left for the caller to delete.
+func move(store storage.Storage, from, to string) (int64, error) {
+	info, err := store.Stat(from)
+	if err != nil {
+		return 0, err
+	}
+	reader, err := store.Get(from)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close reader for %s: %v", from, err)
+		}
+	}()
+
+	if err := storage.PutWithMetadata(store, to, reader, info.ContentType, info.Metadata); err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+func deleteIfExists(store storage.Storage, key string) error {
+	if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return nil
+}
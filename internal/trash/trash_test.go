@@ -0,0 +1,148 @@
+package trash
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/config"
+	"video-processor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, store storage.Storage, key string) string {
+	reader, err := store.Get(key)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBin_DiscardAndRestore(t *testing.T) {
+	store := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, storage.PutWithMetadata(store, "frames_20240101_120000.zip", strings.NewReader("zip"), "application/zip",
+		map[string]string{storage.MetaOwner: "editor"}))
+	require.NoError(t, store.Put("hls_20240101_120000/master.m3u8", strings.NewReader("#EXTM3U"), ""))
+	bin := NewBin(store, "")
+	now := time.Now()
+
+	item, err := bin.Discard("20240101_120000", []string{"frames_20240101_120000.zip", "hls_20240101_120000/master.m3u8"}, now, now.Add(time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), item.Size)
+	_, err = store.Stat("frames_20240101_120000.zip")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	items, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, []string{"frames_20240101_120000.zip", "hls_20240101_120000/master.m3u8"}, items[0].Keys)
+
+	_, err = bin.Restore("20240101_120000")
+
+	require.NoError(t, err)
+	assert.Equal(t, "zip", readAll(t, store, "frames_20240101_120000.zip"))
+	assert.Equal(t, "#EXTM3U", readAll(t, store, "hls_20240101_120000/master.m3u8"))
+	info, err := store.Stat("frames_20240101_120000.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "editor", info.Metadata[storage.MetaOwner], "metadata survives the trash")
+
+	items, err = bin.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	objects, err := store.List(Prefix)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestBin_DiscardJoinsExistingItem(t *testing.T) {
+	store := storage.NewMemory()
+	require.NoError(t, store.Put("proxy_20240101_120000.mp4", strings.NewReader("proxy"), ""))
+	require.NoError(t, store.Put("frames_20240101_120000.zip", strings.NewReader("zip"), ""))
+	bin := NewBin(store, "")
+	now := time.Now()
+
+	_, err := bin.Discard("20240101_120000", []string{"proxy_20240101_120000.mp4"}, now, now.Add(time.Hour))
+	require.NoError(t, err)
+	item, err := bin.Discard("20240101_120000", []string{"frames_20240101_120000.zip"}, now, now.Add(2*time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"frames_20240101_120000.zip", "proxy_20240101_120000.mp4"}, item.Keys)
+	assert.Equal(t, int64(8), item.Size)
+	assert.Equal(t, now.Add(2*time.Hour), item.ExpiresAt)
+}
+
+func TestBin_RestoreRefusesToOverwrite(t *testing.T) {
+	store := storage.NewMemory()
+	require.NoError(t, store.Put("frames_20240101_120000.zip", strings.NewReader("old"), ""))
+	bin := NewBin(store, "")
+	now := time.Now()
+	_, err := bin.Discard("20240101_120000", []string{"frames_20240101_120000.zip"}, now, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, store.Put("frames_20240101_120000.zip", strings.NewReader("new"), ""))
+
+	_, err = bin.Restore("20240101_120000")
+
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "new", readAll(t, store, "frames_20240101_120000.zip"))
+	_, err = bin.Get("20240101_120000")
+	assert.NoError(t, err, "the item stays in the trash")
+
+	_, err = bin.Restore("20240102_120000")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBin_TenantsHaveSeparateTrash(t *testing.T) {
+	shared := storage.NewMemory()
+	router, err := storage.NewRouter(&config.RoutingConfig{KeyPrefix: "{tenant}/", DefaultTenant: "default"}, nil, nil, storage.NewMemory(), shared)
+	require.NoError(t, err)
+	acme, globex := router.Outputs("acme"), router.Outputs("globex")
+	require.NoError(t, acme.Put("frames_20240101_120000.zip", strings.NewReader("acme"), ""))
+	require.NoError(t, globex.Put("frames_20240101_120000.zip", strings.NewReader("globex"), ""))
+	now := time.Now()
+
+	for tenant, store := range map[string]storage.Storage{"acme": acme, "globex": globex} {
+		_, err := NewBin(store, tenant).Discard("20240101_120000", []string{"frames_20240101_120000.zip"}, now, now.Add(time.Hour))
+		require.NoError(t, err)
+	}
+
+	items, err := NewBin(acme, "acme").List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "acme", items[0].Tenant)
+
+	_, err = NewBin(globex, "globex").Restore("20240101_120000")
+	require.NoError(t, err)
+	assert.Equal(t, "globex", readAll(t, globex, "frames_20240101_120000.zip"))
+	_, err = acme.Stat("frames_20240101_120000.zip")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestPurge_DeletesExpiredItems(t *testing.T) {
+	store := storage.NewMemory()
+	require.NoError(t, store.Put("frames_20240101_120000.zip", strings.NewReader("old"), ""))
+	require.NoError(t, store.Put("frames_20240102_120000.zip", strings.NewReader("recent"), ""))
+	bin := NewBin(store, "")
+	now := time.Now()
+	_, err := bin.Discard("20240101_120000", []string{"frames_20240101_120000.zip"}, now, now.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = bin.Discard("20240102_120000", []string{"frames_20240102_120000.zip"}, now, now.Add(48*time.Hour))
+	require.NoError(t, err)
+
+	purged, err := Purge(store, now.Add(2*time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	items, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "20240102_120000", items[0].VideoID)
+	objects, err := store.List(Prefix + "objects/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, objectKey("", "frames_20240102_120000.zip"), objects[0].Key)
+}
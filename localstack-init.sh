@@ -9,7 +9,7 @@ echo "🚀 Initializing LocalStack resources..."
 
 # Wait for LocalStack to be ready
 echo "⏳ Waiting for LocalStack to be ready..."
-until curl -s http://127.0.0.1:4566/health > /dev/null 2>&1; do
+until curl -s http://127.0.0.1:4566/_localstack/health > /dev/null 2>&1; do
     sleep 2
 done
 
@@ -25,12 +25,45 @@ echo "📦 Creating S3 buckets..."
 aws s3 mb s3://videogrinder-uploads --endpoint-url=http://127.0.0.1:4566 2>/dev/null || echo "  Bucket videogrinder-uploads already exists"
 aws s3 mb s3://videogrinder-outputs --endpoint-url=http://127.0.0.1:4566 2>/dev/null || echo "  Bucket videogrinder-outputs already exists"
 
+echo "🌐 Allowing direct browser uploads to videogrinder-uploads..."
+aws s3api put-bucket-cors \
+    --bucket videogrinder-uploads \
+    --cors-configuration '{
+        "CORSRules": [
+            {
+                "AllowedOrigins": ["*"],
+                "AllowedMethods": ["PUT"],
+                "AllowedHeaders": ["*"],
+                "ExposeHeaders": ["ETag"],
+                "MaxAgeSeconds": 3000
+            }
+        ]
+    }' \
+    --endpoint-url=http://127.0.0.1:4566
+
+echo "🔐 Creating KMS key for S3 encryption..."
+if ! aws kms describe-key --key-id alias/videogrinder-s3 --endpoint-url=http://127.0.0.1:4566 --no-cli-pager > /dev/null 2>&1; then
+    KMS_KEY_ID=$(aws kms create-key \
+        --description "VideoGrinder S3 objects" \
+        --endpoint-url=http://127.0.0.1:4566 \
+        --query KeyMetadata.KeyId \
+        --output text)
+    aws kms create-alias \
+        --alias-name alias/videogrinder-s3 \
+        --target-key-id "$KMS_KEY_ID" \
+        --endpoint-url=http://127.0.0.1:4566
+else
+    echo "  Key alias/videogrinder-s3 already exists"
+fi
+
 echo "🗃️ Creating DynamoDB tables..."
 aws dynamodb create-table \
     --table-name video-jobs \
     --attribute-definitions \
         AttributeName=id,AttributeType=S \
         AttributeName=status,AttributeType=S \
+        AttributeName=tenant_status,AttributeType=S \
+        AttributeName=position,AttributeType=S \
     --key-schema \
         AttributeName=id,KeyType=HASH \
     --global-secondary-indexes \
@@ -50,6 +83,26 @@ aws dynamodb create-table \
                     "ReadCapacityUnits": 5,
                     "WriteCapacityUnits": 5
                 }
+            },
+            {
+                "IndexName": "tenant-status-index",
+                "KeySchema": [
+                    {
+                        "AttributeName": "tenant_status",
+                        "KeyType": "HASH"
+                    },
+                    {
+                        "AttributeName": "position",
+                        "KeyType": "RANGE"
+                    }
+                ],
+                "Projection": {
+                    "ProjectionType": "ALL"
+                },
+                "ProvisionedThroughput": {
+                    "ReadCapacityUnits": 5,
+                    "WriteCapacityUnits": 5
+                }
             }
         ]' \
     --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
@@ -57,6 +110,49 @@ aws dynamodb create-table \
     --no-cli-pager \
     --output json 2>/dev/null || echo "  Table video-jobs already exists"
 
+# Tables created before tenant-status-index existed get it here; jobs stored before it
+# are backfilled with `go run ./cmd/vgctl index-jobs`.
+if ! aws dynamodb describe-table \
+    --table-name video-jobs \
+    --endpoint-url=http://127.0.0.1:4566 \
+    --query 'Table.GlobalSecondaryIndexes[].IndexName' \
+    --output text | grep -qw tenant-status-index; then
+    echo "  Adding tenant-status-index to video-jobs"
+    aws dynamodb update-table \
+        --table-name video-jobs \
+        --attribute-definitions \
+            AttributeName=tenant_status,AttributeType=S \
+            AttributeName=position,AttributeType=S \
+        --global-secondary-index-updates \
+            '[
+                {
+                    "Create": {
+                        "IndexName": "tenant-status-index",
+                        "KeySchema": [
+                            {
+                                "AttributeName": "tenant_status",
+                                "KeyType": "HASH"
+                            },
+                            {
+                                "AttributeName": "position",
+                                "KeyType": "RANGE"
+                            }
+                        ],
+                        "Projection": {
+                            "ProjectionType": "ALL"
+                        },
+                        "ProvisionedThroughput": {
+                            "ReadCapacityUnits": 5,
+                            "WriteCapacityUnits": 5
+                        }
+                    }
+                }
+            ]' \
+        --endpoint-url=http://127.0.0.1:4566 \
+        --no-cli-pager \
+        --output json >/dev/null
+fi
+
 echo "📬 Creating SQS queues..."
 aws sqs create-queue \
     --queue-name video-processing-queue \
@@ -74,9 +170,10 @@ echo "🎉 LocalStack initialization completed!"
 echo ""
 echo "📋 Created resources:"
 echo "   S3 Buckets: videogrinder-uploads, videogrinder-outputs"
+echo "   KMS Key: alias/videogrinder-s3"
 echo "   DynamoDB Table: video-jobs"
 echo "   SQS Queues: video-processing-queue, video-processing-dlq"
 echo ""
 echo "🔗 LocalStack endpoints:"
 echo "   Main: http://127.0.0.1:4566"
-echo "   Health: http://127.0.0.1:4566/health"
+echo "   Health: http://127.0.0.1:4566/_localstack/health"
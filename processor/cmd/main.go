@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"video-processor/internal/queue"
+	"video-processor/internal/reconcile"
+	"video-processor/internal/storage"
 	"video-processor/processor/internal/config"
 	"video-processor/processor/internal/handlers"
 	"video-processor/processor/internal/services"
@@ -16,9 +21,35 @@ func main() {
 	cfg := config.New()
 	cfg.CreateDirectories()
 
+	if encrypted, ok := cfg.Outputs.(*storage.Encrypted); ok && cfg.RewrapOutputs {
+		go rewrapOutputs(encrypted)
+	}
+
 	videoService := services.NewVideoService(cfg)
 	processorHandlers := handlers.NewProcessorHandlers(videoService, cfg)
 
+	if cfg.SweepInterval > 0 {
+		go sweepExpired(videoService, cfg.SweepInterval)
+	}
+
+	if cfg.ReconcileInterval > 0 {
+		reconciler := reconcile.New(cfg.Uploads, cfg.Outputs, cfg.Jobs, cfg.TempDir, reconcile.Options{
+			Grace:  cfg.ReconcileGrace,
+			Delete: cfg.ReconcileDelete,
+		})
+		go reconcileOrphans(reconciler, cfg.ReconcileInterval)
+	}
+
+	if cfg.Queue != nil {
+		worker := queue.NewWorker(cfg.Queue, processorHandlers.ProcessQueuedJob, queue.Options{
+			Visibility:  cfg.SQS.VisibilityTimeout,
+			MaxAttempts: cfg.SQS.MaxReceiveCount,
+			Exhausted:   processorHandlers.FailExhaustedJob,
+		})
+		go worker.Run(context.Background())
+		fmt.Println("📬 Consumindo jobs da fila", cfg.SQS.VideoProcessingQueue)
+	}
+
 	r := gin.Default()
 
 	r.Use(func(c *gin.Context) {
@@ -36,6 +67,8 @@ func main() {
 
 	r.POST("/process", processorHandlers.ProcessVideoUpload)
 	r.POST("/process-s3", processorHandlers.ProcessVideoFromS3)
+	r.GET("/frame", processorHandlers.GetFrame)
+	r.DELETE("/frame", processorHandlers.DeleteFrames)
 	r.GET("/health", processorHandlers.GetProcessorStatus)
 
 	fmt.Println("🔧 Processor service iniciado na porta", cfg.Port)
@@ -43,3 +76,48 @@ func main() {
 
 	log.Fatal(r.Run(":" + cfg.Port))
 }
+
+// rewrapOutputs moves every stored output to the active key after a key rotation.
+func rewrapOutputs(outputs *storage.Encrypted) {
+	rewritten, err := outputs.RewrapAll("")
+	if err != nil {
+		log.Printf("Warning: Failed to rewrap outputs after %d objects: %v", rewritten, err)
+		return
+	}
+	log.Printf("Rewrapped %d outputs with the active encryption key", rewritten)
+}
+
+// sweepExpired expires retained sources and old outputs every interval.
+func sweepExpired(videoService *services.VideoService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := videoService.SweepExpired(time.Now()); err != nil {
+			log.Printf("Warning: Failed to sweep expired objects: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// reconcileOrphans reports, or deletes, orphaned uploads, outputs and temp entries every interval.
+func reconcileOrphans(reconciler *reconcile.Reconciler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := reconciler.Run(time.Now())
+		if err != nil {
+			log.Printf("Warning: Failed to reconcile orphaned objects: %v", err)
+		}
+		if report != nil {
+			for _, orphan := range report.Orphans {
+				log.Printf("Orphaned %s: %s (%d bytes, last modified %s)", orphan.Kind, orphan.Key, orphan.Size, orphan.LastModified.Format(time.RFC3339))
+			}
+			if len(report.Orphans) > 0 {
+				log.Printf("Reconciled %d orphans, deleted %d", len(report.Orphans), report.Deleted)
+			}
+		}
+		<-ticker.C
+	}
+}
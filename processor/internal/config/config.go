@@ -1,12 +1,52 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	baseConfig "video-processor/internal/config"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+	"video-processor/internal/storage"
 )
 
+// defaultSourceCacheMaxSize bounds the local source copies when SOURCE_CACHE_MAX_SIZE
+// is not set.
+const defaultSourceCacheMaxSize int64 = 10 << 30
+
 type ProcessorConfig struct {
-	Port string
+	Port          string
+	RetainSources bool
+	SourceTTL     time.Duration
+	OutputTTL     time.Duration
+	SweepInterval time.Duration
+	// FrameCacheTTL is how long a rendered frame stays cached; zero keeps frames until
+	// their video is deleted or its source expires.
+	FrameCacheTTL time.Duration
+	// SourceCacheMaxSize bounds the local copies of sources kept for frames when
+	// uploads are remote; the least recently used go first. Zero means no limit.
+	SourceCacheMaxSize int64
+	HLSRenditions      []int
+	HLSSegmentSeconds  int
+	OverlayImage       string
+	OverlayText        string
+	OverlayPosition    string
+	OverlayOpacity     float64
+	OverlayFontFile    string
+	RewrapOutputs      bool
+	MaxUploadSize      int64
+	DedupOutputs       bool
+	ReconcileInterval  time.Duration
+	ReconcileGrace     time.Duration
+	ReconcileDelete    bool
+	Uploads            storage.Storage
+	Outputs            storage.Storage
+	Router             *storage.Router
+	Jobs               jobs.JobRepository
+	Queue              queue.Consumer
 	*baseConfig.DirectoryConfig
 	*baseConfig.AWSConfig
 	S3Service *baseConfig.S3Service
@@ -29,11 +69,69 @@ func New() *ProcessorConfig {
 		s3Service = nil
 	}
 
+	dirs := baseConfig.NewDirectoryConfig()
+	uploads, outputs, err := storage.NewBackends(dirs, awsConfig, s3Service)
+	if err != nil {
+		// Never fall back to plaintext outputs when encryption was requested.
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+	router, err := storage.NewRouter(baseConfig.NewRoutingConfig(), awsConfig, s3Service, uploads, outputs)
+	if err != nil {
+		log.Fatalf("Failed to configure storage routing: %v", err)
+	}
+	// The embedded store and queue belong to the API, which locks their database and
+	// dispatches queued jobs to the processor over HTTP.
+	jobStore := GetEnv("JOB_STORE", "")
+	if jobStore == jobs.BackendBolt {
+		jobStore = jobs.BackendMemory
+	}
+	queueBackend := GetEnv("JOB_QUEUE", "")
+	if queueBackend == queue.BackendBolt {
+		queueBackend = queue.BackendHTTP
+	}
+	embedded := baseConfig.NewEmbeddedConfig()
+	jobRepository, err := jobs.Open(jobStore, awsConfig, s3Service != nil, embedded)
+	if err != nil {
+		log.Fatalf("Failed to configure job store: %v", err)
+	}
+	jobQueue, err := queue.Open(queueBackend, awsConfig, embedded)
+	if err != nil {
+		log.Fatalf("Failed to configure job queue: %v", err)
+	}
+	var consumer queue.Consumer
+	if jobQueue != nil {
+		consumer = jobQueue
+	}
+
 	return &ProcessorConfig{
-		Port:            GetEnv("PORT", "8082"),
-		DirectoryConfig: baseConfig.NewDirectoryConfig(),
-		AWSConfig:       awsConfig,
-		S3Service:       s3Service,
+		Port:               GetEnv("PORT", "8082"),
+		RetainSources:      GetEnv("RETAIN_SOURCES", "false") == "true",
+		SourceTTL:          parseRetention(GetEnv("SOURCE_RETENTION", "0")),
+		OutputTTL:          parseRetention(GetEnv("OUTPUT_RETENTION", "0")),
+		SweepInterval:      parseRetention(GetEnv("RETENTION_SWEEP_INTERVAL", "1h")),
+		FrameCacheTTL:      parseRetention(GetEnv("FRAME_CACHE_TTL", "24h")),
+		SourceCacheMaxSize: baseConfig.ParseSize(GetEnv("SOURCE_CACHE_MAX_SIZE", "10GB"), defaultSourceCacheMaxSize),
+		HLSRenditions:      parseIntList(GetEnv("HLS_RENDITIONS", "360,720")),
+		HLSSegmentSeconds:  parseInt(GetEnv("HLS_SEGMENT_SECONDS", "6"), 6),
+		OverlayImage:       GetEnv("OVERLAY_IMAGE", ""),
+		OverlayText:        GetEnv("OVERLAY_TEXT", ""),
+		OverlayPosition:    GetEnv("OVERLAY_POSITION", "bottom-right"),
+		OverlayOpacity:     parseOpacity(GetEnv("OVERLAY_OPACITY", "0.8"), 0.8),
+		OverlayFontFile:    GetEnv("OVERLAY_FONT_FILE", ""),
+		RewrapOutputs:      GetEnv("OUTPUTS_ENCRYPTION_REWRAP", "false") == "true",
+		MaxUploadSize:      baseConfig.ParseSize(GetEnv("MAX_UPLOAD_SIZE", "10GB"), baseConfig.DefaultMaxUploadSize),
+		DedupOutputs:       GetEnv("DEDUP_OUTPUTS", "true") == "true",
+		ReconcileInterval:  parseRetention(GetEnv("RECONCILE_INTERVAL", "0")),
+		ReconcileGrace:     parseRetention(GetEnv("RECONCILE_GRACE", "24h")),
+		ReconcileDelete:    GetEnv("RECONCILE_DELETE", "false") == "true",
+		Uploads:            uploads,
+		Outputs:            outputs,
+		Router:             router,
+		Jobs:               jobRepository,
+		Queue:              consumer,
+		DirectoryConfig:    dirs,
+		AWSConfig:          awsConfig,
+		S3Service:          s3Service,
 	}
 }
 
@@ -44,3 +142,47 @@ func (c *ProcessorConfig) CreateDirectories() {
 func (c *ProcessorConfig) IsS3Enabled() bool {
 	return c.S3Service != nil
 }
+
+func parseInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: Invalid integer %s, using default %d", value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func parseRetention(value string) time.Duration {
+	ttl, err := baseConfig.ParseTTL(value)
+	if err != nil {
+		log.Printf("Warning: Invalid retention %s, keeping objects forever", value)
+		return 0
+	}
+	return ttl
+}
+
+func parseOpacity(value string, fallback float64) float64 {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 || n > 1 {
+		log.Printf("Warning: Invalid opacity %s, using default %.2f", value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func parseIntList(value string) []int {
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSuffix(strings.TrimSpace(part), "p")
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			log.Printf("Warning: Invalid list entry %s ignored", part)
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
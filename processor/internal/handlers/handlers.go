@@ -1,19 +1,29 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"video-processor/internal/checksum"
+	"video-processor/internal/jobs"
+	"video-processor/internal/storage"
 	"video-processor/processor/internal/config"
 	"video-processor/processor/internal/models"
 	"video-processor/processor/internal/services"
+	"video-processor/processor/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,6 +31,10 @@ import (
 const (
 	StatusHealthy   = "healthy"
 	StatusUnhealthy = "unhealthy"
+
+	// multipartOverhead is the room left for boundaries and form fields around the
+	// video when the request body of an upload is limited.
+	multipartOverhead = 1 << 20
 )
 
 type ProcessorHandlers struct {
@@ -36,8 +50,26 @@ func NewProcessorHandlers(videoService *services.VideoService, cfg *config.Proce
 }
 
 func (ph *ProcessorHandlers) ProcessVideoUpload(c *gin.Context) {
+	ph, ok := ph.forTenant(c)
+	if !ok {
+		return
+	}
+
+	if ph.config.MaxUploadSize > 0 {
+		if ph.exceedsUploadLimit(c.Request.ContentLength - multipartOverhead) {
+			ph.respondUploadTooLarge(c)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ph.config.MaxUploadSize+multipartOverhead)
+	}
+
 	file, header, err := c.Request.FormFile("video")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ph.respondUploadTooLarge(c)
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
 			Message: "Erro ao receber arquivo: " + err.Error(),
@@ -50,6 +82,11 @@ func (ph *ProcessorHandlers) ProcessVideoUpload(c *gin.Context) {
 		}
 	}()
 
+	if ph.exceedsUploadLimit(header.Size) {
+		ph.respondUploadTooLarge(c)
+		return
+	}
+
 	if !IsValidVideoFile(header.Filename) {
 		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
@@ -58,11 +95,26 @@ func (ph *ProcessorHandlers) ProcessVideoUpload(c *gin.Context) {
 		return
 	}
 
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("%s_%s", timestamp, filepath.Base(header.Filename))
-	videoPath := filepath.Join(ph.config.UploadsDir, filename)
+	opts, err := parseProcessingOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ProcessingResult{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
 
-	out, err := os.Create(filepath.Clean(videoPath))
+	declared, ok := declaredChecksum(c)
+	if !ok {
+		respondInvalidChecksum(c)
+		return
+	}
+
+	videoID := storage.NewJobID(time.Now())
+	filename := fmt.Sprintf("%s_%s", videoID, filepath.Base(header.Filename))
+	videoPath := filepath.Join(ph.config.TempDir, filename)
+
+	sourceHash, err := saveUpload(file, videoPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
 			Success: false,
@@ -71,24 +123,35 @@ func (ph *ProcessorHandlers) ProcessVideoUpload(c *gin.Context) {
 		return
 	}
 	defer func() {
-		if err := out.Close(); err != nil {
-			log.Printf("Warning: Failed to close output file: %v", err)
+		if err := os.Remove(videoPath); err != nil {
+			log.Printf("Warning: Failed to remove video file %s: %v", videoPath, err)
 		}
 	}()
 
-	_, err = io.Copy(out, file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
-			Success: false,
-			Message: "Erro ao salvar arquivo: " + err.Error(),
-		})
+	if err := checksum.Verify(declared, sourceHash); err != nil {
+		respondChecksumMismatch(c, sourceHash)
+		return
+	}
+
+	jobID := ph.requestJob(c)
+	if err := ph.videoService.ValidateClipSource(videoPath, opts.Clips); err != nil {
+		result := models.ProcessingResult{Success: false, Message: err.Error()}
+		ph.finishJob(jobID, result)
+		c.JSON(http.StatusBadRequest, result)
 		return
 	}
 
-	result := ph.videoService.ProcessVideo(videoPath, timestamp)
+	opts.Source = models.SourceInfo{Name: filepath.Base(header.Filename), Hash: sourceHash}
+	opts.Progress = ph.jobProgress(jobID)
+	ph.updateJob(jobID, jobs.Update{Status: jobs.StatusProcessing})
+	result := ph.processVideo(videoPath, sourceHash, videoID, opts)
+	ph.finishJob(jobID, result)
 
-	if err := os.Remove(videoPath); err != nil {
-		log.Printf("Warning: Failed to remove video file %s: %v", videoPath, err)
+	// A deduplicated result belongs to the earlier job, whose source is already retained.
+	if result.Success && ph.videoService.RetainsSource(opts) && !result.Deduplicated {
+		if err := ph.retainSource(videoPath, filename, videoID, opts); err != nil {
+			log.Printf("Warning: Failed to retain source %s: %v", filename, err)
+		}
 	}
 
 	if result.Success {
@@ -98,6 +161,99 @@ func (ph *ProcessorHandlers) ProcessVideoUpload(c *gin.Context) {
 	}
 }
 
+// exceedsUploadLimit reports whether a video of size bytes is above MaxUploadSize.
+func (ph *ProcessorHandlers) exceedsUploadLimit(size int64) bool {
+	return ph.config.MaxUploadSize > 0 && size > ph.config.MaxUploadSize
+}
+
+func (ph *ProcessorHandlers) respondUploadTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, ph.uploadTooLargeResult())
+}
+
+func (ph *ProcessorHandlers) uploadTooLargeResult() models.ProcessingResult {
+	return models.ProcessingResult{
+		Success: false,
+		Message: fmt.Sprintf("Arquivo excede o tamanho máximo permitido de %d bytes", ph.config.MaxUploadSize),
+	}
+}
+
+// declaredChecksum returns the SHA-256 the sender declared for the video, in the
+// "sha256" form field (which may follow the file) or the X-Checksum-SHA256 header.
+func declaredChecksum(c *gin.Context) (string, bool) {
+	declared := c.PostForm("sha256")
+	if declared == "" {
+		declared = c.GetHeader(checksum.Header)
+	}
+	return checksum.Normalize(declared)
+}
+
+func respondInvalidChecksum(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, models.ProcessingResult{
+		Success: false,
+		Message: "Checksum SHA-256 inválido: use 64 caracteres hexadecimais",
+	})
+}
+
+// respondChecksumMismatch rejects a video whose bytes differ from the declared checksum
+// before any processing happens.
+func respondChecksumMismatch(c *gin.Context, computed string) {
+	c.JSON(http.StatusUnprocessableEntity, checksumMismatchResult(computed))
+}
+
+func checksumMismatchResult(computed string) models.ProcessingResult {
+	return models.ProcessingResult{
+		Success:        false,
+		Message:        "Checksum do vídeo não confere: o arquivo foi corrompido na transferência",
+		SourceChecksum: computed,
+	}
+}
+
+// saveUpload writes the video to videoPath and returns its hex SHA-256, computed while
+// the bytes stream through.
+func saveUpload(file io.Reader, videoPath string) (string, error) {
+	out, err := os.Create(filepath.Clean(videoPath))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), file); err != nil {
+		if closeErr := out.Close(); closeErr != nil {
+			log.Printf("Warning: Failed to close output file: %v", closeErr)
+		}
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// processVideo reuses the outputs of an identical earlier job when deduplication is on.
+func (ph *ProcessorHandlers) processVideo(videoPath, sourceHash, videoID string, opts models.ProcessingOptions) models.ProcessingResult {
+	if ph.config.DedupOutputs {
+		return ph.videoService.ProcessVideoDeduplicated(videoPath, sourceHash, videoID, opts)
+	}
+	return ph.videoService.ProcessVideoWithOptions(videoPath, videoID, opts)
+}
+
+// retainSource keeps a directly uploaded video in the uploads store for on-demand frames.
+func (ph *ProcessorHandlers) retainSource(videoPath, key, videoID string, opts models.ProcessingOptions) error {
+	file, err := os.Open(filepath.Clean(videoPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Warning: Failed to close video file: %v", err)
+		}
+	}()
+
+	if err := ph.config.Uploads.Put(key, file, ""); err != nil {
+		return err
+	}
+	return ph.videoService.RecordRetention(key, videoID, opts, time.Now())
+}
+
 func (ph *ProcessorHandlers) GetProcessorStatus(c *gin.Context) {
 	health := gin.H{
 		"status":    StatusHealthy,
@@ -217,6 +373,7 @@ func (ph *ProcessorHandlers) checkS3Connectivity() gin.H {
 			"latency_ms": latency.Milliseconds(),
 			"last_check": time.Now().Unix(),
 			"endpoint":   ph.config.AWSConfig.GetS3Endpoint(),
+			"provider":   ph.config.AWSConfig.ProviderType(),
 		}
 	}
 
@@ -225,6 +382,7 @@ func (ph *ProcessorHandlers) checkS3Connectivity() gin.H {
 		"latency_ms": latency.Milliseconds(),
 		"last_check": time.Now().Unix(),
 		"endpoint":   ph.config.AWSConfig.GetS3Endpoint(),
+		"provider":   ph.config.AWSConfig.ProviderType(),
 	}
 }
 
@@ -245,6 +403,11 @@ func IsValidVideoFile(filename string) bool {
 }
 
 func (ph *ProcessorHandlers) ProcessVideoFromS3(c *gin.Context) {
+	ph, ok := ph.forTenant(c)
+	if !ok {
+		return
+	}
+
 	s3Key := c.PostForm("s3_key")
 	if s3Key == "" {
 		c.JSON(http.StatusBadRequest, models.ProcessingResult{
@@ -254,71 +417,236 @@ func (ph *ProcessorHandlers) ProcessVideoFromS3(c *gin.Context) {
 		return
 	}
 
-	if !ph.config.IsS3Enabled() {
-		c.JSON(http.StatusServiceUnavailable, models.ProcessingResult{
+	opts, err := parseProcessingOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ProcessingResult{
 			Success: false,
-			Message: "S3 não está habilitado neste serviço",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	timestamp := time.Now().Format("20060102_150405")
-	tempVideoPath := filepath.Join(ph.config.TempDir, fmt.Sprintf("temp_%s_%s", timestamp, filepath.Base(s3Key)))
-
-	if err := ph.downloadVideoFromS3(s3Key, tempVideoPath); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ProcessingResult{
-			Success: false,
-			Message: "Erro ao baixar vídeo do S3: " + err.Error(),
-		})
+	declared, ok := declaredChecksum(c)
+	if !ok {
+		respondInvalidChecksum(c)
 		return
 	}
 
-	defer func() {
-		if err := os.Remove(tempVideoPath); err != nil {
-			log.Printf("Warning: Failed to cleanup temp video file: %v", err)
-		}
-	}()
+	status, result := ph.processStored(s3Key, declared, ph.requestJob(c), opts)
+	c.JSON(status, result)
+}
 
-	result := ph.videoService.ProcessVideo(tempVideoPath, timestamp)
+// processStored processes a video from uploads storage for the job with jobID, if any,
+// and returns the result with the HTTP status to answer it with. The job ends with the
+// result unless the upload could not be read (500). The upload is deleted once processed
+// unless it is retained for on-demand frames.
+func (ph *ProcessorHandlers) processStored(s3Key, declared, jobID string, opts models.ProcessingOptions) (int, models.ProcessingResult) {
+	if info, err := ph.config.Uploads.Stat(s3Key); err == nil {
+		if ph.exceedsUploadLimit(info.Size) {
+			result := ph.uploadTooLargeResult()
+			ph.finishJob(jobID, result)
+			return http.StatusRequestEntityTooLarge, result
+		}
+		// A checksum stored with the upload stands in for one the request left out.
+		if declared == "" {
+			declared, _ = checksum.Normalize(info.Metadata[storage.MetaChecksum])
+		}
+	}
 
-	if result.Success {
-		if err := ph.config.S3Service.DeleteFile(ph.config.S3Buckets.UploadsBucket, s3Key); err != nil {
-			log.Printf("Warning: Failed to cleanup uploaded video from S3: %v", err)
+	// Each run downloads under an ID of its own, so a redelivered job does not write
+	// over the file of a run still going.
+	runID := storage.NewJobID(time.Now())
+	videoPath, sourceHash, cleanup, err := ph.fetchSource(s3Key, runID)
+	if err != nil {
+		return http.StatusInternalServerError, models.ProcessingResult{
+			Success: false,
+			Message: "Erro ao baixar vídeo: " + err.Error(),
 		}
 	}
 
-	if result.Success {
-		c.JSON(http.StatusOK, result)
-	} else {
-		c.JSON(http.StatusUnprocessableEntity, result)
+	if err := checksum.Verify(declared, sourceHash); err != nil {
+		cleanup()
+		result := checksumMismatchResult(sourceHash)
+		ph.finishJob(jobID, result)
+		return http.StatusUnprocessableEntity, result
+	}
+
+	if err := ph.videoService.ValidateClipSource(videoPath, opts.Clips); err != nil {
+		cleanup()
+		result := models.ProcessingResult{Success: false, Message: err.Error()}
+		ph.finishJob(jobID, result)
+		return http.StatusBadRequest, result
+	}
+
+	videoID := videoIDFromKey(s3Key, runID)
+	opts.Source = models.SourceInfo{Name: sourceNameFromKey(s3Key), Hash: sourceHash}
+	opts.Progress = ph.jobProgress(jobID)
+	ph.updateJob(jobID, jobs.Update{Status: jobs.StatusProcessing, SourceKey: s3Key})
+	result := ph.processVideo(videoPath, sourceHash, videoID, opts)
+	cleanup()
+	ph.finishJob(jobID, result)
+
+	if !result.Success {
+		return http.StatusUnprocessableEntity, result
 	}
+
+	if ph.videoService.RetainsSource(opts) && !result.Deduplicated {
+		if err := ph.videoService.RecordRetention(s3Key, videoID, opts, time.Now()); err != nil {
+			log.Printf("Warning: Failed to record retention of %s: %v", s3Key, err)
+		}
+	} else if err := ph.config.Uploads.Delete(s3Key); err != nil {
+		log.Printf("Warning: Failed to cleanup uploaded video %s: %v", s3Key, err)
+	}
+	return http.StatusOK, result
 }
 
-func (ph *ProcessorHandlers) downloadVideoFromS3(s3Key, localPath string) error {
-	reader, err := ph.config.S3Service.DownloadFile(ph.config.S3Buckets.UploadsBucket, s3Key)
+// fetchSource returns a local path for a stored upload, reading it in place when the
+// uploads store keeps files on disk and downloading it into TempDir otherwise, along
+// with the SHA-256 of the bytes FFmpeg will read.
+func (ph *ProcessorHandlers) fetchSource(key, runID string) (videoPath, sourceHash string, cleanup func(), err error) {
+	if localPath, ok := storage.LocalPath(ph.config.Uploads, key); ok {
+		if _, err := os.Stat(localPath); err != nil {
+			return "", "", nil, err
+		}
+		if sourceHash, err = checksum.File(localPath); err != nil {
+			return "", "", nil, err
+		}
+		return localPath, sourceHash, func() {}, nil
+	}
+
+	tempVideoPath := filepath.Join(ph.config.TempDir, fmt.Sprintf("temp_%s_%s", runID, path.Base(key)))
+
+	reader, err := ph.config.Uploads.Get(key)
 	if err != nil {
-		return fmt.Errorf("failed to download from S3: %w", err)
+		return "", "", nil, err
 	}
 	defer func() {
 		if err := reader.Close(); err != nil {
-			log.Printf("Warning: Failed to close S3 reader: %v", err)
+			log.Printf("Warning: Failed to close storage reader: %v", err)
 		}
 	}()
 
-	file, err := os.Create(filepath.Clean(localPath))
+	sourceHash, err = saveUpload(reader, tempVideoPath)
 	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
+		return "", "", nil, fmt.Errorf("failed to copy stored video to local file: %w", err)
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf("Warning: Failed to close local file: %v", err)
+
+	log.Printf("Downloaded stored video %s -> %s", key, tempVideoPath)
+	return tempVideoPath, sourceHash, func() {
+		if err := os.Remove(tempVideoPath); err != nil {
+			log.Printf("Warning: Failed to cleanup temp video file: %v", err)
 		}
-	}()
+	}, nil
+}
+
+// parseProcessingOptions reads the optional JSON "options" form field.
+func parseProcessingOptions(c *gin.Context) (models.ProcessingOptions, error) {
+	return parseOptions(c.PostForm("options"))
+}
+
+// parseOptions decodes and validates processing options; empty options are the defaults.
+func parseOptions(raw string) (models.ProcessingOptions, error) {
+	var opts models.ProcessingOptions
+	if raw == "" {
+		return opts, nil
+	}
 
-	if _, err := io.Copy(file, reader); err != nil {
-		return fmt.Errorf("failed to copy S3 content to local file: %w", err)
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+		return opts, fmt.Errorf("opções de processamento inválidas: %w", err)
 	}
 
-	log.Printf("Downloaded video from S3: s3://%s/%s -> %s", ph.config.S3Buckets.UploadsBucket, s3Key, localPath)
-	return nil
+	if err := services.ValidateOptions(opts); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// GetFrame renders a single still at an arbitrary timestamp from a retained source video.
+func (ph *ProcessorHandlers) GetFrame(c *gin.Context) {
+	ph, ok := ph.forTenant(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Query("video_id")
+	if videoID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video_id é obrigatório"})
+		return
+	}
+
+	timestamp, err := utils.ParseTimestamp(c.Query("t"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetro t inválido: " + err.Error()})
+		return
+	}
+
+	format, ok := services.NormalizeFrameFormat(c.Query("format"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato não suportado. Use: jpeg, png, webp"})
+		return
+	}
+
+	width := 0
+	if raw := c.Query("width"); raw != "" {
+		width, err = strconv.Atoi(raw)
+		if err != nil || width <= 0 || width > services.MaxFrameWidth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetro width inválido"})
+			return
+		}
+	}
+
+	framePath, err := ph.videoService.ExtractFrame(models.FrameRequest{
+		VideoID:   videoID,
+		Timestamp: timestamp,
+		Format:    format,
+		Width:     width,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrSourceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Vídeo de origem não encontrado"})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", services.FrameContentType(format))
+	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	c.File(framePath)
+}
+
+// DeleteFrames drops the frames cached for a video, once the API deleted it.
+func (ph *ProcessorHandlers) DeleteFrames(c *gin.Context) {
+	ph, ok := ph.forTenant(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Query("video_id")
+	if videoID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "video_id é obrigatório"})
+		return
+	}
+	if err := ph.videoService.ClearFrameCache(videoID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// videoIDFromKey reuses the video ID prefix the API assigns to uploaded keys so
+// frames can later be requested with the same video ID.
+func videoIDFromKey(key, fallback string) string {
+	if videoID, _, ok := storage.SplitUploadName(filepath.Base(key)); ok {
+		return videoID
+	}
+	return fallback
+}
+
+// sourceNameFromKey recovers the client's filename from an uploads key by dropping the
+// video ID prefix the API adds.
+func sourceNameFromKey(key string) string {
+	_, filename, _ := storage.SplitUploadName(filepath.Base(key))
+	return filename
 }
@@ -9,8 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	baseConfig "video-processor/internal/config"
+	"video-processor/internal/jobs"
+	"video-processor/internal/storage"
 	"video-processor/processor/internal/config"
 	"video-processor/processor/internal/models"
 	"video-processor/processor/internal/services"
@@ -37,6 +40,8 @@ func setupTestHandlers() (handlers *ProcessorHandlers, cleanup func()) {
 			OutputsDir: outputsDir,
 			TempDir:    tempVideoDir,
 		},
+		Uploads: storage.NewFilesystem(uploadsDir),
+		Outputs: storage.NewFilesystem(outputsDir),
 	}
 
 	videoService := services.NewVideoService(cfg)
@@ -205,3 +210,228 @@ func BenchmarkProcessVideoUpload_ShouldPerformEfficientlyUnderLoad(b *testing.B)
 		handlers.ProcessVideoUpload(c)
 	}
 }
+
+func TestGetFrame_ShouldValidateQueryParameters(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "missing video id", query: "t=10"},
+		{name: "missing timestamp", query: "video_id=20240101_120000"},
+		{name: "invalid timestamp", query: "video_id=20240101_120000&t=abc"},
+		{name: "unsupported format", query: "video_id=20240101_120000&t=10&format=gif"},
+		{name: "invalid width", query: "video_id=20240101_120000&t=10&width=-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/frame?"+tt.query, http.NoBody)
+
+			handlers.GetFrame(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestGetFrame_ShouldReturnNotFoundWhenSourceIsNotRetained(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/frame?video_id=20240101_120000&t=00:00:01.500", http.NoBody)
+
+	handlers.GetFrame(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVideoIDFromKey(t *testing.T) {
+	assert.Equal(t, "20240101_120000", videoIDFromKey("20240101_120000_clip.mp4", "20250101_000000"))
+	assert.Equal(t, "20240101_120000-0a1b2c3d", videoIDFromKey("uploads/20240101_120000-0a1b2c3d_clip.mp4", "20250101_000000"))
+	assert.Equal(t, "20250101_000000", videoIDFromKey("clip.mp4", "20250101_000000"))
+	assert.Equal(t, "20250101_000000", videoIDFromKey("notatimestamp_clip.mp4", "20250101_000000"))
+}
+
+func TestSourceNameFromKey(t *testing.T) {
+	assert.Equal(t, "my_clip.mp4", sourceNameFromKey("20240101_120000_my_clip.mp4"))
+	assert.Equal(t, "my_clip.mp4", sourceNameFromKey("20240101_120000-0a1b2c3d_my_clip.mp4"))
+	assert.Equal(t, "clip.mp4", sourceNameFromKey("uploads/clip.mp4"))
+}
+
+func TestProcessVideoUpload_ShouldReturnBadRequestForInvalidClipOptions(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "test.mp4")
+	require.NoError(t, err)
+	part.Write([]byte("fake video content"))
+	require.NoError(t, writer.WriteField("options", `{"clips":[{"start":"20","end":"10"}]}`))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/process", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	handlers.ProcessVideoUpload(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Contains(t, response.Message, "clipe 1")
+}
+
+func TestProcessVideoUpload_ShouldRejectVideosAboveMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.MaxUploadSize = 1024
+
+	for _, size := range []int{4096, 2 * multipartOverhead} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("video", "test.mp4")
+		require.NoError(t, err)
+		part.Write(bytes.Repeat([]byte{0}, size))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/process", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.ContentLength = -1
+		c.Request = req
+
+		handlers.ProcessVideoUpload(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestProcessVideoFromS3_ShouldRejectStoredVideosAboveMaxUploadSize(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	handlers.config.MaxUploadSize = 4
+	require.NoError(t, handlers.config.Uploads.Put("20240101_120000_video.mp4", bytes.NewReader([]byte("video")), "video/mp4"))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/process-s3", bytes.NewBufferString("s3_key=20240101_120000_video.mp4"))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	handlers.ProcessVideoFromS3(c)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestProcessVideoUpload_ShouldVerifyDeclaredChecksum(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+
+	for declared, status := range map[string]int{
+		"not-a-checksum": http.StatusBadRequest,
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824": http.StatusUnprocessableEntity,
+	} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("video", "test.mp4")
+		require.NoError(t, err)
+		part.Write([]byte("fake video content"))
+		// The checksum follows the file, as senders that hash while streaming write it.
+		require.NoError(t, writer.WriteField("sha256", declared))
+		writer.Close()
+
+		c.Request = httptest.NewRequest("POST", "/process", body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		handlers.ProcessVideoUpload(c)
+
+		require.Equal(t, status, w.Code, declared)
+		var response models.ProcessingResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Contains(t, response.Message, "Checksum")
+	}
+}
+
+func TestProcessVideoFromS3_ShouldKeepUploadWhenChecksumMismatches(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	require.NoError(t, handlers.config.Uploads.Put("20240101_120000_video.mp4", bytes.NewReader([]byte("video")), "video/mp4"))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	form := "s3_key=20240101_120000_video.mp4&sha256=2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	c.Request = httptest.NewRequest("POST", "/process-s3", bytes.NewBufferString(form))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	handlers.ProcessVideoFromS3(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response models.ProcessingResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.SourceChecksum, 64)
+	_, err := handlers.config.Uploads.Stat("20240101_120000_video.mp4")
+	assert.NoError(t, err, "a mismatched source is kept for inspection")
+}
+
+func TestProcessVideoUpload_ShouldMoveTheNamedJobAlong(t *testing.T) {
+	handlers, cleanup := setupTestHandlers()
+	defer cleanup()
+	repository := jobs.NewMemoryRepository()
+	handlers.config.Jobs = repository
+	jobID, err := jobs.NewID()
+	require.NoError(t, err)
+	require.NoError(t, repository.Create(jobs.New(jobID, time.Now())))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "test.mp4")
+	require.NoError(t, err)
+	part.Write([]byte("fake video content"))
+	writer.Close()
+
+	c.Request = httptest.NewRequest("POST", "/process", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request.Header.Set(jobs.Header, jobID)
+
+	handlers.ProcessVideoUpload(c)
+
+	// The fake video cannot be decoded, so the job ends failed with the processing error.
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	job, err := repository.Get(jobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.NotEmpty(t, job.Error)
+	require.Len(t, job.Transitions, 3)
+	assert.Equal(t, jobs.StatusProcessing, job.Transitions[1].Status)
+	require.NotNil(t, job.Progress, "the stage the job failed in is kept")
+	assert.Equal(t, jobs.StageExtractingFrames, job.Progress.Stage)
+}
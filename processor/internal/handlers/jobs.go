@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"video-processor/internal/jobs"
+	"video-processor/processor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestJob returns the job the API names in the X-Job-ID header, or "" when jobs are
+// not tracked or the header is missing or malformed.
+func (ph *ProcessorHandlers) requestJob(c *gin.Context) string {
+	return ph.trackedJob(c.GetHeader(jobs.Header))
+}
+
+// trackedJob returns id when jobs are tracked and id is well formed, and "" otherwise.
+func (ph *ProcessorHandlers) trackedJob(id string) string {
+	if ph.config.Jobs == nil || !jobs.ValidID(id) {
+		return ""
+	}
+	return id
+}
+
+// updateJob applies update to the job with id. A job the store does not know, as when
+// the API keeps jobs in memory, is left alone.
+func (ph *ProcessorHandlers) updateJob(id string, update jobs.Update) {
+	if id == "" {
+		return
+	}
+	if _, err := ph.config.Jobs.Update(id, update, time.Now()); err != nil && !errors.Is(err, jobs.ErrNotFound) {
+		log.Printf("Warning: Failed to update job %s: %v", id, err)
+	}
+}
+
+// jobProgress returns the reporter that records the progress of processing on the job
+// with id, or nil when the job is not tracked.
+func (ph *ProcessorHandlers) jobProgress(id string) func(stage string, frames int) {
+	if id == "" {
+		return nil
+	}
+	return func(stage string, frames int) {
+		ph.updateJob(id, jobs.Update{Progress: &jobs.Progress{Stage: stage, Frames: frames}})
+	}
+}
+
+// finishJob records the outcome of processing on the job with id.
+func (ph *ProcessorHandlers) finishJob(id string, result models.ProcessingResult) {
+	if !result.Success {
+		ph.updateJob(id, jobs.Update{Status: jobs.StatusFailed, Error: result.Message})
+		return
+	}
+	ph.updateJob(id, jobs.Update{Status: jobs.StatusCompleted, Result: jobResult(result)})
+}
+
+// jobResult summarizes a processing result for the job record.
+func jobResult(result models.ProcessingResult) *jobs.Result {
+	return &jobs.Result{
+		Message:        result.Message,
+		VideoID:        result.VideoID,
+		ZipPath:        result.ZipPath,
+		FrameCount:     result.FrameCount,
+		Clips:          len(result.Clips),
+		ProxyPath:      result.ProxyPath,
+		PlaylistPath:   result.PlaylistPath,
+		Deduplicated:   result.Deduplicated,
+		SourceChecksum: result.SourceChecksum,
+	}
+}
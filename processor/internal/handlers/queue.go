@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+)
+
+// ProcessQueuedJob processes a job taken off the job queue as ProcessVideoFromS3 would.
+// A job that cannot succeed, such as one with invalid options, ends failed and its
+// message is acknowledged; an error, as when the upload cannot be read, leaves the
+// message to be retried.
+func (ph *ProcessorHandlers) ProcessQueuedJob(_ context.Context, delivery *queue.Delivery) error {
+	message := delivery.Message
+	jobID := ph.trackedJob(message.JobID)
+	fail := func(reason string) error {
+		log.Printf("Warning: Rejected queued job %s: %s", message.JobID, reason)
+		ph.updateJob(jobID, jobs.Update{Status: jobs.StatusFailed, Error: reason})
+		return nil
+	}
+
+	scoped, err := ph.withTenant(message.Tenant)
+	if err != nil {
+		return fail("Tenant inválido: use letras minúsculas, números e hífens")
+	}
+	if message.SourceKey == "" {
+		return fail("S3 key é obrigatório")
+	}
+	opts, err := parseOptions(message.Options)
+	if err != nil {
+		return fail(err.Error())
+	}
+	declared, ok := checksum.Normalize(message.SHA256)
+	if !ok {
+		return fail("Checksum SHA-256 inválido: use 64 caracteres hexadecimais")
+	}
+
+	status, result := scoped.processStored(message.SourceKey, declared, jobID, opts)
+	if status >= http.StatusInternalServerError {
+		return errors.New(result.Message)
+	}
+	log.Printf("Processed queued job %s (attempt %d): %s", message.JobID, delivery.Attempts, result.Message)
+	return nil
+}
+
+// FailExhaustedJob ends failed a queued job whose last attempt failed, before its
+// message moves to the dead-letter queue.
+func (ph *ProcessorHandlers) FailExhaustedJob(delivery *queue.Delivery, err error) {
+	ph.updateJob(ph.trackedJob(delivery.Message.JobID), jobs.Update{
+		Status: jobs.StatusFailed,
+		Error:  fmt.Sprintf("%v (após %d tentativas)", err, delivery.Attempts),
+	})
+}
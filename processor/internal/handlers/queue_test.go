@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"video-processor/internal/jobs"
+	"video-processor/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupQueuedJob(t *testing.T) (*ProcessorHandlers, *jobs.MemoryRepository, string) {
+	handlers, cleanup := setupTestHandlers()
+	t.Cleanup(cleanup)
+	repository := jobs.NewMemoryRepository()
+	handlers.config.Jobs = repository
+	jobID, err := jobs.NewID()
+	require.NoError(t, err)
+	require.NoError(t, repository.Create(jobs.New(jobID, time.Now())))
+	return handlers, repository, jobID
+}
+
+func TestProcessQueuedJob_ShouldFailJobsThatCannotSucceed(t *testing.T) {
+	handlers, repository, jobID := setupQueuedJob(t)
+
+	err := handlers.ProcessQueuedJob(context.Background(), &queue.Delivery{
+		Message:  queue.Message{JobID: jobID, SourceKey: "20240101_120000_video.mp4", Options: `{"clips":[{"start":"x"}]}`},
+		Attempts: 1,
+	})
+
+	require.NoError(t, err, "retrying cannot fix invalid options")
+	job, err := repository.Get(jobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.NotEmpty(t, job.Error)
+}
+
+func TestProcessQueuedJob_ShouldRetryWhenUploadCannotBeRead(t *testing.T) {
+	handlers, repository, jobID := setupQueuedJob(t)
+	delivery := &queue.Delivery{
+		Message:  queue.Message{JobID: jobID, SourceKey: "20240101_120000_missing.mp4"},
+		Attempts: 3,
+	}
+
+	err := handlers.ProcessQueuedJob(context.Background(), delivery)
+
+	require.Error(t, err)
+	job, getErr := repository.Get(jobID)
+	require.NoError(t, getErr)
+	assert.Equal(t, jobs.StatusQueued, job.Status, "the job waits for the next attempt")
+
+	handlers.FailExhaustedJob(delivery, errors.New("upload missing"))
+
+	job, err = repository.Get(jobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.StatusFailed, job.Status)
+	assert.Equal(t, "upload missing (após 3 tentativas)", job.Error)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/processor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forTenant returns the handlers to serve a request with: with storage routing enabled,
+// a copy bound to the tenant the API names in the X-Tenant-ID header. An invalid tenant
+// is answered with 400.
+func (ph *ProcessorHandlers) forTenant(c *gin.Context) (*ProcessorHandlers, bool) {
+	scoped, err := ph.withTenant(c.GetHeader(baseConfig.TenantHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ProcessingResult{
+			Success: false,
+			Message: "Tenant inválido: use letras minúsculas, números e hífens",
+		})
+		return nil, false
+	}
+	return scoped, true
+}
+
+// withTenant returns the handlers bound to tenant, or ph itself without storage routing.
+func (ph *ProcessorHandlers) withTenant(tenant string) (*ProcessorHandlers, error) {
+	router := ph.config.Router
+	if router == nil || !router.Enabled() {
+		return ph, nil
+	}
+
+	tenant, err := router.Tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	videoService := ph.videoService.ForTenant(tenant)
+	return &ProcessorHandlers{videoService: videoService, config: videoService.Config()}, nil
+}
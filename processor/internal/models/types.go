@@ -1,10 +1,143 @@
 package models
 
 type ProcessingResult struct {
-	Success     bool     `json:"success"`
-	Message     string   `json:"message"`
-	ZipPath     string   `json:"zip_path,omitempty"`
-	DownloadURL string   `json:"download_url,omitempty"`
-	FrameCount  int      `json:"frame_count,omitempty"`
-	Images      []string `json:"images,omitempty"`
+	Success      bool         `json:"success"`
+	Message      string       `json:"message"`
+	VideoID      string       `json:"video_id,omitempty"`
+	ZipPath      string       `json:"zip_path,omitempty"`
+	DownloadURL  string       `json:"download_url,omitempty"`
+	FrameCount   int          `json:"frame_count,omitempty"`
+	Images       []string     `json:"images,omitempty"`
+	Clips        []ClipResult `json:"clips,omitempty"`
+	ProxyPath    string       `json:"proxy_path,omitempty"`
+	PlaylistPath string       `json:"playlist_path,omitempty"`
+	// Deduplicated is set when the outputs of an identical earlier job were reused.
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// SourceChecksum is the hex SHA-256 of the processed video; Checksums holds that of
+	// every stored output, by key.
+	SourceChecksum string            `json:"source_checksum,omitempty"`
+	Checksums      map[string]string `json:"checksums,omitempty"`
+}
+
+// ProcessingOptions holds optional per-job settings sent as JSON in the "options" form field.
+type ProcessingOptions struct {
+	Clips []ClipRequest `json:"clips,omitempty"`
+
+	// Proxy requests a browser-playable H.264/AAC MP4; HLS requests an adaptive ladder.
+	// HLSRenditions overrides the configured ladder heights (e.g. [360, 720]).
+	Proxy         bool  `json:"proxy,omitempty"`
+	HLS           bool  `json:"hls,omitempty"`
+	HLSRenditions []int `json:"hls_renditions,omitempty"`
+
+	Redactions []RedactionRegion `json:"redactions,omitempty"`
+
+	// Overlay overrides the configured watermark/caption for this job.
+	Overlay *OverlayOptions `json:"overlay,omitempty"`
+
+	// RetainSource keeps the source video after processing even when RETAIN_SOURCES is
+	// off. SourceTTL (e.g. "72h" or "7d") expires it, overriding SOURCE_RETENTION, and
+	// implies RetainSource.
+	RetainSource bool   `json:"retain_source,omitempty"`
+	SourceTTL    string `json:"source_ttl,omitempty"`
+
+	// Owner identifies who the outputs belong to. It is stored with them as metadata.
+	Owner string `json:"owner,omitempty"`
+
+	// Source describes the uploaded video. The handlers fill it; clients cannot set it.
+	Source SourceInfo `json:"-"`
+
+	// Progress, when set, is told each stage processing enters and, from archiving on,
+	// how many frames were extracted. The handlers set it to update the job.
+	Progress func(stage string, frames int) `json:"-"`
+}
+
+// SourceInfo is what the processor knows about the video behind a job.
+type SourceInfo struct {
+	Name string
+	Hash string
+}
+
+// OverlayOptions stamps a PNG watermark and/or a caption onto every extracted frame.
+// Image is a key in the outputs bucket (or a file under the outputs directory).
+// Text may reference {source}, {timecode} and {video_id}. Position is one of
+// "top-left", "top-right", "bottom-left" or "bottom-right"; Opacity ranges 0-1.
+type OverlayOptions struct {
+	Image    string  `json:"image,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Position string  `json:"position,omitempty"`
+	Opacity  float64 `json:"opacity,omitempty"`
+	FontSize int     `json:"font_size,omitempty"`
+}
+
+// RedactionRegion is a rectangle burned into every extracted frame. Start/End limit it
+// to a time range; Keyframes move its top-left corner over time (linearly interpolated).
+// Style is "blur" (default), "pixelate" or "fill" (solid Color, default black).
+type RedactionRegion struct {
+	X         int                 `json:"x"`
+	Y         int                 `json:"y"`
+	Width     int                 `json:"width"`
+	Height    int                 `json:"height"`
+	Start     string              `json:"start,omitempty"`
+	End       string              `json:"end,omitempty"`
+	Style     string              `json:"style,omitempty"`
+	Color     string              `json:"color,omitempty"`
+	Keyframes []RedactionKeyframe `json:"keyframes,omitempty"`
+}
+
+// RedactionKeyframe positions a redaction region at a given time (seconds or timecode).
+type RedactionKeyframe struct {
+	At string `json:"t"`
+	X  int    `json:"x"`
+	Y  int    `json:"y"`
+}
+
+// Manifest is written as manifest.json inside every frames archive.
+type Manifest struct {
+	VideoID    string            `json:"video_id"`
+	CreatedAt  string            `json:"created_at"`
+	FrameRate  int               `json:"frame_rate"`
+	FrameCount int               `json:"frame_count"`
+	Frames     []string          `json:"frames"`
+	Redaction  ManifestRedaction `json:"redaction"`
+	Overlay    ManifestOverlay   `json:"overlay"`
+}
+
+// ManifestRedaction records whether regions were burned into the frames.
+type ManifestRedaction struct {
+	Applied bool     `json:"applied"`
+	Regions int      `json:"regions"`
+	Styles  []string `json:"styles,omitempty"`
+}
+
+// ManifestOverlay records whether a watermark or caption was stamped onto the frames.
+type ManifestOverlay struct {
+	Applied  bool   `json:"applied"`
+	Image    bool   `json:"image,omitempty"`
+	Text     bool   `json:"text,omitempty"`
+	Position string `json:"position,omitempty"`
+}
+
+// ClipRequest asks for an MP4 clip cut between Start and End (seconds or HH:MM:SS.mmm).
+// Mode is "auto" (default), "copy" or "reencode".
+type ClipRequest struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Mode  string `json:"mode,omitempty"`
+}
+
+// ClipResult describes an exported clip stored next to the frames archive.
+type ClipResult struct {
+	Filename    string  `json:"filename"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Mode        string  `json:"mode"`
+	DownloadURL string  `json:"download_url,omitempty"`
+}
+
+// FrameRequest describes a single still to be rendered from a retained source video.
+type FrameRequest struct {
+	VideoID   string
+	Timestamp float64
+	Format    string
+	Width     int
 }
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+	"video-processor/processor/internal/utils"
+)
+
+const (
+	ClipModeAuto     = "auto"
+	ClipModeCopy     = "copy"
+	ClipModeReencode = "reencode"
+
+	MaxClipsPerJob = 20
+
+	// keyframeTolerance is how far (in seconds) a cut point may be from a keyframe
+	// and still be considered safe for stream copy.
+	keyframeTolerance = 0.05
+)
+
+// Codecs a clip can be stream-copied from, to stay an H.264/AAC MP4.
+const (
+	copyVideoCodec = "h264"
+	copyAudioCodec = "aac"
+)
+
+// sourceCodecs names the first video and audio codec of a source, as ffprobe reports
+// them; Audio is empty for a silent source.
+type sourceCodecs struct {
+	Video string
+	Audio string
+}
+
+// copyable reports whether clips cut by stream copy come out as H.264/AAC MP4s.
+func (c sourceCodecs) copyable() bool {
+	return c.Video == copyVideoCodec && (c.Audio == "" || c.Audio == copyAudioCodec)
+}
+
+type clipSpec struct {
+	start float64
+	end   float64
+	mode  string
+}
+
+// ValidateClipRequests parses and checks the requested clip ranges.
+func ValidateClipRequests(clips []models.ClipRequest) error {
+	_, err := parseClipRequests(clips)
+	return err
+}
+
+func parseClipRequests(clips []models.ClipRequest) ([]clipSpec, error) {
+	if len(clips) > MaxClipsPerJob {
+		return nil, fmt.Errorf("máximo de %d clipes por processamento", MaxClipsPerJob)
+	}
+
+	specs := make([]clipSpec, 0, len(clips))
+	for i, clip := range clips {
+		start, err := utils.ParseTimestamp(clip.Start)
+		if err != nil {
+			return nil, fmt.Errorf("clipe %d: início inválido: %w", i+1, err)
+		}
+		end, err := utils.ParseTimestamp(clip.End)
+		if err != nil {
+			return nil, fmt.Errorf("clipe %d: fim inválido: %w", i+1, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("clipe %d: fim deve ser maior que o início", i+1)
+		}
+
+		mode := strings.ToLower(strings.TrimSpace(clip.Mode))
+		switch mode {
+		case "":
+			mode = ClipModeAuto
+		case ClipModeAuto, ClipModeCopy, ClipModeReencode:
+		default:
+			return nil, fmt.Errorf("clipe %d: modo inválido %q (use auto, copy ou reencode)", i+1, clip.Mode)
+		}
+
+		specs = append(specs, clipSpec{start: start, end: end, mode: mode})
+	}
+
+	return specs, nil
+}
+
+// ValidateClipSource rejects clips asking for stream copy from a source whose codecs
+// would not make an H.264/AAC MP4. A source that cannot be probed is left to FFmpeg.
+func (vs *VideoService) ValidateClipSource(videoPath string, clips []models.ClipRequest) error {
+	specs, err := parseClipRequests(clips)
+	if err != nil || !requestsCopy(specs) {
+		return err
+	}
+	codecs, err := probeCodecs(videoPath)
+	if err != nil {
+		log.Printf("Warning: Failed to probe codecs of %s: %v", videoPath, err)
+		return nil
+	}
+	return checkCopyCodecs(specs, codecs)
+}
+
+func requestsCopy(specs []clipSpec) bool {
+	for _, spec := range specs {
+		if spec.mode == ClipModeCopy {
+			return true
+		}
+	}
+	return false
+}
+
+func checkCopyCodecs(specs []clipSpec, codecs sourceCodecs) error {
+	if codecs.copyable() {
+		return nil
+	}
+	for i, spec := range specs {
+		if spec.mode == ClipModeCopy {
+			return fmt.Errorf("clipe %d: o modo copy exige vídeo H.264 com áudio AAC, mas a origem é %s/%s; use auto ou reencode",
+				i+1, codecName(codecs.Video), codecName(codecs.Audio))
+		}
+	}
+	return nil
+}
+
+func codecName(codec string) string {
+	if codec == "" {
+		return "nenhum"
+	}
+	return codec
+}
+
+// exportClips cuts the requested ranges from the source and stores them in the outputs
+// store. Auto clips are stream-copied only from H.264/AAC sources with a keyframe at the
+// cut point, and re-encoded otherwise.
+func (vs *VideoService) exportClips(videoPath, tempDir, timestamp string, clips []models.ClipRequest, checksums map[string]string) ([]models.ClipResult, error) {
+	specs, err := parseClipRequests(clips)
+	if err != nil || len(specs) == 0 {
+		return nil, err
+	}
+
+	codecs, err := probeCodecs(videoPath)
+	if err != nil {
+		log.Printf("Warning: Failed to probe codecs of %s: %v", videoPath, err)
+	}
+	if err == nil {
+		if err := checkCopyCodecs(specs, codecs); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]models.ClipResult, 0, len(specs))
+	for i, spec := range specs {
+		mode := spec.mode
+		if mode == ClipModeAuto {
+			mode = ClipModeReencode
+			if codecs.copyable() && vs.isKeyframeAt(videoPath, spec.start) {
+				mode = ClipModeCopy
+			}
+		}
+
+		clipFilename := fmt.Sprintf("clip_%s_%02d.mp4", timestamp, i+1)
+		clipPath := filepath.Join(tempDir, clipFilename)
+
+		if err := vs.cutClip(videoPath, clipPath, spec.start, spec.end, mode); err != nil {
+			return nil, err
+		}
+
+		storedName, err := vs.storeOutputFile(clipPath, clipFilename, "video/mp4", checksums)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao salvar clipe: %w", err)
+		}
+
+		results = append(results, models.ClipResult{
+			Filename: filepath.Base(storedName),
+			Start:    spec.start,
+			End:      spec.end,
+			Mode:     mode,
+		})
+	}
+
+	return results, nil
+}
+
+func (vs *VideoService) cutClip(videoPath, clipPath string, start, end float64, mode string) error {
+	if err := utils.ValidatePathSafety(videoPath, clipPath); err != nil {
+		return err
+	}
+
+	absVideoPath, err := filepath.Abs(filepath.Clean(videoPath))
+	if err != nil {
+		return fmt.Errorf("error resolving video path: %w", err)
+	}
+	absClipPath, err := filepath.Abs(filepath.Clean(clipPath))
+	if err != nil {
+		return fmt.Errorf("error resolving clip path: %w", err)
+	}
+
+	args := []string{
+		"-ss", utils.FormatSeconds(start),
+		"-i", absVideoPath,
+		"-t", utils.FormatSeconds(end - start),
+	}
+	if mode == ClipModeCopy {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	} else {
+		args = append(args,
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "128k",
+		)
+	}
+	args = append(args, "-movflags", "+faststart", "-y", absClipPath)
+
+	cmd := exec.Command("ffmpeg", args...) // #nosec G204
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("erro no ffmpeg ao cortar clipe: %s\nOutput: %s", err.Error(), string(output))
+	}
+
+	return nil
+}
+
+// isKeyframeAt reports whether the video has a keyframe close enough to the cut point
+// for a stream-copy cut to start exactly where requested.
+func (vs *VideoService) isKeyframeAt(videoPath string, at float64) bool {
+	absVideoPath, err := filepath.Abs(filepath.Clean(videoPath))
+	if err != nil {
+		return false
+	}
+
+	window := fmt.Sprintf("%s%%%s", utils.FormatSeconds(math.Max(0, at-1)), utils.FormatSeconds(at+1))
+	cmd := exec.Command("ffprobe", // #nosec G204
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-skip_frame", "nokey",
+		"-read_intervals", window,
+		"-show_entries", "frame=pts_time",
+		"-of", "csv=p=0",
+		absVideoPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		log.Printf("Warning: Failed to probe keyframes for %s: %v", videoPath, err)
+		return false
+	}
+
+	return hasKeyframeNear(string(output), at)
+}
+
+// probeCodecs reads the codecs of the first video and audio streams of a source.
+func probeCodecs(videoPath string) (sourceCodecs, error) {
+	absVideoPath, err := filepath.Abs(filepath.Clean(videoPath))
+	if err != nil {
+		return sourceCodecs{}, err
+	}
+	if err := utils.ValidatePathSafety(absVideoPath); err != nil {
+		return sourceCodecs{}, err
+	}
+
+	cmd := exec.Command("ffprobe", // #nosec G204
+		"-v", "error",
+		"-show_entries", "stream=codec_type,codec_name",
+		"-of", "csv=p=0",
+		absVideoPath,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return sourceCodecs{}, err
+	}
+	return parseStreamCodecs(string(output)), nil
+}
+
+// parseStreamCodecs reads ffprobe's "codec_name,codec_type" lines, one per stream.
+func parseStreamCodecs(ffprobeOutput string) sourceCodecs {
+	var codecs sourceCodecs
+	for _, line := range strings.Split(ffprobeOutput, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 {
+			continue
+		}
+		name, kind := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		switch {
+		case kind == "video" && codecs.Video == "":
+			codecs.Video = name
+		case kind == "audio" && codecs.Audio == "":
+			codecs.Audio = name
+		}
+	}
+	return codecs
+}
+
+func hasKeyframeNear(ffprobeOutput string, at float64) bool {
+	for _, line := range strings.Split(ffprobeOutput, "\n") {
+		pts, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ",")), 64)
+		if err != nil {
+			continue
+		}
+		if math.Abs(pts-at) <= keyframeTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// storeOutputFile moves a locally rendered artifact into the outputs store and returns its
+// stored location: the on-disk path for local storage, otherwise the key. The store
+// verifies the artifact's SHA-256, which is also recorded in checksums.
+func (vs *VideoService) storeOutputFile(localPath, filename, contentType string, checksums map[string]string) (string, error) {
+	sum, err := checksum.File(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filepath.Clean(localPath))
+	if err != nil {
+		return "", err
+	}
+
+	putErr := storage.PutWithMetadata(vs.config.Outputs, filename, file, contentType, map[string]string{storage.MetaChecksum: sum})
+	if err := file.Close(); err != nil {
+		log.Printf("Warning: Failed to close file %s: %v", localPath, err)
+	}
+	if putErr != nil {
+		return "", fmt.Errorf("erro ao salvar saída %s: %w", filename, putErr)
+	}
+	checksums[filename] = sum
+
+	if err := os.Remove(localPath); err != nil {
+		log.Printf("Warning: Failed to remove local file %s: %v", localPath, err)
+	}
+
+	if storedPath, ok := storage.LocalPath(vs.config.Outputs, filename); ok {
+		return storedPath, nil
+	}
+	return filename, nil
+}
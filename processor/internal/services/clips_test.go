@@ -0,0 +1,140 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClipRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		clips     []models.ClipRequest
+		expectErr string
+		expected  []clipSpec
+	}{
+		{
+			name:     "defaults to auto mode",
+			clips:    []models.ClipRequest{{Start: "10", End: "00:00:20.500"}},
+			expected: []clipSpec{{start: 10, end: 20.5, mode: ClipModeAuto}},
+		},
+		{
+			name:     "explicit modes",
+			clips:    []models.ClipRequest{{Start: "0", End: "5", Mode: "COPY"}, {Start: "5", End: "6", Mode: "reencode"}},
+			expected: []clipSpec{{start: 0, end: 5, mode: ClipModeCopy}, {start: 5, end: 6, mode: ClipModeReencode}},
+		},
+		{
+			name:      "end before start",
+			clips:     []models.ClipRequest{{Start: "20", End: "10"}},
+			expectErr: "fim deve ser maior",
+		},
+		{
+			name:      "invalid start",
+			clips:     []models.ClipRequest{{Start: "abc", End: "10"}},
+			expectErr: "início inválido",
+		},
+		{
+			name:      "invalid mode",
+			clips:     []models.ClipRequest{{Start: "0", End: "10", Mode: "fast"}},
+			expectErr: "modo inválido",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := parseClipRequests(tt.clips)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, specs)
+		})
+	}
+}
+
+func TestParseClipRequests_LimitsClipCount(t *testing.T) {
+	clips := make([]models.ClipRequest, MaxClipsPerJob+1)
+	for i := range clips {
+		clips[i] = models.ClipRequest{Start: "0", End: "1"}
+	}
+
+	_, err := parseClipRequests(clips)
+
+	assert.Error(t, err)
+}
+
+func TestHasKeyframeNear(t *testing.T) {
+	output := "0.000000\n2.002000,\n4.004000\n"
+
+	assert.True(t, hasKeyframeNear(output, 2))
+	assert.True(t, hasKeyframeNear(output, 4.03))
+	assert.False(t, hasKeyframeNear(output, 3))
+	assert.False(t, hasKeyframeNear("", 0))
+}
+
+func TestParseStreamCodecs(t *testing.T) {
+	output := "h264,video\naac,audio\nmov_text,subtitle\nmp3,audio\n"
+
+	assert.Equal(t, sourceCodecs{Video: "h264", Audio: "aac"}, parseStreamCodecs(output))
+	assert.Equal(t, sourceCodecs{Video: "vp9"}, parseStreamCodecs("vp9,video\n"))
+	assert.Equal(t, sourceCodecs{}, parseStreamCodecs(""))
+}
+
+func TestSourceCodecs_Copyable(t *testing.T) {
+	assert.True(t, sourceCodecs{Video: "h264", Audio: "aac"}.copyable())
+	assert.True(t, sourceCodecs{Video: "h264"}.copyable(), "a silent H.264 source copies")
+	assert.False(t, sourceCodecs{Video: "h264", Audio: "opus"}.copyable())
+	assert.False(t, sourceCodecs{Video: "hevc", Audio: "aac"}.copyable())
+}
+
+func TestCheckCopyCodecs(t *testing.T) {
+	specs := []clipSpec{{start: 0, end: 5, mode: ClipModeAuto}, {start: 5, end: 10, mode: ClipModeCopy}}
+
+	assert.NoError(t, checkCopyCodecs(specs, sourceCodecs{Video: "h264", Audio: "aac"}))
+	assert.NoError(t, checkCopyCodecs(specs[:1], sourceCodecs{Video: "vp9", Audio: "opus"}), "auto clips re-encode instead")
+
+	err := checkCopyCodecs(specs, sourceCodecs{Video: "vp9", Audio: "opus"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "clipe 2")
+	assert.Contains(t, err.Error(), "vp9/opus")
+}
+
+func TestVideoService_storeOutputFile_Filesystem(t *testing.T) {
+	service := newFrameTestService(t)
+	require.NoError(t, os.MkdirAll(service.config.OutputsDir, 0750))
+
+	localPath := filepath.Join(t.TempDir(), "clip_20240101_120000_01.mp4")
+	require.NoError(t, os.WriteFile(localPath, []byte("mp4"), 0600))
+
+	checksums := map[string]string{}
+	stored, err := service.storeOutputFile(localPath, "clip_20240101_120000_01.mp4", "video/mp4", checksums)
+
+	require.NoError(t, err)
+	assert.FileExists(t, stored)
+	assert.NoFileExists(t, localPath)
+	assert.Equal(t, service.config.OutputsDir, filepath.Dir(stored))
+
+	sum, err := checksum.File(stored)
+	require.NoError(t, err)
+	assert.Equal(t, sum, checksums["clip_20240101_120000_01.mp4"])
+	info, err := service.config.Outputs.Stat("clip_20240101_120000_01.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, sum, info.Metadata[storage.MetaChecksum])
+}
+
+func TestVideoService_storeOutputFile_RejectsTraversal(t *testing.T) {
+	service := newFrameTestService(t)
+
+	_, err := service.storeOutputFile("/tmp/x.mp4", "../x.mp4", "video/mp4", map[string]string{})
+
+	assert.Error(t, err)
+}
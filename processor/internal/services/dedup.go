@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"video-processor/internal/dedup"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+)
+
+// dedupFingerprint is everything besides the source that shapes the outputs of a job.
+type dedupFingerprint struct {
+	Tenant            string                   `json:"tenant,omitempty"`
+	Options           models.ProcessingOptions `json:"options"`
+	Overlay           *models.OverlayOptions   `json:"overlay,omitempty"`
+	HLSRenditions     []int                    `json:"hls_renditions,omitempty"`
+	HLSSegmentSeconds int                      `json:"hls_segment_seconds,omitempty"`
+}
+
+// digestLocks serializes work per digest. A lock is dropped when its last holder or
+// waiter unlocks, so the map only holds the digests being processed right now.
+type digestLocks struct {
+	mu    sync.Mutex
+	locks map[string]*digestLock
+}
+
+type digestLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newDigestLocks() *digestLocks {
+	return &digestLocks{locks: map[string]*digestLock{}}
+}
+
+// lock waits for the lock of digest and returns the function releasing it.
+func (dl *digestLocks) lock(digest string) func() {
+	dl.mu.Lock()
+	lock, ok := dl.locks[digest]
+	if !ok {
+		lock = &digestLock{}
+		dl.locks[digest] = lock
+	}
+	lock.refs++
+	dl.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		dl.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(dl.locks, digest)
+		}
+		dl.mu.Unlock()
+	}
+}
+
+// ProcessVideoDeduplicated returns the outputs of an earlier job when the same content
+// (sourceHash, the hex SHA-256 of the video) was processed with the same effective
+// options, and processes the video otherwise, indexing the new outputs for later jobs.
+func (vs *VideoService) ProcessVideoDeduplicated(videoPath, sourceHash, timestamp string, opts models.ProcessingOptions) models.ProcessingResult {
+	fingerprint, ok := vs.dedupFingerprint(opts)
+	if !ok || sourceHash == "" {
+		return vs.ProcessVideoWithOptions(videoPath, timestamp, opts)
+	}
+	digest := dedup.Digest(sourceHash, fingerprint)
+
+	// Identical jobs arriving together wait for the first one instead of all running FFmpeg.
+	defer vs.dedupLocks.lock(digest)()
+
+	if result, ok := vs.reuseResult(digest); ok {
+		fmt.Printf("♻️ Reutilizando resultado de %s para %s\n", result.VideoID, videoPath)
+		return result
+	}
+
+	result := vs.ProcessVideoWithOptions(videoPath, timestamp, opts)
+	if result.Success {
+		vs.recordResult(digest, result)
+	}
+	return result
+}
+
+// dedupFingerprint serializes the effective options. Captions naming the source file or
+// the video ID differ between uploads of the same content, so those jobs never dedup.
+func (vs *VideoService) dedupFingerprint(opts models.ProcessingOptions) ([]byte, bool) {
+	overlay := vs.effectiveOverlay(opts.Overlay)
+	if overlay != nil && (strings.Contains(overlay.Text, "{source}") || strings.Contains(overlay.Text, "{video_id}")) {
+		return nil, false
+	}
+
+	fingerprint := dedupFingerprint{Tenant: vs.tenant, Options: opts, Overlay: overlay}
+	fingerprint.Options.Overlay = nil
+	fingerprint.Options.RetainSource = false
+	fingerprint.Options.SourceTTL = ""
+	if opts.HLS {
+		fingerprint.HLSRenditions = vs.config.HLSRenditions
+		fingerprint.HLSSegmentSeconds = vs.config.HLSSegmentSeconds
+	}
+
+	data, err := json.Marshal(fingerprint)
+	if err != nil {
+		log.Printf("Warning: Failed to fingerprint processing options: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// reuseResult takes a reference on an indexed result whose archive still exists.
+func (vs *VideoService) reuseResult(digest string) (models.ProcessingResult, bool) {
+	entry, err := vs.dedup.Acquire(digest)
+	if err != nil {
+		if !errors.Is(err, dedup.ErrNotFound) {
+			log.Printf("Warning: Failed to look up dedup entry %s: %v", digest, err)
+		}
+		return models.ProcessingResult{}, false
+	}
+
+	var result models.ProcessingResult
+	if err := json.Unmarshal(entry.Result, &result); err != nil {
+		log.Printf("Warning: Failed to decode dedup entry %s: %v", digest, err)
+		vs.forgetResult(digest)
+		return models.ProcessingResult{}, false
+	}
+
+	if _, err := vs.config.Outputs.Stat(result.ZipPath); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// The outputs were removed behind the index's back; index the new ones instead.
+			vs.forgetResult(digest)
+		} else {
+			log.Printf("Warning: Failed to check outputs of %s: %v", result.VideoID, err)
+			if err := vs.dedup.Drop(digest); err != nil {
+				log.Printf("Warning: Failed to drop dedup reference on %s: %v", digest, err)
+			}
+		}
+		return models.ProcessingResult{}, false
+	}
+
+	result.Deduplicated = true
+	result.Message = fmt.Sprintf("Resultado reaproveitado de processamento anterior: %d frames extraídos.", result.FrameCount)
+	return result, true
+}
+
+func (vs *VideoService) recordResult(digest string, result models.ProcessingResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Warning: Failed to encode result of %s for dedup: %v", result.VideoID, err)
+		return
+	}
+
+	entry := &dedup.Entry{Digest: digest, VideoID: result.VideoID, Result: data, CreatedAt: time.Now()}
+	if _, err := vs.dedup.Record(entry); err != nil {
+		log.Printf("Warning: Failed to index result of %s for dedup: %v", result.VideoID, err)
+	}
+}
+
+func (vs *VideoService) forgetResult(digest string) {
+	if err := vs.dedup.Forget(digest); err != nil {
+		log.Printf("Warning: Failed to forget dedup entry %s: %v", digest, err)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/dedup"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSourceHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func recordTestResult(t *testing.T, vs *VideoService, opts models.ProcessingOptions, result models.ProcessingResult) string {
+	fingerprint, ok := vs.dedupFingerprint(opts)
+	require.True(t, ok)
+	digest := dedup.Digest(testSourceHash, fingerprint)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	recorded, err := vs.dedup.Record(&dedup.Entry{Digest: digest, VideoID: result.VideoID, Result: data})
+	require.NoError(t, err)
+	require.True(t, recorded)
+	return digest
+}
+
+func TestVideoService_ProcessVideoDeduplicated_ReusesExistingOutputs(t *testing.T) {
+	vs := newFrameTestService(t)
+	opts := models.ProcessingOptions{Proxy: true}
+	require.NoError(t, vs.config.Outputs.Put("frames_20240101_120000.zip", strings.NewReader("zip"), "application/zip"))
+	recordTestResult(t, vs, opts, models.ProcessingResult{
+		Success:    true,
+		VideoID:    "20240101_120000",
+		ZipPath:    "frames_20240101_120000.zip",
+		FrameCount: 12,
+		ProxyPath:  "proxy_20240101_120000.mp4",
+	})
+
+	result := vs.ProcessVideoDeduplicated(filepath.Join(vs.config.TempDir, "missing.mp4"), testSourceHash, "20240102_090000", opts)
+
+	require.True(t, result.Success, result.Message)
+	assert.True(t, result.Deduplicated)
+	assert.Equal(t, "20240101_120000", result.VideoID)
+	assert.Equal(t, "frames_20240101_120000.zip", result.ZipPath)
+	assert.Equal(t, 12, result.FrameCount)
+
+	removed, err := vs.dedup.Release("20240101_120000", func() error {
+		t.Error("outputs removed while the deduplicated job holds a reference")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.False(t, removed, "the deduplicated job holds a reference")
+}
+
+func TestVideoService_ProcessVideoDeduplicated_ForgetsEntriesWithoutOutputs(t *testing.T) {
+	vs := newFrameTestService(t)
+	opts := models.ProcessingOptions{}
+	digest := recordTestResult(t, vs, opts, models.ProcessingResult{Success: true, VideoID: "20240101_120000", ZipPath: "frames_20240101_120000.zip"})
+
+	result := vs.ProcessVideoDeduplicated(filepath.Join(vs.config.TempDir, "missing.mp4"), testSourceHash, "20240102_090000", opts)
+
+	assert.False(t, result.Deduplicated)
+	_, err := vs.dedup.Lookup(digest)
+	assert.ErrorIs(t, err, dedup.ErrNotFound)
+	assert.Empty(t, vs.dedupLocks.locks)
+}
+
+func TestDigestLocks_SerializeAndDropIdleLocks(t *testing.T) {
+	locks := newDigestLocks()
+	unlock := locks.lock("digest-1")
+
+	acquired := make(chan func())
+	go func() { acquired <- locks.lock("digest-1") }()
+	select {
+	case <-acquired:
+		t.Fatal("second holder got the lock while the first held it")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	(<-acquired)()
+	locks.lock("digest-2")()
+	assert.Empty(t, locks.locks)
+}
+
+func TestVideoService_DedupFingerprint(t *testing.T) {
+	vs := newFrameTestService(t)
+	vs.config.HLSRenditions = []int{360, 720}
+
+	plain, ok := vs.dedupFingerprint(models.ProcessingOptions{})
+	require.True(t, ok)
+	hls, ok := vs.dedupFingerprint(models.ProcessingOptions{HLS: true})
+	require.True(t, ok)
+	assert.NotEqual(t, plain, hls)
+
+	vs.config.HLSRenditions = []int{1080}
+	otherLadder, ok := vs.dedupFingerprint(models.ProcessingOptions{HLS: true})
+	require.True(t, ok)
+	assert.NotEqual(t, hls, otherLadder, "the configured ladder shapes the outputs")
+
+	_, ok = vs.dedupFingerprint(models.ProcessingOptions{Overlay: &models.OverlayOptions{Text: "{source} {timecode}"}})
+	assert.False(t, ok, "captions naming the source never dedup")
+
+	vs.config.OverlayText = "© agência"
+	withOverlay, ok := vs.dedupFingerprint(models.ProcessingOptions{})
+	require.True(t, ok)
+	assert.NotEqual(t, plain, withOverlay)
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+	"video-processor/processor/internal/utils"
+)
+
+const (
+	frameCacheDirName  = "frame_cache"
+	sourceCacheDirName = "source_cache"
+	MaxFrameWidth      = 7680
+)
+
+// ErrSourceNotFound is returned when no retained source video exists for a video ID.
+var ErrSourceNotFound = errors.New("source video not found")
+
+var frameFormats = map[string]struct {
+	extension   string
+	codec       string
+	contentType string
+}{
+	"jpeg": {extension: "jpg", codec: "mjpeg", contentType: "image/jpeg"},
+	"png":  {extension: "png", codec: "png", contentType: "image/png"},
+	"webp": {extension: "webp", codec: "libwebp", contentType: "image/webp"},
+}
+
+// NormalizeFrameFormat maps user supplied formats to a supported key, defaulting to jpeg.
+func NormalizeFrameFormat(format string) (string, bool) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "":
+		return "jpeg", true
+	case "jpg":
+		return "jpeg", true
+	}
+	_, ok := frameFormats[format]
+	return format, ok
+}
+
+// FrameContentType returns the MIME type for a normalized frame format.
+func FrameContentType(format string) string {
+	return frameFormats[format].contentType
+}
+
+// ExtractFrame renders a single still from a retained source video, caching the result on disk.
+func (vs *VideoService) ExtractFrame(req models.FrameRequest) (string, error) {
+	format, ok := NormalizeFrameFormat(req.Format)
+	if !ok {
+		return "", fmt.Errorf("formato de imagem não suportado: %s", req.Format)
+	}
+	if req.Width < 0 || req.Width > MaxFrameWidth {
+		return "", fmt.Errorf("largura inválida: %d", req.Width)
+	}
+	if err := validateVideoID(req.VideoID); err != nil {
+		return "", err
+	}
+
+	cacheDir := vs.frameCacheDir(req.VideoID)
+	if err := utils.SetupTempDirectory(cacheDir); err != nil {
+		return "", err
+	}
+
+	// The regions are part of the cache key, so frames cached before they changed are
+	// never served.
+	redactions, err := vs.loadRedactions(req.VideoID)
+	if err != nil {
+		return "", err
+	}
+
+	cacheKey := frameCacheKey(req.VideoID, req.Timestamp, format, req.Width, redactions)
+	framePath := filepath.Join(cacheDir, cacheKey+"."+frameFormats[format].extension)
+	if _, err := os.Stat(framePath); err == nil {
+		return framePath, nil
+	}
+
+	sourcePath, err := vs.resolveSource(req.VideoID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := vs.renderFrame(sourcePath, framePath, req.Timestamp, format, req.Width, redactions); err != nil {
+		return "", err
+	}
+
+	return framePath, nil
+}
+
+// frameCacheDir holds the cached frames of one video, so they can be dropped with it.
+func (vs *VideoService) frameCacheDir(videoID string) string {
+	return filepath.Join(vs.cacheDir(frameCacheDirName), videoID)
+}
+
+// validateVideoID accepts only a bare video ID, which names cache directories and the
+// uploads a source is looked up by.
+func validateVideoID(videoID string) error {
+	if videoID == "" || filepath.Base(videoID) != videoID || !storage.ValidJobID(videoID) {
+		return fmt.Errorf("ID de vídeo inválido: %s", videoID)
+	}
+	return nil
+}
+
+// ClearFrameCache removes the cached frames of a video.
+func (vs *VideoService) ClearFrameCache(videoID string) error {
+	if err := validateVideoID(videoID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(vs.frameCacheDir(videoID)); err != nil {
+		return fmt.Errorf("failed to clear cached frames of %s: %w", videoID, err)
+	}
+	return nil
+}
+
+// sweepFrameCache removes frames cached before FRAME_CACHE_TTL ago, of every tenant,
+// and the video directories left empty. It returns how many frames were removed.
+func (vs *VideoService) sweepFrameCache(now time.Time) (int, error) {
+	if vs.config.FrameCacheTTL == 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-vs.config.FrameCacheTTL)
+	root := filepath.Join(vs.config.TempDir, frameCacheDirName)
+
+	removed := 0
+	var dirs []string
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if name != root {
+				dirs = append(dirs, name)
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to remove cached frame %s: %v", name, err)
+			return nil
+		}
+		removed++
+		return nil
+	})
+
+	// Deepest first, so tenant directories empty once their videos are gone. Directories
+	// still holding frames fail to be removed and stay.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return removed, err
+}
+
+// frameCacheKey names a cached frame after everything it was rendered from, including
+// the redaction regions of the video.
+func frameCacheKey(videoID string, timestamp float64, format string, width int, redactions []redactionSpec) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d|%+v", videoID, utils.FormatSeconds(timestamp), format, width, redactions)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (vs *VideoService) renderFrame(sourcePath, framePath string, timestamp float64, format string, width int, redactions []redactionSpec) error {
+	if err := utils.ValidatePathSafety(sourcePath, framePath); err != nil {
+		return err
+	}
+
+	absSourcePath, err := filepath.Abs(filepath.Clean(sourcePath))
+	if err != nil {
+		return fmt.Errorf("error resolving video path: %w", err)
+	}
+
+	// Render into a temporary file of its own so concurrent requests never read, or
+	// publish, a partial cache entry.
+	partialPath, err := createPartial(framePath)
+	if err != nil {
+		return err
+	}
+	defer removePartial(partialPath)
+
+	args := []string{
+		"-ss", utils.FormatSeconds(timestamp),
+		"-i", absSourcePath,
+		"-frames:v", "1",
+	}
+
+	scale := ""
+	if width > 0 {
+		scale = "scale=" + strconv.Itoa(width) + ":-2"
+	}
+
+	if graph, output := buildRedactionGraph(redactions, "0:v"); graph != "" {
+		// Keep source timestamps so time-ranged and keyframed regions line up after seeking.
+		if scale != "" {
+			graph += ";[" + output + "]" + scale + "[scaled]"
+			output = "scaled"
+		}
+		args = append([]string{"-copyts"}, args...)
+		args = append(args, "-filter_complex", graph, "-map", "["+output+"]")
+	} else if scale != "" {
+		args = append(args, "-vf", scale)
+	}
+	args = append(args, "-c:v", frameFormats[format].codec, "-f", "image2", "-y", partialPath)
+
+	cmd := exec.Command("ffmpeg", args...) // #nosec G204
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("erro no ffmpeg: %s\nOutput: %s", err.Error(), string(output))
+	}
+
+	if info, err := os.Stat(partialPath); err != nil || info.Size() == 0 {
+		return fmt.Errorf("nenhum frame encontrado no instante %s", utils.FormatSeconds(timestamp))
+	}
+
+	return os.Rename(partialPath, framePath)
+}
+
+// resolveSource finds the retained source for a video ID, downloading it into a local
+// cache when the uploads store does not keep files on disk. Should several uploads
+// carry the ID, the first by key is used, so every request renders from the same one.
+func (vs *VideoService) resolveSource(videoID string) (string, error) {
+	objects, err := vs.config.Uploads.List(videoID + "_")
+	if err != nil {
+		return "", fmt.Errorf("erro ao listar vídeos: %w", err)
+	}
+
+	key := ""
+	for _, object := range objects {
+		jobID, _, ok := storage.SplitUploadName(path.Base(object.Key))
+		if !ok || jobID != videoID {
+			continue
+		}
+		if key == "" || object.Key < key {
+			key = object.Key
+		}
+	}
+	if key == "" {
+		return "", ErrSourceNotFound
+	}
+
+	if localPath, ok := storage.LocalPath(vs.config.Uploads, key); ok {
+		return localPath, nil
+	}
+
+	cacheDir := vs.cacheDir(sourceCacheDirName)
+	if err := utils.SetupTempDirectory(cacheDir); err != nil {
+		return "", err
+	}
+
+	localPath := filepath.Join(cacheDir, path.Base(key))
+	if _, err := os.Stat(localPath); err == nil {
+		// Mark the copy as used, so trimming the cache removes it last.
+		now := time.Now()
+		if err := os.Chtimes(localPath, now, now); err != nil {
+			log.Printf("Warning: Failed to touch cached source %s: %v", localPath, err)
+		}
+		return localPath, nil
+	}
+
+	if err := vs.downloadSource(key, localPath); err != nil {
+		return "", err
+	}
+	if _, err := vs.trimSourceCache(localPath); err != nil {
+		log.Printf("Warning: Failed to trim source cache: %v", err)
+	}
+
+	return localPath, nil
+}
+
+// trimSourceCache removes the least recently used sources copied from remote uploads,
+// of every tenant, until the copies fit in SOURCE_CACHE_MAX_SIZE. The copy just made,
+// keep, is never removed. It returns how many copies were removed.
+func (vs *VideoService) trimSourceCache(keep string) (int, error) {
+	if vs.config.SourceCacheMaxSize == 0 {
+		return 0, nil
+	}
+	root := filepath.Join(vs.config.TempDir, sourceCacheDirName)
+
+	type cachedSource struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var sources []cachedSource
+	var total int64
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		// Partial downloads belong to their writers.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		if name != keep {
+			sources = append(sources, cachedSource{path: name, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].modTime.Before(sources[j].modTime) })
+	removed := 0
+	for _, source := range sources {
+		if total <= vs.config.SourceCacheMaxSize {
+			break
+		}
+		if err := os.Remove(source.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to remove cached source %s: %v", source.path, err)
+			continue
+		}
+		total -= source.size
+		removed++
+	}
+	return removed, nil
+}
+
+func (vs *VideoService) downloadSource(key, localPath string) error {
+	reader, err := vs.config.Uploads.Get(key)
+	if err != nil {
+		return fmt.Errorf("erro ao baixar vídeo: %w", err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage reader: %v", err)
+		}
+	}()
+
+	file, err := os.CreateTemp(filepath.Dir(localPath), partialPattern(localPath))
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	partialPath := file.Name()
+	defer removePartial(partialPath)
+
+	if _, err := io.Copy(file, reader); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("Warning: Failed to close local file: %v", closeErr)
+		}
+		return fmt.Errorf("failed to copy stored content to local file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close local file: %w", err)
+	}
+
+	return os.Rename(partialPath, localPath)
+}
+
+// partialPattern names the temporary files written before target is renamed into place.
+// They are hidden, and unique per writer, so concurrent requests for the same entry
+// never write to or publish each other's file.
+func partialPattern(target string) string {
+	return "." + filepath.Base(target) + ".*.partial"
+}
+
+// createPartial creates an empty temporary file next to target for a command to write.
+func createPartial(target string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(target), partialPattern(target))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// removePartial removes a temporary file that was not renamed into place.
+func removePartial(partialPath string) {
+	if err := os.Remove(partialPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove temporary file %s: %v", partialPath, err)
+	}
+}
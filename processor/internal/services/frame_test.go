@@ -0,0 +1,274 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/config"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFrameTestService(t *testing.T) *VideoService {
+	tempDir := t.TempDir()
+	cfg := &config.ProcessorConfig{
+		Port: "8082",
+		DirectoryConfig: &baseConfig.DirectoryConfig{
+			UploadsDir: filepath.Join(tempDir, "uploads"),
+			OutputsDir: filepath.Join(tempDir, "outputs"),
+			TempDir:    filepath.Join(tempDir, "temp"),
+		},
+	}
+	cfg.Uploads = storage.NewFilesystem(cfg.UploadsDir)
+	cfg.Outputs = storage.NewFilesystem(cfg.OutputsDir)
+	require.NoError(t, os.MkdirAll(cfg.UploadsDir, 0750))
+	return NewVideoService(cfg)
+}
+
+func TestNormalizeFrameFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"", "jpeg", true},
+		{"jpg", "jpeg", true},
+		{"JPEG", "jpeg", true},
+		{"png", "png", true},
+		{"webp", "webp", true},
+		{"gif", "gif", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			format, ok := NormalizeFrameFormat(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestVideoService_ExtractFrame_ReturnsCachedFrame(t *testing.T) {
+	service := newFrameTestService(t)
+
+	cacheDir := filepath.Join(service.config.TempDir, frameCacheDirName, "20240101_120000")
+	require.NoError(t, os.MkdirAll(cacheDir, 0750))
+	cached := filepath.Join(cacheDir, frameCacheKey("20240101_120000", 754.5, "jpeg", 1280, nil)+".jpg")
+	require.NoError(t, os.WriteFile(cached, []byte("jpeg"), 0600))
+
+	framePath, err := service.ExtractFrame(models.FrameRequest{
+		VideoID:   "20240101_120000",
+		Timestamp: 754.5,
+		Format:    "jpg",
+		Width:     1280,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, cached, framePath)
+}
+
+func TestVideoService_ExtractFrame_SourceNotFound(t *testing.T) {
+	service := newFrameTestService(t)
+
+	_, err := service.ExtractFrame(models.FrameRequest{VideoID: "20240101_120000", Timestamp: 1})
+
+	assert.ErrorIs(t, err, ErrSourceNotFound)
+}
+
+func TestVideoService_ExtractFrame_RejectsUnsafeInput(t *testing.T) {
+	service := newFrameTestService(t)
+
+	tests := []models.FrameRequest{
+		{VideoID: "../etc", Timestamp: 1},
+		{VideoID: "id;rm", Timestamp: 1},
+		{VideoID: "20240101_120000_video.mp4", Timestamp: 1},
+		{VideoID: "20240101_120000/..", Timestamp: 1},
+		{VideoID: "20240101_120000", Timestamp: 1, Format: "gif"},
+		{VideoID: "20240101_120000", Timestamp: 1, Width: MaxFrameWidth + 1},
+	}
+
+	for _, req := range tests {
+		_, err := service.ExtractFrame(req)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrSourceNotFound)
+	}
+}
+
+func TestVideoService_ExtractFrame_IgnoresFramesCachedBeforeRedactions(t *testing.T) {
+	service := newFrameTestService(t)
+	cacheDir := service.frameCacheDir("20240101_120000")
+	require.NoError(t, os.MkdirAll(cacheDir, 0750))
+	unredacted := filepath.Join(cacheDir, frameCacheKey("20240101_120000", 1, "jpeg", 0, nil)+".jpg")
+	require.NoError(t, os.WriteFile(unredacted, []byte("jpeg"), 0600))
+	require.NoError(t, service.saveRedactions("20240101_120000", []models.RedactionRegion{{Width: 10, Height: 10}}))
+
+	_, err := service.ExtractFrame(models.FrameRequest{VideoID: "20240101_120000", Timestamp: 1})
+
+	assert.ErrorIs(t, err, ErrSourceNotFound, "the frame is rendered again instead of served unredacted")
+}
+
+func TestFrameCacheKey_IsStablePerRequest(t *testing.T) {
+	a := frameCacheKey("20240101_120000", 754.5, "jpeg", 1280, nil)
+	b := frameCacheKey("20240101_120000", 754.5, "jpeg", 1280, nil)
+	c := frameCacheKey("20240101_120000", 754.5, "png", 1280, nil)
+	redacted, err := parseRedactions([]models.RedactionRegion{{Width: 10, Height: 10}})
+	require.NoError(t, err)
+	d := frameCacheKey("20240101_120000", 754.5, "jpeg", 1280, redacted)
+
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.NotEqual(t, a, d, "frames rendered with other redaction regions are cached apart")
+}
+
+func TestVideoService_ResolveSource_ReadsLocalStorageInPlace(t *testing.T) {
+	service := newFrameTestService(t)
+	sourcePath := filepath.Join(service.config.UploadsDir, "20240101_120000_video.mp4")
+	require.NoError(t, os.WriteFile(sourcePath, []byte("mp4"), 0600))
+
+	resolved, err := service.resolveSource("20240101_120000")
+
+	require.NoError(t, err)
+	assert.Equal(t, sourcePath, resolved)
+}
+
+func TestVideoService_ResolveSource_DownloadsFromRemoteStorage(t *testing.T) {
+	service := newFrameTestService(t)
+	uploads := storage.NewMemory()
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader("mp4"), "video/mp4"))
+	service.config.Uploads = uploads
+
+	resolved, err := service.resolveSource("20240101_120000")
+
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(service.config.TempDir, sourceCacheDirName, "20240101_120000_video.mp4"), resolved)
+	data, err := os.ReadFile(resolved)
+	require.NoError(t, err)
+	assert.Equal(t, "mp4", string(data))
+}
+
+func TestVideoService_ResolveSource_PicksTheSameUploadEveryTime(t *testing.T) {
+	service := newFrameTestService(t)
+	uploads := storage.NewMemory()
+	for _, key := range []string{"20240101_120000_b.mp4", "20240101_120000_a.mp4", "20240101_120000_c.mp4"} {
+		require.NoError(t, uploads.Put(key, strings.NewReader(key), "video/mp4"))
+	}
+	service.config.Uploads = uploads
+
+	resolved, err := service.resolveSource("20240101_120000")
+
+	require.NoError(t, err)
+	assert.Equal(t, "20240101_120000_a.mp4", filepath.Base(resolved))
+}
+
+func TestVideoService_TrimSourceCache_RemovesLeastRecentlyUsedCopies(t *testing.T) {
+	service := newFrameTestService(t)
+	service.config.SourceCacheMaxSize = 10
+	cacheDir := service.cacheDir(sourceCacheDirName)
+	require.NoError(t, os.MkdirAll(cacheDir, 0750))
+	now := time.Now()
+	for i, name := range []string{"oldest.mp4", "older.mp4", "newest.mp4"} {
+		copied := filepath.Join(cacheDir, name)
+		require.NoError(t, os.WriteFile(copied, []byte("12345"), 0600))
+		at := now.Add(time.Duration(i-3) * time.Hour)
+		require.NoError(t, os.Chtimes(copied, at, at))
+	}
+	kept := filepath.Join(cacheDir, "oldest.mp4")
+
+	removed, err := service.trimSourceCache(kept)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	for name, exists := range map[string]bool{"oldest.mp4": true, "older.mp4": false, "newest.mp4": true} {
+		_, err := os.Stat(filepath.Join(cacheDir, name))
+		assert.Equal(t, exists, err == nil, name)
+	}
+}
+
+func TestVideoService_DownloadSource_ConcurrentDownloadsPublishWholeFiles(t *testing.T) {
+	service := newFrameTestService(t)
+	uploads := storage.NewMemory()
+	content := strings.Repeat("mp4", 1<<16)
+	require.NoError(t, uploads.Put("20240101_120000_video.mp4", strings.NewReader(content), "video/mp4"))
+	service.config.Uploads = uploads
+	cacheDir := filepath.Join(service.config.TempDir, sourceCacheDirName)
+	require.NoError(t, os.MkdirAll(cacheDir, 0750))
+	localPath := filepath.Join(cacheDir, "20240101_120000_video.mp4")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, service.downloadSource("20240101_120000_video.mp4", localPath))
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestCreatePartial_IsUniquePerWriter(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "frame.jpg")
+
+	first, err := createPartial(target)
+	require.NoError(t, err)
+	second, err := createPartial(target)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, filepath.Dir(target), filepath.Dir(first), "renames stay on one filesystem")
+	assert.True(t, strings.HasPrefix(filepath.Base(first), ".frame.jpg."))
+}
+
+func TestVideoService_ClearFrameCache_RemovesOnlyThatVideo(t *testing.T) {
+	service := newFrameTestService(t)
+	for _, videoID := range []string{"20240101_120000", "20240102_120000"} {
+		require.NoError(t, os.MkdirAll(service.frameCacheDir(videoID), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(service.frameCacheDir(videoID), "frame.jpg"), []byte("jpeg"), 0600))
+	}
+
+	require.NoError(t, service.ClearFrameCache("20240101_120000"))
+
+	_, err := os.Stat(service.frameCacheDir("20240101_120000"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(service.frameCacheDir("20240102_120000"), "frame.jpg"))
+	assert.NoError(t, err)
+	assert.Error(t, service.ClearFrameCache("../uploads"))
+}
+
+func TestVideoService_SweepExpired_RemovesStaleCachedFrames(t *testing.T) {
+	service := newFrameTestService(t)
+	service.config.FrameCacheTTL = 24 * time.Hour
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	stale := filepath.Join(service.frameCacheDir("20240101_120000"), "stale.jpg")
+	fresh := filepath.Join(service.frameCacheDir("20240102_120000"), "fresh.jpg")
+	for _, frame := range []string{stale, fresh} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(frame), 0750))
+		require.NoError(t, os.WriteFile(frame, []byte("jpeg"), 0600))
+	}
+	require.NoError(t, os.Chtimes(stale, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
+	require.NoError(t, os.Chtimes(fresh, now.Add(-time.Hour), now.Add(-time.Hour)))
+
+	deleted, err := service.SweepExpired(now)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = os.Stat(service.frameCacheDir("20240101_120000"))
+	assert.True(t, os.IsNotExist(err), "emptied video directories are removed")
+	_, err = os.Stat(fresh)
+	assert.NoError(t, err)
+}
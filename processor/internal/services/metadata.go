@@ -0,0 +1,42 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+)
+
+// maxOptionsMetadata keeps the options within S3's 2 KB limit on user metadata; larger
+// option sets (long redaction tracks) are left out rather than failing the upload.
+const maxOptionsMetadata = 1024
+
+// outputMetadata describes a frames archive: where it came from, how it was made and
+// for whom. The API lists these fields alongside each archive.
+func outputMetadata(opts models.ProcessingOptions, frameCount int) map[string]string {
+	metadata := map[string]string{
+		storage.MetaFrameCount: strconv.Itoa(frameCount),
+	}
+	if opts.Source.Name != "" {
+		metadata[storage.MetaOriginalName] = opts.Source.Name
+	}
+	if opts.Source.Hash != "" {
+		metadata[storage.MetaSourceHash] = opts.Source.Hash
+	}
+	if opts.Owner != "" {
+		metadata[storage.MetaOwner] = opts.Owner
+	}
+
+	data, err := json.Marshal(opts)
+	switch {
+	case err != nil:
+		log.Printf("Warning: Failed to encode processing options as metadata: %v", err)
+	case len(data) > maxOptionsMetadata:
+		log.Printf("Warning: Processing options too large for metadata (%d bytes), leaving them out", len(data))
+	case string(data) != "{}":
+		metadata[storage.MetaOptions] = string(data)
+	}
+	return metadata
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"video-processor/internal/checksum"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputMetadata(t *testing.T) {
+	opts := models.ProcessingOptions{
+		Proxy:  true,
+		Owner:  "acme",
+		Source: models.SourceInfo{Name: "entrevista.mp4", Hash: testSourceHash},
+	}
+
+	metadata := outputMetadata(opts, 12)
+
+	assert.Equal(t, map[string]string{
+		storage.MetaOriginalName: "entrevista.mp4",
+		storage.MetaSourceHash:   testSourceHash,
+		storage.MetaFrameCount:   "12",
+		storage.MetaOwner:        "acme",
+		storage.MetaOptions:      `{"proxy":true,"owner":"acme"}`,
+	}, metadata)
+}
+
+func TestOutputMetadata_LeavesOutLargeOptions(t *testing.T) {
+	regions := make([]models.RedactionRegion, 50)
+	for i := range regions {
+		regions[i] = models.RedactionRegion{Width: 10, Height: 10, Style: "pixelate"}
+	}
+
+	metadata := outputMetadata(models.ProcessingOptions{Redactions: regions}, 3)
+
+	assert.NotContains(t, metadata, storage.MetaOptions)
+	assert.NotContains(t, metadata, storage.MetaOriginalName)
+	assert.Equal(t, "3", metadata[storage.MetaFrameCount])
+}
+
+func TestVideoService_createFramesZip_StoresMetadata(t *testing.T) {
+	vs := newFrameTestService(t)
+	frame := filepath.Join(t.TempDir(), "frame_0001.png")
+	require.NoError(t, os.WriteFile(frame, []byte("png"), 0600))
+
+	checksums := map[string]string{}
+	zipPath, err := vs.createFramesZip([]string{frame}, "20240101_120000", map[string]string{storage.MetaOwner: "acme"}, checksums)
+	require.NoError(t, err)
+
+	info, err := vs.config.Outputs.Stat("frames_20240101_120000.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", info.Metadata[storage.MetaOwner])
+
+	sum, err := checksum.File(zipPath)
+	require.NoError(t, err)
+	assert.Equal(t, sum, info.Metadata[storage.MetaChecksum], "the checksum matches the stored bytes")
+	assert.Equal(t, sum, checksums["frames_20240101_120000.zip"])
+
+	objects, err := vs.config.Outputs.List("")
+	require.NoError(t, err)
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	assert.Equal(t, []string{"frames_20240101_120000.zip"}, keys, "the sidecar stays out of listings")
+}
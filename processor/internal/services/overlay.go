@@ -0,0 +1,321 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+	"video-processor/processor/internal/utils"
+)
+
+const (
+	OverlayTopLeft     = "top-left"
+	OverlayTopRight    = "top-right"
+	OverlayBottomLeft  = "bottom-left"
+	OverlayBottomRight = "bottom-right"
+
+	MaxOverlayTextLength = 200
+	MaxOverlayFontSize   = 200
+
+	overlayMargin          = 16
+	overlayDefaultFontSize = 24
+	overlayImageFilename   = "overlay.png"
+	overlayCaptionFilename = "overlay_caption.txt"
+)
+
+type overlaySpec struct {
+	imagePath string
+	textFile  string
+	position  string
+	opacity   float64
+	fontSize  int
+	fontFile  string
+}
+
+// ValidateOverlay checks the per-job overlay settings before any work starts.
+func ValidateOverlay(overlay *models.OverlayOptions) error {
+	if overlay == nil {
+		return nil
+	}
+
+	if overlay.Image != "" {
+		if filepath.IsAbs(overlay.Image) {
+			return fmt.Errorf("overlay: imagem deve ser relativa ao armazenamento de saída")
+		}
+		if err := validateOverlayImageRef(overlay.Image); err != nil {
+			return err
+		}
+	}
+	if len(overlay.Text) > MaxOverlayTextLength {
+		return fmt.Errorf("overlay: texto excede %d caracteres", MaxOverlayTextLength)
+	}
+	if _, err := normalizeOverlayPosition(overlay.Position); err != nil {
+		return err
+	}
+	if overlay.Opacity < 0 || overlay.Opacity > 1 {
+		return fmt.Errorf("overlay: opacidade deve estar entre 0 e 1")
+	}
+	if overlay.FontSize < 0 || overlay.FontSize > MaxOverlayFontSize {
+		return fmt.Errorf("overlay: tamanho de fonte inválido: %d", overlay.FontSize)
+	}
+
+	return nil
+}
+
+func validateOverlayImageRef(ref string) error {
+	if strings.ToLower(filepath.Ext(ref)) != ".png" {
+		return fmt.Errorf("overlay: a imagem deve ser PNG")
+	}
+	if err := utils.ValidateProcessingInputs(ref, ref); err != nil {
+		return err
+	}
+	return utils.ValidatePathSafety(ref)
+}
+
+func normalizeOverlayPosition(position string) (string, error) {
+	position = strings.ToLower(strings.TrimSpace(position))
+	switch position {
+	case "":
+		return OverlayBottomRight, nil
+	case OverlayTopLeft, OverlayTopRight, OverlayBottomLeft, OverlayBottomRight:
+		return position, nil
+	default:
+		return "", fmt.Errorf("overlay: posição inválida %q (use top-left, top-right, bottom-left ou bottom-right)", position)
+	}
+}
+
+// effectiveOverlay merges the configured watermark with the job overrides. It returns
+// nil when neither an image nor a caption would be stamped.
+func (vs *VideoService) effectiveOverlay(job *models.OverlayOptions) *models.OverlayOptions {
+	merged := models.OverlayOptions{
+		Image:    vs.config.OverlayImage,
+		Text:     vs.config.OverlayText,
+		Position: vs.config.OverlayPosition,
+		Opacity:  vs.config.OverlayOpacity,
+	}
+
+	if job != nil {
+		if job.Image != "" {
+			merged.Image = job.Image
+		}
+		if job.Text != "" {
+			merged.Text = job.Text
+		}
+		if job.Position != "" {
+			merged.Position = job.Position
+		}
+		if job.Opacity > 0 {
+			merged.Opacity = job.Opacity
+		}
+		merged.FontSize = job.FontSize
+	}
+
+	if merged.Image == "" && merged.Text == "" {
+		return nil
+	}
+	return &merged
+}
+
+// prepareOverlay fetches the watermark image and renders the caption template into
+// tempDir so both can be handed to ffmpeg as plain files.
+func (vs *VideoService) prepareOverlay(job *models.OverlayOptions, tempDir, videoPath, videoID string) (*overlaySpec, error) {
+	overlay := vs.effectiveOverlay(job)
+	if overlay == nil {
+		return nil, nil
+	}
+
+	position, err := normalizeOverlayPosition(overlay.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &overlaySpec{
+		position: position,
+		opacity:  overlay.Opacity,
+		fontSize: overlay.FontSize,
+		fontFile: vs.config.OverlayFontFile,
+	}
+	if spec.opacity <= 0 || spec.opacity > 1 {
+		spec.opacity = 1
+	}
+	if spec.fontSize == 0 {
+		spec.fontSize = overlayDefaultFontSize
+	}
+	if spec.fontFile != "" {
+		if err := validateFilterPath(spec.fontFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if overlay.Image != "" {
+		if spec.imagePath, err = vs.fetchOverlayImage(overlay.Image, tempDir); err != nil {
+			return nil, fmt.Errorf("erro ao carregar imagem de overlay: %w", err)
+		}
+	}
+
+	if overlay.Text != "" {
+		if spec.textFile, err = filepath.Abs(filepath.Join(tempDir, overlayCaptionFilename)); err != nil {
+			return nil, err
+		}
+		if err := validateFilterPath(spec.textFile); err != nil {
+			return nil, err
+		}
+		caption := renderCaption(overlay.Text, sourceDisplayName(videoPath), videoID)
+		if err := os.WriteFile(filepath.Clean(spec.textFile), []byte(caption), 0600); err != nil {
+			return nil, fmt.Errorf("erro ao gravar legenda do overlay: %w", err)
+		}
+	}
+
+	return spec, nil
+}
+
+// fetchOverlayImage resolves a watermark reference. Absolute paths come only from the
+// operator configuration and are read as-is; relative references are keys in the outputs store.
+func (vs *VideoService) fetchOverlayImage(ref, tempDir string) (string, error) {
+	if err := validateOverlayImageRef(ref); err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(ref) {
+		if _, err := os.Stat(ref); err != nil {
+			return "", err
+		}
+		return filepath.Clean(ref), nil
+	}
+
+	key := strings.TrimPrefix(ref, "/")
+	if imagePath, ok := storage.LocalPath(vs.config.Outputs, key); ok {
+		if _, err := os.Stat(imagePath); err != nil {
+			return "", err
+		}
+		return imagePath, nil
+	}
+
+	reader, err := vs.config.Outputs.Get(key)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage reader: %v", err)
+		}
+	}()
+
+	localPath := filepath.Join(tempDir, overlayImageFilename)
+	file, err := os.Create(filepath.Clean(localPath))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("Warning: Failed to close overlay image: %v", closeErr)
+		}
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	return localPath, nil
+}
+
+// renderCaption expands the text template. Literal text is escaped for drawtext's
+// expansion syntax and {timecode} becomes the frame's presentation time.
+func renderCaption(template, source, videoID string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`)
+	caption := escape.Replace(template)
+
+	return strings.NewReplacer(
+		"{source}", escape.Replace(source),
+		"{video_id}", escape.Replace(videoID),
+		"{timecode}", "%{pts:hms}",
+	).Replace(caption)
+}
+
+// sourceDisplayName strips the temp_ and video ID prefixes the services add to uploads.
+func sourceDisplayName(videoPath string) string {
+	name := strings.TrimPrefix(filepath.Base(videoPath), "temp_")
+	for {
+		_, filename, ok := storage.SplitUploadName(name)
+		if !ok {
+			return name
+		}
+		name = filename
+	}
+}
+
+// validateFilterPath rejects paths that could break out of a quoted filtergraph value.
+func validateFilterPath(path string) error {
+	if strings.ContainsAny(path, `'\:,`) {
+		return fmt.Errorf("invalid characters in file path")
+	}
+	return utils.ValidatePathSafety(path)
+}
+
+// buildOverlayGraph stamps the watermark (read from imageInput) and the caption onto
+// the input label and returns the graph with its final output label.
+func buildOverlayGraph(spec *overlaySpec, input, imageInput string) (graph, output string) {
+	if spec == nil {
+		return "", input
+	}
+
+	var parts []string
+	current := input
+	right := strings.HasSuffix(spec.position, "right")
+	bottom := strings.HasPrefix(spec.position, "bottom")
+	opacity := fmt.Sprintf("%.2f", spec.opacity)
+
+	if spec.imagePath != "" {
+		x, y := fmt.Sprint(overlayMargin), fmt.Sprint(overlayMargin)
+		if right {
+			x = fmt.Sprintf("W-w-%d", overlayMargin)
+		}
+		if bottom {
+			y = fmt.Sprintf("H-h-%d", overlayMargin)
+		}
+		parts = append(parts,
+			fmt.Sprintf("[%s]format=rgba,colorchannelmixer=aa=%s[wm]", imageInput, opacity),
+			fmt.Sprintf("[%s][wm]overlay=x=%s:y=%s[wmo]", current, x, y),
+		)
+		current = "wmo"
+		// Keep the caption clear of the watermark by moving it to the opposite edge.
+		bottom = !bottom
+	}
+
+	if spec.textFile != "" {
+		x, y := fmt.Sprint(overlayMargin), fmt.Sprint(overlayMargin)
+		if right {
+			x = fmt.Sprintf("w-text_w-%d", overlayMargin)
+		}
+		if bottom {
+			y = fmt.Sprintf("h-text_h-%d", overlayMargin)
+		}
+		font := ""
+		if spec.fontFile != "" {
+			font = ":fontfile='" + spec.fontFile + "'"
+		}
+		parts = append(parts, fmt.Sprintf(
+			"[%s]drawtext=textfile='%s':expansion=normal%s:fontsize=%d:fontcolor=white@%s:box=1:boxcolor=black@%.2f:boxborderw=8:x=%s:y=%s[txt]",
+			current, spec.textFile, font, spec.fontSize, opacity, spec.opacity/2, x, y))
+		current = "txt"
+	}
+
+	return strings.Join(parts, ";"), current
+}
+
+func (spec *overlaySpec) manifest() models.ManifestOverlay {
+	if spec == nil {
+		return models.ManifestOverlay{}
+	}
+	return models.ManifestOverlay{
+		Applied:  true,
+		Image:    spec.imagePath != "",
+		Text:     spec.textFile != "",
+		Position: spec.position,
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOverlay(t *testing.T) {
+	tests := []struct {
+		name      string
+		overlay   *models.OverlayOptions
+		expectErr bool
+	}{
+		{name: "nil", overlay: nil},
+		{name: "text only", overlay: &models.OverlayOptions{Text: "© agency {timecode}", Position: "top-left", Opacity: 0.5}},
+		{name: "bucket image", overlay: &models.OverlayOptions{Image: "branding/logo.png"}},
+		{name: "absolute image", overlay: &models.OverlayOptions{Image: "/etc/logo.png"}, expectErr: true},
+		{name: "traversal", overlay: &models.OverlayOptions{Image: "../logo.png"}, expectErr: true},
+		{name: "not png", overlay: &models.OverlayOptions{Image: "logo.jpg"}, expectErr: true},
+		{name: "shell characters", overlay: &models.OverlayOptions{Image: "logo;rm.png"}, expectErr: true},
+		{name: "bad position", overlay: &models.OverlayOptions{Text: "x", Position: "center"}, expectErr: true},
+		{name: "opacity above one", overlay: &models.OverlayOptions{Text: "x", Opacity: 1.5}, expectErr: true},
+		{name: "font too large", overlay: &models.OverlayOptions{Text: "x", FontSize: 500}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOverlay(tt.overlay)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVideoService_EffectiveOverlay(t *testing.T) {
+	service := newFrameTestService(t)
+	assert.Nil(t, service.effectiveOverlay(nil))
+
+	service.config.OverlayText = "© agency"
+	service.config.OverlayPosition = OverlayBottomRight
+	service.config.OverlayOpacity = 0.8
+
+	merged := service.effectiveOverlay(&models.OverlayOptions{Position: OverlayTopLeft, FontSize: 32})
+	require.NotNil(t, merged)
+	assert.Equal(t, "© agency", merged.Text)
+	assert.Equal(t, OverlayTopLeft, merged.Position)
+	assert.Equal(t, 0.8, merged.Opacity)
+	assert.Equal(t, 32, merged.FontSize)
+}
+
+func TestRenderCaption(t *testing.T) {
+	caption := renderCaption("{source} @ {timecode} - 100% {video_id}", `clip\1.mp4`, "20240101_120000")
+	assert.Equal(t, `clip\\1.mp4 @ %{pts:hms} - 100\% 20240101_120000`, caption)
+}
+
+func TestSourceDisplayName(t *testing.T) {
+	assert.Equal(t, "video.mp4", sourceDisplayName("uploads/20240101_120000_video.mp4"))
+	assert.Equal(t, "video.mp4", sourceDisplayName("temp/temp_20240101_130000_20240101_120000_video.mp4"))
+	assert.Equal(t, "video.mp4", sourceDisplayName("temp/temp_20240101_130000-0a1b2c3d_20240101_120000-0a1b2c3d_video.mp4"))
+	assert.Equal(t, "holiday_2024.mp4", sourceDisplayName("holiday_2024.mp4"))
+}
+
+func TestBuildOverlayGraph(t *testing.T) {
+	graph, output := buildOverlayGraph(nil, "sampled", "1:v")
+	assert.Empty(t, graph)
+	assert.Equal(t, "sampled", output)
+
+	spec := &overlaySpec{
+		imagePath: "/tmp/logo.png",
+		textFile:  "/tmp/caption.txt",
+		position:  OverlayBottomRight,
+		opacity:   0.5,
+		fontSize:  24,
+	}
+	graph, output = buildOverlayGraph(spec, "sampled", "1:v")
+
+	assert.Equal(t, "txt", output)
+	assert.Contains(t, graph, "[1:v]format=rgba,colorchannelmixer=aa=0.50[wm]")
+	assert.Contains(t, graph, "[sampled][wm]overlay=x=W-w-16:y=H-h-16[wmo]")
+	// The caption moves to the top edge so it never covers the watermark.
+	assert.Contains(t, graph, "[wmo]drawtext=textfile='/tmp/caption.txt':expansion=normal:fontsize=24:fontcolor=white@0.50")
+	assert.Contains(t, graph, "x=w-text_w-16:y=16[txt]")
+}
+
+func TestVideoService_FetchOverlayImage_Filesystem(t *testing.T) {
+	service := newFrameTestService(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(service.config.OutputsDir, "branding"), 0750))
+	logo := filepath.Join(service.config.OutputsDir, "branding", "logo.png")
+	require.NoError(t, os.WriteFile(logo, []byte("png"), 0600))
+
+	path, err := service.fetchOverlayImage("branding/logo.png", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, logo, path)
+
+	_, err = service.fetchOverlayImage("branding/missing.png", t.TempDir())
+	assert.Error(t, err)
+
+	_, err = service.fetchOverlayImage("../outside.png", t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestVideoService_PrepareOverlay_WritesCaption(t *testing.T) {
+	service := newFrameTestService(t)
+	tempDir := t.TempDir()
+
+	spec, err := service.prepareOverlay(&models.OverlayOptions{Text: "{source}"}, tempDir, "uploads/20240101_120000_video.mp4", "20240101_120000")
+	require.NoError(t, err)
+	require.NotNil(t, spec)
+	assert.Equal(t, OverlayBottomRight, spec.position)
+	assert.Equal(t, overlayDefaultFontSize, spec.fontSize)
+
+	data, err := os.ReadFile(spec.textFile)
+	require.NoError(t, err)
+	assert.Equal(t, "video.mp4", string(data))
+
+	manifest := spec.manifest()
+	assert.True(t, manifest.Applied)
+	assert.True(t, manifest.Text)
+	assert.False(t, manifest.Image)
+}
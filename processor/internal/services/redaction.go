@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+	"video-processor/processor/internal/utils"
+)
+
+const (
+	RedactionStyleBlur     = "blur"
+	RedactionStylePixelate = "pixelate"
+	RedactionStyleFill     = "fill"
+
+	MaxRedactionRegions   = 50
+	MaxRedactionKeyframes = 500
+
+	redactionsDirName = "redactions"
+	pixelateBlockSize = 16
+)
+
+var redactionColorPattern = regexp.MustCompile(`^(#?[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
+
+type redactionKeyframe struct {
+	at   float64
+	x, y int
+}
+
+type redactionSpec struct {
+	x, y, width, height int
+	start, end          float64
+	hasStart, hasEnd    bool
+	style               string
+	color               string
+	keyframes           []redactionKeyframe
+}
+
+// ValidateRedactions parses and checks the requested redaction regions.
+func ValidateRedactions(regions []models.RedactionRegion) error {
+	_, err := parseRedactions(regions)
+	return err
+}
+
+func parseRedactions(regions []models.RedactionRegion) ([]redactionSpec, error) {
+	if len(regions) > MaxRedactionRegions {
+		return nil, fmt.Errorf("máximo de %d regiões de redação por processamento", MaxRedactionRegions)
+	}
+
+	specs := make([]redactionSpec, 0, len(regions))
+	for i, region := range regions {
+		spec, err := parseRedaction(region)
+		if err != nil {
+			return nil, fmt.Errorf("redação %d: %w", i+1, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+func parseRedaction(region models.RedactionRegion) (redactionSpec, error) {
+	spec := redactionSpec{
+		x:      region.X,
+		y:      region.Y,
+		width:  region.Width,
+		height: region.Height,
+		style:  strings.ToLower(strings.TrimSpace(region.Style)),
+		color:  strings.TrimSpace(region.Color),
+	}
+
+	if spec.width <= 0 || spec.height <= 0 {
+		return spec, fmt.Errorf("largura e altura devem ser positivas")
+	}
+	if spec.x < 0 || spec.y < 0 {
+		return spec, fmt.Errorf("posição não pode ser negativa")
+	}
+
+	switch spec.style {
+	case "":
+		spec.style = RedactionStyleBlur
+	case RedactionStyleBlur, RedactionStylePixelate, RedactionStyleFill:
+	default:
+		return spec, fmt.Errorf("estilo inválido %q (use blur, pixelate ou fill)", region.Style)
+	}
+
+	if spec.color == "" {
+		spec.color = "black"
+	}
+	if !redactionColorPattern.MatchString(spec.color) {
+		return spec, fmt.Errorf("cor inválida %q", region.Color)
+	}
+
+	var err error
+	if region.Start != "" {
+		if spec.start, err = utils.ParseTimestamp(region.Start); err != nil {
+			return spec, fmt.Errorf("início inválido: %w", err)
+		}
+		spec.hasStart = true
+	}
+	if region.End != "" {
+		if spec.end, err = utils.ParseTimestamp(region.End); err != nil {
+			return spec, fmt.Errorf("fim inválido: %w", err)
+		}
+		spec.hasEnd = true
+	}
+	if spec.hasStart && spec.hasEnd && spec.end <= spec.start {
+		return spec, fmt.Errorf("fim deve ser maior que o início")
+	}
+
+	if len(region.Keyframes) > MaxRedactionKeyframes {
+		return spec, fmt.Errorf("máximo de %d keyframes por região", MaxRedactionKeyframes)
+	}
+	for _, kf := range region.Keyframes {
+		at, err := utils.ParseTimestamp(kf.At)
+		if err != nil {
+			return spec, fmt.Errorf("keyframe inválido: %w", err)
+		}
+		if kf.X < 0 || kf.Y < 0 {
+			return spec, fmt.Errorf("posição do keyframe não pode ser negativa")
+		}
+		spec.keyframes = append(spec.keyframes, redactionKeyframe{at: at, x: kf.X, y: kf.Y})
+	}
+	sort.SliceStable(spec.keyframes, func(a, b int) bool {
+		return spec.keyframes[a].at < spec.keyframes[b].at
+	})
+
+	return spec, nil
+}
+
+// xExpr and yExpr return ffmpeg expressions for the box position, linearly
+// interpolating between keyframes and holding the first/last value outside them.
+func (s redactionSpec) xExpr() string {
+	return s.positionExpr(s.x, func(kf redactionKeyframe) int { return kf.x })
+}
+
+func (s redactionSpec) yExpr() string {
+	return s.positionExpr(s.y, func(kf redactionKeyframe) int { return kf.y })
+}
+
+func (s redactionSpec) positionExpr(static int, value func(redactionKeyframe) int) string {
+	if len(s.keyframes) == 0 {
+		return strconv.Itoa(static)
+	}
+
+	last := s.keyframes[len(s.keyframes)-1]
+	expr := strconv.Itoa(value(last))
+	for i := len(s.keyframes) - 2; i >= 0; i-- {
+		from, to := s.keyframes[i], s.keyframes[i+1]
+		if to.at == from.at {
+			continue
+		}
+		lerp := fmt.Sprintf("%d+(%d)*(t-%s)/%s",
+			value(from), value(to)-value(from), utils.FormatSeconds(from.at), utils.FormatSeconds(to.at-from.at))
+		expr = fmt.Sprintf("if(lt(t,%s),%s,%s)", utils.FormatSeconds(to.at), lerp, expr)
+	}
+
+	first := s.keyframes[0]
+	return fmt.Sprintf("if(lt(t,%s),%d,%s)", utils.FormatSeconds(first.at), value(first), expr)
+}
+
+func (s redactionSpec) enableExpr() string {
+	switch {
+	case s.hasStart && s.hasEnd:
+		return fmt.Sprintf("between(t,%s,%s)", utils.FormatSeconds(s.start), utils.FormatSeconds(s.end))
+	case s.hasStart:
+		return fmt.Sprintf("gte(t,%s)", utils.FormatSeconds(s.start))
+	case s.hasEnd:
+		return fmt.Sprintf("lte(t,%s)", utils.FormatSeconds(s.end))
+	default:
+		return ""
+	}
+}
+
+// buildRedactionGraph chains one filter per region starting from the input label and
+// returns the graph together with the label of its final output.
+func buildRedactionGraph(specs []redactionSpec, input string) (graph, output string) {
+	var parts []string
+	current := input
+
+	for i, spec := range specs {
+		next := fmt.Sprintf("r%d", i)
+		enable := ""
+		if expr := spec.enableExpr(); expr != "" {
+			enable = ":enable='" + expr + "'"
+		}
+
+		if spec.style == RedactionStyleFill {
+			parts = append(parts, fmt.Sprintf("[%s]drawbox=x='%s':y='%s':w=%d:h=%d:color=%s@1:t=fill%s[%s]",
+				current, spec.xExpr(), spec.yExpr(), spec.width, spec.height, spec.color, enable, next))
+			current = next
+			continue
+		}
+
+		effect := "boxblur=luma_radius='min(w,h)/5':luma_power=3:chroma_radius='min(cw,ch)/5':chroma_power=3"
+		if spec.style == RedactionStylePixelate {
+			effect = fmt.Sprintf("scale='max(1,iw/%d)':'max(1,ih/%d)',scale=%d:%d:flags=neighbor",
+				pixelateBlockSize, pixelateBlockSize, spec.width, spec.height)
+		}
+
+		parts = append(parts,
+			fmt.Sprintf("[%s]split[%s_base][%s_src]", current, next, next),
+			fmt.Sprintf("[%s_src]crop=w=%d:h=%d:x='%s':y='%s',%s[%s_fx]",
+				next, spec.width, spec.height, spec.xExpr(), spec.yExpr(), effect, next),
+			fmt.Sprintf("[%s_base][%s_fx]overlay=x='%s':y='%s'%s[%s]",
+				next, next, spec.xExpr(), spec.yExpr(), enable, next),
+		)
+		current = next
+	}
+
+	return strings.Join(parts, ";"), current
+}
+
+// saveRedactions keeps a job's redaction regions next to its retained source so
+// on-demand frames are redacted the same way as the extracted archive.
+func (vs *VideoService) saveRedactions(videoID string, regions []models.RedactionRegion) error {
+	data, err := json.Marshal(regions)
+	if err != nil {
+		return err
+	}
+
+	return vs.config.Uploads.Put(redactionsKey(videoID), bytes.NewReader(data), "application/json")
+}
+
+// loadRedactions returns the regions stored for a video. A missing sidecar means no
+// redaction; an unreadable one is an error so frames never leave unredacted by accident.
+func (vs *VideoService) loadRedactions(videoID string) ([]redactionSpec, error) {
+	reader, err := vs.config.Uploads.Get(redactionsKey(videoID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Warning: Failed to close storage reader: %v", err)
+		}
+	}()
+
+	var regions []models.RedactionRegion
+	if err := json.NewDecoder(reader).Decode(&regions); err != nil {
+		return nil, fmt.Errorf("redações armazenadas inválidas para %s: %w", videoID, err)
+	}
+
+	return parseRedactions(regions)
+}
+
+func redactionsKey(videoID string) string {
+	return redactionsDirName + "/" + videoID + ".json"
+}
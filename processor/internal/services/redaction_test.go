@@ -0,0 +1,131 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedactions_Validation(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    models.RedactionRegion
+		expectErr string
+	}{
+		{name: "valid static blur", region: models.RedactionRegion{X: 10, Y: 20, Width: 100, Height: 50}},
+		{name: "valid fill with hex color", region: models.RedactionRegion{Width: 10, Height: 10, Style: "fill", Color: "#FF0000"}},
+		{name: "zero size", region: models.RedactionRegion{Width: 0, Height: 10}, expectErr: "largura e altura"},
+		{name: "negative position", region: models.RedactionRegion{X: -1, Width: 10, Height: 10}, expectErr: "negativa"},
+		{name: "unknown style", region: models.RedactionRegion{Width: 10, Height: 10, Style: "emoji"}, expectErr: "estilo inválido"},
+		{name: "color injection", region: models.RedactionRegion{Width: 10, Height: 10, Style: "fill", Color: "red:t=1"}, expectErr: "cor inválida"},
+		{name: "end before start", region: models.RedactionRegion{Width: 10, Height: 10, Start: "10", End: "5"}, expectErr: "fim deve ser maior"},
+		{name: "bad keyframe", region: models.RedactionRegion{Width: 10, Height: 10, Keyframes: []models.RedactionKeyframe{{At: "x"}}}, expectErr: "keyframe inválido"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRedactions([]models.RedactionRegion{tt.region})
+			if tt.expectErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectErr)
+		})
+	}
+}
+
+func TestRedactionSpec_PositionExpr(t *testing.T) {
+	static := redactionSpec{x: 10, y: 20}
+	assert.Equal(t, "10", static.xExpr())
+	assert.Equal(t, "20", static.yExpr())
+
+	moving := redactionSpec{keyframes: []redactionKeyframe{{at: 0, x: 0, y: 0}, {at: 2, x: 100, y: 50}}}
+	assert.Equal(t, "if(lt(t,0.000),0,if(lt(t,2.000),0+(100)*(t-0.000)/2.000,100))", moving.xExpr())
+	assert.Equal(t, "if(lt(t,0.000),0,if(lt(t,2.000),0+(50)*(t-0.000)/2.000,50))", moving.yExpr())
+}
+
+func TestParseRedactions_SortsKeyframes(t *testing.T) {
+	specs, err := parseRedactions([]models.RedactionRegion{{
+		Width: 10, Height: 10,
+		Keyframes: []models.RedactionKeyframe{{At: "5", X: 50}, {At: "1", X: 10}},
+	}})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, specs[0].keyframes[0].at)
+	assert.Equal(t, 5.0, specs[0].keyframes[1].at)
+}
+
+func TestRedactionSpec_EnableExpr(t *testing.T) {
+	assert.Equal(t, "", redactionSpec{}.enableExpr())
+	assert.Equal(t, "between(t,1.000,2.500)", redactionSpec{start: 1, end: 2.5, hasStart: true, hasEnd: true}.enableExpr())
+	assert.Equal(t, "gte(t,1.000)", redactionSpec{start: 1, hasStart: true}.enableExpr())
+	assert.Equal(t, "lte(t,3.000)", redactionSpec{end: 3, hasEnd: true}.enableExpr())
+}
+
+func TestBuildRedactionGraph(t *testing.T) {
+	graph, output := buildRedactionGraph(nil, "0:v")
+	assert.Empty(t, graph)
+	assert.Equal(t, "0:v", output)
+
+	specs := []redactionSpec{
+		{x: 1, y: 2, width: 30, height: 40, style: RedactionStyleFill, color: "black"},
+		{x: 5, y: 6, width: 70, height: 80, style: RedactionStyleBlur, start: 1, end: 2, hasStart: true, hasEnd: true},
+	}
+
+	graph, output = buildRedactionGraph(specs, "0:v")
+
+	assert.Equal(t, "r1", output)
+	assert.Contains(t, graph, "[0:v]drawbox=x='1':y='2':w=30:h=40:color=black@1:t=fill[r0]")
+	assert.Contains(t, graph, "[r0]split[r1_base][r1_src]")
+	assert.Contains(t, graph, "[r1_src]crop=w=70:h=80:x='5':y='6',boxblur=")
+	assert.Contains(t, graph, "[r1_base][r1_fx]overlay=x='5':y='6':enable='between(t,1.000,2.000)'[r1]")
+}
+
+func TestVideoService_SaveAndLoadRedactions(t *testing.T) {
+	service := newFrameTestService(t)
+
+	specs, err := service.loadRedactions("20240101_120000")
+	require.NoError(t, err)
+	assert.Nil(t, specs)
+
+	regions := []models.RedactionRegion{{X: 1, Y: 2, Width: 3, Height: 4, Style: "pixelate"}}
+	require.NoError(t, service.saveRedactions("20240101_120000", regions))
+
+	specs, err = service.loadRedactions("20240101_120000")
+	require.NoError(t, err)
+	require.Len(t, specs, 1)
+	assert.Equal(t, RedactionStylePixelate, specs[0].style)
+
+	corrupt := filepath.Join(service.config.UploadsDir, redactionsDirName, "20240101_130000.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0600))
+	_, err = service.loadRedactions("20240101_130000")
+	assert.Error(t, err)
+}
+
+func TestVideoService_WriteManifest_RecordsRedaction(t *testing.T) {
+	service := newFrameTestService(t)
+	tempDir := t.TempDir()
+
+	specs := []redactionSpec{{style: RedactionStyleBlur}, {style: RedactionStyleBlur}, {style: RedactionStyleFill}}
+	manifestPath, err := service.writeManifest(tempDir, "20240101_120000", []string{filepath.Join(tempDir, "frame_0001.png")}, specs, nil)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+
+	var manifest models.Manifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, "20240101_120000", manifest.VideoID)
+	assert.Equal(t, 1, manifest.FrameCount)
+	assert.Equal(t, []string{"frame_0001.png"}, manifest.Frames)
+	assert.True(t, manifest.Redaction.Applied)
+	assert.Equal(t, 3, manifest.Redaction.Regions)
+	assert.Equal(t, []string{RedactionStyleBlur, RedactionStyleFill}, manifest.Redaction.Styles)
+}
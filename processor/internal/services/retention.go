@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/retention"
+	"video-processor/internal/storage"
+	"video-processor/internal/trash"
+	"video-processor/processor/internal/models"
+)
+
+// ValidateRetention checks the per-job source TTL.
+func ValidateRetention(opts models.ProcessingOptions) error {
+	if opts.SourceTTL == "" {
+		return nil
+	}
+	ttl, err := baseConfig.ParseTTL(opts.SourceTTL)
+	if err != nil || ttl <= 0 {
+		return fmt.Errorf("source_ttl inválido: %q (use, por exemplo, 72h ou 7d)", opts.SourceTTL)
+	}
+	return nil
+}
+
+// RetainsSource reports whether a job keeps its source video after processing.
+func (vs *VideoService) RetainsSource(opts models.ProcessingOptions) bool {
+	return vs.config.RetainSources || opts.RetainSource || opts.SourceTTL != ""
+}
+
+// RecordRetention registers a retained source so the sweeper can expire it.
+func (vs *VideoService) RecordRetention(key, videoID string, opts models.ProcessingOptions, now time.Time) error {
+	record := retention.Record{Key: key, VideoID: videoID, Tenant: vs.tenant, RetainedAt: now}
+	if opts.SourceTTL != "" {
+		ttl, err := baseConfig.ParseTTL(opts.SourceTTL)
+		if err != nil {
+			return err
+		}
+		record.ExpiresAt = now.Add(ttl)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return vs.config.Uploads.Put(retention.Key(vs.tenant, key), bytes.NewReader(data), "application/json")
+}
+
+// SweepExpired deletes retained sources past their expiry, videos kept in the trash past
+// theirs, frames cached longer than FRAME_CACHE_TTL and, when OUTPUT_RETENTION is set,
+// outputs older than it. It returns how many sources, trashed videos, cached frames and
+// outputs were deleted.
+func (vs *VideoService) SweepExpired(now time.Time) (int, error) {
+	sources, err := vs.sweepSources(now)
+	if err != nil {
+		return sources, err
+	}
+	trashed, err := trash.Purge(vs.config.Outputs, now)
+	if err != nil {
+		return sources + trashed, err
+	}
+	frames, err := vs.sweepFrameCache(now)
+	if err != nil {
+		return sources + trashed + frames, err
+	}
+	outputs, err := vs.sweepOutputs(now)
+	return sources + trashed + frames + outputs, err
+}
+
+func (vs *VideoService) sweepSources(now time.Time) (int, error) {
+	records, err := vs.config.Uploads.List(retention.Prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list retained sources: %w", err)
+	}
+
+	deleted := 0
+	for _, object := range records {
+		record, err := retention.Load(vs.config.Uploads, object.Key)
+		if err != nil {
+			log.Printf("Warning: Failed to read retention record %s: %v", object.Key, err)
+			continue
+		}
+
+		expiresAt := record.ExpiresAt
+		if expiresAt.IsZero() {
+			if vs.config.SourceTTL == 0 {
+				continue
+			}
+			expiresAt = record.RetainedAt.Add(vs.config.SourceTTL)
+		}
+		if now.Before(expiresAt) {
+			continue
+		}
+
+		if err := vs.expireSource(record); err != nil {
+			log.Printf("Warning: Failed to expire source %s: %v", record.Key, err)
+			continue
+		}
+		if err := deleteIfExists(vs.config.Uploads, object.Key); err != nil {
+			log.Printf("Warning: Failed to delete retention record %s: %v", object.Key, err)
+		}
+		log.Printf("Expired retained source %s", record.Key)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// expireSource removes a retained source with its redaction sidecar, local copy and
+// cached frames, in the stores of the tenant that retained it.
+func (vs *VideoService) expireSource(record *retention.Record) error {
+	owner := vs.ForTenant(record.Tenant)
+	if err := deleteIfExists(owner.config.Uploads, record.Key); err != nil {
+		return err
+	}
+	if record.VideoID != "" {
+		if err := deleteIfExists(owner.config.Uploads, redactionsKey(record.VideoID)); err != nil {
+			return err
+		}
+	}
+
+	cached := filepath.Join(owner.cacheDir(sourceCacheDirName), path.Base(record.Key))
+	if err := os.Remove(cached); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove cached source %s: %v", cached, err)
+	}
+	if record.VideoID != "" {
+		if err := owner.ClearFrameCache(record.VideoID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
+
+// sweepOutputs deletes outputs last written before OUTPUT_RETENTION ago. Indexes are left
+// alone: dedup entries whose archive is gone are dropped the next time they match, and
+// the trash keeps its own retention.
+// Tenants sharing the outputs bucket are swept with it; buckets of their own are left to
+// bucket lifecycle rules.
+func (vs *VideoService) sweepOutputs(now time.Time) (int, error) {
+	if vs.config.OutputTTL == 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-vs.config.OutputTTL)
+
+	objects, err := vs.config.Outputs.List("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list outputs: %w", err)
+	}
+
+	deleted := 0
+	for _, object := range objects {
+		if strings.HasPrefix(object.Key, ".") || !object.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := deleteIfExists(vs.config.Outputs, object.Key); err != nil {
+			log.Printf("Warning: Failed to expire output %s: %v", object.Key, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Printf("Expired %d outputs older than %s", deleted, vs.config.OutputTTL)
+	}
+	return deleted, nil
+}
+
+func deleteIfExists(store storage.Storage, key string) error {
+	if err := store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return nil
+}
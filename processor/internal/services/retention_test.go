@@ -0,0 +1,145 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"video-processor/internal/dedup"
+	"video-processor/internal/retention"
+	"video-processor/internal/storage"
+	"video-processor/internal/trash"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRetention(t *testing.T) {
+	assert.NoError(t, ValidateRetention(models.ProcessingOptions{}))
+	assert.NoError(t, ValidateRetention(models.ProcessingOptions{SourceTTL: "72h"}))
+	assert.NoError(t, ValidateRetention(models.ProcessingOptions{SourceTTL: "7d"}))
+	assert.Error(t, ValidateRetention(models.ProcessingOptions{SourceTTL: "0"}))
+	assert.Error(t, ValidateRetention(models.ProcessingOptions{SourceTTL: "-1h"}))
+	assert.Error(t, ValidateRetention(models.ProcessingOptions{SourceTTL: "soon"}))
+}
+
+func TestVideoService_RetainsSource(t *testing.T) {
+	vs := newFrameTestService(t)
+
+	assert.False(t, vs.RetainsSource(models.ProcessingOptions{}))
+	assert.True(t, vs.RetainsSource(models.ProcessingOptions{RetainSource: true}))
+	assert.True(t, vs.RetainsSource(models.ProcessingOptions{SourceTTL: "1h"}))
+
+	vs.config.RetainSources = true
+	assert.True(t, vs.RetainsSource(models.ProcessingOptions{}))
+}
+
+func TestVideoService_SweepExpired_ExpiresRetainedSources(t *testing.T) {
+	for name, uploads := range map[string]storage.Storage{"filesystem": storage.NewFilesystem(t.TempDir()), "memory": storage.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			vs := newFrameTestService(t)
+			vs.config.Uploads = uploads
+			vs.config.SourceTTL = 30 * 24 * time.Hour
+			retainedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			for _, key := range []string{"20240101_120000_short.mp4", "20240101_120001_global.mp4", "20240101_120002_long.mp4"} {
+				require.NoError(t, uploads.Put(key, strings.NewReader("video"), "video/mp4"))
+			}
+			require.NoError(t, uploads.Put(redactionsKey("20240101_120000"), strings.NewReader("[]"), "application/json"))
+			require.NoError(t, os.MkdirAll(vs.frameCacheDir("20240101_120000"), 0750))
+
+			require.NoError(t, vs.RecordRetention("20240101_120000_short.mp4", "20240101_120000", models.ProcessingOptions{SourceTTL: "2d"}, retainedAt))
+			require.NoError(t, vs.RecordRetention("20240101_120001_global.mp4", "20240101_120001", models.ProcessingOptions{}, retainedAt))
+			require.NoError(t, vs.RecordRetention("20240101_120002_long.mp4", "20240101_120002", models.ProcessingOptions{SourceTTL: "90d"}, retainedAt))
+
+			deleted, err := vs.SweepExpired(retainedAt.Add(24 * time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 0, deleted)
+
+			deleted, err = vs.SweepExpired(retainedAt.Add(3 * 24 * time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+			_, err = uploads.Stat("20240101_120000_short.mp4")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			_, err = uploads.Stat(redactionsKey("20240101_120000"))
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			_, err = os.Stat(vs.frameCacheDir("20240101_120000"))
+			assert.True(t, os.IsNotExist(err), "cached frames go with the source")
+
+			deleted, err = vs.SweepExpired(retainedAt.Add(31 * 24 * time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+			_, err = uploads.Stat("20240101_120001_global.mp4")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+
+			_, err = uploads.Stat("20240101_120002_long.mp4")
+			assert.NoError(t, err)
+			records, err := uploads.List(retention.Prefix)
+			require.NoError(t, err)
+			assert.Len(t, records, 1)
+		})
+	}
+}
+
+func TestVideoService_SweepExpired_KeepsSourcesWithoutTTL(t *testing.T) {
+	vs := newFrameTestService(t)
+	vs.config.Uploads = storage.NewMemory()
+	require.NoError(t, vs.config.Uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), "video/mp4"))
+	require.NoError(t, vs.RecordRetention("20240101_120000_video.mp4", "20240101_120000", models.ProcessingOptions{}, time.Now()))
+
+	deleted, err := vs.SweepExpired(time.Now().Add(10 * 365 * 24 * time.Hour))
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+func TestVideoService_SweepExpired_ExpiresOldOutputs(t *testing.T) {
+	vs := newFrameTestService(t)
+	vs.config.OutputTTL = 24 * time.Hour
+	now := time.Now()
+
+	for _, key := range []string{"frames_old.zip", "hls_old/master.m3u8", "frames_new.zip", dedup.Prefix + "entries/d1.json"} {
+		require.NoError(t, vs.config.Outputs.Put(key, strings.NewReader("data"), ""))
+	}
+	for _, key := range []string{"frames_old.zip", "hls_old/master.m3u8", dedup.Prefix + "entries/d1.json"} {
+		old := now.Add(-48 * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(vs.config.OutputsDir, key), old, old))
+	}
+
+	deleted, err := vs.SweepExpired(now)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	objects, err := vs.config.Outputs.List("")
+	require.NoError(t, err)
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	assert.ElementsMatch(t, []string{"frames_new.zip", dedup.Prefix + "entries/d1.json"}, keys)
+}
+
+func TestVideoService_SweepExpired_PurgesTrash(t *testing.T) {
+	vs := newFrameTestService(t)
+	now := time.Now()
+
+	require.NoError(t, vs.config.Outputs.Put("frames_20240101_120000.zip", strings.NewReader("zip"), ""))
+	require.NoError(t, vs.config.Outputs.Put("frames_20240102_120000.zip", strings.NewReader("zip"), ""))
+	bin := trash.NewBin(vs.config.Outputs, "")
+	_, err := bin.Discard("20240101_120000", []string{"frames_20240101_120000.zip"}, now.Add(-48*time.Hour), now.Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = bin.Discard("20240102_120000", []string{"frames_20240102_120000.zip"}, now, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	deleted, err := vs.SweepExpired(now)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	items, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "20240102_120000", items[0].VideoID)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"path/filepath"
+
+	"video-processor/internal/dedup"
+	"video-processor/processor/internal/config"
+)
+
+// ForTenant returns the service bound to a tenant's uploads and outputs stores, the
+// default tenant's for an empty one. Without storage routing it returns vs itself.
+func (vs *VideoService) ForTenant(tenant string) *VideoService {
+	router := vs.config.Router
+	if router == nil || !router.Enabled() {
+		return vs
+	}
+	if tenant == "" {
+		tenant, _ = router.Tenant("")
+	}
+
+	cfg := *vs.config
+	cfg.Uploads = router.Uploads(tenant)
+	cfg.Outputs = router.Outputs(tenant)
+	return &VideoService{
+		config:     &cfg,
+		dedup:      dedup.NewIndex(cfg.Outputs),
+		dedupLocks: vs.dedupLocks,
+		tenant:     tenant,
+	}
+}
+
+// Config returns the configuration the service runs with, including its tenant's stores.
+func (vs *VideoService) Config() *config.ProcessorConfig {
+	return vs.config
+}
+
+// cacheDir returns a local cache directory below TempDir. Each tenant gets its own, so
+// cached frames and sources of equal video IDs never cross tenants.
+func (vs *VideoService) cacheDir(name string) string {
+	return filepath.Join(vs.config.TempDir, name, vs.tenant)
+}
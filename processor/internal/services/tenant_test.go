@@ -0,0 +1,63 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	baseConfig "video-processor/internal/config"
+	"video-processor/internal/retention"
+	"video-processor/internal/storage"
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantTestService(t *testing.T) (*VideoService, *storage.Memory) {
+	vs := newFrameTestService(t)
+	uploads := storage.NewMemory()
+	router, err := storage.NewRouter(&baseConfig.RoutingConfig{KeyPrefix: "{tenant}/{job_id}/", DefaultTenant: "default"}, nil, nil, uploads, vs.config.Outputs)
+	require.NoError(t, err)
+	vs.config.Uploads = uploads
+	vs.config.Router = router
+	return vs, uploads
+}
+
+func TestVideoService_ForTenant(t *testing.T) {
+	vs := newFrameTestService(t)
+	assert.Same(t, vs, vs.ForTenant("acme"), "without routing every tenant shares the service")
+
+	vs, uploads := newTenantTestService(t)
+	acme := vs.ForTenant("acme")
+	require.NoError(t, acme.config.Uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), ""))
+
+	_, err := uploads.Stat("acme/20240101_120000/20240101_120000_video.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", vs.ForTenant("").tenant)
+	assert.NotEqual(t, acme.cacheDir(frameCacheDirName), vs.ForTenant("globex").cacheDir(frameCacheDirName))
+}
+
+func TestVideoService_SweepExpired_ExpiresSourcesOfEachTenant(t *testing.T) {
+	vs, uploads := newTenantTestService(t)
+	retainedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tenant := range []string{"acme", "globex"} {
+		scoped := vs.ForTenant(tenant)
+		require.NoError(t, scoped.config.Uploads.Put("20240101_120000_video.mp4", strings.NewReader("video"), ""))
+		require.NoError(t, scoped.RecordRetention("20240101_120000_video.mp4", "20240101_120000", models.ProcessingOptions{SourceTTL: "1d"}, retainedAt))
+	}
+
+	records, err := uploads.List(retention.Prefix)
+	require.NoError(t, err)
+	assert.Len(t, records, 2, "records of equal keys stay apart")
+
+	deleted, err := vs.SweepExpired(retainedAt.Add(48 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	for _, tenant := range []string{"acme", "globex"} {
+		_, err = uploads.Stat(tenant + "/20240101_120000/20240101_120000_video.mp4")
+		assert.ErrorIs(t, err, storage.ErrNotFound, tenant)
+	}
+}
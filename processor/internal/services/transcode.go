@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"video-processor/processor/internal/models"
+	"video-processor/processor/internal/utils"
+)
+
+const (
+	hlsMasterPlaylist = "master.m3u8"
+	hlsAudioBitrate   = 128000
+	hlsSegmentSeconds = 6
+	proxyMaxHeight    = 720
+)
+
+// hlsVideoBitrates maps supported rendition heights to their target video bitrate (bits/s).
+var hlsVideoBitrates = map[int]int{
+	240:  400000,
+	360:  800000,
+	480:  1400000,
+	720:  2800000,
+	1080: 5000000,
+}
+
+// ValidateRenditions ensures every requested height is part of the supported ladder.
+func ValidateRenditions(heights []int) error {
+	for _, height := range heights {
+		if _, ok := hlsVideoBitrates[height]; !ok {
+			return fmt.Errorf("rendição HLS não suportada: %dp (use 240, 360, 480, 720 ou 1080)", height)
+		}
+	}
+	return nil
+}
+
+// createWebRenditions produces the browser-friendly MP4 proxy and/or HLS ladder requested for a job.
+func (vs *VideoService) createWebRenditions(videoPath, tempDir, timestamp string, opts models.ProcessingOptions, checksums map[string]string) (proxyPath, playlistPath string, err error) {
+	if opts.Proxy {
+		proxyFilename := fmt.Sprintf("proxy_%s.mp4", timestamp)
+		localProxy := filepath.Join(tempDir, proxyFilename)
+
+		if err := vs.transcodeProxy(videoPath, localProxy); err != nil {
+			return "", "", err
+		}
+
+		stored, err := vs.storeOutputFile(localProxy, proxyFilename, "video/mp4", checksums)
+		if err != nil {
+			return "", "", fmt.Errorf("erro ao salvar proxy: %w", err)
+		}
+		proxyPath = filepath.Base(stored)
+	}
+
+	if opts.HLS {
+		renditions := opts.HLSRenditions
+		if len(renditions) == 0 {
+			renditions = vs.config.HLSRenditions
+		}
+		if len(renditions) == 0 {
+			return "", "", fmt.Errorf("nenhuma rendição HLS configurada")
+		}
+
+		hlsPrefix := fmt.Sprintf("hls_%s", timestamp)
+		localDir := filepath.Join(tempDir, hlsPrefix)
+		if err := utils.SetupTempDirectory(localDir); err != nil {
+			return "", "", err
+		}
+
+		if err := vs.transcodeHLS(videoPath, localDir, renditions); err != nil {
+			return "", "", err
+		}
+
+		if err := vs.storeOutputDir(localDir, hlsPrefix, checksums); err != nil {
+			return "", "", fmt.Errorf("erro ao salvar HLS: %w", err)
+		}
+		playlistPath = hlsPrefix + "/" + hlsMasterPlaylist
+	}
+
+	return proxyPath, playlistPath, nil
+}
+
+func (vs *VideoService) transcodeProxy(videoPath, proxyPath string) error {
+	absVideoPath, absProxyPath, err := resolveFFmpegPaths(videoPath, proxyPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("ffmpeg", // #nosec G204
+		"-i", absVideoPath,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", proxyMaxHeight),
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "128k",
+		"-movflags", "+faststart",
+		"-y", absProxyPath,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("erro no ffmpeg ao gerar proxy: %s\nOutput: %s", err.Error(), string(output))
+	}
+
+	return nil
+}
+
+func (vs *VideoService) transcodeHLS(videoPath, outputDir string, renditions []int) error {
+	if err := ValidateRenditions(renditions); err != nil {
+		return err
+	}
+
+	heights := append([]int(nil), renditions...)
+	sort.Ints(heights)
+
+	segmentSeconds := vs.config.HLSSegmentSeconds
+	if segmentSeconds <= 0 {
+		segmentSeconds = hlsSegmentSeconds
+	}
+
+	for _, height := range heights {
+		name := fmt.Sprintf("%dp", height)
+		absVideoPath, absPlaylist, err := resolveFFmpegPaths(videoPath, filepath.Join(outputDir, name+".m3u8"))
+		if err != nil {
+			return err
+		}
+		segmentPattern := filepath.Join(filepath.Dir(absPlaylist), name+"_%03d.ts")
+
+		bitrate := hlsVideoBitrates[height]
+		cmd := exec.Command("ffmpeg", // #nosec G204
+			"-i", absVideoPath,
+			"-map", "0:v:0", "-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=-2:%d", height),
+			"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+			"-b:v", strconv.Itoa(bitrate), "-maxrate", strconv.Itoa(bitrate*3/2), "-bufsize", strconv.Itoa(bitrate*2),
+			"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
+			"-c:a", "aac", "-b:a", strconv.Itoa(hlsAudioBitrate),
+			"-f", "hls",
+			"-hls_time", strconv.Itoa(segmentSeconds),
+			"-hls_playlist_type", "vod",
+			"-hls_segment_filename", segmentPattern,
+			"-y", absPlaylist,
+		)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("erro no ffmpeg ao gerar HLS %s: %s\nOutput: %s", name, err.Error(), string(output))
+		}
+	}
+
+	masterPath := filepath.Join(outputDir, hlsMasterPlaylist)
+	return os.WriteFile(filepath.Clean(masterPath), []byte(buildMasterPlaylist(heights)), 0600)
+}
+
+func buildMasterPlaylist(heights []int) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, height := range heights {
+		bandwidth := hlsVideoBitrates[height] + hlsAudioBitrate
+		fmt.Fprintf(&sb, "#EXT-X-STREAM-INF:BANDWIDTH=%d,NAME=\"%dp\"\n%dp.m3u8\n", bandwidth, height, height)
+	}
+	return sb.String()
+}
+
+func resolveFFmpegPaths(inputPath, outputPath string) (absInput, absOutput string, err error) {
+	if err := utils.ValidatePathSafety(inputPath, outputPath); err != nil {
+		return "", "", err
+	}
+
+	absInput, err = filepath.Abs(filepath.Clean(inputPath))
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving video path: %w", err)
+	}
+	absOutput, err = filepath.Abs(filepath.Clean(outputPath))
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving output path: %w", err)
+	}
+
+	return absInput, absOutput, nil
+}
+
+// storeOutputDir moves every file of a locally rendered directory under prefix in the outputs store.
+func (vs *VideoService) storeOutputDir(localDir, prefix string, checksums map[string]string) error {
+	entries, err := os.ReadDir(localDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, err := vs.storeOutputFile(filepath.Join(localDir, entry.Name()), prefix+"/"+entry.Name(), hlsContentType(entry.Name()), checksums); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Stored %d HLS files under %s", len(entries), prefix)
+	return nil
+}
+
+func hlsContentType(name string) string {
+	switch filepath.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	default:
+		return "binary/octet-stream"
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"video-processor/processor/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRenditions(t *testing.T) {
+	assert.NoError(t, ValidateRenditions(nil))
+	assert.NoError(t, ValidateRenditions([]int{360, 720, 1080}))
+	assert.Error(t, ValidateRenditions([]int{360, 900}))
+}
+
+func TestBuildMasterPlaylist(t *testing.T) {
+	playlist := buildMasterPlaylist([]int{360, 720})
+
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=928000,NAME=\"360p\"\n360p.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2928000,NAME=\"720p\"\n720p.m3u8\n", playlist)
+}
+
+func TestHLSContentType(t *testing.T) {
+	assert.Equal(t, "application/vnd.apple.mpegurl", hlsContentType("master.m3u8"))
+	assert.Equal(t, "video/mp2t", hlsContentType("720p_001.ts"))
+	assert.Equal(t, "binary/octet-stream", hlsContentType("notes.txt"))
+}
+
+func TestVideoService_storeOutputDir_Filesystem(t *testing.T) {
+	service := newFrameTestService(t)
+	require.NoError(t, os.MkdirAll(service.config.OutputsDir, 0750))
+
+	localDir := filepath.Join(t.TempDir(), "hls_20240101_120000")
+	require.NoError(t, os.MkdirAll(localDir, 0750))
+	for _, name := range []string{"master.m3u8", "360p.m3u8", "360p_000.ts"} {
+		require.NoError(t, os.WriteFile(filepath.Join(localDir, name), []byte(name), 0600))
+	}
+
+	checksums := map[string]string{}
+	require.NoError(t, service.storeOutputDir(localDir, "hls_20240101_120000", checksums))
+
+	for _, name := range []string{"master.m3u8", "360p.m3u8", "360p_000.ts"} {
+		assert.FileExists(t, filepath.Join(service.config.OutputsDir, "hls_20240101_120000", name))
+		assert.Contains(t, checksums, "hls_20240101_120000/"+name)
+	}
+}
+
+func TestVideoService_createWebRenditions_NoopWithoutOptions(t *testing.T) {
+	service := newFrameTestService(t)
+
+	proxyPath, playlistPath, err := service.createWebRenditions("uploads/test.mp4", t.TempDir(), "20240101_120000", models.ProcessingOptions{}, map[string]string{})
+
+	require.NoError(t, err)
+	assert.Empty(t, proxyPath)
+	assert.Empty(t, playlistPath)
+}
+
+func TestValidateOptions_RejectsUnsupportedRendition(t *testing.T) {
+	err := ValidateOptions(models.ProcessingOptions{HLS: true, HLSRenditions: []int{999}})
+
+	assert.Error(t, err)
+}
@@ -2,71 +2,208 @@ package services
 
 import (
 	"archive/zip"
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 
+	"video-processor/internal/dedup"
+	"video-processor/internal/jobs"
+	"video-processor/internal/storage"
 	"video-processor/processor/internal/config"
 	"video-processor/processor/internal/models"
 	"video-processor/processor/internal/utils"
 )
 
+const manifestFilename = "manifest.json"
+
 type VideoService struct {
-	config *config.ProcessorConfig
+	config     *config.ProcessorConfig
+	dedup      *dedup.Index
+	dedupLocks *digestLocks
+	// tenant is set on the services ForTenant returns when storage routing is enabled.
+	tenant string
 }
 
 func NewVideoService(cfg *config.ProcessorConfig) *VideoService {
 	return &VideoService{
-		config: cfg,
+		config:     cfg,
+		dedup:      dedup.NewIndex(cfg.Outputs),
+		dedupLocks: newDigestLocks(),
 	}
 }
 
 func (vs *VideoService) ProcessVideo(videoPath, timestamp string) models.ProcessingResult {
+	return vs.ProcessVideoWithOptions(videoPath, timestamp, models.ProcessingOptions{})
+}
+
+func (vs *VideoService) ProcessVideoWithOptions(videoPath, timestamp string, opts models.ProcessingOptions) models.ProcessingResult {
 	fmt.Printf("Iniciando processamento: %s\n", videoPath)
 
 	if err := utils.ValidateProcessingInputs(videoPath, timestamp); err != nil {
 		return models.ProcessingResult{Success: false, Message: err.Error()}
 	}
 
+	if err := ValidateOptions(opts); err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
+	redactions, err := parseRedactions(opts.Redactions)
+	if err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
 	tempDir := filepath.Join(vs.config.TempDir, timestamp)
 	if err := utils.SetupTempDirectory(tempDir); err != nil {
 		return models.ProcessingResult{Success: false, Message: err.Error()}
 	}
 	defer utils.CleanupTempDirectory(tempDir)
 
-	frames, err := vs.extractFrames(videoPath, tempDir)
+	overlay, err := vs.prepareOverlay(opts.Overlay, tempDir, videoPath, timestamp)
+	if err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
+	reportProgress(opts, jobs.StageExtractingFrames, 0)
+	frames, err := vs.extractFrames(videoPath, tempDir, redactions, overlay)
 	if err != nil {
 		return models.ProcessingResult{Success: false, Message: err.Error()}
 	}
 
 	fmt.Printf("📸 Extraídos %d frames\n", len(frames))
 
-	zipPath, err := vs.createFramesZip(frames, timestamp)
+	if len(redactions) > 0 && vs.RetainsSource(opts) {
+		if err := vs.saveRedactions(timestamp, opts.Redactions); err != nil {
+			return models.ProcessingResult{Success: false, Message: "erro ao salvar redações: " + err.Error()}
+		}
+	}
+
+	manifestPath, err := vs.writeManifest(tempDir, timestamp, frames, redactions, overlay)
+	if err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
+	reportProgress(opts, jobs.StageArchiving, len(frames))
+	// checksums collects the hex SHA-256 of every stored output, by key.
+	checksums := map[string]string{}
+	zipPath, err := vs.createFramesZip(append(frames, manifestPath), timestamp, outputMetadata(opts, len(frames)), checksums)
 	if err != nil {
 		return models.ProcessingResult{Success: false, Message: err.Error()}
 	}
 
 	fmt.Printf("✅ ZIP criado: %s\n", zipPath)
 
+	if len(opts.Clips) > 0 {
+		reportProgress(opts, jobs.StageExportingClips, len(frames))
+	}
+	clips, err := vs.exportClips(videoPath, tempDir, timestamp, opts.Clips, checksums)
+	if err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
+	if len(clips) > 0 {
+		fmt.Printf("🎞️ Exportados %d clipes\n", len(clips))
+	}
+
+	if opts.Proxy || opts.HLS {
+		reportProgress(opts, jobs.StageRenditions, len(frames))
+	}
+	proxyPath, playlistPath, err := vs.createWebRenditions(videoPath, tempDir, timestamp, opts, checksums)
+	if err != nil {
+		return models.ProcessingResult{Success: false, Message: err.Error()}
+	}
+
 	imageNames := make([]string, len(frames))
 	for i, frame := range frames {
 		imageNames[i] = filepath.Base(frame)
 	}
 
 	return models.ProcessingResult{
-		Success:    true,
-		Message:    fmt.Sprintf("Processamento concluído! %d frames extraídos.", len(frames)),
-		ZipPath:    filepath.Base(zipPath),
+		Success:        true,
+		Message:        fmt.Sprintf("Processamento concluído! %d frames extraídos.", len(frames)),
+		VideoID:        timestamp,
+		ZipPath:        filepath.Base(zipPath),
+		FrameCount:     len(frames),
+		Images:         imageNames,
+		Clips:          clips,
+		ProxyPath:      proxyPath,
+		PlaylistPath:   playlistPath,
+		SourceChecksum: opts.Source.Hash,
+		Checksums:      checksums,
+	}
+}
+
+// reportProgress tells the caller of a processing run the stage it entered.
+func reportProgress(opts models.ProcessingOptions, stage string, frames int) {
+	if opts.Progress != nil {
+		opts.Progress(stage, frames)
+	}
+}
+
+// ValidateOptions checks every optional processing stage before any work starts.
+func ValidateOptions(opts models.ProcessingOptions) error {
+	if err := ValidateClipRequests(opts.Clips); err != nil {
+		return err
+	}
+	if err := ValidateRenditions(opts.HLSRenditions); err != nil {
+		return err
+	}
+	if err := ValidateRedactions(opts.Redactions); err != nil {
+		return err
+	}
+	if err := ValidateRetention(opts); err != nil {
+		return err
+	}
+	return ValidateOverlay(opts.Overlay)
+}
+
+// writeManifest describes the archive contents, including whether frames were redacted or stamped.
+func (vs *VideoService) writeManifest(tempDir, videoID string, frames []string, redactions []redactionSpec, overlay *overlaySpec) (string, error) {
+	manifest := models.Manifest{
+		VideoID:    videoID,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		FrameRate:  1,
 		FrameCount: len(frames),
-		Images:     imageNames,
+		Frames:     make([]string, len(frames)),
+		Redaction: models.ManifestRedaction{
+			Applied: len(redactions) > 0,
+			Regions: len(redactions),
+		},
+		Overlay: overlay.manifest(),
+	}
+
+	for i, frame := range frames {
+		manifest.Frames[i] = filepath.Base(frame)
+	}
+
+	seen := map[string]bool{}
+	for _, spec := range redactions {
+		if !seen[spec.style] {
+			seen[spec.style] = true
+			manifest.Redaction.Styles = append(manifest.Redaction.Styles, spec.style)
+		}
 	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("erro ao gerar manifest: %w", err)
+	}
+
+	manifestPath := filepath.Join(tempDir, manifestFilename)
+	if err := os.WriteFile(filepath.Clean(manifestPath), data, 0600); err != nil {
+		return "", fmt.Errorf("erro ao gravar manifest: %w", err)
+	}
+
+	return manifestPath, nil
 }
 
-func (vs *VideoService) extractFrames(videoPath, tempDir string) ([]string, error) {
+func (vs *VideoService) extractFrames(videoPath, tempDir string, redactions []redactionSpec, overlay *overlaySpec) ([]string, error) {
 	framePattern := filepath.Join(tempDir, "frame_%04d.png")
 
 	videoPath = filepath.Clean(videoPath)
@@ -85,12 +222,35 @@ func (vs *VideoService) extractFrames(videoPath, tempDir string) ([]string, erro
 		return nil, fmt.Errorf("error resolving frame pattern path: %w", err)
 	}
 
-	cmd := exec.Command("ffmpeg", // #nosec G204
-		"-i", absVideoPath,
-		"-vf", "fps=1",
-		"-y",
-		absFramePattern,
-	)
+	args := []string{"-i", absVideoPath}
+	if overlay != nil && overlay.imagePath != "" {
+		absImagePath, err := filepath.Abs(overlay.imagePath)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving overlay path: %w", err)
+		}
+		args = append(args, "-i", absImagePath)
+	}
+
+	if len(redactions) > 0 || overlay != nil {
+		// Redact at source resolution and time, then sample, then stamp the sampled frames.
+		graph, output := buildRedactionGraph(redactions, "0:v")
+		if graph != "" {
+			graph += ";"
+		}
+		graph += "[" + output + "]fps=1[sampled]"
+		if overlayGraph, overlayOutput := buildOverlayGraph(overlay, "sampled", "1:v"); overlayGraph != "" {
+			graph += ";" + overlayGraph
+			output = overlayOutput
+		} else {
+			output = "sampled"
+		}
+		args = append(args, "-filter_complex", graph, "-map", "["+output+"]")
+	} else {
+		args = append(args, "-vf", "fps=1")
+	}
+	args = append(args, "-y", absFramePattern)
+
+	cmd := exec.Command("ffmpeg", args...) // #nosec G204
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -105,54 +265,54 @@ func (vs *VideoService) extractFrames(videoPath, tempDir string) ([]string, erro
 	return frames, nil
 }
 
-func (vs *VideoService) createFramesZip(frames []string, timestamp string) (string, error) {
+// createFramesZip streams the archive straight into the outputs store, with metadata,
+// and returns its stored location: the on-disk path for local storage, otherwise the key.
+// The archive is built twice: once to hash it, then again into the store, which rejects
+// it unless the second build hashes the same. Its checksum is recorded in checksums.
+func (vs *VideoService) createFramesZip(frames []string, timestamp string, metadata, checksums map[string]string) (string, error) {
 	zipFilename := fmt.Sprintf("frames_%s.zip", timestamp)
 
-	if vs.config.IsS3Enabled() {
-		return vs.createFramesZipToS3(frames, zipFilename)
-	} else {
-		return vs.createFramesZipToFilesystem(frames, zipFilename)
+	hash := sha256.New()
+	if err := vs.writeFramesZip(hash, frames); err != nil {
+		return "", err
 	}
-}
+	sum := hex.EncodeToString(hash.Sum(nil))
 
-func (vs *VideoService) createFramesZipToS3(frames []string, zipFilename string) (string, error) {
-	var zipBuffer bytes.Buffer
-	zipWriter := zip.NewWriter(&zipBuffer)
-
-	for _, file := range frames {
-		if err := vs.addFileToZip(zipWriter, file); err != nil {
-			if closeErr := zipWriter.Close(); closeErr != nil {
-				log.Printf("Warning: Failed to close ZIP writer: %v", closeErr)
-			}
-			return "", fmt.Errorf("erro ao adicionar arquivo ao ZIP: %w", err)
-		}
+	withChecksum := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		withChecksum[key] = value
 	}
+	withChecksum[storage.MetaChecksum] = sum
 
-	if err := zipWriter.Close(); err != nil {
-		return "", fmt.Errorf("erro ao finalizar ZIP: %w", err)
-	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(vs.writeFramesZip(writer, frames))
+	}()
 
-	reader := bytes.NewReader(zipBuffer.Bytes())
-	if err := vs.config.S3Service.UploadFile(vs.config.S3Buckets.OutputsBucket, zipFilename, reader); err != nil {
-		return "", fmt.Errorf("erro ao fazer upload do ZIP para S3: %w", err)
+	err := storage.PutWithMetadata(vs.config.Outputs, zipFilename, reader, "application/zip", withChecksum)
+	// Unblock the writer goroutine if the store gave up before reading everything.
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", fmt.Errorf("erro ao salvar ZIP: %w", err)
 	}
+	checksums[zipFilename] = sum
 
-	fmt.Printf("✅ ZIP salvo no S3: s3://%s/%s\n", vs.config.S3Buckets.OutputsBucket, zipFilename)
+	if zipPath, ok := storage.LocalPath(vs.config.Outputs, zipFilename); ok {
+		return zipPath, nil
+	}
 	return zipFilename, nil
 }
 
-func (vs *VideoService) createFramesZipToFilesystem(frames []string, zipFilename string) (string, error) {
-	zipPath := filepath.Join(vs.config.OutputsDir, zipFilename)
-
-	if err := utils.ValidateOutputPath(zipPath, vs.config.OutputsDir); err != nil {
-		return "", err
-	}
-
-	if err := vs.createZipFile(frames, zipPath); err != nil {
-		return "", fmt.Errorf("erro ao criar arquivo ZIP: %w", err)
+// writeFramesZip writes the frames archive to w. The output depends only on the files,
+// so writing it twice yields the same bytes.
+func (vs *VideoService) writeFramesZip(w io.Writer, frames []string) error {
+	zipWriter := zip.NewWriter(w)
+	for _, file := range frames {
+		if err := vs.addFileToZip(zipWriter, file); err != nil {
+			return fmt.Errorf("erro ao adicionar arquivo ao ZIP: %w", err)
+		}
 	}
-
-	return zipPath, nil
+	return zipWriter.Close()
 }
 
 func (vs *VideoService) createZipFile(files []string, zipPath string) error {
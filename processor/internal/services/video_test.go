@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	baseConfig "video-processor/internal/config"
+	"video-processor/internal/storage"
 	"video-processor/processor/internal/config"
 
 	"github.com/stretchr/testify/assert"
@@ -143,7 +144,7 @@ func TestVideoService_extractFrames_PathSafetyValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := service.extractFrames(tt.videoPath, tt.tempDir)
+			_, err := service.extractFrames(tt.videoPath, tt.tempDir, nil, nil)
 
 			assert.Error(t, err)
 			assert.Contains(t, err.Error(), tt.expectErr)
@@ -162,6 +163,7 @@ func TestVideoService_createFramesZip_OutputPathValidation(t *testing.T) {
 		DirectoryConfig: &baseConfig.DirectoryConfig{
 			OutputsDir: tempDir,
 		},
+		Outputs: storage.NewFilesystem(tempDir),
 	}
 	service := NewVideoService(cfg)
 
@@ -189,7 +191,7 @@ func TestVideoService_createFramesZip_OutputPathValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			zipPath, err := service.createFramesZip(frames, tt.timestamp)
+			zipPath, err := service.createFramesZip(frames, tt.timestamp, nil, map[string]string{})
 
 			if tt.expectError {
 				assert.Error(t, err)
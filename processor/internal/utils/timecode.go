@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseTimestamp accepts plain seconds ("754.5") or a HH:MM:SS(.mmm) / MM:SS timecode ("00:12:34.500").
+func ParseTimestamp(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("timestamp is required")
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", value)
+	}
+
+	var seconds float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+			return 0, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		if i < len(parts)-1 && n != math.Trunc(n) {
+			return 0, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		if i > 0 && n >= 60 {
+			return 0, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		seconds = seconds*60 + n
+	}
+
+	return seconds, nil
+}
+
+// FormatSeconds renders seconds in the form expected by ffmpeg's -ss/-to options.
+func FormatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    float64
+		expectError bool
+	}{
+		{name: "plain seconds", input: "754.5", expected: 754.5},
+		{name: "integer seconds", input: "12", expected: 12},
+		{name: "full timecode", input: "00:12:34.500", expected: 754.5},
+		{name: "minutes and seconds", input: "12:34", expected: 754},
+		{name: "hours", input: "01:00:00", expected: 3600},
+		{name: "empty", input: "", expectError: true},
+		{name: "negative", input: "-1", expectError: true},
+		{name: "seconds overflow", input: "00:00:75", expectError: true},
+		{name: "fractional minutes", input: "00:1.5:00", expectError: true},
+		{name: "too many fields", input: "1:00:00:00", expectError: true},
+		{name: "garbage", input: "abc", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseTimestamp(tt.input)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.expected, result, 0.0001)
+		})
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	assert.Equal(t, "754.500", FormatSeconds(754.5))
+	assert.Equal(t, "0.000", FormatSeconds(0))
+}
@@ -2,8 +2,12 @@ class ApiService {
   constructor() {
     this.baseURL = `${window.location.protocol}//${window.location.hostname}:8081`
     this.endpoints = {
-      videos: `${this.baseURL}/api/v1/videos`
+      videos: `${this.baseURL}/api/v1/videos`,
+      jobs: `${this.baseURL}/api/v1/jobs`
     }
+    this.pollInterval = 2000
+    // Jobs still running after maxWait are left to finish in the background.
+    this.maxWait = 30 * 60 * 1000
   }
 
   async uploadVideo(formData) {
@@ -11,7 +15,42 @@ class ApiService {
       method: 'POST',
       body: formData
     })
-    return await response.json()
+    const data = await response.json()
+    if (response.status !== 202) {
+      return data
+    }
+    return await this.waitForJob(data.id)
+  }
+
+  // Polls an accepted job until it ends, or until maxWait passes, and returns it as a
+  // processing result.
+  async waitForJob(jobId) {
+    const deadline = Date.now() + this.maxWait
+    while (Date.now() < deadline) {
+      await new Promise((resolve) => setTimeout(resolve, this.pollInterval))
+      const response = await fetch(`${this.endpoints.jobs}/${jobId}`)
+      const job = await response.json()
+      if (!response.ok) {
+        return { success: false, message: job.error, job_id: jobId }
+      }
+      if (job.status === 'completed') {
+        return {
+          success: true,
+          message: job.result.message,
+          zip_path: job.result.zip_path,
+          download_url: job.download_url,
+          job_id: jobId
+        }
+      }
+      if (job.status === 'failed') {
+        return { success: false, message: job.error, job_id: jobId }
+      }
+    }
+    return {
+      success: false,
+      message: `O processamento segue em segundo plano; acompanhe o job ${jobId}`,
+      job_id: jobId
+    }
   }
 
   async getFilesList() {
@@ -81,6 +81,73 @@ describe('ApiService Class', () => {
       expect(result.success).toBe(false)
     })
 
+    test('should poll an accepted job until it completes', async() => {
+      apiService.pollInterval = 0
+      fetch
+        .mockResolvedValueOnce({
+          status: 202,
+          json: () => Promise.resolve({ id: 'abc', status: 'queued' })
+        })
+        .mockResolvedValueOnce({
+          ok: true,
+          json: () => Promise.resolve({ id: 'abc', status: 'processing' })
+        })
+        .mockResolvedValueOnce({
+          ok: true,
+          json: () => Promise.resolve({
+            id: 'abc',
+            status: 'completed',
+            result: { message: 'Processamento concluído!', zip_path: 'frames_123.zip' }
+          })
+        })
+
+      const result = await apiService.uploadVideo(new FormData())
+
+      expect(fetch).toHaveBeenLastCalledWith('http://localhost:8081/api/v1/jobs/abc')
+      expect(result.success).toBe(true)
+      expect(result.zip_path).toBe('frames_123.zip')
+      expect(result.job_id).toBe('abc')
+    })
+
+    test('should return the error of a failed job', async() => {
+      apiService.pollInterval = 0
+      fetch
+        .mockResolvedValueOnce({
+          status: 202,
+          json: () => Promise.resolve({ id: 'abc', status: 'queued' })
+        })
+        .mockResolvedValueOnce({
+          ok: true,
+          json: () => Promise.resolve({ id: 'abc', status: 'failed', error: 'formato inválido' })
+        })
+
+      const result = await apiService.uploadVideo(new FormData())
+
+      expect(result.success).toBe(false)
+      expect(result.message).toBe('formato inválido')
+    })
+
+    test('should stop waiting for a job after maxWait', async() => {
+      apiService.pollInterval = 0
+      apiService.maxWait = 20
+      fetch
+        .mockResolvedValueOnce({
+          status: 202,
+          json: () => Promise.resolve({ id: 'abc', status: 'queued' })
+        })
+        .mockResolvedValue({
+          ok: true,
+          json: () => Promise.resolve({ id: 'abc', status: 'processing' })
+        })
+
+      const result = await apiService.uploadVideo(new FormData())
+
+      expect(result.success).toBe(false)
+      expect(result.job_id).toBe('abc')
+      expect(result.message).toContain('abc')
+      fetch.mockReset()
+    })
+
     test('should throw error when network request fails', async() => {
       fetch.mockRejectedValueOnce(new Error('Network error'))
 